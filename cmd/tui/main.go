@@ -1,23 +1,60 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/bengesoff/mail-tui/internal/backend/fake"
 	"github.com/bengesoff/mail-tui/internal/backend/imap"
+	"github.com/bengesoff/mail-tui/internal/config"
+	"github.com/bengesoff/mail-tui/internal/contacts"
 	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/identity"
+	"github.com/bengesoff/mail-tui/internal/mailcap"
+	"github.com/bengesoff/mail-tui/internal/outbox"
+	"github.com/bengesoff/mail-tui/internal/pgp"
+	"github.com/bengesoff/mail-tui/internal/senderauth"
+	"github.com/bengesoff/mail-tui/internal/smime"
+	"github.com/bengesoff/mail-tui/internal/smtp"
+	"github.com/bengesoff/mail-tui/internal/termimage"
+	"github.com/bengesoff/mail-tui/internal/ui"
 	"github.com/bengesoff/mail-tui/internal/ui/app"
+	"github.com/bengesoff/mail-tui/internal/ui/email_composer"
+	"github.com/bengesoff/mail-tui/internal/ui/email_viewer"
 )
 
 var flags struct {
-	useImap     bool
-	imapAddress string
-	username    string
-	password    string
+	useImap      bool
+	imapAddress  string
+	username     string
+	password     string
+	smtpAddress  string
+	smtpSecurity string
+	from         string
+	sentMailbox  string
+	saveSent     bool
+	undoSend     time.Duration
+	headless     bool
+	markdown     bool
+	openCommand  string
+	images       string
+	verifyDKIM   bool
+	authServers  string
+	pgpKeyring   string
+	smimeTrust   string
+	smimeCert    string
+	smimeKey     string
 }
 
 func main() {
@@ -26,13 +63,101 @@ func main() {
 	// Not good, shouldn't be passed in plaintext. Ideally would be an environment variable
 	flag.StringVar(&flags.username, "username", "bob", "IMAP username")
 	flag.StringVar(&flags.password, "password", "pass", "IMAP password")
+	flag.StringVar(&flags.smtpAddress, "smtp-address", "", "SMTP server address (hostname:port), which uses the same credentials as IMAP")
+	flag.StringVar(&flags.smtpSecurity, "smtp-security", "auto", "How to encrypt the connection to the SMTP server: auto (TLS on port 465, STARTTLS otherwise), tls, starttls or none")
+	flag.StringVar(&flags.from, "from", "", "Address to send emails from (defaults to the username)")
+	flag.StringVar(&flags.sentMailbox, "sent-mailbox", "", "Mailbox to save sent emails to (detected automatically by default)")
+	flag.BoolVar(&flags.saveSent, "save-sent", true, "Save sent emails to the sent mailbox (disable for servers that do it themselves, like Gmail)")
+	flag.DurationVar(&flags.undoSend, "undo-send", 10*time.Second, "How long to wait after pressing Send before sending, during which it can be undone")
+	flag.BoolVar(&flags.markdown, "markdown", false, "Write new emails in Markdown, which are sent with an HTML version")
+	flag.StringVar(&flags.openCommand, "open-command", defaultOpenCommand(), "Command to open links with, which is given the URL as its last argument")
+	flag.StringVar(&flags.images, "images", "off", "Show images attached to emails in the viewer: off, auto, kitty, sixel or halfblock")
+	flag.BoolVar(&flags.verifyDKIM, "verify-dkim", false, "Verify DKIM signatures in the viewer, looking up the signers' keys in DNS, as well as trusting the server's checks")
+	flag.StringVar(&flags.authServers, "auth-servers", "", "Comma-separated ids of the servers whose Authentication-Results headers are trusted (defaults to the topmost header)")
+	flag.StringVar(&flags.pgpKeyring, "pgp-keyring", "", "OpenPGP keyring file with your private key and others' public keys, to sign, encrypt, verify and decrypt emails (its passphrase is read from $MAIL_TUI_PGP_PASSPHRASE)")
+	flag.StringVar(&flags.smimeTrust, "smime-trust", "", "PEM file with the certificate authorities S/MIME signatures are verified against (defaults to the system's)")
+	flag.StringVar(&flags.smimeCert, "smime-cert", "", "PEM file with your S/MIME certificate, followed by the ones that issued it, to sign emails and decrypt ones sent to you")
+	flag.StringVar(&flags.smimeKey, "smime-key", "", "PEM file with the unencrypted private key for --smime-cert")
+	flag.BoolVar(&flags.headless, "headless", false, "Send queued and scheduled emails from the outbox without showing the UI")
 
 	flag.Parse()
 
+	// identities are who emails can be sent as, each of which can have its own SMTP server
+	configDir, err := config.ConfigDir()
+	if err != nil {
+		fmt.Printf("failed to find config directory: %v\n", err)
+		os.Exit(1)
+	}
+	identities, err := identity.Load(filepath.Join(configDir, "identities.json"))
+	if err != nil {
+		fmt.Printf("failed to load identities: %v\n", err)
+		os.Exit(1)
+	}
+	mailcaps, err := mailcap.Load(mailcap.DefaultPaths()...)
+	if err != nil {
+		fmt.Printf("failed to load mailcap: %v\n", err)
+		os.Exit(1)
+	}
+	images, err := termimage.ParseProtocol(flags.images)
+	if err != nil {
+		fmt.Printf("invalid --images: %v\n", err)
+		os.Exit(1)
+	}
+	var senderAuth senderauth.Options
+	if flags.verifyDKIM {
+		senderAuth.Resolver = net.DefaultResolver
+	}
+	for _, server := range strings.Split(flags.authServers, ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			senderAuth.TrustedServers = append(senderAuth.TrustedServers, server)
+		}
+	}
+	var keyring *pgp.Keyring
+	if flags.pgpKeyring != "" {
+		var passphrase []byte
+		if value, ok := os.LookupEnv("MAIL_TUI_PGP_PASSPHRASE"); ok {
+			passphrase = []byte(value)
+		}
+		keyring, err = pgp.Open(flags.pgpKeyring, passphrase)
+		if err != nil {
+			fmt.Printf("failed to open OpenPGP keyring: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	smimeConfig, err := smime.Load(flags.smimeTrust, flags.smimeCert, flags.smimeKey)
+	if err != nil {
+		fmt.Printf("failed to load S/MIME certificates: %v\n", err)
+		os.Exit(1)
+	}
+
 	var backend core.EmailBackend
 	if flags.useImap {
 		// could also be initialised inside the bubbletea program in order to display a loading spinner
-		imapBackend, err := imap.NewImapBackend(flags.imapAddress, flags.username, flags.password)
+		config := imap.Config{
+			Address:      flags.imapAddress,
+			Username:     flags.username,
+			Password:     flags.password,
+			From:         flags.from,
+			SentMailbox:  flags.sentMailbox,
+			SkipSaveSent: !flags.saveSent,
+			Identities:   identities,
+			Keyring:      keyring,
+			SMIME:        smimeConfig,
+		}
+		if flags.smtpAddress != "" {
+			security, err := smtp.ParseSecurity(flags.smtpSecurity)
+			if err != nil {
+				fmt.Printf("invalid --smtp-security: %v\n", err)
+				os.Exit(1)
+			}
+			config.Smtp = &smtp.Sender{
+				Address:  flags.smtpAddress,
+				Username: flags.username,
+				Password: flags.password,
+				Security: security,
+			}
+		}
+		imapBackend, err := imap.NewImapBackend(config)
 		if err != nil {
 			fmt.Printf("failed to create IMAP backend: %v\n", err)
 			os.Exit(1)
@@ -43,15 +168,88 @@ func main() {
 		backend = fake.NewFakeBackend()
 	}
 
-	appModel := app.NewAppModel(backend)
+	// emails wait in the outbox on disk until they've been sent, so they're sent next time if the app is closed first
+	dataDir, err := config.DataDir()
+	if err != nil {
+		fmt.Printf("failed to find data directory: %v\n", err)
+		os.Exit(1)
+	}
+	queue, err := outbox.New(filepath.Join(dataDir, "outbox"))
+	if err != nil {
+		fmt.Printf("failed to open outbox: %v\n", err)
+		os.Exit(1)
+	}
+
+	// the address book learns who emails are sent to and received from, to suggest addresses in the composer
+	book, err := contacts.Open(filepath.Join(dataDir, "contacts.json"))
+	if err != nil {
+		fmt.Printf("failed to open address book: %v\n", err)
+		os.Exit(1)
+	}
+	backend = contacts.NewHarvester(backend, book)
+
+	if flags.headless {
+		runHeadless(backend, queue)
+		return
+	}
+
+	appModel := app.NewAppModel(backend, queue, book, email_composer.Options{
+		UndoSendDelay: flags.undoSend,
+		Markdown:      flags.markdown,
+		Identities:    identities.Identities(),
+		TemplateDir:   filepath.Join(configDir, "templates"),
+		Keyring:       keyring,
+		SMIME:         smimeConfig,
+	}, email_viewer.Options{
+		OpenCommand: flags.openCommand,
+		Mailcap:     mailcaps,
+		Images:      images,
+		Outbox:      queue,
+		Identities:  identities.Identities(),
+		SenderAuth:  senderAuth,
+		Keyring:     keyring,
+		SMIME:       smimeConfig,
+	})
 	program := tea.NewProgram(
 		appModel,
 		tea.WithAltScreen(),
 		tea.WithMouseCellMotion())
 
-	_, err := program.Run()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker := outbox.NewWorker(queue, backend, func(event outbox.Event) {
+		program.Send(ui.OutboxEventMessage{Event: event})
+	})
+	go worker.Run(ctx)
+
+	_, err = program.Run()
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// runHeadless sends emails from the outbox as they become due until it's interrupted, e.g. so scheduled emails are
+// sent while the UI isn't open.
+func runHeadless(backend core.EmailBackend, queue *outbox.Outbox) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Println("Sending emails from the outbox, press Ctrl+C to stop")
+	worker := outbox.NewWorker(queue, backend, func(event outbox.Event) {
+		fmt.Printf("%s %s\n", time.Now().Format(time.DateTime), event.Describe())
+	})
+	worker.Run(ctx)
+}
+
+// defaultOpenCommand is the usual way of opening a URL in the default browser on each platform.
+func defaultOpenCommand() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "open"
+	case "windows":
+		return "rundll32 url.dll,FileProtocolHandler"
+	default:
+		return "xdg-open"
+	}
+}
@@ -4,58 +4,104 @@ import (
 	"fmt"
 	"maps"
 	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/threading"
 )
 
+const inbox = "INBOX"
+
 type FakeBackend struct {
-	emails map[core.EmailId]core.EmailMetadata
+	// commands run concurrently, so the mailboxes need protecting
+	mu sync.Mutex
+	// mailboxes holds the emails in each mailbox, keyed by mailbox name
+	mailboxes map[string]map[core.EmailId]core.EmailMetadata
+	roles     map[string]core.MailboxRole
+	drafts    map[core.EmailId]core.Draft
+	// nextId is used to give moved and copied emails and saved drafts new ids, like IMAP UIDs
+	nextId int
 }
 
 func NewFakeBackend() *FakeBackend {
 	return &FakeBackend{
-		emails: map[core.EmailId]core.EmailMetadata{
-			"1": {
-				Id:      "1",
-				From:    "test1@example.com",
-				To:      "me@example.com",
-				Subject: "First email",
-				SentAt:  time.Now(),
-				IsRead:  false,
-			},
-			"2": {
-				Id:      "2",
-				From:    "test2@example.com",
-				To:      "me@example.com",
-				Subject: "Second email",
-				SentAt:  time.Now().Add(-1 * time.Hour),
-				IsRead:  false,
-			},
-			"3": {
-				Id:      "3",
-				From:    "test3@example.com",
-				To:      "me@example.com",
-				Subject: "Third email",
-				SentAt:  time.Now().Add(-2 * time.Hour),
-				IsRead:  false,
-			},
-			"4": {
-				Id:      "4",
-				From:    "test4@example.com",
-				To:      "me@example.com",
-				Subject: "Fourth email",
-				SentAt:  time.Now().Add(-3 * time.Hour),
-				IsRead:  false,
+		mailboxes: map[string]map[core.EmailId]core.EmailMetadata{
+			inbox: {
+				"1": {
+					Id:      "1",
+					From:    "test1@example.com",
+					To:      "me@example.com",
+					Subject: "First email",
+					SentAt:  time.Now(),
+					Flags:   nil,
+
+					MessageId: "1@example.com",
+				},
+				"2": {
+					Id:      "2",
+					From:    "test2@example.com",
+					To:      "me@example.com",
+					Subject: "Re: Fourth email",
+					SentAt:  time.Now().Add(-1 * time.Hour),
+					Flags:   nil,
+
+					MessageId:  "2@example.com",
+					InReplyTo:  []string{"4@example.com"},
+					References: []string{"4@example.com"},
+				},
+				"3": {
+					Id:      "3",
+					From:    "test3@example.com",
+					To:      "me@example.com",
+					Subject: "Third email",
+					SentAt:  time.Now().Add(-2 * time.Hour),
+					Flags:   []core.Flag{core.FlagFlagged},
+
+					MessageId: "3@example.com",
+					List: &core.MailingList{
+						Id:          "announce.example.com",
+						Name:        "Example Announcements",
+						Post:        "announce@example.com",
+						Unsubscribe: []string{"mailto:announce-leave@example.com?subject=unsubscribe"},
+					},
+				},
+				// impersonates someone at example.com, which the viewer warns about
+				"4": {
+					Id:      "4",
+					From:    `"boss@example.com" <boss@example.net>`,
+					To:      "me@example.com",
+					Subject: "Fourth email",
+					SentAt:  time.Now().Add(-3 * time.Hour),
+					Flags:   []core.Flag{core.FlagSeen, core.FlagAnswered},
+
+					MessageId: "4@example.com",
+				},
 			},
+			"Archive": {},
+			"Sent":    {},
+			"Trash":   {},
+			"Work":    {},
+		},
+		roles: map[string]core.MailboxRole{
+			inbox:     core.MailboxRoleInbox,
+			"Archive": core.MailboxRoleArchive,
+			"Sent":    core.MailboxRoleSent,
+			"Trash":   core.MailboxRoleTrash,
 		},
+		drafts: map[core.EmailId]core.Draft{},
+		nextId: 5,
 	}
 }
 
 func (b *FakeBackend) ListEmails() ([]core.EmailMetadata, error) {
 	time.Sleep(1 * time.Second)
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	// just a fake implementation, otherwise should probably use an ordered data structure
-	return slices.SortedFunc(maps.Values(b.emails), func(a, b core.EmailMetadata) int {
+	return slices.SortedFunc(maps.Values(b.mailboxes[inbox]), func(a, b core.EmailMetadata) int {
 		if a.SentAt.Before(b.SentAt) {
 			return 1
 		}
@@ -66,33 +112,222 @@ func (b *FakeBackend) ListEmails() ([]core.EmailMetadata, error) {
 	}), nil
 }
 
+func (b *FakeBackend) ListThreads() ([]*core.Thread, error) {
+	emails, err := b.ListEmails()
+	if err != nil {
+		return nil, err
+	}
+	return threading.Thread(emails), nil
+}
+
 func (b *FakeBackend) GetEmail(id core.EmailId) (*core.Email, error) {
 	time.Sleep(1 * time.Second)
-	email, ok := b.emails[id]
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	email, ok := b.mailboxes[inbox][id]
 	if !ok {
 		return nil, fmt.Errorf("email not found")
 	}
 	return &core.Email{
 		EmailMetadata: email,
-		Body: fmt.Sprintf("To whom it may concern,\n\n"+
-			"This is a test email with ID %s.\n\n"+
-			"Yours sincerely,\n\n"+
-			"Tester", id),
+		Body:          fakeBody(id),
 	}, nil
 }
 
+func fakeBody(id core.EmailId) string {
+	return fmt.Sprintf("To whom it may concern,\n\n"+
+		"This is a test email with ID %s.\n\n"+
+		"Yours sincerely,\n\n"+
+		"Tester", id)
+}
+
+// GetRawEmail makes up the source of an email from its metadata, with a few of the headers a real server would add.
+func (b *FakeBackend) GetRawEmail(id core.EmailId) ([]byte, error) {
+	time.Sleep(1 * time.Second)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	email, ok := b.mailboxes[inbox][id]
+	if !ok {
+		return nil, fmt.Errorf("email not found")
+	}
+
+	var raw strings.Builder
+	fmt.Fprintf(&raw, "Received: from mail.example.com by mx.example.com; %s\r\n", email.SentAt.Format(time.RFC1123Z))
+	raw.WriteString("Authentication-Results: mx.example.com; spf=pass smtp.mailfrom=example.com\r\n")
+	fmt.Fprintf(&raw, "From: %s\r\n", email.From)
+	fmt.Fprintf(&raw, "To: %s\r\n", email.To)
+	fmt.Fprintf(&raw, "Subject: %s\r\n", email.Subject)
+	fmt.Fprintf(&raw, "Date: %s\r\n", email.SentAt.Format(time.RFC1123Z))
+	fmt.Fprintf(&raw, "Message-ID: <%s>\r\n", email.MessageId)
+	if len(email.InReplyTo) > 0 {
+		fmt.Fprintf(&raw, "In-Reply-To: <%s>\r\n", strings.Join(email.InReplyTo, "> <"))
+	}
+	if email.List != nil {
+		fmt.Fprintf(&raw, "List-Id: %s <%s>\r\n", email.List.Name, email.List.Id)
+		fmt.Fprintf(&raw, "List-Post: <mailto:%s>\r\n", email.List.Post)
+		fmt.Fprintf(&raw, "List-Unsubscribe: <%s>\r\n", strings.Join(email.List.Unsubscribe, ">, <"))
+	}
+	raw.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	raw.WriteString(strings.ReplaceAll(fakeBody(id), "\n", "\r\n"))
+	return []byte(raw.String()), nil
+}
+
+// SendEmail pretends to send the email, saving a copy to the sent mailbox.
 func (b *FakeBackend) SendEmail(email core.OutgoingEmail) error {
 	time.Sleep(1 * time.Second)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := core.EmailId(strconv.Itoa(b.nextId))
+	b.nextId++
+	from := email.From
+	if from == "" {
+		from = "me@example.com"
+	}
+	b.mailboxes["Sent"][id] = core.EmailMetadata{
+		Id:         id,
+		From:       from,
+		To:         email.To,
+		Subject:    email.Subject,
+		SentAt:     time.Now(),
+		Flags:      []core.Flag{core.FlagSeen},
+		MessageId:  email.MessageId,
+		References: email.References,
+	}
 	return nil
 }
 
-func (b *FakeBackend) MarkAsRead(id core.EmailId) error {
+// SaveDraft gives each version of a draft a new id, like appending it to an IMAP mailbox would.
+func (b *FakeBackend) SaveDraft(draft core.Draft) (*core.Draft, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.drafts, draft.Id)
+	draft.Id = core.EmailId(strconv.Itoa(b.nextId))
+	b.nextId++
+	draft.SavedAt = time.Now()
+	b.drafts[draft.Id] = draft
+	return &draft, nil
+}
+
+func (b *FakeBackend) ListDrafts() ([]core.Draft, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.SortedFunc(maps.Values(b.drafts), func(a, b core.Draft) int {
+		return b.SavedAt.Compare(a.SavedAt)
+	}), nil
+}
+
+func (b *FakeBackend) DeleteDraft(id core.EmailId) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.drafts[id]; !ok {
+		return fmt.Errorf("draft not found")
+	}
+	delete(b.drafts, id)
+	return nil
+}
+
+func (b *FakeBackend) AddFlags(ids []core.EmailId, flags ...core.Flag) error {
+	return b.setFlags(ids, flags, true)
+}
+
+func (b *FakeBackend) RemoveFlags(ids []core.EmailId, flags ...core.Flag) error {
+	return b.setFlags(ids, flags, false)
+}
+
+func (b *FakeBackend) setFlags(ids []core.EmailId, flags []core.Flag, set bool) error {
+	time.Sleep(1 * time.Second)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var missing []core.EmailId
+	for _, id := range ids {
+		email, ok := b.mailboxes[inbox][id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		for _, flag := range flags {
+			email.SetFlag(flag, set)
+		}
+		b.mailboxes[inbox][id] = email
+	}
+	return bulkError(missing)
+}
+
+// bulkError reports the emails that couldn't be found, if there were any.
+func bulkError(missing []core.EmailId) error {
+	if len(missing) == 0 {
+		return nil
+	}
+	return &core.BulkError{Failed: missing}
+}
+
+func (b *FakeBackend) ListMailboxes() ([]core.Mailbox, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var mailboxes []core.Mailbox
+	for _, name := range slices.Sorted(maps.Keys(b.mailboxes)) {
+		mailboxes = append(mailboxes, core.Mailbox{Name: name, Role: b.roles[name]})
+	}
+	return mailboxes, nil
+}
+
+func (b *FakeBackend) MoveEmails(ids []core.EmailId, mailbox string) (*core.MoveResult, error) {
+	time.Sleep(1 * time.Second)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.moveEmails(inbox, ids, mailbox)
+}
+
+func (b *FakeBackend) moveEmails(source string, ids []core.EmailId, destination string) (*core.MoveResult, error) {
+	result, err := b.copyEmails(source, ids, destination)
+	if result == nil {
+		return nil, err
+	}
+	for id := range result.Ids {
+		delete(b.mailboxes[source], id)
+	}
+	return result, err
+}
+
+func (b *FakeBackend) CopyEmails(ids []core.EmailId, mailbox string) (*core.MoveResult, error) {
 	time.Sleep(1 * time.Second)
-	email, ok := b.emails[id]
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.copyEmails(inbox, ids, mailbox)
+}
+
+func (b *FakeBackend) copyEmails(source string, ids []core.EmailId, mailbox string) (*core.MoveResult, error) {
+	destination, ok := b.mailboxes[mailbox]
 	if !ok {
-		return fmt.Errorf("email not found")
+		return nil, fmt.Errorf("mailbox %q not found", mailbox)
 	}
-	email.IsRead = true
-	b.emails[id] = email
-	return nil
+	if _, ok := b.mailboxes[source]; !ok {
+		return nil, fmt.Errorf("mailbox %q not found", source)
+	}
+
+	result := &core.MoveResult{Mailbox: mailbox, Ids: map[core.EmailId]core.EmailId{}}
+	var missing []core.EmailId
+	for _, id := range ids {
+		email, ok := b.mailboxes[source][id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		email.Id = core.EmailId(strconv.Itoa(b.nextId))
+		b.nextId++
+		destination[email.Id] = email
+		result.Ids[id] = email.Id
+	}
+	return result, bulkError(missing)
+}
+
+func (b *FakeBackend) DeleteEmails(ids []core.EmailId) (*core.MoveResult, error) {
+	return b.MoveEmails(ids, "Trash")
+}
+
+func (b *FakeBackend) RestoreEmails(mailbox string, ids []core.EmailId) (*core.MoveResult, error) {
+	time.Sleep(1 * time.Second)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.moveEmails(mailbox, ids, inbox)
 }
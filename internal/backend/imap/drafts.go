@@ -0,0 +1,183 @@
+package imap
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/message"
+)
+
+// SaveDraft appends the draft to the drafts mailbox with the \Draft flag, then expunges the previous version.
+// If the server doesn't have a drafts mailbox, the draft is stored locally instead.
+func (b *ImapBackend) SaveDraft(draft core.Draft) (*core.Draft, error) {
+	mailbox, ok, err := b.draftsMailbox()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return b.localDrafts.Save(draft)
+	}
+
+	if draft.From == "" {
+		draft.From = b.from
+	}
+	if draft.MessageId == "" {
+		draft.MessageId = message.GenerateMessageId(draft.From)
+	}
+	draft.SavedAt = time.Now()
+	raw, err := message.BuildDraft(draft.OutgoingEmail, draft.SavedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.withMailbox(mailbox, func(*imap.SelectData) error {
+		uid, err := b.append(mailbox, raw, []imap.Flag{imap.FlagDraft, imap.FlagSeen}, draft.SavedAt)
+		if err != nil {
+			return err
+		}
+		if uid == 0 {
+			// without UIDPLUS the new version has to be found again, and it'll have the highest UID
+			uid, err = b.findByMessageId(draft.MessageId)
+			if err != nil {
+				return err
+			}
+		}
+
+		previous := draft.Id
+		draft.Id = uidToId(uid)
+		if previous == "" || previous == draft.Id {
+			return nil
+		}
+		previousUid, err := parseUid(previous)
+		if err != nil {
+			return err
+		}
+		return b.expunge(imap.UIDSetNum(previousUid))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// ListDrafts fetches every message in the drafts mailbox that can be read, or lists the local drafts if there isn't
+// one.
+func (b *ImapBackend) ListDrafts() ([]core.Draft, error) {
+	mailbox, ok, err := b.draftsMailbox()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return b.localDrafts.List()
+	}
+
+	drafts := []core.Draft{}
+	err = b.withMailbox(mailbox, func(data *imap.SelectData) error {
+		if data.NumMessages == 0 {
+			return nil
+		}
+		sequenceSet := imap.SeqSet{}
+		sequenceSet.AddRange(1, 0)
+		messages, err := b.client.Fetch(sequenceSet, &imap.FetchOptions{
+			UID:          true,
+			InternalDate: true,
+			BodySection:  []*imap.FetchItemBodySection{{Peek: true}},
+		}).Collect()
+		if err != nil {
+			return err
+		}
+
+		for _, fetched := range messages {
+			if len(fetched.BodySection) == 0 {
+				continue
+			}
+			email, err := message.Parse(bytes.NewReader(fetched.BodySection[0].Bytes))
+			if err != nil {
+				// other clients share the mailbox, so a draft one of them saved in a form that can't be read is
+				// skipped rather than hiding the rest
+				continue
+			}
+			drafts = append(drafts, core.Draft{
+				Id:            uidToId(fetched.UID),
+				SavedAt:       fetched.InternalDate,
+				OutgoingEmail: *email,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(drafts, func(a, b core.Draft) int {
+		return b.SavedAt.Compare(a.SavedAt)
+	})
+	return drafts, nil
+}
+
+// DeleteDraft expunges a draft from the drafts mailbox, or deletes it locally if there isn't one.
+func (b *ImapBackend) DeleteDraft(id core.EmailId) error {
+	mailbox, ok, err := b.draftsMailbox()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return b.localDrafts.Delete(id)
+	}
+
+	uid, err := parseUid(id)
+	if err != nil {
+		return err
+	}
+	return b.withMailbox(mailbox, func(*imap.SelectData) error {
+		return b.expunge(imap.UIDSetNum(uid))
+	})
+}
+
+func (b *ImapBackend) draftsMailbox() (string, bool, error) {
+	mailboxes, err := b.ListMailboxes()
+	if err != nil {
+		return "", false, err
+	}
+	drafts, ok := core.FindMailbox(mailboxes, core.MailboxRoleDrafts)
+	return drafts.Name, ok, nil
+}
+
+// append uploads a message to a mailbox, returning its UID if the server supports UIDPLUS, or 0 otherwise.
+func (b *ImapBackend) append(mailbox string, raw []byte, flags []imap.Flag, date time.Time) (imap.UID, error) {
+	command := b.client.Append(mailbox, int64(len(raw)), &imap.AppendOptions{
+		Flags: flags,
+		Time:  date,
+	})
+	if _, err := command.Write(raw); err != nil {
+		return 0, err
+	}
+	if err := command.Close(); err != nil {
+		return 0, err
+	}
+	data, err := command.Wait()
+	if err != nil {
+		return 0, err
+	}
+	return data.UID, nil
+}
+
+// findByMessageId searches the selected mailbox for the newest message with a Message-ID.
+func (b *ImapBackend) findByMessageId(messageId string) (imap.UID, error) {
+	data, err := b.client.UIDSearch(&imap.SearchCriteria{
+		Header: []imap.SearchCriteriaHeaderField{{Key: "Message-Id", Value: messageId}},
+	}, nil).Wait()
+	if err != nil {
+		return 0, err
+	}
+	uids := data.AllUIDs()
+	if len(uids) == 0 {
+		return 0, fmt.Errorf("couldn't find the message that was just saved")
+	}
+	return slices.Max(uids), nil
+}
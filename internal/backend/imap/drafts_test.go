@@ -0,0 +1,32 @@
+package imap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+)
+
+func TestListDrafts_SkipsUnreadable(t *testing.T) {
+	backend := newTestBackend(t, imap.CapSet{imap.CapIMAP4rev1: {}, imap.CapUIDPlus: {}}, 0)
+	if err := backend.client.Create("Drafts", nil).Wait(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, raw := range []string{
+		"Subject: Half written\r\n\r\nDear Bob\r\n",
+		"Subject: Broken\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n--b\r\nContent-Type: text/plain\r\n",
+	} {
+		if _, err := backend.append("Drafts", []byte(raw), []imap.Flag{imap.FlagDraft}, time.Now()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	drafts, err := backend.ListDrafts()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(drafts) != 1 || drafts[0].Subject != "Half written" {
+		t.Errorf("Expected only the draft that can be read, got %+v", drafts)
+	}
+}
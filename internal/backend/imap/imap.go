@@ -1,48 +1,137 @@
 package imap
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"maps"
+	"net"
+	"path"
+	"path/filepath"
 	"slices"
 	"strconv"
-	"time"
+	"strings"
+	"sync"
 
+	"github.com/bengesoff/mail-tui/internal/config"
 	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/drafts"
+	"github.com/bengesoff/mail-tui/internal/identity"
+	"github.com/bengesoff/mail-tui/internal/lists"
+	"github.com/bengesoff/mail-tui/internal/message"
+	"github.com/bengesoff/mail-tui/internal/pgp"
+	"github.com/bengesoff/mail-tui/internal/smime"
+	"github.com/bengesoff/mail-tui/internal/smtp"
+	"github.com/bengesoff/mail-tui/internal/threading"
 	"github.com/emersion/go-imap/v2"
 	"github.com/emersion/go-imap/v2/imapclient"
+	gomessage "github.com/emersion/go-message"
+	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-message/textproto"
 )
 
+// headersSection fetches the headers that aren't part of the envelope: References, and the mailing list's headers.
+var headersSection = &imap.FetchItemBodySection{
+	Specifier:    imap.PartSpecifierHeader,
+	HeaderFields: []string{"References", "List-Id", "List-Post", "List-Unsubscribe", "List-Unsubscribe-Post"},
+	Peek:         true,
+}
+
+const inbox = "INBOX"
+
+// Config holds the settings for connecting to an IMAP server, and for sending emails.
+type Config struct {
+	Address  string
+	Username string
+	Password string
+
+	// From is the address emails are sent from, which defaults to the username
+	From string
+	// Smtp submits emails that are sent, unless the sender's identity has its own server, and sending fails if it's nil
+	Smtp *smtp.Sender
+	// Identities are the addresses emails can be sent from, whose accounts can each have their own SMTP server
+	Identities *identity.Config
+	// SentMailbox is where copies of sent emails are saved, which is found from its SPECIAL-USE attribute or name if
+	// it's empty
+	SentMailbox string
+	// SkipSaveSent stops copies of sent emails being saved, for servers like Gmail that save them automatically
+	SkipSaveSent bool
+	// Keyring signs and encrypts emails that ask to be protected with OpenPGP, which fail to send if it's nil
+	Keyring *pgp.Keyring
+	// SMIME signs emails that ask to be signed with S/MIME, which fail to send if it's nil
+	SMIME *smime.Config
+}
+
 type ImapBackend struct {
 	client *imapclient.Client
+	config Config
+	// from is the address emails are sent from
+	from string
+	// localDrafts stores drafts if the server doesn't have a drafts mailbox
+	localDrafts *drafts.Store
+	// selection guards which mailbox is selected. Most commands act on the inbox, so they can run at the same time,
+	// but commands on other mailboxes need to select them, which mustn't happen while anything else is running.
+	selection sync.RWMutex
 }
 
-func NewImapBackend(address, username, password string) (*ImapBackend, error) {
+func NewImapBackend(cfg Config) (*ImapBackend, error) {
 	// should really use TLS
-	client, err := imapclient.DialInsecure(address, nil)
+	client, err := imapclient.DialInsecure(cfg.Address, nil)
 	if err != nil {
 		return nil, err
 	}
-	err = client.Login(username, password).Wait()
+	err = client.Login(cfg.Username, cfg.Password).Wait()
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = client.Select("INBOX", nil).Wait()
+	_, err = client.Select(inbox, nil).Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return nil, err
+	}
+	localDrafts, err := drafts.NewStore(filepath.Join(dataDir, "drafts"))
 	if err != nil {
 		return nil, err
 	}
 
-	return &ImapBackend{client}, nil
+	from := cfg.From
+	if from == "" {
+		// usernames are usually email addresses, but local test servers often just use names
+		from = cfg.Username
+		if !strings.Contains(from, "@") {
+			host, _, _ := net.SplitHostPort(cfg.Address)
+			from += "@" + host
+		}
+	}
+
+	return &ImapBackend{client: client, config: cfg, from: from, localDrafts: localDrafts}, nil
 }
 
 // ListEmails fetches all messages.
 // It does not do any pagination, but it should do for large mailboxes.
+//
+// Emails are identified by their UIDs rather than sequence numbers, since sequence numbers change when other
+// messages are moved or deleted.
 func (b *ImapBackend) ListEmails() ([]core.EmailMetadata, error) {
+	b.selection.RLock()
+	defer b.selection.RUnlock()
+	return b.listEmails()
+}
+
+func (b *ImapBackend) listEmails() ([]core.EmailMetadata, error) {
 	sequenceSet := imap.SeqSet{}
 	// fetch 1:* (for fetching all)
 	sequenceSet.AddRange(1, 0)
 	messages, err := b.client.Fetch(sequenceSet, &imap.FetchOptions{
-		Envelope: true,
-		Flags:    true,
+		UID:         true,
+		Envelope:    true,
+		Flags:       true,
+		BodySection: []*imap.FetchItemBodySection{headersSection},
 	}).Collect()
 	if err != nil {
 		return nil, err
@@ -55,16 +144,59 @@ func (b *ImapBackend) ListEmails() ([]core.EmailMetadata, error) {
 	return result, nil
 }
 
-// GetEmail fetches a single email by its sequence number.
+// ListThreads groups all messages into conversations.
+// It uses the THREAD=REFERENCES extension if the server supports it, and otherwise threads the messages locally.
+func (b *ImapBackend) ListThreads() ([]*core.Thread, error) {
+	b.selection.RLock()
+	defer b.selection.RUnlock()
+
+	emails, err := b.listEmails()
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(b.client.Caps().ThreadAlgorithms(), imap.ThreadReferences) {
+		return threading.Thread(emails), nil
+	}
+
+	data, err := b.client.UIDThread(&imapclient.ThreadOptions{
+		Algorithm:      imap.ThreadReferences,
+		SearchCriteria: &imap.SearchCriteria{},
+	}).Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	emailsById := map[core.EmailId]*core.EmailMetadata{}
+	for i := range emails {
+		emailsById[emails[i].Id] = &emails[i]
+	}
+
+	threads := make([]*core.Thread, 0, len(data))
+	for _, thread := range data {
+		threads = append(threads, threadDataToThread(thread, emailsById))
+	}
+	threading.SortNewestFirst(threads)
+	return threads, nil
+}
+
+// GetEmail fetches a single email by its UID.
 func (b *ImapBackend) GetEmail(id core.EmailId) (*core.Email, error) {
-	sequenceNumber, err := strconv.Atoi(string(id))
+	b.selection.RLock()
+	defer b.selection.RUnlock()
+
+	uid, err := parseUid(id)
 	if err != nil {
 		return nil, err
 	}
 
-	messages, err := b.client.Fetch(imap.SeqSetNum(uint32(sequenceNumber)), &imap.FetchOptions{
+	// the whole message is fetched so the text part can be found and decoded, which also marks it as read
+	bodySection := &imap.FetchItemBodySection{}
+	messages, err := b.client.Fetch(imap.UIDSetNum(uid), &imap.FetchOptions{
+		UID:         true,
 		Envelope:    true,
-		BodySection: []*imap.FetchItemBodySection{{Specifier: imap.PartSpecifierText}},
+		Flags:       true,
+		BodySection: []*imap.FetchItemBodySection{bodySection, headersSection},
 	}).Collect()
 	if err != nil {
 		return nil, err
@@ -73,38 +205,345 @@ func (b *ImapBackend) GetEmail(id core.EmailId) (*core.Email, error) {
 		return nil, fmt.Errorf("expected 1 message, got %d", len(messages))
 	}
 
-	message := messages[0]
-	// fairly rudimentary body decoding - will probably break for more complicated multipart messages
-	body := string(message.BodySection[0].Bytes)
+	fetched := messages[0]
+	raw := fetched.FindBodySection(bodySection)
+	body, isFlowed, err := message.ParseBody(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	// the email can still be read if its invitation can't
+	calendar, _ := message.FindCalendar(bytes.NewReader(raw))
 
 	return &core.Email{
-		EmailMetadata: fetchMessageBufferToEmailMetadata(message),
+		EmailMetadata: fetchMessageBufferToEmailMetadata(fetched),
 		Body:          body,
+		Flowed:        isFlowed,
+		Calendar:      calendar,
 	}, nil
 }
 
-// SendEmail does nothing currently.
-func (b *ImapBackend) SendEmail(email core.OutgoingEmail) error {
-	time.Sleep(1 * time.Second)
-	// should use net/smtp to send the message somewhere
-	return nil
+// GetRawEmail fetches the whole of an email by its UID with BODY.PEEK[], so looking at it doesn't set \Seen.
+func (b *ImapBackend) GetRawEmail(id core.EmailId) ([]byte, error) {
+	b.selection.RLock()
+	defer b.selection.RUnlock()
+
+	uid, err := parseUid(id)
+	if err != nil {
+		return nil, err
+	}
+
+	bodySection := &imap.FetchItemBodySection{Peek: true}
+	messages, err := b.client.Fetch(imap.UIDSetNum(uid), &imap.FetchOptions{
+		UID:         true,
+		BodySection: []*imap.FetchItemBodySection{bodySection},
+	}).Collect()
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) != 1 {
+		return nil, fmt.Errorf("expected 1 message, got %d", len(messages))
+	}
+	return messages[0].FindBodySection(bodySection), nil
+}
+
+// AddFlags uses the STORE command to add flags to emails with the given UIDs.
+func (b *ImapBackend) AddFlags(ids []core.EmailId, flags ...core.Flag) error {
+	return b.storeFlags(ids, imap.StoreFlagsAdd, flags)
+}
+
+// RemoveFlags uses the STORE command to remove flags from emails with the given UIDs.
+func (b *ImapBackend) RemoveFlags(ids []core.EmailId, flags ...core.Flag) error {
+	return b.storeFlags(ids, imap.StoreFlagsDel, flags)
 }
 
-// MarkAsRead uses the STORE command to add the SEEN flag to an email with a given sequence number.
-func (b *ImapBackend) MarkAsRead(id core.EmailId) error {
-	sequenceNumber, err := strconv.Atoi(string(id))
+func (b *ImapBackend) storeFlags(ids []core.EmailId, op imap.StoreFlagsOp, flags []core.Flag) error {
+	b.selection.RLock()
+	defer b.selection.RUnlock()
+
+	uids, err := parseUidSet(ids)
 	if err != nil {
 		return err
 	}
 
-	return b.client.Store(
-		imap.SeqSetNum(uint32(sequenceNumber)),
+	imapFlags := make([]imap.Flag, 0, len(flags))
+	for _, flag := range flags {
+		imapFlags = append(imapFlags, imap.Flag(flag))
+	}
+
+	err = b.client.Store(
+		uids,
 		&imap.StoreFlags{
-			Op:     imap.StoreFlagsAdd,
-			Flags:  []imap.Flag{imap.FlagSeen},
+			Op:     op,
+			Flags:  imapFlags,
 			Silent: true,
 		},
 		nil).Close()
+	if err != nil {
+		return err
+	}
+
+	// STORE skips messages that no longer exist, and some servers ignore flags they can't store, so the flags are
+	// fetched again to find the messages that weren't updated
+	messages, err := b.client.Fetch(uids, &imap.FetchOptions{UID: true, Flags: true}).Collect()
+	if err != nil {
+		return err
+	}
+	updated := map[core.EmailId]bool{}
+	for _, message := range messages {
+		email := core.EmailMetadata{Flags: imapFlagsToFlags(message.Flags)}
+		ok := true
+		for _, flag := range flags {
+			if email.HasFlag(flag) != (op == imap.StoreFlagsAdd) {
+				ok = false
+			}
+		}
+		updated[uidToId(message.UID)] = ok
+	}
+	return bulkError(ids, updated)
+}
+
+// ListMailboxes lists every mailbox, working out their roles from their SPECIAL-USE attributes if the server
+// supports them, or from their names otherwise.
+func (b *ImapBackend) ListMailboxes() ([]core.Mailbox, error) {
+	var options *imap.ListOptions
+	if b.client.Caps().Has(imap.CapSpecialUse) {
+		options = &imap.ListOptions{ReturnSpecialUse: true}
+	}
+	data, err := b.client.List("", "*", options).Collect()
+	if err != nil {
+		return nil, err
+	}
+
+	var mailboxes []core.Mailbox
+	var guessed []int
+	// roles the server has marked, which take priority over guesses based on the names of other mailboxes
+	markedRoles := map[core.MailboxRole]bool{}
+	for _, mailbox := range data {
+		if slices.Contains(mailbox.Attrs, imap.MailboxAttrNoSelect) ||
+			slices.Contains(mailbox.Attrs, imap.MailboxAttrNonExistent) {
+			continue
+		}
+		role, marked := mailboxRole(mailbox)
+		if marked {
+			markedRoles[role] = true
+		} else {
+			guessed = append(guessed, len(mailboxes))
+		}
+		mailboxes = append(mailboxes, core.Mailbox{
+			Name: mailbox.Mailbox,
+			Role: role,
+		})
+	}
+	for _, i := range guessed {
+		if markedRoles[mailboxes[i].Role] {
+			mailboxes[i].Role = core.MailboxRoleNone
+		}
+	}
+
+	slices.SortFunc(mailboxes, func(a, b core.Mailbox) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return mailboxes, nil
+}
+
+// MoveEmails uses the MOVE command if the server supports it.
+// Otherwise it copies the messages and then expunges the originals, only once the copy has succeeded.
+func (b *ImapBackend) MoveEmails(ids []core.EmailId, mailbox string) (*core.MoveResult, error) {
+	b.selection.RLock()
+	defer b.selection.RUnlock()
+	return b.moveEmails(ids, mailbox)
+}
+
+// moveEmails moves messages out of the selected mailbox.
+func (b *ImapBackend) moveEmails(ids []core.EmailId, mailbox string) (*core.MoveResult, error) {
+	uids, err := parseUidSet(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	if !b.client.Caps().Has(imap.CapMove) {
+		// checked before copying, so the messages aren't left in both mailboxes if they can't be expunged
+		if err := b.checkExpunge(uids); err != nil {
+			return nil, err
+		}
+		// the client library has its own fallback, but it sends all the commands at once, so it would delete the
+		// messages even if copying them failed
+		result, copyErr := b.copyEmails(ids, mailbox)
+		if result == nil {
+			return nil, copyErr
+		}
+		if result.Ids != nil {
+			if len(result.Ids) == 0 {
+				return result, copyErr
+			}
+			// only the messages that were copied are expunged
+			uids, err = parseUidSet(slices.Collect(maps.Keys(result.Ids)))
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := b.expunge(uids); err != nil {
+			return nil, err
+		}
+		return result, copyErr
+	}
+
+	data, err := b.client.Move(uids, mailbox).Wait()
+	if err != nil {
+		return nil, err
+	}
+	destinationUids, _ := data.DestUIDs.(imap.UIDSet)
+	sourceUids, _ := data.SourceUIDs.(imap.UIDSet)
+	return moveResult(ids, mailbox, sourceUids, destinationUids)
+}
+
+// CopyEmails uses the COPY command.
+// The ids of the copies are only known if the server supports UIDPLUS.
+func (b *ImapBackend) CopyEmails(ids []core.EmailId, mailbox string) (*core.MoveResult, error) {
+	b.selection.RLock()
+	defer b.selection.RUnlock()
+	return b.copyEmails(ids, mailbox)
+}
+
+func (b *ImapBackend) copyEmails(ids []core.EmailId, mailbox string) (*core.MoveResult, error) {
+	uids, err := parseUidSet(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := b.client.Copy(uids, mailbox).Wait()
+	if err != nil {
+		return nil, err
+	}
+	return moveResult(ids, mailbox, data.SourceUIDs, data.DestUIDs)
+}
+
+// moveResult builds the result of copying or moving messages from a COPYUID response.
+// The server skips messages that no longer exist, so any requested messages missing from the response failed.
+func moveResult(ids []core.EmailId, mailbox string, source, destination imap.UIDSet) (*core.MoveResult, error) {
+	result := &core.MoveResult{
+		Mailbox: mailbox,
+		Ids:     mapUids(source, destination),
+	}
+	if result.Ids == nil {
+		// without UIDPLUS there's no way to tell which messages were copied
+		return result, nil
+	}
+	succeeded := map[core.EmailId]bool{}
+	for id := range result.Ids {
+		succeeded[id] = true
+	}
+	return result, bulkError(ids, succeeded)
+}
+
+// bulkError returns a *core.BulkError listing the ids that didn't succeed, or nil if they all did.
+func bulkError(ids []core.EmailId, succeeded map[core.EmailId]bool) error {
+	var failed []core.EmailId
+	for _, id := range ids {
+		if !succeeded[id] {
+			failed = append(failed, id)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &core.BulkError{Failed: failed}
+}
+
+// DeleteEmails moves messages to the trash mailbox.
+// If there isn't one, the messages are flagged as deleted and expunged.
+func (b *ImapBackend) DeleteEmails(ids []core.EmailId) (*core.MoveResult, error) {
+	mailboxes, err := b.ListMailboxes()
+	if err != nil {
+		return nil, err
+	}
+
+	b.selection.RLock()
+	defer b.selection.RUnlock()
+	if trash, ok := core.FindMailbox(mailboxes, core.MailboxRoleTrash); ok {
+		return b.moveEmails(ids, trash.Name)
+	}
+
+	uids, err := parseUidSet(ids)
+	if err != nil {
+		return nil, err
+	}
+	return &core.MoveResult{}, b.expunge(uids)
+}
+
+// RestoreEmails selects another mailbox to move messages from it back to the inbox, then selects the inbox again.
+func (b *ImapBackend) RestoreEmails(mailbox string, ids []core.EmailId) (*core.MoveResult, error) {
+	var result *core.MoveResult
+	err := b.withMailbox(mailbox, func(*imap.SelectData) error {
+		var err error
+		result, err = b.moveEmails(ids, inbox)
+		return err
+	})
+	return result, err
+}
+
+// withMailbox selects another mailbox to run some commands in, then selects the inbox again.
+// Nothing else can run in the meantime, since other commands expect the inbox to be selected.
+func (b *ImapBackend) withMailbox(mailbox string, run func(*imap.SelectData) error) error {
+	b.selection.Lock()
+	defer b.selection.Unlock()
+
+	data, err := b.client.Select(mailbox, nil).Wait()
+	if err != nil {
+		return err
+	}
+	runErr := run(data)
+	if _, err := b.client.Select(inbox, nil).Wait(); err != nil {
+		return err
+	}
+	return runErr
+}
+
+// expunge permanently deletes messages.
+// Without UIDPLUS, EXPUNGE would also delete any other messages that were already flagged as deleted, so it's refused
+// if there are any.
+func (b *ImapBackend) expunge(uids imap.UIDSet) error {
+	if err := b.checkExpunge(uids); err != nil {
+		return err
+	}
+	err := b.client.Store(uids, &imap.StoreFlags{
+		Op:     imap.StoreFlagsAdd,
+		Flags:  []imap.Flag{imap.FlagDeleted},
+		Silent: true,
+	}, nil).Close()
+	if err != nil {
+		return err
+	}
+
+	if b.client.Caps().Has(imap.CapUIDPlus) {
+		return b.client.UIDExpunge(uids).Close()
+	}
+	return b.client.Expunge().Close()
+}
+
+// checkExpunge makes sure that expunging messages won't delete any others. Only servers without UIDPLUS need
+// checking, since they can only expunge every message flagged as deleted at once.
+func (b *ImapBackend) checkExpunge(uids imap.UIDSet) error {
+	if b.client.Caps().Has(imap.CapUIDPlus) {
+		return nil
+	}
+	data, err := b.client.UIDSearch(&imap.SearchCriteria{
+		Flag: []imap.Flag{imap.FlagDeleted},
+	}, nil).Wait()
+	if err != nil {
+		return err
+	}
+	var others int
+	for _, uid := range data.AllUIDs() {
+		if !uids.Contains(uid) {
+			others++
+		}
+	}
+	if others > 0 {
+		return fmt.Errorf("refusing to expunge: %d other messages are already flagged as deleted, and the server "+
+			"doesn't support UIDPLUS to expunge only these ones", others)
+	}
+	return nil
 }
 
 func (b *ImapBackend) Close() error {
@@ -116,12 +555,156 @@ func (b *ImapBackend) Close() error {
 }
 
 func fetchMessageBufferToEmailMetadata(message *imapclient.FetchMessageBuffer) core.EmailMetadata {
+	header := parseHeader(message.FindBodySection(headersSection))
 	return core.EmailMetadata{
-		Id:      core.EmailId(strconv.Itoa(int(message.SeqNum))),
+		Id:      uidToId(message.UID),
 		Subject: message.Envelope.Subject,
 		From:    message.Envelope.From[0].Addr(),
 		To:      message.Envelope.To[0].Addr(),
 		SentAt:  message.Envelope.Date,
-		IsRead:  slices.Contains(message.Flags, imap.FlagSeen),
+		Flags:   imapFlagsToFlags(message.Flags),
+
+		MessageId:  message.Envelope.MessageID,
+		InReplyTo:  message.Envelope.InReplyTo,
+		References: parseReferences(header),
+		List:       lists.FromHeader(header),
+	}
+}
+
+// parseHeader reads the fetched headers section. Malformed headers are treated as missing, since the email can still
+// be listed without them.
+func parseHeader(rawHeader []byte) textproto.Header {
+	if len(rawHeader) == 0 {
+		return textproto.Header{}
+	}
+	header, err := textproto.ReadHeader(bufio.NewReader(bytes.NewReader(rawHeader)))
+	if err != nil {
+		return textproto.Header{}
+	}
+	return header
+}
+
+// parseReferences extracts the message IDs from the References header.
+// Malformed headers are ignored, since threading can still fall back to In-Reply-To.
+func parseReferences(header textproto.Header) []string {
+	mailHeader := mail.Header{Header: gomessage.Header{Header: header}}
+	references, err := mailHeader.MsgIDList("References")
+	if err != nil {
+		return nil
+	}
+	return references
+}
+
+// threadDataToThread converts a THREAD response into a tree.
+// Each thread is a chain of messages, each replying to the previous one, followed by any branches off the last message.
+// An empty chain means the root message is missing.
+func threadDataToThread(data imapclient.ThreadData, emails map[core.EmailId]*core.EmailMetadata) *core.Thread {
+	root := &core.Thread{}
+	current := root
+	for i, uid := range data.Chain {
+		node := &core.Thread{Email: emails[uidToId(imap.UID(uid))]}
+		if i == 0 {
+			root = node
+		} else {
+			current.Children = append(current.Children, node)
+		}
+		current = node
+	}
+	for _, subThread := range data.SubThreads {
+		current.Children = append(current.Children, threadDataToThread(subThread, emails))
+	}
+	return root
+}
+
+func imapFlagsToFlags(imapFlags []imap.Flag) []core.Flag {
+	flags := make([]core.Flag, 0, len(imapFlags))
+	for _, flag := range imapFlags {
+		flags = append(flags, core.Flag(flag))
+	}
+	return flags
+}
+
+func uidToId(uid imap.UID) core.EmailId {
+	return core.EmailId(strconv.FormatUint(uint64(uid), 10))
+}
+
+func parseUid(id core.EmailId) (imap.UID, error) {
+	uid, err := strconv.ParseUint(string(id), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid email id %q: %w", id, err)
+	}
+	return imap.UID(uid), nil
+}
+
+func parseUidSet(ids []core.EmailId) (imap.UIDSet, error) {
+	var uids imap.UIDSet
+	for _, id := range ids {
+		uid, err := parseUid(id)
+		if err != nil {
+			return nil, err
+		}
+		uids.AddNum(uid)
+	}
+	return uids, nil
+}
+
+// mapUids pairs up the source and destination UIDs from a COPYUID response, which are listed in the same order.
+func mapUids(source, destination imap.UIDSet) map[core.EmailId]core.EmailId {
+	sourceUids, ok := source.Nums()
+	if !ok {
+		return nil
+	}
+	destinationUids, ok := destination.Nums()
+	if !ok || len(sourceUids) != len(destinationUids) {
+		return nil
+	}
+
+	ids := map[core.EmailId]core.EmailId{}
+	for i := range sourceUids {
+		ids[uidToId(sourceUids[i])] = uidToId(destinationUids[i])
+	}
+	return ids
+}
+
+var mailboxRolesByAttr = map[imap.MailboxAttr]core.MailboxRole{
+	imap.MailboxAttrArchive: core.MailboxRoleArchive,
+	imap.MailboxAttrDrafts:  core.MailboxRoleDrafts,
+	imap.MailboxAttrJunk:    core.MailboxRoleJunk,
+	imap.MailboxAttrSent:    core.MailboxRoleSent,
+	imap.MailboxAttrTrash:   core.MailboxRoleTrash,
+}
+
+// mailboxRolesByName are the common names for special-use mailboxes, used when the server doesn't mark them.
+var mailboxRolesByName = map[string]core.MailboxRole{
+	"archive":          core.MailboxRoleArchive,
+	"archives":         core.MailboxRoleArchive,
+	"drafts":           core.MailboxRoleDrafts,
+	"junk":             core.MailboxRoleJunk,
+	"spam":             core.MailboxRoleJunk,
+	"sent":             core.MailboxRoleSent,
+	"sent items":       core.MailboxRoleSent,
+	"sent messages":    core.MailboxRoleSent,
+	"trash":            core.MailboxRoleTrash,
+	"deleted items":    core.MailboxRoleTrash,
+	"deleted messages": core.MailboxRoleTrash,
+}
+
+// mailboxRole works out a mailbox's role, and whether the server marked it with that role.
+func mailboxRole(mailbox *imap.ListData) (role core.MailboxRole, marked bool) {
+	if strings.EqualFold(mailbox.Mailbox, "INBOX") {
+		return core.MailboxRoleInbox, true
+	}
+	for _, attr := range mailbox.Attrs {
+		for roleAttr, role := range mailboxRolesByAttr {
+			if strings.EqualFold(string(attr), string(roleAttr)) {
+				return role, true
+			}
+		}
+	}
+
+	name := mailbox.Mailbox
+	if mailbox.Delim != 0 {
+		name = path.Base(strings.ReplaceAll(name, string(mailbox.Delim), "/"))
 	}
+	return mailboxRolesByName[strings.ToLower(name)], false
 }
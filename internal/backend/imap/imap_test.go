@@ -0,0 +1,193 @@
+package imap
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+	"github.com/emersion/go-imap/v2/imapserver"
+	"github.com/emersion/go-imap/v2/imapserver/imapmemserver"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+// newTestBackend connects to an in-memory server with the capabilities given, with an inbox holding count messages
+// and an empty archive.
+func newTestBackend(t *testing.T, caps imap.CapSet, count int) *ImapBackend {
+	t.Helper()
+	user := imapmemserver.NewUser("alice", "password")
+	for _, mailbox := range []string{inbox, "Archive"} {
+		if err := user.Create(mailbox, nil); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	memServer := imapmemserver.New()
+	memServer.AddUser(user)
+
+	server := imapserver.New(&imapserver.Options{
+		NewSession: func(*imapserver.Conn) (imapserver.Session, *imapserver.GreetingData, error) {
+			return memServer.NewSession(), nil, nil
+		},
+		Caps:         caps,
+		InsecureAuth: true,
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	client, err := imapclient.DialInsecure(listener.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if err := client.Login("alice", "password").Wait(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	backend := &ImapBackend{client: client, from: "alice@example.com"}
+	for i := 1; i <= count; i++ {
+		raw := fmt.Sprintf("From: bob@example.com\r\nSubject: Message %d\r\n\r\nHello\r\n", i)
+		if _, err := backend.append(inbox, []byte(raw), nil, time.Now()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if _, err := client.Select(inbox, nil).Wait(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return backend
+}
+
+// subjects lists the subjects of the messages in a mailbox, along with whether they're flagged as deleted.
+func subjects(t *testing.T, backend *ImapBackend, mailbox string) []string {
+	t.Helper()
+	var found []string
+	err := backend.withMailbox(mailbox, func(data *imap.SelectData) error {
+		if data.NumMessages == 0 {
+			return nil
+		}
+		all := imap.SeqSet{}
+		all.AddRange(1, 0)
+		messages, err := backend.client.Fetch(all, &imap.FetchOptions{Envelope: true, Flags: true}).Collect()
+		if err != nil {
+			return err
+		}
+		for _, message := range messages {
+			subject := message.Envelope.Subject
+			for _, flag := range message.Flags {
+				if flag == imap.FlagDeleted {
+					subject += " (deleted)"
+				}
+			}
+			found = append(found, subject)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return found
+}
+
+// flagDeleted flags a message as deleted without expunging it, like another client would.
+func flagDeleted(t *testing.T, backend *ImapBackend, uid imap.UID) {
+	t.Helper()
+	err := backend.client.Store(imap.UIDSetNum(uid), &imap.StoreFlags{
+		Op:    imap.StoreFlagsAdd,
+		Flags: []imap.Flag{imap.FlagDeleted},
+	}, nil).Close()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestMoveEmails_Move(t *testing.T) {
+	backend := newTestBackend(t, imap.CapSet{imap.CapIMAP4rev1: {}, imap.CapUIDPlus: {}, imap.CapMove: {}}, 2)
+
+	result, err := backend.MoveEmails([]core.EmailId{"1"}, "Archive")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if result.Mailbox != "Archive" || result.Ids["1"] != "1" {
+		t.Errorf("Expected the message's id in the archive, got %+v", result)
+	}
+	if got := strings.Join(subjects(t, backend, inbox), ", "); got != "Message 2" {
+		t.Errorf("Expected only the other message left in the inbox, got %q", got)
+	}
+	if got := strings.Join(subjects(t, backend, "Archive"), ", "); got != "Message 1" {
+		t.Errorf("Expected the message in the archive, got %q", got)
+	}
+}
+
+func TestMoveEmails_CopyAndExpungeOnlyThese(t *testing.T) {
+	backend := newTestBackend(t, imap.CapSet{imap.CapIMAP4rev1: {}, imap.CapUIDPlus: {}}, 3)
+	flagDeleted(t, backend, 3)
+
+	result, err := backend.MoveEmails([]core.EmailId{"1"}, "Archive")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if result.Ids["1"] != "1" {
+		t.Errorf("Expected the copy's id from COPYUID, got %+v", result)
+	}
+	if got := strings.Join(subjects(t, backend, inbox), ", "); got != "Message 2, Message 3 (deleted)" {
+		t.Errorf("Expected UID EXPUNGE to leave the other deleted message alone, got %q", got)
+	}
+	if got := strings.Join(subjects(t, backend, "Archive"), ", "); got != "Message 1" {
+		t.Errorf("Expected the message in the archive, got %q", got)
+	}
+}
+
+func TestMoveEmails_CopyAndExpungeWithoutUidPlus(t *testing.T) {
+	backend := newTestBackend(t, imap.CapSet{imap.CapIMAP4rev1: {}}, 2)
+
+	if _, err := backend.MoveEmails([]core.EmailId{"1"}, "Archive"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := strings.Join(subjects(t, backend, inbox), ", "); got != "Message 2" {
+		t.Errorf("Expected only the other message left in the inbox, got %q", got)
+	}
+	if got := strings.Join(subjects(t, backend, "Archive"), ", "); got != "Message 1" {
+		t.Errorf("Expected the message in the archive, got %q", got)
+	}
+}
+
+func TestMoveEmails_RefusesToExpungeOthers(t *testing.T) {
+	backend := newTestBackend(t, imap.CapSet{imap.CapIMAP4rev1: {}}, 3)
+	flagDeleted(t, backend, 3)
+
+	_, err := backend.MoveEmails([]core.EmailId{"1"}, "Archive")
+	if err == nil || !strings.Contains(err.Error(), "1 other messages are already flagged as deleted") {
+		t.Fatalf("Expected the move to be refused, got %v", err)
+	}
+
+	if got := strings.Join(subjects(t, backend, inbox), ", "); got != "Message 1, Message 2, Message 3 (deleted)" {
+		t.Errorf("Expected the inbox to be left alone, got %q", got)
+	}
+	if got := subjects(t, backend, "Archive"); len(got) != 0 {
+		t.Errorf("Expected nothing to be copied, got %q", got)
+	}
+}
+
+func TestDeleteEmails_RefusesToExpungeOthers(t *testing.T) {
+	backend := newTestBackend(t, imap.CapSet{imap.CapIMAP4rev1: {}}, 2)
+	flagDeleted(t, backend, 2)
+
+	// there's no trash mailbox, so the message would be expunged
+	if _, err := backend.DeleteEmails([]core.EmailId{"1"}); err == nil {
+		t.Fatal("Expected the delete to be refused")
+	}
+
+	if got := strings.Join(subjects(t, backend, inbox), ", "); got != "Message 1, Message 2 (deleted)" {
+		t.Errorf("Expected the inbox to be left alone, got %q", got)
+	}
+}
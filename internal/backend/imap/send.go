@@ -0,0 +1,131 @@
+package imap
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/message"
+	"github.com/bengesoff/mail-tui/internal/pgp"
+	"github.com/bengesoff/mail-tui/internal/smime"
+)
+
+// SendEmail submits the email over SMTP, using the server of the sender's identity if it has one, then appends exactly
+// the message sent to the To and Cc recipients to the sent mailbox.
+// If the email was sent but saving the copy failed, a *core.SaveSentError is returned.
+func (b *ImapBackend) SendEmail(email core.OutgoingEmail) error {
+	if email.From == "" {
+		email.From = b.from
+	}
+	sender := b.config.Smtp
+	if identitySender, ok := b.config.Identities.SenderFor(email.From); ok {
+		sender = identitySender
+	}
+	if sender == nil {
+		return errors.New("no SMTP server has been configured for sending emails")
+	}
+
+	if email.MessageId == "" {
+		email.MessageId = message.GenerateMessageId(email.From)
+	}
+	from, recipients, err := message.Envelope(email)
+	if err != nil {
+		return err
+	}
+	raw, err := message.Build(email, time.Now())
+	if err != nil {
+		return err
+	}
+	copies, err := b.protect(email, raw, from, recipients)
+	if err != nil {
+		return err
+	}
+
+	for _, outgoing := range copies {
+		if len(outgoing.recipients) == 0 {
+			continue
+		}
+		if err := sender.Send(from, outgoing.recipients, outgoing.raw); err != nil {
+			return err
+		}
+	}
+
+	if b.config.SkipSaveSent {
+		return nil
+	}
+	if err := b.saveSent(copies[0].raw); err != nil {
+		return &core.SaveSentError{Err: err}
+	}
+	return nil
+}
+
+// outgoingCopy is a copy of an email and who it's sent to.
+type outgoingCopy struct {
+	recipients []string
+	raw        []byte
+}
+
+// protect signs or encrypts an email as it asks, returning the copies to send, where the first one is also saved.
+// Everyone an OpenPGP email is encrypted to can see which keys it was encrypted to, so each Bcc recipient is sent their
+// own copy, which isn't encrypted to anyone else's key but the sender's.
+// The copy that's saved is protected too, and encrypted emails are encrypted to the sender so it can be read.
+func (b *ImapBackend) protect(email core.OutgoingEmail, raw []byte, from string, recipients []string) ([]outgoingCopy, error) {
+	if email.SignSMIME {
+		if email.OpenPGP.Enabled() {
+			return nil, errors.New("an email can't be protected with both OpenPGP and S/MIME")
+		}
+		signed, err := smime.Sign(raw, b.config.SMIME, from)
+		if err != nil {
+			return nil, err
+		}
+		return []outgoingCopy{{recipients: recipients, raw: signed}}, nil
+	}
+
+	var bcc []string
+	if email.OpenPGP.Encrypt {
+		var err error
+		if bcc, err = message.Addresses(email.Bcc); err != nil {
+			return nil, err
+		}
+		recipients = slices.DeleteFunc(slices.Clone(recipients), func(recipient string) bool {
+			return slices.Contains(bcc, recipient)
+		})
+	}
+
+	protected, err := pgp.Protect(raw, b.config.Keyring, from, recipients, email.OpenPGP)
+	if err != nil {
+		return nil, err
+	}
+	copies := []outgoingCopy{{recipients: recipients, raw: protected}}
+	for _, recipient := range bcc {
+		protected, err := pgp.Protect(raw, b.config.Keyring, from, []string{recipient}, email.OpenPGP)
+		if err != nil {
+			return nil, err
+		}
+		copies = append(copies, outgoingCopy{recipients: []string{recipient}, raw: protected})
+	}
+	return copies, nil
+}
+
+// saveSent appends a sent message to the sent mailbox, marked as read.
+func (b *ImapBackend) saveSent(raw []byte) error {
+	mailbox := b.config.SentMailbox
+	if mailbox == "" {
+		mailboxes, err := b.ListMailboxes()
+		if err != nil {
+			return err
+		}
+		sent, ok := core.FindMailbox(mailboxes, core.MailboxRoleSent)
+		if !ok {
+			return fmt.Errorf("couldn't find a sent mailbox")
+		}
+		mailbox = sent.Name
+	}
+
+	_, err := b.append(mailbox, raw, []imap.Flag{imap.FlagSeen}, time.Now())
+	return err
+}
@@ -0,0 +1,110 @@
+package imap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/message"
+	"github.com/bengesoff/mail-tui/internal/pgp"
+)
+
+func newEntity(t *testing.T, name, address string) *openpgp.Entity {
+	t.Helper()
+	entity, err := openpgp.NewEntity(name, "", address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entity
+}
+
+// encryptedTo lists the IDs of the keys an encrypted email was encrypted to.
+func encryptedTo(t *testing.T, raw []byte) []uint64 {
+	t.Helper()
+	start := bytes.Index(raw, []byte("-----BEGIN PGP MESSAGE-----"))
+	if start == -1 {
+		t.Fatalf("Expected an encrypted email, got %s", raw)
+	}
+	block, err := armor.Decode(bytes.NewReader(raw[start:]))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var keyIds []uint64
+	packets := packet.NewReader(block.Body)
+	for {
+		p, err := packets.Next()
+		if errors.Is(err, io.EOF) {
+			return keyIds
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		encryptedKey, ok := p.(*packet.EncryptedKey)
+		if !ok {
+			return keyIds
+		}
+		keyIds = append(keyIds, encryptedKey.KeyId)
+	}
+}
+
+// encryptionKeyId is the ID of the subkey emails are encrypted to.
+func encryptionKeyId(t *testing.T, entity *openpgp.Entity) uint64 {
+	t.Helper()
+	key, ok := entity.EncryptionKey(time.Now())
+	if !ok {
+		t.Fatal("Expected an encryption key")
+	}
+	return key.PublicKey.KeyId
+}
+
+func TestProtect_BccGetsOwnCopy(t *testing.T) {
+	alice, bob, carol := newEntity(t, "Alice", "alice@example.com"), newEntity(t, "Bob", "bob@example.com"),
+		newEntity(t, "Carol", "carol@example.com")
+	backend := &ImapBackend{config: Config{Keyring: pgp.NewKeyring(alice, bob, carol)}}
+	email := core.OutgoingEmail{
+		From:      "alice@example.com",
+		To:        "bob@example.com",
+		Bcc:       "carol@example.com",
+		Subject:   "Launch codes",
+		Body:      "The code is 1234.",
+		MessageId: "launch@example.com",
+		OpenPGP:   core.Protection{Encrypt: true},
+	}
+	from, recipients, err := message.Envelope(email)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	raw, err := message.Build(email, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	copies, err := backend.protect(email, raw, from, recipients)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(copies) != 2 {
+		t.Fatalf("Expected a copy for Bob and one for Carol, got %d", len(copies))
+	}
+	if len(copies[0].recipients) != 1 || copies[0].recipients[0] != "bob@example.com" {
+		t.Errorf("Expected the first copy to go to Bob, got %v", copies[0].recipients)
+	}
+	if keyIds := encryptedTo(t, copies[0].raw); slices.Contains(keyIds, encryptionKeyId(t, carol)) {
+		t.Errorf("Expected Bob's copy not to name Carol's key, got %x", keyIds)
+	}
+	if len(copies[1].recipients) != 1 || copies[1].recipients[0] != "carol@example.com" {
+		t.Errorf("Expected the second copy to go to Carol, got %v", copies[1].recipients)
+	}
+	if keyIds := encryptedTo(t, copies[1].raw); !slices.Equal(keyIds, []uint64{encryptionKeyId(t, alice), encryptionKeyId(t, carol)}) {
+		t.Errorf("Expected Carol's copy to be encrypted to Alice and her, got %x", keyIds)
+	}
+}
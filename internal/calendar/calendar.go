@@ -0,0 +1,247 @@
+// Package calendar reads the meeting invitations sent in emails as iCalendar objects (RFC 5545), and writes the
+// replies that accept or decline them (iTIP, RFC 5546).
+package calendar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+// Methods say what an iCalendar object sent by email is for.
+const (
+	MethodRequest = "REQUEST"
+	MethodReply   = "REPLY"
+	MethodCancel  = "CANCEL"
+)
+
+// PartStat is whether an attendee is going to an event.
+type PartStat string
+
+const (
+	NeedsAction PartStat = "NEEDS-ACTION"
+	Accepted    PartStat = "ACCEPTED"
+	Tentative   PartStat = "TENTATIVE"
+	Declined    PartStat = "DECLINED"
+)
+
+// Describe says what an attendee's status means, like "accepted" or "hasn't replied".
+func (s PartStat) Describe() string {
+	switch s {
+	case Accepted, Tentative, Declined:
+		return strings.ToLower(string(s))
+	case NeedsAction, "":
+		return "hasn't replied"
+	}
+	return strings.ToLower(string(s))
+}
+
+// Attendee is someone invited to an event, or the person who organised it.
+type Attendee struct {
+	Name    string
+	Address string
+	Status  PartStat
+	// Role is like "REQ-PARTICIPANT" for people who need to be there and "OPT-PARTICIPANT" for people who don't
+	Role string
+}
+
+// String formats the attendee like an email address, "Alice <alice@example.com>".
+func (a Attendee) String() string {
+	if a.Name == "" {
+		return a.Address
+	}
+	return fmt.Sprintf("%s <%s>", a.Name, a.Address)
+}
+
+// Invitation is an event from an iCalendar object, with its times in the local time zone.
+type Invitation struct {
+	// Method is what the object is for, like MethodRequest for an invitation or MethodCancel when it's called off
+	Method      string
+	UID         string
+	Summary     string
+	Location    string
+	Description string
+	Organizer   Attendee
+	Attendees   []Attendee
+	Start       time.Time
+	End         time.Time
+	// AllDay is set for events that take up whole days rather than having times
+	AllDay bool
+	// Recurrence describes how the event repeats, like "Every week on Monday", and is empty if it doesn't
+	Recurrence string
+	// Cancelled is set when the event has been called off
+	Cancelled bool
+
+	event     *ical.Event
+	timezones []*ical.Component
+}
+
+// Parse reads the events in an iCalendar object, with their times converted to loc.
+func Parse(data []byte, loc *time.Location) ([]Invitation, error) {
+	cal, err := ical.NewDecoder(bytes.NewReader(data)).Decode()
+	if err != nil {
+		return nil, err
+	}
+	method, _ := cal.Props.Text(ical.PropMethod)
+
+	var timezones []*ical.Component
+	for _, child := range cal.Children {
+		if child.Name == ical.CompTimezone {
+			timezones = append(timezones, child)
+		}
+	}
+
+	var invitations []Invitation
+	for _, event := range cal.Events() {
+		invitation := Invitation{
+			Method:    strings.ToUpper(method),
+			event:     &event,
+			timezones: timezones,
+		}
+		invitation.UID, _ = event.Props.Text(ical.PropUID)
+		invitation.Summary, _ = event.Props.Text(ical.PropSummary)
+		invitation.Location, _ = event.Props.Text(ical.PropLocation)
+		invitation.Description, _ = event.Props.Text(ical.PropDescription)
+		if status, err := event.Status(); err == nil {
+			invitation.Cancelled = status == ical.EventCancelled
+		}
+		if organizer := event.Props.Get(ical.PropOrganizer); organizer != nil {
+			invitation.Organizer = attendee(organizer)
+		}
+		for _, prop := range event.Props.Values(ical.PropAttendee) {
+			invitation.Attendees = append(invitation.Attendees, attendee(&prop))
+		}
+
+		start := event.Props.Get(ical.PropDateTimeStart)
+		if start == nil {
+			return nil, fmt.Errorf("event %q has no start time", invitation.Summary)
+		}
+		invitation.AllDay = start.ValueType() == ical.ValueDate || len(start.Value) == len("20060102")
+		if invitation.Start, err = dateTime(start, loc); err != nil {
+			return nil, err
+		}
+		if end := event.Props.Get(ical.PropDateTimeEnd); end != nil {
+			if invitation.End, err = dateTime(end, loc); err != nil {
+				return nil, err
+			}
+		} else if invitation.End, err = event.DateTimeEnd(loc); err != nil {
+			return nil, err
+		}
+		invitation.End = invitation.End.In(loc)
+
+		rule, err := event.Props.RecurrenceRule()
+		if err != nil {
+			return nil, err
+		}
+		invitation.Recurrence = describeRecurrence(rule, loc)
+
+		invitations = append(invitations, invitation)
+	}
+	if len(invitations) == 0 {
+		return nil, errors.New("there are no events in the calendar")
+	}
+	return invitations, nil
+}
+
+// dateTime reads a time in the local time zone. Outlook names time zones the way Windows does, like "GMT Standard
+// Time", which Go doesn't know, so those times are taken to be local rather than failing.
+func dateTime(prop *ical.Prop, loc *time.Location) (time.Time, error) {
+	if prop.ValueType() == ical.ValueDate || len(prop.Value) == len("20060102") {
+		// dates are the same day wherever you are, so they aren't converted
+		return time.ParseInLocation("20060102", prop.Value, loc)
+	}
+	t, err := prop.DateTime(loc)
+	if err != nil && prop.Params.Get(ical.PropTimezoneID) != "" {
+		floating := *prop
+		floating.Params = ical.Params{}
+		t, err = floating.DateTime(loc)
+	}
+	return t.In(loc), err
+}
+
+func attendee(prop *ical.Prop) Attendee {
+	address := prop.Value
+	if len(address) > len("mailto:") && strings.EqualFold(address[:len("mailto:")], "mailto:") {
+		address = address[len("mailto:"):]
+	}
+	return Attendee{
+		Name:    prop.Params.Get(ical.ParamCommonName),
+		Address: address,
+		Status:  PartStat(strings.ToUpper(prop.Params.Get(ical.ParamParticipationStatus))),
+		Role:    strings.ToUpper(prop.Params.Get(ical.ParamRole)),
+	}
+}
+
+// NeedsReply reports whether the invitation asks the attendees whether they're going.
+func (i Invitation) NeedsReply() bool {
+	return i.Method == MethodRequest && !i.Cancelled
+}
+
+// FindAttendee finds the first of the addresses that was invited, ignoring case.
+func (i Invitation) FindAttendee(addresses ...string) (Attendee, bool) {
+	for _, address := range addresses {
+		for _, attendee := range i.Attendees {
+			if strings.EqualFold(attendee.Address, address) {
+				return attendee, true
+			}
+		}
+	}
+	return Attendee{}, false
+}
+
+// Reply writes the iTIP reply saying whether the attendee with the address is going, to be sent to the organiser.
+// It only has the properties that identify the event and the attendee's answer, as RFC 5546 asks for.
+func (i Invitation) Reply(address string, status PartStat, now time.Time) ([]byte, error) {
+	if i.event == nil {
+		return nil, errors.New("the invitation wasn't read from a calendar")
+	}
+	var attendeeProp *ical.Prop
+	for _, prop := range i.event.Props.Values(ical.PropAttendee) {
+		if strings.EqualFold(attendee(&prop).Address, address) {
+			attendeeProp = &prop
+			break
+		}
+	}
+	if attendeeProp == nil {
+		return nil, fmt.Errorf("%s wasn't invited to %q", address, i.Summary)
+	}
+
+	event := ical.NewEvent()
+	for _, name := range []string{
+		ical.PropUID, ical.PropSequence, ical.PropRecurrenceID, ical.PropOrganizer, ical.PropSummary,
+		ical.PropDateTimeStart, ical.PropDateTimeEnd, ical.PropDuration,
+	} {
+		if prop := i.event.Props.Get(name); prop != nil {
+			event.Props.Set(prop)
+		}
+	}
+	event.Props.SetDateTime(ical.PropDateTimeStamp, now.UTC())
+
+	reply := ical.Prop{Name: ical.PropAttendee, Value: attendeeProp.Value, Params: ical.Params{}}
+	for name, values := range attendeeProp.Params {
+		// the RSVP request is only for the invitation
+		if name != ical.ParamRSVP {
+			reply.Params[name] = values
+		}
+	}
+	reply.Params.Set(ical.ParamParticipationStatus, string(status))
+	event.Props.Set(&reply)
+
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropProductID, "-//mail-tui//EN")
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropMethod, MethodReply)
+	// the times are in the invitation's time zones, so their definitions go along with them
+	cal.Children = append(cal.Children, i.timezones...)
+	cal.Children = append(cal.Children, event.Component)
+
+	var b bytes.Buffer
+	if err := ical.NewEncoder(&b).Encode(cal); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,164 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const invitation = "BEGIN:VCALENDAR\r\n" +
+	"PRODID:-//Example//Calendar//EN\r\n" +
+	"VERSION:2.0\r\n" +
+	"METHOD:REQUEST\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:Europe/London\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:19701025T020000\r\n" +
+	"TZOFFSETFROM:+0100\r\n" +
+	"TZOFFSETTO:+0000\r\n" +
+	"END:STANDARD\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:standup@example.com\r\n" +
+	"SEQUENCE:2\r\n" +
+	"DTSTAMP:20250101T090000Z\r\n" +
+	"DTSTART;TZID=Europe/London:20250106T093000\r\n" +
+	"DTEND;TZID=Europe/London:20250106T094500\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=10\r\n" +
+	"SUMMARY:Standup\r\n" +
+	"LOCATION:Room 1\\, second floor\r\n" +
+	"ORGANIZER;CN=Alice:mailto:alice@example.com\r\n" +
+	"ATTENDEE;CN=Bob;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:bob@example.com\r\n" +
+	"ATTENDEE;CN=Carol;ROLE=OPT-PARTICIPANT;PARTSTAT=ACCEPTED:MAILTO:carol@example.com\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParse(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	invitations, err := Parse([]byte(invitation), tokyo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invitations) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(invitations))
+	}
+	event := invitations[0]
+
+	if event.Method != MethodRequest || !event.NeedsReply() {
+		t.Errorf("expected an invitation that needs a reply, got method %q", event.Method)
+	}
+	if event.Summary != "Standup" || event.Location != "Room 1, second floor" {
+		t.Errorf("unexpected summary %q or location %q", event.Summary, event.Location)
+	}
+	if event.Organizer.String() != "Alice <alice@example.com>" {
+		t.Errorf("unexpected organiser %q", event.Organizer)
+	}
+	// 9:30 in London in January is 18:30 in Tokyo
+	if got := event.Start.Format("2006-01-02 15:04 MST"); got != "2025-01-06 18:30 JST" {
+		t.Errorf("expected the start in Tokyo time, got %s", got)
+	}
+	if got := event.End.Sub(event.Start); got != 15*time.Minute {
+		t.Errorf("expected the event to last 15 minutes, got %s", got)
+	}
+	if event.Recurrence != "Every week on Monday, Wednesday and Friday, 10 times" {
+		t.Errorf("unexpected recurrence %q", event.Recurrence)
+	}
+
+	if len(event.Attendees) != 2 {
+		t.Fatalf("expected 2 attendees, got %+v", event.Attendees)
+	}
+	carol, ok := event.FindAttendee("someone@example.com", "CAROL@example.com")
+	if !ok || carol.Status != Accepted || carol.Role != "OPT-PARTICIPANT" {
+		t.Errorf("expected to find Carol, who accepted, got %+v", carol)
+	}
+}
+
+func TestParse_AllDayAndUnknownTimeZone(t *testing.T) {
+	data := strings.NewReplacer(
+		"DTSTART;TZID=Europe/London:20250106T093000", "DTSTART;VALUE=DATE:20250106",
+		"DTEND;TZID=Europe/London:20250106T094500", "DTEND;TZID=GMT Standard Time:20250107T094500",
+	).Replace(invitation)
+
+	invitations, err := Parse([]byte(data), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := invitations[0]
+	if !event.AllDay || event.Start.Format("2006-01-02 15:04") != "2025-01-06 00:00" {
+		t.Errorf("expected an all-day event on 6 January, got %s", event.Start)
+	}
+	// Windows time zone names aren't known, so the time is taken to be local
+	if event.End.Format("15:04") != "09:45" {
+		t.Errorf("expected the end to be read as a local time, got %s", event.End)
+	}
+}
+
+func TestReply(t *testing.T) {
+	invitations, err := Parse([]byte(invitation), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := invitations[0].Reply("BOB@example.com", Tentative, time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := string(data)
+	for _, want := range []string{
+		"METHOD:REPLY\r\n",
+		"UID:standup@example.com\r\n",
+		"SEQUENCE:2\r\n",
+		"DTSTAMP:20250102T100000Z\r\n",
+		"DTSTART;TZID=Europe/London:20250106T093000\r\n",
+		"ORGANIZER;CN=Alice:mailto:alice@example.com\r\n",
+		"BEGIN:VTIMEZONE\r\n",
+	} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("expected the reply to contain %q, got:\n%s", want, reply)
+		}
+	}
+	if !strings.Contains(reply, "PARTSTAT=TENTATIVE") || strings.Contains(reply, "RSVP") {
+		t.Errorf("expected Bob's attendance to be tentative without an RSVP request, got:\n%s", reply)
+	}
+	if strings.Contains(reply, "carol@example.com") || strings.Contains(reply, "RRULE") {
+		t.Errorf("expected only Bob's answer, got:\n%s", reply)
+	}
+
+	// the reply can be read back, as the organiser's client would
+	replies, err := Parse(data, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bob, ok := replies[0].FindAttendee("bob@example.com"); !ok || bob.Status != Tentative {
+		t.Errorf("expected Bob to be tentative, got %+v", replies[0].Attendees)
+	}
+
+	if _, err := invitations[0].Reply("mallory@example.com", Accepted, time.Now()); err == nil {
+		t.Error("expected an error replying as someone who wasn't invited")
+	}
+}
+
+func TestDescribeRecurrence(t *testing.T) {
+	tests := map[string]string{
+		"FREQ=DAILY":                                  "Every day",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=TH":             "Every 2 weeks on Thursday",
+		"FREQ=MONTHLY;BYDAY=-1FR":                     "Every month on the last Friday",
+		"FREQ=MONTHLY;BYMONTHDAY=1,22":                "Every month on the 1st and 22nd",
+		"FREQ=YEARLY;UNTIL=20301231T000000Z":          "Every year until Tue 31 Dec 2030",
+		"FREQ=WEEKLY;BYDAY=MO;COUNT=1":                "Every week on Monday, once",
+		"FREQ=MONTHLY;BYMONTHDAY=11,12,13;BYSETPOS=1": "Every month on the 11th, 12th and 13th",
+	}
+	for rule, want := range tests {
+		data := strings.Replace(invitation, "FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=10", rule, 1)
+		invitations, err := Parse([]byte(data), time.UTC)
+		if err != nil {
+			t.Fatalf("%s: %v", rule, err)
+		}
+		if got := invitations[0].Recurrence; got != want {
+			t.Errorf("%s: expected %q, got %q", rule, want, got)
+		}
+	}
+}
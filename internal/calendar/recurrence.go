@@ -0,0 +1,98 @@
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+var (
+	frequencyUnits = map[rrule.Frequency]string{
+		rrule.YEARLY:   "year",
+		rrule.MONTHLY:  "month",
+		rrule.WEEKLY:   "week",
+		rrule.DAILY:    "day",
+		rrule.HOURLY:   "hour",
+		rrule.MINUTELY: "minute",
+		rrule.SECONDLY: "second",
+	}
+
+	// weekdays are in the order rrule numbers them, from Monday
+	weekdays = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+
+	ordinals = map[int]string{1: "first", 2: "second", 3: "third", 4: "fourth", 5: "fifth", -1: "last"}
+)
+
+// describeRecurrence puts a recurrence rule into words, like "Every 2 weeks on Monday and Thursday, 10 times". Rules
+// with parts that aren't described, like BYSETPOS, still get their frequency described.
+func describeRecurrence(rule *rrule.ROption, loc *time.Location) string {
+	if rule == nil {
+		return ""
+	}
+	unit, ok := frequencyUnits[rule.Freq]
+	if !ok {
+		return "Repeats"
+	}
+
+	description := "Every " + unit
+	if rule.Interval > 1 {
+		description = fmt.Sprintf("Every %d %ss", rule.Interval, unit)
+	}
+
+	if len(rule.Byweekday) > 0 {
+		var days []string
+		for _, day := range rule.Byweekday {
+			name := weekdays[day.Day()]
+			if ordinal, ok := ordinals[day.N()]; ok {
+				name = "the " + ordinal + " " + name
+			}
+			days = append(days, name)
+		}
+		description += " on " + joinWords(days)
+	} else if len(rule.Bymonthday) > 0 {
+		var days []string
+		for _, day := range rule.Bymonthday {
+			days = append(days, ordinalDay(day))
+		}
+		description += " on the " + joinWords(days)
+	}
+
+	switch {
+	case rule.Count > 0:
+		if rule.Count == 1 {
+			description += ", once"
+		} else {
+			description += fmt.Sprintf(", %d times", rule.Count)
+		}
+	case !rule.Until.IsZero():
+		description += " until " + rule.Until.In(loc).Format("Mon 2 Jan 2006")
+	}
+	return description
+}
+
+func ordinalDay(day int) string {
+	if day == -1 {
+		return "last day"
+	}
+	suffix := "th"
+	switch {
+	case day%100 >= 11 && day%100 <= 13:
+	case day%10 == 1:
+		suffix = "st"
+	case day%10 == 2:
+		suffix = "nd"
+	case day%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", day, suffix)
+}
+
+// joinWords lists words like "Monday, Wednesday and Friday".
+func joinWords(words []string) string {
+	if len(words) == 1 {
+		return words[0]
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " and " + words[len(words)-1]
+}
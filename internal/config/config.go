@@ -0,0 +1,43 @@
+// Package config works out where the app keeps its files.
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const appName = "mail-tui"
+
+// DataDir returns the directory for data the app keeps between runs, such as drafts, creating it if needed.
+// It follows the XDG base directory specification, so it's usually ~/.local/share/mail-tui.
+func DataDir() (string, error) {
+	base := os.Getenv("XDG_DATA_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".local", "share")
+	}
+
+	dir := filepath.Join(base, appName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// ConfigDir returns the directory for settings the user writes, such as identities, which isn't created since the app
+// only reads from it.
+// It follows the XDG base directory specification, so it's usually ~/.config/mail-tui.
+func ConfigDir() (string, error) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, appName), nil
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDataDir(t *testing.T) {
+	base := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", base)
+
+	dir, err := DataDir()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if dir != filepath.Join(base, "mail-tui") {
+		t.Errorf("Expected the directory to be inside XDG_DATA_HOME, got '%s'", dir)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Error("Expected the directory to be created")
+	}
+}
+
+func TestConfigDir(t *testing.T) {
+	base := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", base)
+
+	dir, err := ConfigDir()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if dir != filepath.Join(base, "mail-tui") {
+		t.Errorf("Expected the directory to be inside XDG_CONFIG_HOME, got '%s'", dir)
+	}
+}
@@ -0,0 +1,247 @@
+// Package contacts keeps an address book of the people emails are sent to and received from, which is used to
+// suggest addresses while writing emails.
+package contacts
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-message/mail"
+	"github.com/sahilm/fuzzy"
+)
+
+// recencyHalfLife is how long it takes for a contact's emails to count for half as much when ranking suggestions.
+const recencyHalfLife = 30 * 24 * time.Hour
+
+var ErrNotFound = errors.New("contact not found")
+
+type Contact struct {
+	Name    string
+	Address string
+	// Count is how many emails have been sent to or received from the contact
+	Count int
+	// LastSeen is when the most recent of those emails was sent
+	LastSeen time.Time
+	// Edited is set once the contact has been edited or imported, so the name isn't replaced by the one in later emails
+	Edited bool
+}
+
+// String formats the contact as an address for the To field, like "Alice Smith <alice@example.com>".
+func (c Contact) String() string {
+	if c.Name == "" {
+		return c.Address
+	}
+	return (&mail.Address{Name: c.Name, Address: c.Address}).String()
+}
+
+// rank scores the contact higher the more emails there have been, with recent ones counting for more.
+func (c Contact) rank(now time.Time) float64 {
+	age := now.Sub(c.LastSeen)
+	if age < 0 {
+		age = 0
+	}
+	return float64(c.Count) / (1 + float64(age)/float64(recencyHalfLife))
+}
+
+// Book stores the contacts in a JSON file.
+type Book struct {
+	path string
+	mu   sync.Mutex
+	// contacts is keyed by the lowercase address, since addresses aren't case-sensitive in practice
+	contacts map[string]*Contact
+}
+
+// Open reads the address book from a file, which is created when the book is first saved.
+func Open(path string) (*Book, error) {
+	book := &Book{
+		path:     path,
+		contacts: map[string]*Contact{},
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return book, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var contacts []Contact
+	if err := json.Unmarshal(data, &contacts); err != nil {
+		return nil, err
+	}
+	for _, contact := range contacts {
+		book.contacts[key(contact.Address)] = &contact
+	}
+	return book, nil
+}
+
+// Save writes the address book to its file.
+func (b *Book) Save() error {
+	b.mu.Lock()
+	data, err := json.Marshal(b.list())
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(b.path), 0o700); err != nil {
+		return err
+	}
+	// written to a temporary file first, so a crash while saving leaves the previous version intact
+	file, err := os.CreateTemp(filepath.Dir(b.path), ".contacts-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), b.path)
+}
+
+// Record counts an email sent to or received from an address at the given time, adding the contact if it's new.
+// Emails from before the contact was last seen are ignored, so the same emails can be recorded again without counting
+// them twice.
+func (b *Book) Record(name, address string, at time.Time) {
+	if address == "" {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	contact, ok := b.contacts[key(address)]
+	if !ok {
+		b.contacts[key(address)] = &Contact{Name: name, Address: address, Count: 1, LastSeen: at}
+		return
+	}
+	if !at.After(contact.LastSeen) {
+		return
+	}
+	contact.Count++
+	contact.LastSeen = at
+	if name != "" && !contact.Edited {
+		contact.Name = name
+	}
+}
+
+// List returns every contact, ranked by how often and recently they've been emailed.
+func (b *Book) List() []Contact {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.list()
+}
+
+func (b *Book) list() []Contact {
+	now := time.Now()
+	contacts := make([]Contact, 0, len(b.contacts))
+	for _, contact := range b.contacts {
+		contacts = append(contacts, *contact)
+	}
+	slices.SortFunc(contacts, func(a, c Contact) int {
+		return compareRank(a, c, now)
+	})
+	return contacts
+}
+
+// Update replaces the contact with the given address, which can change the address too.
+func (b *Book) Update(address string, contact Contact) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.contacts[key(address)]; !ok {
+		return ErrNotFound
+	}
+	delete(b.contacts, key(address))
+	contact.Edited = true
+	b.contacts[key(contact.Address)] = &contact
+	return nil
+}
+
+// Delete removes a contact. It's added again if another email is sent to or received from it.
+func (b *Book) Delete(address string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.contacts[key(address)]; !ok {
+		return ErrNotFound
+	}
+	delete(b.contacts, key(address))
+	return nil
+}
+
+// Suggest returns up to limit contacts that fuzzily match the query, like "asmi" for "Alice Smith".
+// Contacts with a name or address starting with the query come first, then they're ranked by how often and recently
+// they've been emailed.
+func (b *Book) Suggest(query string, limit int) []Contact {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil
+	}
+	contacts := b.List()
+
+	targets := make([]string, len(contacts))
+	for i, contact := range contacts {
+		targets[i] = contact.Name + " " + contact.Address
+	}
+
+	var prefixed, others []Contact
+	for _, match := range fuzzy.Find(query, targets) {
+		contact := contacts[match.Index]
+		if hasWordPrefix(contact, query) {
+			prefixed = append(prefixed, contact)
+		} else {
+			others = append(others, contact)
+		}
+	}
+
+	now := time.Now()
+	for _, group := range [][]Contact{prefixed, others} {
+		slices.SortStableFunc(group, func(a, c Contact) int {
+			return compareRank(a, c, now)
+		})
+	}
+	suggestions := append(prefixed, others...)
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// compareRank sorts higher ranked contacts first, and then alphabetically by address so the order is stable.
+func compareRank(a, b Contact, now time.Time) int {
+	rankA, rankB := a.rank(now), b.rank(now)
+	switch {
+	case rankA > rankB:
+		return -1
+	case rankA < rankB:
+		return 1
+	default:
+		return strings.Compare(a.Address, b.Address)
+	}
+}
+
+// hasWordPrefix reports whether any word of the contact's name, or its address, starts with the query.
+func hasWordPrefix(contact Contact, query string) bool {
+	query = strings.ToLower(query)
+	words := append(strings.Fields(strings.ToLower(contact.Name)), strings.ToLower(contact.Address))
+	return slices.ContainsFunc(words, func(word string) bool {
+		return strings.HasPrefix(word, query)
+	})
+}
+
+func key(address string) string {
+	return strings.ToLower(address)
+}
@@ -0,0 +1,151 @@
+package contacts
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newBook(t *testing.T) *Book {
+	book, err := Open(filepath.Join(t.TempDir(), "contacts.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return book
+}
+
+func addresses(contacts []Contact) []string {
+	var result []string
+	for _, contact := range contacts {
+		result = append(result, contact.Address)
+	}
+	return result
+}
+
+func TestBook_ContactsSurviveReopening(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.json")
+	first, _ := Open(path)
+	first.Record("Alice Smith", "alice@example.com", time.Now())
+	if err := first.Save(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	second, err := Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	contacts := second.List()
+	if len(contacts) != 1 || contacts[0].Name != "Alice Smith" || contacts[0].Count != 1 {
+		t.Errorf("Expected the contact to be read back, got %+v", contacts)
+	}
+}
+
+func TestBook_RecordIgnoresEmailsAlreadySeen(t *testing.T) {
+	book := newBook(t)
+	sentAt := time.Now().Add(-time.Hour)
+
+	book.Record("Alice", "alice@example.com", sentAt)
+	book.Record("Alice", "ALICE@example.com", sentAt)
+	book.Record("Alice Smith", "alice@example.com", sentAt.Add(time.Minute))
+
+	contacts := book.List()
+	if len(contacts) != 1 {
+		t.Fatalf("Expected addresses to be case-insensitive, got %+v", contacts)
+	}
+	if contacts[0].Count != 2 || contacts[0].Name != "Alice Smith" {
+		t.Errorf("Expected 2 emails and the latest name, got %+v", contacts[0])
+	}
+}
+
+func TestBook_RecordKeepsEditedNames(t *testing.T) {
+	book := newBook(t)
+	book.Record("alice", "alice@example.com", time.Now().Add(-time.Hour))
+	if err := book.Update("alice@example.com", Contact{Name: "Alice Smith", Address: "alice@example.com"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	book.Record("alice", "alice@example.com", time.Now())
+
+	if name := book.List()[0].Name; name != "Alice Smith" {
+		t.Errorf("Expected the edited name to be kept, got %q", name)
+	}
+}
+
+func TestBook_SuggestRanksByFrequencyAndRecency(t *testing.T) {
+	book := newBook(t)
+	now := time.Now()
+	// alex has emailed more often, but a long time ago
+	for i := range 5 {
+		book.Record("Alex Old", "alex@example.com", now.AddDate(0, -12, i))
+	}
+	book.Record("Alexandra New", "alexandra@example.com", now.Add(-time.Hour))
+	book.Record("Alexandra New", "alexandra@example.com", now)
+	// "alx" matches fuzzily but not as a prefix
+	book.Record("Bob", "bob.alx@example.com", now)
+
+	got := addresses(book.Suggest("al", 5))
+	want := []string{"alexandra@example.com", "alex@example.com", "bob.alx@example.com"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, got)
+			break
+		}
+	}
+
+	if got := book.Suggest("al", 1); len(got) != 1 {
+		t.Errorf("Expected the suggestions to be limited, got %v", addresses(got))
+	}
+}
+
+func TestBook_SuggestMatchesFuzzily(t *testing.T) {
+	book := newBook(t)
+	book.Record("Alice Smith", "alice@example.com", time.Now())
+	book.Record("Bob Jones", "bob@example.com", time.Now())
+
+	got := addresses(book.Suggest("asmi", 5))
+	if len(got) != 1 || got[0] != "alice@example.com" {
+		t.Errorf("Expected Alice Smith to match, got %v", got)
+	}
+}
+
+func TestBook_UpdateAndDelete(t *testing.T) {
+	book := newBook(t)
+	book.Record("Alice", "alice@example.com", time.Now())
+
+	if err := book.Update("alice@example.com", Contact{Name: "Alice", Address: "alice@work.example.com"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := addresses(book.List()); len(got) != 1 || got[0] != "alice@work.example.com" {
+		t.Errorf("Expected the address to change, got %v", got)
+	}
+
+	if err := book.Delete("alice@example.com"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for the old address, got %v", err)
+	}
+	if err := book.Delete("alice@work.example.com"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(book.List()) != 0 {
+		t.Error("Expected the contact to be deleted")
+	}
+}
+
+func TestContact_String(t *testing.T) {
+	tests := map[string]struct {
+		contact Contact
+		want    string
+	}{
+		"address only": {Contact{Address: "alice@example.com"}, "alice@example.com"},
+		"with name":    {Contact{Name: "Alice", Address: "alice@example.com"}, `"Alice" <alice@example.com>`},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.contact.String(); got != test.want {
+				t.Errorf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package contacts
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/emersion/go-message/mail"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+// Harvester wraps a backend, adding the senders of the emails it loads and the recipients of the emails it sends to
+// the address book.
+type Harvester struct {
+	core.EmailBackend
+	book *Book
+}
+
+func NewHarvester(backend core.EmailBackend, book *Book) *Harvester {
+	return &Harvester{
+		EmailBackend: backend,
+		book:         book,
+	}
+}
+
+func (h *Harvester) ListThreads() ([]*core.Thread, error) {
+	threads, err := h.EmailBackend.ListThreads()
+	if err != nil {
+		return nil, err
+	}
+
+	var emails []core.EmailMetadata
+	for _, thread := range threads {
+		emails = append(emails, thread.Emails()...)
+	}
+	// the book ignores emails from before a contact was last seen, so they're recorded oldest first
+	slices.SortFunc(emails, func(a, b core.EmailMetadata) int {
+		return a.SentAt.Compare(b.SentAt)
+	})
+	for _, email := range emails {
+		h.record(email.From, email.SentAt)
+	}
+	// failing to save the address book shouldn't stop the emails from loading, and it's saved again next time
+	_ = h.book.Save()
+	return threads, nil
+}
+
+func (h *Harvester) SendEmail(email core.OutgoingEmail) error {
+	err := h.EmailBackend.SendEmail(email)
+	var saveSentErr *core.SaveSentError
+	if err != nil && !errors.As(err, &saveSentErr) {
+		return err
+	}
+
+	for _, recipients := range []string{email.To, email.Cc, email.Bcc} {
+		h.record(recipients, time.Now())
+	}
+	_ = h.book.Save()
+	return err
+}
+
+// record adds each address in a list like "Alice <alice@example.com>, bob@example.com".
+func (h *Harvester) record(addresses string, at time.Time) {
+	parsed, err := mail.ParseAddressList(addresses)
+	if err != nil {
+		return
+	}
+	for _, address := range parsed {
+		h.book.Record(address.Name, address.Address, at)
+	}
+}
@@ -0,0 +1,81 @@
+package contacts
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+type mockBackend struct {
+	core.EmailBackend
+
+	threads []*core.Thread
+	sendErr error
+}
+
+func (m *mockBackend) ListThreads() ([]*core.Thread, error) {
+	return m.threads, nil
+}
+
+func (m *mockBackend) SendEmail(core.OutgoingEmail) error {
+	return m.sendErr
+}
+
+func TestHarvester_RecordsRecipientsOfSentEmails(t *testing.T) {
+	book := newBook(t)
+	backend := &mockBackend{}
+	harvester := NewHarvester(backend, book)
+
+	if err := harvester.SendEmail(core.OutgoingEmail{To: "Alice <alice@example.com>", Cc: "bob@example.com", Bcc: "dave@example.com"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	backend.sendErr = errors.New("connection refused")
+	_ = harvester.SendEmail(core.OutgoingEmail{To: "carol@example.com"})
+
+	got := addresses(book.List())
+	if len(got) != 3 {
+		t.Errorf("Expected only the recipients of sent emails, got %v", got)
+	}
+}
+
+func TestHarvester_SaveSentErrorStillRecords(t *testing.T) {
+	book := newBook(t)
+	harvester := NewHarvester(&mockBackend{sendErr: &core.SaveSentError{Err: errors.New("no sent mailbox")}}, book)
+
+	err := harvester.SendEmail(core.OutgoingEmail{To: "alice@example.com"})
+
+	var saveSentErr *core.SaveSentError
+	if !errors.As(err, &saveSentErr) {
+		t.Errorf("Expected the error to be passed on, got %v", err)
+	}
+	if len(book.List()) != 1 {
+		t.Error("Expected the recipient to be recorded, since the email was sent")
+	}
+}
+
+func TestHarvester_RecordsSendersOfLoadedEmails(t *testing.T) {
+	book := newBook(t)
+	now := time.Now()
+	thread := &core.Thread{
+		Email: &core.EmailMetadata{Id: "1", From: "alice <alice@example.com>", SentAt: now.Add(-time.Hour)},
+		Children: []*core.Thread{
+			{Email: &core.EmailMetadata{Id: "2", From: "Alice Smith <alice@example.com>", SentAt: now}},
+		},
+	}
+	harvester := NewHarvester(&mockBackend{threads: []*core.Thread{thread}}, book)
+
+	if _, err := harvester.ListThreads(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// loading the same emails again doesn't count them twice
+	if _, err := harvester.ListThreads(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	contacts := book.List()
+	if len(contacts) != 1 || contacts[0].Count != 2 || contacts[0].Name != "Alice Smith" {
+		t.Errorf("Expected 2 emails from Alice with the latest name, got %+v", contacts)
+	}
+}
@@ -0,0 +1,108 @@
+package contacts
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Import adds the contacts from a vCard (.vcf) file, which can hold any number of cards, and returns how many
+// addresses were imported. Cards with several email addresses are imported as a contact for each of them.
+// Imported contacts count as edited, so their names are kept.
+func (b *Book) Import(r io.Reader) (int, error) {
+	cards, err := parseVCards(r)
+	if err != nil {
+		return 0, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	imported := 0
+	for _, card := range cards {
+		for _, address := range card.emails {
+			contact, ok := b.contacts[key(address)]
+			if !ok {
+				contact = &Contact{Address: address}
+				b.contacts[key(address)] = contact
+			}
+			if card.name != "" {
+				contact.Name = card.name
+			}
+			contact.Edited = true
+			imported++
+		}
+	}
+	return imported, nil
+}
+
+type vCard struct {
+	name   string
+	emails []string
+}
+
+// parseVCards reads the formatted name (FN) and EMAIL properties of each card, which is all the address book needs.
+func parseVCards(r io.Reader) ([]vCard, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cards []vCard
+		card  *vCard
+	)
+	for _, line := range lines {
+		property, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// parameters like TYPE=work come after the name, and groups like "item1." before it
+		name, _, _ := strings.Cut(property, ";")
+		if _, after, grouped := strings.Cut(name, "."); grouped {
+			name = after
+		}
+
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VCARD") {
+				card = &vCard{}
+			}
+		case "END":
+			if strings.EqualFold(value, "VCARD") && card != nil {
+				cards = append(cards, *card)
+				card = nil
+			}
+		case "FN":
+			if card != nil {
+				card.name = unescape(value)
+			}
+		case "EMAIL":
+			if card != nil && strings.TrimSpace(value) != "" {
+				card.emails = append(card.emails, strings.TrimPrefix(strings.TrimSpace(value), "mailto:"))
+			}
+		}
+	}
+	return cards, nil
+}
+
+// unfoldLines joins long lines that have been folded onto several lines starting with a space or tab.
+func unfoldLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// unescape removes the backslashes from escaped characters in text values, like "Smith\, Alice".
+func unescape(value string) string {
+	replacer := strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\N`, " ", `\\`, `\`)
+	return replacer.Replace(value)
+}
@@ -0,0 +1,55 @@
+package contacts
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBook_Import(t *testing.T) {
+	book := newBook(t)
+	vcf := strings.Join([]string{
+		"BEGIN:VCARD",
+		"VERSION:3.0",
+		"FN:Smith\\, Alice",
+		"EMAIL;TYPE=work:alice@work.exa",
+		" mple.com",
+		"item1.EMAIL:alice@example.com",
+		"END:VCARD",
+		"BEGIN:VCARD",
+		"VERSION:4.0",
+		"FN:No Email",
+		"END:VCARD",
+		"BEGIN:VCARD",
+		"EMAIL:mailto:bob@example.com",
+		"END:VCARD",
+	}, "\r\n")
+
+	imported, err := book.Import(strings.NewReader(vcf))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if imported != 3 {
+		t.Errorf("Expected 3 addresses to be imported, got %d", imported)
+	}
+
+	names := map[string]string{}
+	for _, contact := range book.List() {
+		names[contact.Address] = contact.Name
+		if !contact.Edited {
+			t.Errorf("Expected imported contacts to count as edited, got %+v", contact)
+		}
+	}
+	want := map[string]string{
+		"alice@work.example.com": "Smith, Alice",
+		"alice@example.com":      "Smith, Alice",
+		"bob@example.com":        "",
+	}
+	if len(names) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, names)
+	}
+	for address, name := range want {
+		if names[address] != name {
+			t.Errorf("Expected %s to be named %q, got %q", address, name, names[address])
+		}
+	}
+}
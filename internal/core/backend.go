@@ -1,8 +1,33 @@
 package core
 
+// EmailBackend is implemented by each source of emails.
+// Operations that take several ids are applied in bulk, and return a *BulkError if only some of the emails could be
+// updated.
 type EmailBackend interface {
 	ListEmails() ([]EmailMetadata, error)
+	ListThreads() ([]*Thread, error)
 	GetEmail(id EmailId) (*Email, error)
+	// GetRawEmail fetches the RFC 5322 source of an email, without marking it as read.
+	GetRawEmail(id EmailId) ([]byte, error)
 	SendEmail(email OutgoingEmail) error
-	MarkAsRead(id EmailId) error
+
+	// SaveDraft stores a draft, replacing the previous version if it has been saved before.
+	// It returns the draft with its new id.
+	SaveDraft(draft Draft) (*Draft, error)
+	// ListDrafts lists the saved drafts, most recently saved first.
+	ListDrafts() ([]Draft, error)
+	DeleteDraft(id EmailId) error
+
+	AddFlags(ids []EmailId, flags ...Flag) error
+	RemoveFlags(ids []EmailId, flags ...Flag) error
+
+	ListMailboxes() ([]Mailbox, error)
+	// MoveEmails moves emails from the inbox to another mailbox.
+	MoveEmails(ids []EmailId, mailbox string) (*MoveResult, error)
+	// CopyEmails copies emails from the inbox to another mailbox.
+	CopyEmails(ids []EmailId, mailbox string) (*MoveResult, error)
+	// DeleteEmails moves emails to the trash, or deletes them permanently if there isn't a trash mailbox.
+	DeleteEmails(ids []EmailId) (*MoveResult, error)
+	// RestoreEmails moves emails from another mailbox back to the inbox, e.g. to undo moving them.
+	RestoreEmails(mailbox string, ids []EmailId) (*MoveResult, error)
 }
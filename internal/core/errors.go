@@ -0,0 +1,28 @@
+package core
+
+import "fmt"
+
+// BulkError is returned by operations on several emails when only some of them succeeded.
+type BulkError struct {
+	Failed []EmailId
+}
+
+func (e *BulkError) Error() string {
+	if len(e.Failed) == 1 {
+		return "1 email could not be updated"
+	}
+	return fmt.Sprintf("%d emails could not be updated", len(e.Failed))
+}
+
+// SaveSentError is returned when an email was sent, but a copy of it couldn't be saved to the sent mailbox.
+type SaveSentError struct {
+	Err error
+}
+
+func (e *SaveSentError) Error() string {
+	return "the email was sent, but saving a copy of it failed: " + e.Err.Error()
+}
+
+func (e *SaveSentError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,20 @@
+package core
+
+import "strings"
+
+// Flag is an IMAP message flag.
+// System flags start with a backslash, and anything else is a keyword.
+type Flag string
+
+const (
+	FlagSeen     Flag = `\Seen`
+	FlagAnswered Flag = `\Answered`
+	FlagFlagged  Flag = `\Flagged`
+	FlagDeleted  Flag = `\Deleted`
+	FlagDraft    Flag = `\Draft`
+)
+
+// IsKeyword reports whether the flag is a user-defined keyword rather than a system flag.
+func (f Flag) IsKeyword() bool {
+	return !strings.HasPrefix(string(f), `\`)
+}
@@ -0,0 +1,38 @@
+package core
+
+// MailboxRole identifies mailboxes with a special use, such as the one deleted emails are moved to.
+type MailboxRole string
+
+const (
+	MailboxRoleNone    MailboxRole = ""
+	MailboxRoleInbox   MailboxRole = "inbox"
+	MailboxRoleArchive MailboxRole = "archive"
+	MailboxRoleDrafts  MailboxRole = "drafts"
+	MailboxRoleJunk    MailboxRole = "junk"
+	MailboxRoleSent    MailboxRole = "sent"
+	MailboxRoleTrash   MailboxRole = "trash"
+)
+
+type Mailbox struct {
+	Name string
+	Role MailboxRole
+}
+
+// FindMailbox returns the first mailbox with the given role.
+func FindMailbox(mailboxes []Mailbox, role MailboxRole) (Mailbox, bool) {
+	for _, mailbox := range mailboxes {
+		if mailbox.Role == role {
+			return mailbox, true
+		}
+	}
+	return Mailbox{}, false
+}
+
+// MoveResult describes where emails ended up after being moved, copied or deleted.
+type MoveResult struct {
+	// Mailbox is empty if the emails were deleted permanently.
+	Mailbox string
+	// Ids maps the original ids to the ids of the emails in Mailbox.
+	// It's empty if the backend can't tell what the new ids are.
+	Ids map[EmailId]EmailId
+}
@@ -1,6 +1,10 @@
 package core
 
-import "time"
+import (
+	"slices"
+	"strings"
+	"time"
+)
 
 type EmailId string
 
@@ -10,16 +14,150 @@ type EmailMetadata struct {
 	To      string
 	Subject string
 	SentAt  time.Time
-	IsRead  bool
+	Flags   []Flag
+
+	// Threading headers, with message IDs stored without the surrounding angle brackets.
+	MessageId  string
+	InReplyTo  []string
+	References []string
+
+	// List is the mailing list the email was sent through, or nil if it wasn't.
+	List *MailingList
+}
+
+// MailingList is what an email's List-* headers say about the mailing list it came from (RFC 2369 and RFC 2919).
+type MailingList struct {
+	// Id identifies the list, like "golang-nuts.googlegroups.com"
+	Id string
+	// Name is the description from the List-Id header, like "Go Nuts", which is empty if it hasn't got one
+	Name string
+	// Post is the address to send emails to the list, which is empty if posting to it isn't allowed
+	Post string
+	// Unsubscribe are the URIs to unsubscribe with, from the List-Unsubscribe header, like mailto: and https: ones
+	Unsubscribe []string
+	// OneClick is set when the https: unsubscribe URI can be sent a POST to unsubscribe straight away (RFC 8058)
+	OneClick bool
+}
+
+// Label names the list for showing, by its description if it has one.
+func (l MailingList) Label() string {
+	switch {
+	case l.Name != "":
+		return l.Name
+	case l.Id != "":
+		return l.Id
+	}
+	return l.Post
+}
+
+// HasFlag reports whether the email has a flag, ignoring case as IMAP does.
+func (m EmailMetadata) HasFlag(flag Flag) bool {
+	return slices.ContainsFunc(m.Flags, func(f Flag) bool {
+		return strings.EqualFold(string(f), string(flag))
+	})
+}
+
+func (m EmailMetadata) IsRead() bool {
+	return m.HasFlag(FlagSeen)
+}
+
+// Keywords returns the user-defined flags on the email.
+func (m EmailMetadata) Keywords() []Flag {
+	var keywords []Flag
+	for _, flag := range m.Flags {
+		if flag.IsKeyword() {
+			keywords = append(keywords, flag)
+		}
+	}
+	return keywords
+}
+
+// SetFlag adds or removes a flag.
+func (m *EmailMetadata) SetFlag(flag Flag, set bool) {
+	m.Flags = slices.DeleteFunc(slices.Clone(m.Flags), func(f Flag) bool {
+		return strings.EqualFold(string(f), string(flag))
+	})
+	if set {
+		m.Flags = append(m.Flags, flag)
+	}
 }
 
 type Email struct {
 	EmailMetadata
 	Body string
+	// Flowed is set when the body was sent as format=flowed (RFC 3676), so each line is a paragraph that can be
+	// wrapped to fit, keeping its ">" quote markers.
+	Flowed bool
+	// Calendar is the email's text/calendar part, like a meeting invitation, if it has one.
+	Calendar []byte
+	// Encrypted is set when the body was decrypted to be read, so replies to it can be encrypted too.
+	Encrypted bool
 }
 
 type OutgoingEmail struct {
+	// From is filled in by the backend if it's left empty.
+	From string
+	// ReplyTo is where replies should be sent instead of the sender, if it's set.
+	ReplyTo string
 	To      string
+	Cc      string
+	// Bcc are recipients who are sent the email without being listed in it.
+	Bcc     string
 	Subject string
 	Body    string
+	// Markdown is set when the body is written in Markdown, so it's sent with an HTML version as well.
+	Markdown bool
+
+	// MessageId is generated when the email is first saved or sent, without the surrounding angle brackets.
+	MessageId string
+
+	// Threading headers for replies, with message IDs stored without the surrounding angle brackets.
+	InReplyTo  string
+	References []string
+
+	Attachments []Attachment
+
+	// Calendar is sent alongside the body for emails about events, like replies to invitations.
+	Calendar *CalendarPart
+
+	// OpenPGP is how the email is protected with the sender's OpenPGP key when it's sent.
+	OpenPGP Protection
+	// SignSMIME signs the email with the sender's S/MIME certificate when it's sent, instead of with OpenPGP.
+	SignSMIME bool
+}
+
+// Protection is whether an email is signed, so recipients can tell it's really from the sender and unchanged, and
+// whether it's encrypted, so only the recipients can read it.
+type Protection struct {
+	Sign    bool
+	Encrypt bool
+}
+
+// Enabled reports whether the email is protected at all.
+func (p Protection) Enabled() bool {
+	return p.Sign || p.Encrypt
+}
+
+// CalendarPart is an iCalendar object sent in an email (RFC 6047).
+type CalendarPart struct {
+	// Method is what the object is for, like "REPLY" for a reply to an invitation, and has to match the one in Data.
+	Method string
+	Data   []byte
+}
+
+// Attachment is a file attached to an outgoing email.
+type Attachment struct {
+	Filename string
+	// ContentType is the attachment's MIME type, like "application/pdf".
+	ContentType string
+	Data        []byte
+}
+
+// Draft is an email that's still being written.
+type Draft struct {
+	// Id identifies the saved draft, and is empty until the draft is first saved.
+	// Each save replaces the draft, so its id can change.
+	Id      EmailId
+	SavedAt time.Time
+	OutgoingEmail
 }
@@ -0,0 +1,38 @@
+package core
+
+import "time"
+
+// Thread is a node in a conversation tree.
+// Email is nil for placeholder nodes, which stand in for messages that are referenced by replies but aren't present.
+type Thread struct {
+	Email    *EmailMetadata
+	Children []*Thread
+}
+
+// Emails returns the emails in the thread in depth-first order, skipping placeholders.
+func (t *Thread) Emails() []EmailMetadata {
+	var emails []EmailMetadata
+	if t.Email != nil {
+		emails = append(emails, *t.Email)
+	}
+	for _, child := range t.Children {
+		emails = append(emails, child.Emails()...)
+	}
+	return emails
+}
+
+// Size returns the number of emails in the thread.
+func (t *Thread) Size() int {
+	return len(t.Emails())
+}
+
+// LatestSentAt returns the time the most recent email in the thread was sent.
+func (t *Thread) LatestSentAt() time.Time {
+	var latest time.Time
+	for _, email := range t.Emails() {
+		if email.SentAt.After(latest) {
+			latest = email.SentAt
+		}
+	}
+	return latest
+}
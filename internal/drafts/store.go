@@ -0,0 +1,104 @@
+// Package drafts keeps drafts on disk, for backends that can't store them on the server.
+package drafts
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+// Store saves each draft as a JSON file in a directory, named after the draft's id.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Save writes a draft, overwriting the previous version if it has been saved before.
+// The file is replaced atomically, so a crash while saving leaves the previous version intact.
+func (s *Store) Save(draft core.Draft) (*core.Draft, error) {
+	if draft.Id == "" {
+		draft.Id = core.EmailId(strconv.FormatInt(time.Now().UnixNano(), 10))
+	}
+	draft.SavedAt = time.Now()
+
+	path, err := s.path(draft.Id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(draft)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp(s.dir, ".draft-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// List reads every draft, most recently saved first.
+func (s *Store) List() ([]core.Draft, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	drafts := []core.Draft{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var draft core.Draft
+		if err := json.Unmarshal(data, &draft); err != nil {
+			return nil, fmt.Errorf("reading draft %s: %w", filepath.Base(path), err)
+		}
+		drafts = append(drafts, draft)
+	}
+
+	slices.SortFunc(drafts, func(a, b core.Draft) int {
+		return b.SavedAt.Compare(a.SavedAt)
+	})
+	return drafts, nil
+}
+
+func (s *Store) Delete(id core.EmailId) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// path returns the file for a draft, checking the id can't be used to reach files outside the directory.
+func (s *Store) path(id core.EmailId) (string, error) {
+	if id == "" || strings.ContainsAny(string(id), `/\.`) {
+		return "", fmt.Errorf("invalid draft id %q", id)
+	}
+	return filepath.Join(s.dir, string(id)+".json"), nil
+}
@@ -0,0 +1,60 @@
+package drafts
+
+import (
+	"testing"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+func TestStore_SaveReplacesDraft(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	saved, err := store.Save(core.Draft{OutgoingEmail: core.OutgoingEmail{Subject: "First version"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if saved.Id == "" {
+		t.Fatal("Expected the draft to be given an id")
+	}
+
+	saved.Subject = "Second version"
+	if _, err := store.Save(*saved); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	drafts, err := store.List()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(drafts) != 1 {
+		t.Fatalf("Expected the draft to be replaced rather than duplicated, got %d drafts", len(drafts))
+	}
+	if drafts[0].Subject != "Second version" {
+		t.Errorf("Expected the latest version, got '%s'", drafts[0].Subject)
+	}
+}
+
+func TestStore_Delete(t *testing.T) {
+	store, _ := NewStore(t.TempDir())
+	saved, _ := store.Save(core.Draft{})
+
+	if err := store.Delete(saved.Id); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	drafts, _ := store.List()
+	if len(drafts) != 0 {
+		t.Errorf("Expected no drafts after deleting, got %d", len(drafts))
+	}
+}
+
+func TestStore_RejectsPathsInIds(t *testing.T) {
+	store, _ := NewStore(t.TempDir())
+
+	if err := store.Delete("../config"); err == nil {
+		t.Error("Expected an error for an id containing a path")
+	}
+}
@@ -0,0 +1,155 @@
+// Package flowed encodes and decodes format=flowed text (RFC 3676), where long paragraphs are sent as lines ending in
+// a space so that they can be joined back together and rewrapped to fit whatever is showing them.
+package flowed
+
+import (
+	"strings"
+
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/muesli/reflow/wrap"
+)
+
+// LineLength is how long encoded lines are, which RFC 3676 recommends keeping to 78 characters or fewer.
+const LineLength = 78
+
+// signatureSeparator is the line above a signature, which ends in a space but is never flowed.
+const signatureSeparator = "-- "
+
+// Encode wraps each line of plain text into flowed lines of at most width characters where possible, keeping the
+// ">" quote markers of quoted lines on each of the lines they're wrapped onto.
+// Trailing spaces are removed from the text, since they would mark lines as flowed.
+func Encode(text string, width int) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		depth, content := splitQuote(line)
+		if depth > 0 {
+			content = strings.TrimPrefix(content, " ")
+		}
+		if content != signatureSeparator {
+			content = strings.TrimRight(content, " ")
+		}
+		prefix := strings.Repeat(">", depth)
+		available := max(width-depth-1, 20)
+		for _, chunk := range chunks(content, available) {
+			lines = append(lines, prefix+stuff(chunk, depth))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// chunks breaks a line after spaces so each chunk fits the width where possible, leaving the spaces at the ends of
+// the chunks to mark them as flowed. Words longer than the width are left whole.
+func chunks(content string, width int) []string {
+	if len(content) <= width || content == signatureSeparator {
+		return []string{content}
+	}
+
+	var (
+		result  []string
+		current strings.Builder
+	)
+	for _, token := range tokens(content) {
+		if current.Len() > 0 && current.Len()+len(strings.TrimRight(token, " ")) > width {
+			result = append(result, current.String())
+			current.Reset()
+		}
+		current.WriteString(token)
+	}
+	return append(result, current.String())
+}
+
+// tokens splits a line into words, each with the spaces that follow it.
+func tokens(content string) []string {
+	var result []string
+	start := 0
+	for i := 1; i < len(content); i++ {
+		if content[i] != ' ' && content[i-1] == ' ' {
+			result = append(result, content[start:i])
+			start = i
+		}
+	}
+	return append(result, content[start:])
+}
+
+// stuff adds a space to the start of lines that would otherwise be misread, as RFC 3676 describes: quoted lines, so
+// the quote markers are kept apart from the text, and lines starting with a space, ">" or "From ".
+func stuff(line string, depth int) string {
+	if (depth > 0 && line != "") || strings.HasPrefix(line, " ") || strings.HasPrefix(line, ">") || strings.HasPrefix(line, "From ") {
+		return " " + line
+	}
+	return line
+}
+
+// Decode joins flowed lines back into the paragraphs they were wrapped from, one per line. Quoted paragraphs start
+// with their quote markers and a space, like ">> quoted twice". If delSp is set, the space at the end of each flowed
+// line was added by the sender and is removed too.
+func Decode(text string, delSp bool) string {
+	var (
+		lines     []string
+		paragraph strings.Builder
+		// depth is the quote depth of the paragraph being joined, or -1 if there isn't one
+		depth = -1
+	)
+	finish := func() {
+		if depth == -1 {
+			return
+		}
+		line := strings.Repeat(">", depth)
+		if depth > 0 && paragraph.Len() > 0 {
+			line += " "
+		}
+		lines = append(lines, line+paragraph.String())
+		paragraph.Reset()
+		depth = -1
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		lineDepth, content := splitQuote(line)
+		content = strings.TrimPrefix(content, " ")
+		// a change of quote depth ends the paragraph, even if the line before it was flowed
+		if depth != -1 && lineDepth != depth {
+			finish()
+		}
+		depth = lineDepth
+
+		flowed := strings.HasSuffix(content, " ") && content != signatureSeparator
+		if flowed && delSp {
+			content = strings.TrimSuffix(content, " ")
+		}
+		paragraph.WriteString(content)
+		if !flowed {
+			finish()
+		}
+	}
+	finish()
+	return strings.Join(lines, "\n")
+}
+
+// splitQuote counts the ">" quote markers at the start of a line and returns the rest of it.
+func splitQuote(line string) (int, string) {
+	depth := 0
+	for depth < len(line) && line[depth] == '>' {
+		depth++
+	}
+	return depth, line[depth:]
+}
+
+// Wrap fits decoded paragraphs to a width for showing them, repeating the quote markers of quoted paragraphs on each
+// line they're wrapped onto.
+func Wrap(text string, width int) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		depth, content := splitQuote(line)
+		prefix := ""
+		if depth > 0 {
+			prefix = strings.Repeat(">", depth) + " "
+			content = strings.TrimPrefix(content, " ")
+		}
+		available := max(width-len(prefix), 10)
+		wrapped := wrap.String(wordwrap.String(content, available), available)
+		for _, part := range strings.Split(wrapped, "\n") {
+			lines = append(lines, strings.TrimRight(prefix+part, " "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
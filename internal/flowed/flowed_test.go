@@ -0,0 +1,126 @@
+package flowed
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	text := "The quick brown fox jumps over the lazy dog and keeps on running"
+
+	encoded := Encode(text, 30)
+
+	want := "The quick brown fox jumps \nover the lazy dog and keeps \non running"
+	if encoded != want {
+		t.Errorf("Expected %q, got %q", want, encoded)
+	}
+}
+
+func TestEncode_SpaceStuffing(t *testing.T) {
+	tests := map[string]struct {
+		text string
+		want string
+	}{
+		"leading space":     {" indented", "  indented"},
+		"from":              {"From the team", " From the team"},
+		"quoted":            {"> quoted", "> quoted"},
+		"quoted twice":      {">> quoted", ">> quoted"},
+		"unspaced quote":    {">quoted", "> quoted"},
+		"trailing spaces":   {"fixed   ", "fixed"},
+		"signature":         {"-- ", "-- "},
+		"empty quoted line": {">", ">"},
+		"long word":         {strings.Repeat("a", 30), strings.Repeat("a", 30)},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Encode(test.text, 20); got != test.want {
+				t.Errorf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestEncode_WrappedContinuationIsStuffed(t *testing.T) {
+	encoded := Encode("look at this >here and From there", 12)
+
+	for _, line := range strings.Split(encoded, "\n") {
+		if strings.HasPrefix(line, ">") || strings.HasPrefix(line, "From ") {
+			t.Errorf("Expected continuation lines to be space-stuffed, got %q", encoded)
+		}
+	}
+	if decoded := Decode(encoded, false); decoded != "look at this >here and From there" {
+		t.Errorf("Expected the line to round-trip, got %q", decoded)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	encoded := "Hello there, this \nis one paragraph.\n\n> And this is \n> a quote.\n>> Quoted \n>> twice.\n"
+
+	decoded := Decode(encoded, false)
+
+	want := "Hello there, this is one paragraph.\n\n> And this is a quote.\n>> Quoted twice.\n"
+	if decoded != want {
+		t.Errorf("Expected %q, got %q", want, decoded)
+	}
+}
+
+func TestDecode_DelSp(t *testing.T) {
+	if decoded := Decode("長い文章を \n折り返す", true); decoded != "長い文章を折り返す" {
+		t.Errorf("Expected the added spaces to be removed, got %q", decoded)
+	}
+}
+
+func TestDecode_QuoteDepthChangeEndsParagraph(t *testing.T) {
+	// the flowed line is followed by a line at another depth, so it's treated as fixed
+	decoded := Decode("> quoted \nnot quoted", false)
+
+	if decoded != "> quoted \nnot quoted" {
+		t.Errorf("Expected the paragraphs to stay apart, got %q", decoded)
+	}
+}
+
+func TestDecode_SignatureSeparatorIsFixed(t *testing.T) {
+	if decoded := Decode("Thanks\n-- \nAlice", false); decoded != "Thanks\n-- \nAlice" {
+		t.Errorf("Expected the signature separator not to flow, got %q", decoded)
+	}
+}
+
+func TestRoundTrip_QuotedReply(t *testing.T) {
+	reply := strings.Join([]string{
+		"Sounds good, I'll bring the slides and we can go through the plan for next quarter together before lunch.",
+		"",
+		"On Mon, 2 Jan 2025 at 10:00, Bob <bob@example.com> wrote:",
+		"> Shall we meet at 10 on Thursday? I'd like to go over the roadmap and the budget for the whole of next year.",
+		"> ",
+		">> From what I remember, the last meeting ran long because we didn't have an agenda, so let's write one first.",
+		">",
+		"> Bob",
+		"",
+		"-- ",
+		"Alice",
+		"",
+	}, "\n")
+
+	encoded := Encode(reply, LineLength)
+	for _, line := range strings.Split(encoded, "\n") {
+		if len(line) > LineLength {
+			t.Errorf("Expected lines of at most %d characters, got %q", LineLength, line)
+		}
+	}
+	decoded := Decode(encoded, false)
+
+	// the trailing space on the empty quoted line can't be represented, since it would mean the line is flowed
+	want := strings.Replace(reply, "> \n", ">\n", 1)
+	if decoded != want {
+		t.Errorf("Expected the reply to round-trip:\n%q\ngot:\n%q", want, decoded)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	wrapped := Wrap("Short line\n>> A quoted paragraph that is too long to fit", 20)
+
+	want := "Short line\n>> A quoted\n>> paragraph that is\n>> too long to fit"
+	if wrapped != want {
+		t.Errorf("Expected %q, got %q", want, wrapped)
+	}
+}
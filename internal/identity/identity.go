@@ -0,0 +1,175 @@
+// Package identity holds the addresses emails can be sent from, each with its own name, reply-to address and
+// signature, grouped into the accounts whose SMTP servers send them.
+package identity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/emersion/go-message/mail"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/smtp"
+)
+
+// Identity is someone emails can be sent as.
+type Identity struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	// ReplyTo is where replies should go instead of the address, if it's set
+	ReplyTo string `json:"replyTo,omitempty"`
+	// Signature is added to the end of emails written as the identity
+	Signature string `json:"signature,omitempty"`
+	// Account is the name of the account the identity belongs to, which is filled in when the config is loaded
+	Account string `json:"-"`
+}
+
+// From formats the identity as the From header, like "Alice Smith <alice@example.com>".
+func (i Identity) From() string {
+	if i.Name == "" {
+		return i.Address
+	}
+	return (&mail.Address{Name: i.Name, Address: i.Address}).String()
+}
+
+// Account is an SMTP server that sends emails for its identities.
+type Account struct {
+	Name string `json:"name"`
+	// SmtpAddress is the server's hostname and port. The default server is used if it's empty.
+	SmtpAddress string `json:"smtpAddress,omitempty"`
+	// SmtpSecurity is how the connection to the server is encrypted: "auto" (the default), "tls", "starttls" or "none"
+	SmtpSecurity string `json:"smtpSecurity,omitempty"`
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"password,omitempty"`
+
+	Identities []Identity `json:"identities"`
+}
+
+// Config is the accounts and identities from the identities.json file, like:
+//
+//	{"accounts": [{"name": "work", "smtpAddress": "smtp.example.com:587", "username": "alice", "password": "...",
+//	  "identities": [{"name": "Alice Smith", "address": "alice@example.com", "signature": "Alice"}]}]}
+type Config struct {
+	Accounts []Account `json:"accounts"`
+}
+
+// Load reads the config from a file, which is empty if the file doesn't exist.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid identities file %s: %w", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid identities file %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+func (c *Config) validate() error {
+	seen := map[string]bool{}
+	for i, account := range c.Accounts {
+		if account.Name == "" {
+			return fmt.Errorf("account %d has no name", i+1)
+		}
+		if _, err := smtp.ParseSecurity(account.SmtpSecurity); err != nil {
+			return fmt.Errorf("account %q: %w", account.Name, err)
+		}
+		for j, identity := range account.Identities {
+			address, err := mail.ParseAddress(identity.Address)
+			if err != nil {
+				return fmt.Errorf("identity %q in account %q has an invalid address: %w", identity.Address, account.Name, err)
+			}
+			if seen[key(address.Address)] {
+				return fmt.Errorf("identity %q is in more than one account", identity.Address)
+			}
+			seen[key(address.Address)] = true
+			if identity.ReplyTo != "" {
+				if _, err := mail.ParseAddress(identity.ReplyTo); err != nil {
+					return fmt.Errorf("identity %q has an invalid reply-to address: %w", identity.Address, err)
+				}
+			}
+			c.Accounts[i].Identities[j].Address = address.Address
+			c.Accounts[i].Identities[j].Account = account.Name
+		}
+	}
+	return nil
+}
+
+// Identities lists every identity, in the order they're in the file, so the first one is the default.
+func (c *Config) Identities() []Identity {
+	if c == nil {
+		return nil
+	}
+	var identities []Identity
+	for _, account := range c.Accounts {
+		identities = append(identities, account.Identities...)
+	}
+	return identities
+}
+
+// SenderFor returns the SMTP server of the account that the sender's identity belongs to, or false if the sender
+// isn't one of the identities or its account uses the default server.
+func (c *Config) SenderFor(from string) (*smtp.Sender, bool) {
+	address, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, false
+	}
+	identity, ok := Find(c.Identities(), address.Address)
+	if !ok {
+		return nil, false
+	}
+	for _, account := range c.Accounts {
+		if account.Name == identity.Account && account.SmtpAddress != "" {
+			// the security was checked when the config was loaded
+			security, _ := smtp.ParseSecurity(account.SmtpSecurity)
+			return &smtp.Sender{
+				Address:  account.SmtpAddress,
+				Username: account.Username,
+				Password: account.Password,
+				Security: security,
+			}, true
+		}
+	}
+	return nil, false
+}
+
+// Find returns the identity with the given bare address.
+func Find(identities []Identity, address string) (Identity, bool) {
+	for _, identity := range identities {
+		if key(identity.Address) == key(address) {
+			return identity, true
+		}
+	}
+	return Identity{}, false
+}
+
+// ForReply picks the identity a reply should be sent as, which is whichever of them the original email was sent to,
+// or false if it wasn't sent to any of them.
+func ForReply(identities []Identity, email *core.Email) (Identity, bool) {
+	recipients, err := mail.ParseAddressList(email.To)
+	if err != nil {
+		return Identity{}, false
+	}
+	for _, recipient := range recipients {
+		if identity, ok := Find(identities, recipient.Address); ok {
+			return identity, true
+		}
+	}
+	return Identity{}, false
+}
+
+// key compares addresses without caring about case, since they aren't case-sensitive in practice.
+func key(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
@@ -0,0 +1,130 @@
+package identity
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/smtp"
+)
+
+const testConfig = `{
+	"accounts": [
+		{
+			"name": "work",
+			"smtpAddress": "smtp.work.example.com:587",
+			"username": "alice",
+			"password": "secret",
+			"identities": [
+				{"name": "Alice Smith", "address": "alice@work.example.com", "replyTo": "support@work.example.com"},
+				{"name": "Support", "address": "Support <support@work.example.com>"}
+			]
+		},
+		{
+			"name": "personal",
+			"smtpSecurity": "tls",
+			"identities": [{"name": "Alice", "address": "alice@example.com"}]
+		}
+	]
+}`
+
+func loadConfig(t *testing.T, contents string) (*Config, error) {
+	path := filepath.Join(t.TempDir(), "identities.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return Load(path)
+}
+
+func TestLoad(t *testing.T) {
+	config, err := loadConfig(t, testConfig)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	identities := config.Identities()
+	if len(identities) != 3 {
+		t.Fatalf("Expected 3 identities, got %+v", identities)
+	}
+	if identities[1].Address != "support@work.example.com" || identities[1].Account != "work" {
+		t.Errorf("Expected the bare address and the account to be filled in, got %+v", identities[1])
+	}
+	if identities[2].Account != "personal" {
+		t.Errorf("Expected the personal account, got %+v", identities[2])
+	}
+}
+
+func TestLoad_MissingFileIsEmpty(t *testing.T) {
+	config, err := Load(filepath.Join(t.TempDir(), "identities.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(config.Identities()) != 0 {
+		t.Errorf("Expected no identities, got %+v", config.Identities())
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"no account name":   `{"accounts": [{"identities": []}]}`,
+		"invalid address":   `{"accounts": [{"name": "a", "identities": [{"address": "nope"}]}]}`,
+		"invalid reply-to":  `{"accounts": [{"name": "a", "identities": [{"address": "a@example.com", "replyTo": "nope"}]}]}`,
+		"duplicate address": `{"accounts": [{"name": "a", "identities": [{"address": "a@example.com"}]}, {"name": "b", "identities": [{"address": "A@example.com"}]}]}`,
+		"unknown security":  `{"accounts": [{"name": "a", "smtpSecurity": "ssl", "identities": []}]}`,
+		"not json":          `accounts`,
+	}
+	for name, contents := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadConfig(t, contents); err == nil || !strings.Contains(err.Error(), "invalid identities file") {
+				t.Errorf("Expected an invalid identities file error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestConfig_SenderFor(t *testing.T) {
+	config, _ := loadConfig(t, testConfig)
+
+	sender, ok := config.SenderFor("Support <SUPPORT@work.example.com>")
+	if !ok || sender.Address != "smtp.work.example.com:587" || sender.Username != "alice" || sender.Password != "secret" ||
+		sender.Security != smtp.SecurityAuto {
+		t.Errorf("Expected the work account's server, got %+v", sender)
+	}
+
+	if _, ok := config.SenderFor("alice@example.com"); ok {
+		t.Error("Expected the default server for an account without one")
+	}
+	if _, ok := config.SenderFor("someone@example.com"); ok {
+		t.Error("Expected the default server for an unknown sender")
+	}
+	var empty *Config
+	if _, ok := empty.SenderFor("alice@example.com"); ok {
+		t.Error("Expected the default server without a config")
+	}
+}
+
+func TestForReply(t *testing.T) {
+	config, _ := loadConfig(t, testConfig)
+	email := &core.Email{EmailMetadata: core.EmailMetadata{To: "Bob <bob@example.com>, Support <support@work.example.com>"}}
+
+	identity, ok := ForReply(config.Identities(), email)
+
+	if !ok || identity.Address != "support@work.example.com" {
+		t.Errorf("Expected the identity the email was sent to, got %+v", identity)
+	}
+
+	if _, ok := ForReply(config.Identities(), &core.Email{EmailMetadata: core.EmailMetadata{To: "bob@example.com"}}); ok {
+		t.Error("Expected no identity for an email sent to someone else")
+	}
+}
+
+func TestIdentity_From(t *testing.T) {
+	if got := (Identity{Address: "alice@example.com"}).From(); got != "alice@example.com" {
+		t.Errorf("Expected just the address, got %q", got)
+	}
+	if got := (Identity{Name: "Alice Smith", Address: "alice@example.com"}).From(); got != `"Alice Smith" <alice@example.com>` {
+		t.Errorf("Expected the name and address, got %q", got)
+	}
+}
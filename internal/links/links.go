@@ -0,0 +1,202 @@
+// Package links finds the links in an email, so they can be opened or copied even when the terminal can't click them,
+// and shows where each one really goes.
+package links
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	gomessage "github.com/emersion/go-message"
+	_ "github.com/emersion/go-message/charset"
+	"golang.org/x/net/html"
+	"golang.org/x/net/idna"
+
+	"github.com/bengesoff/mail-tui/internal/flowed"
+)
+
+// Link is a URL found in an email.
+type Link struct {
+	URL string
+	// Text is what an HTML link showed in place of the URL, if it was found in HTML.
+	Text string
+}
+
+var (
+	urlPattern = regexp.MustCompile(`(?i)\b(?:https?://|mailto:)[^\s<>"'` + "`" + `]+`)
+	// domainPattern finds text that looks like it names a host, like "www.example.com" or "https://example.com/login"
+	domainPattern = regexp.MustCompile(`(?i)^(?:[a-z][a-z0-9+.-]*://)?((?:[a-z0-9-]+\.)+[a-z]{2,})(?:[/:?#]\S*)?$`)
+)
+
+// Host returns where the link really goes, with internationalised names in their ASCII form so lookalike characters
+// stand out. It's empty if the URL can't be parsed, and the address for mailto links.
+func (l Link) Host() string {
+	parsed, err := url.Parse(l.URL)
+	if err != nil {
+		return ""
+	}
+	if parsed.Scheme == "mailto" {
+		return parsed.Opaque
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if ascii, err := idna.ToASCII(host); err == nil {
+		return ascii
+	}
+	return host
+}
+
+// Mismatched reports whether the link's text looks like it goes somewhere other than where it really does, like a
+// link showing "paypal.com" that goes to another site.
+func (l Link) Mismatched() bool {
+	match := domainPattern.FindStringSubmatch(strings.TrimSpace(l.Text))
+	if match == nil {
+		return false
+	}
+	shown := strings.ToLower(match[1])
+	if ascii, err := idna.ToASCII(shown); err == nil {
+		shown = ascii
+	}
+	host := l.Host()
+	return shown != host && !strings.HasSuffix(host, "."+shown) && !strings.HasSuffix(shown, "."+host)
+}
+
+// FromText finds the URLs in plain text.
+func FromText(text string) []Link {
+	var links []Link
+	for _, match := range urlPattern.FindAllString(text, -1) {
+		links = append(links, Link{URL: trimPunctuation(match)})
+	}
+	return links
+}
+
+// trimPunctuation takes off punctuation that's more likely to end the sentence than the URL, keeping closing brackets
+// that have a matching opening one in the URL, like in Wikipedia links.
+func trimPunctuation(link string) string {
+	for link != "" {
+		last := link[len(link)-1]
+		switch {
+		case strings.IndexByte(".,;:!?'\"", last) >= 0:
+		case last == ')' && strings.Count(link, "(") < strings.Count(link, ")"):
+		case last == ']' && strings.Count(link, "[") < strings.Count(link, "]"):
+		default:
+			return link
+		}
+		link = link[:len(link)-1]
+	}
+	return link
+}
+
+// FromHTML finds the links in HTML, along with the text each one shows.
+func FromHTML(r io.Reader) ([]Link, error) {
+	var (
+		links     []Link
+		tokenizer = html.NewTokenizer(r)
+		// current is the index of the link whose text is being read, or -1 if not inside one
+		current = -1
+	)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if errors.Is(tokenizer.Err(), io.EOF) {
+				return links, nil
+			}
+			return links, tokenizer.Err()
+		case html.StartTagToken:
+			token := tokenizer.Token()
+			if token.Data != "a" {
+				continue
+			}
+			for _, attribute := range token.Attr {
+				if attribute.Key == "href" && isOpenable(attribute.Val) {
+					links = append(links, Link{URL: strings.TrimSpace(attribute.Val)})
+					current = len(links) - 1
+				}
+			}
+		case html.TextToken:
+			if current != -1 {
+				links[current].Text += string(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "a" && current != -1 {
+				links[current].Text = strings.Join(strings.Fields(links[current].Text), " ")
+				current = -1
+			}
+		}
+	}
+}
+
+// isOpenable leaves out links that don't go anywhere, like anchors within the page or javascript: links.
+func isOpenable(href string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// FromMessage finds the links in every text part of an email's source, in the order they appear. Links that appear
+// more than once are only listed the first time, with the text of the first HTML link to them.
+func FromMessage(r io.Reader) ([]Link, error) {
+	entity, err := gomessage.Read(r)
+	if err != nil && !gomessage.IsUnknownCharset(err) {
+		return nil, err
+	}
+
+	var found []Link
+	err = entity.Walk(func(path []int, part *gomessage.Entity, err error) error {
+		if err != nil {
+			return err
+		}
+		contentType, params, _ := part.Header.ContentType()
+		if contentType == "" {
+			// a message without a Content-Type is plain text
+			contentType = "text/plain"
+		}
+		switch contentType {
+		case "text/plain":
+			body, err := io.ReadAll(part.Body)
+			if err != nil {
+				return err
+			}
+			text := string(body)
+			if strings.EqualFold(params["format"], "flowed") {
+				// joined back together, so URLs wrapped over lines are found whole
+				text = flowed.Decode(text, strings.EqualFold(params["delsp"], "yes"))
+			}
+			found = append(found, FromText(text)...)
+		case "text/html":
+			htmlLinks, err := FromHTML(part.Body)
+			if err != nil {
+				return err
+			}
+			found = append(found, htmlLinks...)
+		}
+		return nil
+	})
+	if err != nil && !gomessage.IsUnknownCharset(err) {
+		return nil, err
+	}
+	return deduplicate(found), nil
+}
+
+func deduplicate(found []Link) []Link {
+	var links []Link
+	indexes := map[string]int{}
+	for _, link := range found {
+		if i, ok := indexes[link.URL]; ok {
+			if links[i].Text == "" {
+				links[i].Text = link.Text
+			}
+			continue
+		}
+		indexes[link.URL] = len(links)
+		links = append(links, link)
+	}
+	return links
+}
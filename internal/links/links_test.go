@@ -0,0 +1,116 @@
+package links
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromText(t *testing.T) {
+	text := "See https://example.com/docs. Or (https://en.wikipedia.org/wiki/Go_(language)), " +
+		"mail mailto:bob@example.com, or the 'http://example.org/a?b=c'!"
+
+	found := FromText(text)
+
+	want := []string{
+		"https://example.com/docs",
+		"https://en.wikipedia.org/wiki/Go_(language)",
+		"mailto:bob@example.com",
+		"http://example.org/a?b=c",
+	}
+	if len(found) != len(want) {
+		t.Fatalf("Expected %d links, got %+v", len(want), found)
+	}
+	for i := range want {
+		if found[i].URL != want[i] {
+			t.Errorf("Expected %q, got %q", want[i], found[i].URL)
+		}
+	}
+}
+
+func TestFromHTML(t *testing.T) {
+	found, err := FromHTML(strings.NewReader(`<p>Please <a href="https://evil.example.net/login">log in to
+		<b>paypal.com</b></a> or <a href="#top">go up</a> <a href="javascript:alert(1)">here</a>
+		<a href="https://example.com/?a=1&amp;b=2">Tom &amp; Jerry</a></p>`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []Link{
+		{URL: "https://evil.example.net/login", Text: "log in to paypal.com"},
+		{URL: "https://example.com/?a=1&b=2", Text: "Tom & Jerry"},
+	}
+	if len(found) != len(want) {
+		t.Fatalf("Expected %d links, got %+v", len(want), found)
+	}
+	for i := range want {
+		if found[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], found[i])
+		}
+	}
+}
+
+func TestLink_Host(t *testing.T) {
+	tests := map[string]string{
+		"https://Example.COM:8443/path":     "example.com",
+		"https://аррӏе.com/":                "xn--80ak6aa92e.com",
+		"mailto:bob@example.com?subject=Hi": "bob@example.com",
+	}
+	for link, want := range tests {
+		if got := (Link{URL: link}).Host(); got != want {
+			t.Errorf("Expected the host of %q to be %q, got %q", link, want, got)
+		}
+	}
+}
+
+func TestLink_Mismatched(t *testing.T) {
+	tests := []struct {
+		link Link
+		want bool
+	}{
+		{Link{URL: "https://evil.example.net/login", Text: "paypal.com"}, true},
+		{Link{URL: "https://evil.example.net/login", Text: "https://www.paypal.com/signin"}, true},
+		{Link{URL: "https://www.paypal.com/signin", Text: "paypal.com"}, false},
+		{Link{URL: "https://paypal.com/signin", Text: "www.paypal.com"}, false},
+		{Link{URL: "https://evil.example.net/login", Text: "Log in"}, false},
+		{Link{URL: "https://example.com"}, false},
+	}
+	for _, test := range tests {
+		if got := test.link.Mismatched(); got != test.want {
+			t.Errorf("Expected %+v mismatched to be %v", test.link, test.want)
+		}
+	}
+}
+
+func TestFromMessage(t *testing.T) {
+	raw := "Content-Type: multipart/alternative; boundary=b\r\n" +
+		"\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/plain; charset=utf-8; format=flowed; delsp=yes\r\n" +
+		"\r\n" +
+		"Read the docs at https://example.com/a/very/long/path/that/was/ \r\n" +
+		"wrapped, then log in.\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"\r\n" +
+		`<a href="https://example.com/a/very/long/path/that/was/wrapped">the docs</a>` +
+		`<a href="https://evil.example.net/">paypal.com</a>` + "\r\n" +
+		"--b--\r\n"
+
+	found, err := FromMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []Link{
+		{URL: "https://example.com/a/very/long/path/that/was/wrapped", Text: "the docs"},
+		{URL: "https://evil.example.net/", Text: "paypal.com"},
+	}
+	if len(found) != len(want) {
+		t.Fatalf("Expected %d links, got %+v", len(want), found)
+	}
+	for i := range want {
+		if found[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], found[i])
+		}
+	}
+}
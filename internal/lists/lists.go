@@ -0,0 +1,160 @@
+// Package lists reads the headers mailing lists add to emails (RFC 2369 and RFC 2919), and unsubscribes from them.
+package lists
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/textproto"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charset.Reader}
+
+// FromHeader reads the mailing list an email came through from its header, returning nil if it didn't come through
+// one.
+func FromHeader(header textproto.Header) *core.MailingList {
+	id := header.Get("List-Id")
+	post := header.Get("List-Post")
+	unsubscribe := header.Get("List-Unsubscribe")
+	if id == "" && post == "" && unsubscribe == "" {
+		return nil
+	}
+
+	list := &core.MailingList{}
+	list.Name, list.Id = parseListId(id)
+	for _, uri := range angleBracketed(post) {
+		if address, ok := mailtoAddress(uri); ok {
+			list.Post = address
+			break
+		}
+	}
+	list.Unsubscribe = angleBracketed(unsubscribe)
+	if strings.EqualFold(strings.TrimSpace(header.Get("List-Unsubscribe-Post")), "List-Unsubscribe=One-Click") {
+		_, list.OneClick = OneClickURL(*list)
+	}
+	return list
+}
+
+// parseListId splits a List-Id header like `"Go Nuts" <golang-nuts.googlegroups.com>` into its description and id.
+// The description is optional, and can be encoded like a subject.
+func parseListId(value string) (name, id string) {
+	value = strings.TrimSpace(value)
+	start, end := strings.LastIndex(value, "<"), strings.LastIndex(value, ">")
+	if start == -1 || end < start {
+		return "", value
+	}
+	id = strings.TrimSpace(value[start+1 : end])
+	name = strings.TrimSpace(value[:start])
+	if decoded, err := wordDecoder.DecodeHeader(name); err == nil {
+		name = decoded
+	}
+	return strings.Trim(name, `"`), id
+}
+
+// angleBracketed lists the URIs in a header like "<mailto:leave@example.com>, <https://example.com/leave>".
+// Anything outside the angle brackets is a comment, and is ignored.
+func angleBracketed(value string) []string {
+	var uris []string
+	for {
+		start := strings.Index(value, "<")
+		if start == -1 {
+			return uris
+		}
+		end := strings.Index(value[start:], ">")
+		if end == -1 {
+			return uris
+		}
+		// URIs can be folded across lines, which leaves whitespace in them
+		uri := strings.Join(strings.Fields(value[start+1:start+end]), "")
+		if uri != "" {
+			uris = append(uris, uri)
+		}
+		value = value[start+end+1:]
+	}
+}
+
+func mailtoAddress(uri string) (string, bool) {
+	parsed, err := url.Parse(uri)
+	if err != nil || !strings.EqualFold(parsed.Scheme, "mailto") || parsed.Opaque == "" {
+		return "", false
+	}
+	address, err := url.PathUnescape(parsed.Opaque)
+	if err != nil {
+		return "", false
+	}
+	return address, true
+}
+
+// OneClickURL returns the https: URI to unsubscribe with a POST, if the list says it can be (RFC 8058).
+func OneClickURL(list core.MailingList) (string, bool) {
+	for _, uri := range list.Unsubscribe {
+		if parsed, err := url.Parse(uri); err == nil && strings.EqualFold(parsed.Scheme, "https") {
+			return uri, true
+		}
+	}
+	return "", false
+}
+
+// MailtoURI returns the mailto: URI to unsubscribe by sending an email, if the list has one.
+func MailtoURI(list core.MailingList) (string, bool) {
+	for _, uri := range list.Unsubscribe {
+		if _, ok := mailtoAddress(uri); ok {
+			return uri, true
+		}
+	}
+	return "", false
+}
+
+// UnsubscribeOneClick unsubscribes with the POST that RFC 8058 describes. It only sends the one form field, without
+// cookies or anything else that could identify who's unsubscribing beyond the URI itself.
+func UnsubscribeOneClick(ctx context.Context, client *http.Client, uri string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, strings.NewReader("List-Unsubscribe=One-Click"))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("the list's server replied %s", response.Status)
+	}
+	return nil
+}
+
+// UnsubscribeEmail makes the email a mailto: URI asks for (RFC 6068), which is usually to the list's request address
+// with a subject like "unsubscribe".
+func UnsubscribeEmail(uri string) (core.OutgoingEmail, error) {
+	address, ok := mailtoAddress(uri)
+	if !ok {
+		return core.OutgoingEmail{}, errors.New("not a mailto: URI: " + uri)
+	}
+	parsed, _ := url.Parse(uri)
+	query := parsed.Query()
+	to := []string{address}
+	if extra := query.Get("to"); extra != "" {
+		to = append(to, extra)
+	}
+	email := core.OutgoingEmail{
+		To:      strings.Join(to, ", "),
+		Subject: query.Get("subject"),
+		Body:    query.Get("body"),
+	}
+	if email.Subject == "" {
+		email.Subject = "unsubscribe"
+	}
+	return email, nil
+}
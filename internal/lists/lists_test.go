@@ -0,0 +1,108 @@
+package lists
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/emersion/go-message/textproto"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+func TestFromHeader(t *testing.T) {
+	var header textproto.Header
+	header.Set("List-Id", `"=?utf-8?q?Caf=C3=A9_Lovers?=" <cafe.lists.example.com>`)
+	header.Set("List-Post", "<mailto:cafe@lists.example.com?subject=hello>")
+	header.Set("List-Unsubscribe", "<mailto:cafe-leave@lists.example.com?subject=unsubscribe>,\r\n <https://lists.example.com/\r\n leave/cafe> (web)")
+	header.Set("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+
+	list := FromHeader(header)
+	want := &core.MailingList{
+		Id:   "cafe.lists.example.com",
+		Name: "Café Lovers",
+		Post: "cafe@lists.example.com",
+		Unsubscribe: []string{
+			"mailto:cafe-leave@lists.example.com?subject=unsubscribe",
+			"https://lists.example.com/leave/cafe",
+		},
+		OneClick: true,
+	}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("expected %+v, got %+v", want, list)
+	}
+}
+
+func TestFromHeader_NotAList(t *testing.T) {
+	var header textproto.Header
+	header.Set("Subject", "Hello")
+	if list := FromHeader(header); list != nil {
+		t.Errorf("expected no list, got %+v", list)
+	}
+}
+
+func TestFromHeader_NoPostingAndNoOneClick(t *testing.T) {
+	var header textproto.Header
+	header.Set("List-Id", "<announce.example.com>")
+	header.Set("List-Post", "NO (posting not allowed)")
+	// asking for one-click without an https: URI doesn't make it one-click
+	header.Set("List-Unsubscribe", "<mailto:leave@example.com>")
+	header.Set("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+
+	list := FromHeader(header)
+	if list == nil || list.Id != "announce.example.com" || list.Name != "" || list.Post != "" || list.OneClick {
+		t.Errorf("unexpected list %+v", list)
+	}
+	if list.Label() != "announce.example.com" {
+		t.Errorf("expected the list to be labelled by its id, got %q", list.Label())
+	}
+}
+
+func TestUnsubscribeOneClick(t *testing.T) {
+	var method, contentType, body string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, contentType = r.Method, r.Header.Get("Content-Type")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	if err := UnsubscribeOneClick(context.Background(), server.Client(), server.URL+"/leave"); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPost || contentType != "application/x-www-form-urlencoded" || body != "List-Unsubscribe=One-Click" {
+		t.Errorf("unexpected request: %s %s %q", method, contentType, body)
+	}
+
+	if err := UnsubscribeOneClick(context.Background(), server.Client(), server.URL+"/gone"); err == nil {
+		t.Error("expected an error when the server doesn't accept the request")
+	}
+}
+
+func TestUnsubscribeEmail(t *testing.T) {
+	email, err := UnsubscribeEmail("mailto:leave%2Bcafe@example.com?subject=Please%20remove%20me&body=unsubscribe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.To != "leave+cafe@example.com" || email.Subject != "Please remove me" || email.Body != "unsubscribe" {
+		t.Errorf("unexpected email %+v", email)
+	}
+
+	email, err = UnsubscribeEmail("mailto:leave@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.Subject != "unsubscribe" {
+		t.Errorf("expected a default subject, got %q", email.Subject)
+	}
+
+	if _, err := UnsubscribeEmail("https://example.com/leave"); err == nil {
+		t.Error("expected an error for a URI that isn't mailto:")
+	}
+}
@@ -0,0 +1,352 @@
+// Package mailcap reads mailcap files (RFC 1524), which say which programs to view each type of file with, and runs
+// them on parts of emails.
+package mailcap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Entry is a line of a mailcap file, saying how to view one type of file.
+type Entry struct {
+	// Type is a MIME type like "application/pdf", or a whole kind of type like "image/*"
+	Type string
+	// Command is run by sh to view the file, with %s replaced by its filename. If there isn't a %s, the file is
+	// given to the command on its standard input instead.
+	Command string
+	// CopiousOutput is set when the command writes the file out as text, which is shown rather than the command
+	// being left to show it itself
+	CopiousOutput bool
+	// NeedsTerminal is set when the command takes over the terminal, like a pager
+	NeedsTerminal bool
+	// Test is a command that has to succeed for the entry to be used, like checking there's a display to show
+	// images on
+	Test string
+	// NameTemplate is how to name the file, like "%s.pdf" for programs that go by the extension
+	NameTemplate string
+	Description  string
+}
+
+type Mailcap struct {
+	entries []Entry
+}
+
+// DefaultPaths returns the mailcap files to read, from $MAILCAPS if it's set, or otherwise the user's file followed
+// by the system-wide ones RFC 1524 lists.
+func DefaultPaths() []string {
+	if paths := os.Getenv("MAILCAPS"); paths != "" {
+		return filepath.SplitList(paths)
+	}
+	var paths []string
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".mailcap"))
+	}
+	return append(paths, "/etc/mailcap", "/usr/etc/mailcap", "/usr/local/etc/mailcap")
+}
+
+// Load reads the mailcap files in order, so entries in earlier files are used first. Files that don't exist are
+// skipped.
+func Load(paths ...string) (*Mailcap, error) {
+	mailcap := &Mailcap{}
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries, err := Parse(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		mailcap.entries = append(mailcap.entries, entries...)
+	}
+	return mailcap, nil
+}
+
+// Parse reads the entries in a mailcap file. Lines can be continued with a backslash at the end, and a semicolon
+// that's part of a field is escaped with a backslash.
+func Parse(r io.Reader) ([]Entry, error) {
+	var (
+		entries []Entry
+		line    strings.Builder
+		number  int
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		number++
+		text := scanner.Text()
+		if strings.HasSuffix(text, "\\") {
+			line.WriteString(strings.TrimSuffix(text, "\\"))
+			continue
+		}
+		line.WriteString(text)
+		full := strings.TrimSpace(line.String())
+		line.Reset()
+		if full == "" || strings.HasPrefix(full, "#") {
+			continue
+		}
+
+		entry, err := parseEntry(full)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func parseEntry(line string) (Entry, error) {
+	fields := splitFields(line)
+	if len(fields) < 2 {
+		return Entry{}, errors.New("expected a type and a command")
+	}
+
+	entry := Entry{
+		Type:    strings.ToLower(fields[0]),
+		Command: fields[1],
+	}
+	// a type on its own, like "text", means all of its subtypes
+	if !strings.Contains(entry.Type, "/") {
+		entry.Type += "/*"
+	}
+	for _, field := range fields[2:] {
+		key, value, _ := strings.Cut(field, "=")
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "copiousoutput":
+			entry.CopiousOutput = true
+		case "needsterminal":
+			entry.NeedsTerminal = true
+		case "test":
+			entry.Test = strings.TrimSpace(value)
+		case "nametemplate":
+			entry.NameTemplate = strings.TrimSpace(value)
+		case "description":
+			entry.Description = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return entry, nil
+}
+
+// splitFields splits a line at the semicolons that aren't escaped. Other escapes are kept for expanding the command.
+func splitFields(line string) []string {
+	var (
+		fields  []string
+		current strings.Builder
+	)
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == ';':
+			current.WriteByte(';')
+			i++
+		case line[i] == '\\' && i+1 < len(line):
+			current.WriteString(line[i : i+2])
+			i++
+		case line[i] == ';':
+			fields = append(fields, strings.TrimSpace(current.String()))
+			current.Reset()
+		default:
+			current.WriteByte(line[i])
+		}
+	}
+	return append(fields, strings.TrimSpace(current.String()))
+}
+
+// Lookup finds the first entry for a type whose test passes. Tests are run without a file, so %s in them is empty.
+func (m *Mailcap) Lookup(contentType string, params map[string]string) (Entry, bool) {
+	if m == nil {
+		return Entry{}, false
+	}
+	contentType = strings.ToLower(contentType)
+	kind, _, _ := strings.Cut(contentType, "/")
+	for _, entry := range m.entries {
+		if entry.Type != contentType && entry.Type != kind+"/*" && entry.Type != "*/*" {
+			continue
+		}
+		if entry.Test != "" && shell(entry.Test, "", contentType, params).Run() != nil {
+			continue
+		}
+		return entry, true
+	}
+	return Entry{}, false
+}
+
+var paramPattern = regexp.MustCompile(`%\{([^}]*)\}`)
+
+// shell quoting states that expand keeps track of, so it knows how to refer to a value wherever the entry puts it
+const (
+	unquoted = iota
+	singleQuoted
+	doubleQuoted
+)
+
+// expand fills in a command, returning the script for sh and the values it refers to. Nothing from the email is put in
+// the script itself, since a type, filename or parameter made up by whoever sent it could otherwise run commands, even
+// when the entry quotes it like "%s": each value is a positional parameter instead, which sh never reads as code.
+func expand(command, filename, contentType string, params map[string]string) (string, []string) {
+	var (
+		b     strings.Builder
+		args  []string
+		state = unquoted
+		// escaped is set after a backslash in the script, which stops the next character ending a quote
+		escaped bool
+	)
+	write := func(c byte) {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && state != singleQuoted:
+			escaped = true
+		case c == '\'' && state == unquoted:
+			state = singleQuoted
+		case c == '\'' && state == singleQuoted:
+			state = unquoted
+		case c == '"' && state == unquoted:
+			state = doubleQuoted
+		case c == '"' && state == doubleQuoted:
+			state = unquoted
+		}
+		b.WriteByte(c)
+	}
+	value := func(v string) {
+		args = append(args, v)
+		parameter := fmt.Sprintf("${%d}", len(args))
+		switch state {
+		case doubleQuoted:
+			b.WriteString(parameter)
+		case singleQuoted:
+			// the single quotes are closed around it, as nothing is expanded inside them
+			b.WriteString(`'"` + parameter + `"'`)
+		default:
+			b.WriteString(`"` + parameter + `"`)
+		}
+	}
+
+	for i := 0; i < len(command); i++ {
+		switch {
+		case command[i] == '\\' && i+1 < len(command):
+			write(command[i+1])
+			i++
+		case command[i] == '%' && i+1 < len(command):
+			switch command[i+1] {
+			case 's':
+				value(filename)
+			case 't':
+				value(contentType)
+			case '%':
+				write('%')
+			case '{':
+				match := paramPattern.FindStringSubmatch(command[i:])
+				if match == nil {
+					write('%')
+					continue
+				}
+				value(params[strings.ToLower(match[1])])
+				i += len(match[0]) - 2
+			default:
+				write(command[i])
+				write(command[i+1])
+			}
+			i++
+		default:
+			write(command[i])
+		}
+	}
+	return b.String(), args
+}
+
+// shell sets up a command from a mailcap entry to be run by sh, with the values it refers to as its arguments.
+func shell(command, filename, contentType string, params map[string]string) *exec.Cmd {
+	script, args := expand(command, filename, contentType, params)
+	// the first argument after the script is $0, which is only used in error messages
+	return exec.Command("sh", append([]string{"-c", script, "mailcap"}, args...)...)
+}
+
+// Command is an entry's command ready to run on a file. Cleanup has to be called once it's finished, to remove the
+// file.
+type Command struct {
+	*exec.Cmd
+	Entry Entry
+
+	dir   string
+	stdin *os.File
+}
+
+// Prepare writes data to a temporary file named after the original filename, or the entry's name template, and sets
+// up the command to view it.
+func (e Entry) Prepare(data []byte, contentType string, params map[string]string, filename string) (*Command, error) {
+	dir, err := os.MkdirTemp("", "mail-tui-")
+	if err != nil {
+		return nil, err
+	}
+	command := &Command{Entry: e, dir: dir}
+
+	path := filepath.Join(dir, e.filename(filename))
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		command.Cleanup()
+		return nil, err
+	}
+
+	command.Cmd = shell(e.Command, path, contentType, params)
+	if !usesFile(e.Command) {
+		command.stdin, err = os.Open(path)
+		if err != nil {
+			command.Cleanup()
+			return nil, err
+		}
+		command.Stdin = command.stdin
+	}
+	return command, nil
+}
+
+var unsafeFilenameCharacters = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// filename names the temporary file, keeping the extension of the original filename for programs that go by it.
+func (e Entry) filename(original string) string {
+	name := unsafeFilenameCharacters.ReplaceAllString(filepath.Base(original), "_")
+	name = strings.TrimLeft(name, ".")
+	if name == "" || name == "_" {
+		name = "part"
+	}
+	if e.NameTemplate != "" {
+		name = strings.ReplaceAll(e.NameTemplate, "%s", strings.TrimSuffix(name, filepath.Ext(name)))
+		name = unsafeFilenameCharacters.ReplaceAllString(filepath.Base(name), "_")
+	}
+	return name
+}
+
+// usesFile reports whether a command is given the filename, rather than reading the file from its standard input.
+func usesFile(command string) bool {
+	for i := 0; i < len(command)-1; i++ {
+		if command[i] == '\\' {
+			i++
+			continue
+		}
+		if command[i] == '%' {
+			if command[i+1] == 's' {
+				return true
+			}
+			// skips the character after it, so "%%s" isn't taken for the filename
+			i++
+		}
+	}
+	return false
+}
+
+// Cleanup removes the temporary file.
+func (c *Command) Cleanup() error {
+	if c.stdin != nil {
+		c.stdin.Close()
+	}
+	return os.RemoveAll(c.dir)
+}
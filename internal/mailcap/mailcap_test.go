@@ -0,0 +1,184 @@
+package mailcap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testMailcap = `# a comment
+application/pdf; zathura %s; test=test -n "$DISPLAY"
+application/pdf; pdftotext %s -; copiousoutput; description="PDF as text"
+text/html; lynx -dump -assume_charset=%{charset} %s; nametemplate=%s.html; copiousoutput
+image; display \
+	-title 'image\; from email' %s
+text/calendar; cat; copiousoutput
+text/plain; less; needsterminal
+`
+
+func parseTestMailcap(t *testing.T) *Mailcap {
+	t.Helper()
+	entries, err := Parse(strings.NewReader(testMailcap))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return &Mailcap{entries: entries}
+}
+
+func TestParse(t *testing.T) {
+	mailcap := parseTestMailcap(t)
+
+	if len(mailcap.entries) != 6 {
+		t.Fatalf("Expected 6 entries, got %+v", mailcap.entries)
+	}
+	pdf := mailcap.entries[1]
+	if pdf.Type != "application/pdf" || pdf.Command != "pdftotext %s -" || !pdf.CopiousOutput || pdf.Description != "PDF as text" {
+		t.Errorf("Unexpected entry %+v", pdf)
+	}
+	image := mailcap.entries[3]
+	if image.Type != "image/*" || image.Command != "display \t-title 'image; from email' %s" {
+		t.Errorf("Expected the continued line and escaped semicolon to be read, got %+v", image)
+	}
+	if !mailcap.entries[5].NeedsTerminal {
+		t.Errorf("Expected needsterminal to be read, got %+v", mailcap.entries[5])
+	}
+}
+
+func TestParse_MissingCommand(t *testing.T) {
+	if _, err := Parse(strings.NewReader("\n\napplication/pdf\n")); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Expected an error naming the line, got %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user")
+	system := filepath.Join(dir, "system")
+	if err := os.WriteFile(user, []byte("image/png; feh %s\n"), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := os.WriteFile(system, []byte("image/*; display %s\n"), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	mailcap, err := Load(user, filepath.Join(dir, "missing"), system)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if entry, ok := mailcap.Lookup("IMAGE/PNG", nil); !ok || entry.Command != "feh %s" {
+		t.Errorf("Expected the user's entry to come first, got %+v", entry)
+	}
+	if entry, ok := mailcap.Lookup("image/jpeg", nil); !ok || entry.Command != "display %s" {
+		t.Errorf("Expected the wildcard entry to match, got %+v", entry)
+	}
+}
+
+func TestLookup_Test(t *testing.T) {
+	t.Setenv("DISPLAY", "")
+	mailcap := parseTestMailcap(t)
+
+	entry, ok := mailcap.Lookup("application/pdf", nil)
+
+	if !ok || entry.Command != "pdftotext %s -" {
+		t.Errorf("Expected the entry whose test fails to be skipped, got %+v", entry)
+	}
+	if _, ok := mailcap.Lookup("application/zip", nil); ok {
+		t.Error("Expected no entry for an unknown type")
+	}
+	if _, ok := (*Mailcap)(nil).Lookup("application/pdf", nil); ok {
+		t.Error("Expected no entry without a mailcap")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	script, args := expand(`view %s --type=%t --charset=%{Charset} 100%% \%s`, "/tmp/part.txt", "text/plain",
+		map[string]string{"charset": "utf-8'; rm -rf ~; '"})
+
+	want := `view "${1}" --type="${2}" --charset="${3}" 100% %s`
+	if script != want {
+		t.Errorf("Expected %q, got %q", want, script)
+	}
+	if strings.Join(args, "|") != "/tmp/part.txt|text/plain|utf-8'; rm -rf ~; '" {
+		t.Errorf("Expected the values as arguments, got %q", args)
+	}
+}
+
+func TestExpand_Quoted(t *testing.T) {
+	script, _ := expand(`view "%s" 'charset=%{charset}' "it's %t"`, "/tmp/part.txt", "text/plain", nil)
+
+	want := `view "${1}" 'charset='"${2}"'' "it's ${3}"`
+	if script != want {
+		t.Errorf("Expected %q, got %q", want, script)
+	}
+}
+
+// Values from the email mustn't run as commands, even where the entry already quotes them.
+func TestShell_QuotedParameterIsNotRun(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	params := map[string]string{"charset": "$(touch " + marker + ")`touch " + marker + "`"}
+
+	for _, command := range []string{`echo charset="%{charset}"`, `echo '%{charset}'`, `echo %{charset}`} {
+		output, err := shell(command, "", "text/plain", params).Output()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := os.Stat(marker); !os.IsNotExist(err) {
+			t.Fatalf("Expected the charset not to run a command in %q", command)
+		}
+		if !strings.Contains(string(output), "$(touch ") {
+			t.Errorf("Expected the charset to be passed as it is to %q, got %q", command, output)
+		}
+	}
+
+	mailcap := &Mailcap{entries: []Entry{{Type: "text/html", Command: "cat", Test: `test -n "%{charset}"`}}}
+	if _, ok := mailcap.Lookup("text/html", params); !ok {
+		t.Error("Expected the test to see the charset")
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("Expected looking up an entry not to run the charset")
+	}
+}
+
+func TestEntry_Prepare(t *testing.T) {
+	entry := Entry{Type: "text/html", Command: "cat %s", NameTemplate: "%s.html", CopiousOutput: true}
+
+	command, err := entry.Prepare([]byte("<p>Hello</p>"), "text/html", nil, "../../evil name.htm")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	output, err := command.Output()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(output) != "<p>Hello</p>" {
+		t.Errorf("Expected the part to be written out, got %q", output)
+	}
+	path := command.Args[len(command.Args)-1]
+	if !strings.Contains(path, "evil_name.html") || strings.Contains(path, "..") {
+		t.Errorf("Expected a safe filename from the name template, got %q", path)
+	}
+
+	if err := command.Cleanup(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(command.dir); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file to be removed, got %v", err)
+	}
+}
+
+func TestEntry_Prepare_Stdin(t *testing.T) {
+	entry := Entry{Type: "text/calendar", Command: "tr a-z A-Z", CopiousOutput: true}
+
+	command, err := entry.Prepare([]byte("begin:vcalendar"), "text/calendar", nil, "invite.ics")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer command.Cleanup()
+
+	output, err := command.Output()
+	if err != nil || string(output) != "BEGIN:VCALENDAR" {
+		t.Errorf("Expected the part on standard input, got %q and %v", output, err)
+	}
+}
@@ -0,0 +1,88 @@
+package message
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+func TestBuildAndParse_FlowedQuotedReply(t *testing.T) {
+	body := "Thursday works for me, and I'll bring the slides so we can go through the plan for next quarter.\n\n" +
+		"> Shall we meet at 10 on Thursday? I'd like to go over the roadmap and the budget for the whole of next year.\n" +
+		">> From the last meeting: we need an agenda.\n"
+	email := core.OutgoingEmail{From: "me@example.com", To: "you@example.com", Body: body, MessageId: "flowed@example.com"}
+
+	raw, err := Build(email, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error building the message: %v", err)
+	}
+	if !strings.Contains(string(raw), "format=flowed") {
+		t.Errorf("Expected the text to be sent as format=flowed, got:\n%s", raw)
+	}
+
+	parsed, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing the message: %v", err)
+	}
+	if parsed.Body != body {
+		t.Errorf("Expected the reply to round-trip:\n%q\ngot:\n%q", body, parsed.Body)
+	}
+
+	received, isFlowed, err := ParseBody(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Unexpected error reading the body: %v", err)
+	}
+	if received != body || !isFlowed {
+		t.Errorf("Expected the flowed body to be joined back together, got %q", received)
+	}
+}
+
+func TestParseBody(t *testing.T) {
+	tests := map[string]struct {
+		raw        string
+		wantBody   string
+		wantFlowed bool
+	}{
+		"flowed with delsp": {
+			raw:        "Content-Type: text/plain; format=flowed; delsp=yes\r\n\r\nJoined \r\ntogether\r\n",
+			wantBody:   "Joinedtogether\n",
+			wantFlowed: true,
+		},
+		"fixed": {
+			raw:      "Content-Type: text/plain\r\n\r\nNot \r\njoined\r\n",
+			wantBody: "Not \njoined\n",
+		},
+		"quoted-printable latin-1": {
+			raw:      "Content-Type: text/plain; charset=iso-8859-1\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nCaf=E9\r\n",
+			wantBody: "Café\n",
+		},
+		"no content type": {
+			raw:      "Subject: Hi\r\n\r\nPlain\r\n",
+			wantBody: "Plain\n",
+		},
+		"plain text preferred over html": {
+			raw: "Content-Type: multipart/alternative; boundary=b\r\n\r\n" +
+				"--b\r\nContent-Type: text/html\r\n\r\n<p>HTML</p>\r\n" +
+				"--b\r\nContent-Type: text/plain\r\n\r\nText\r\n--b--\r\n",
+			wantBody: "Text",
+		},
+		"html only": {
+			raw:      "Content-Type: text/html\r\n\r\n<p>HTML</p>\r\n",
+			wantBody: "<p>HTML</p>\n",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			body, isFlowed, err := ParseBody(strings.NewReader(test.raw))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if body != test.wantBody || isFlowed != test.wantFlowed {
+				t.Errorf("Expected %q (flowed %v), got %q (flowed %v)", test.wantBody, test.wantFlowed, body, isFlowed)
+			}
+		})
+	}
+}
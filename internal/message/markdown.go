@@ -0,0 +1,23 @@
+package message
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// markdown renders GitHub-flavoured Markdown, like tables and ~~strikethrough~~. Raw HTML in the Markdown is left
+// out rather than passed through, so nothing typed into an email can inject markup.
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// RenderMarkdown renders a body written in Markdown as an HTML document for the text/html part of an email.
+func RenderMarkdown(body string) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n</head>\n<body>\n")
+	if err := markdown.Convert([]byte(body), &b); err != nil {
+		return nil, err
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.Bytes(), nil
+}
@@ -0,0 +1,117 @@
+package message
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-message/mail"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+// readParts returns the content type and contents of every part of a message, in order.
+func readParts(t *testing.T, raw []byte) ([]string, []string) {
+	t.Helper()
+	reader, err := mail.CreateReader(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var types, contents []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		contentType, _, _ := part.Header.(interface {
+			ContentType() (string, map[string]string, error)
+		}).ContentType()
+		data, _ := io.ReadAll(part.Body)
+		types = append(types, contentType)
+		contents = append(contents, string(data))
+	}
+	return types, contents
+}
+
+func TestBuildAndParse_Markdown(t *testing.T) {
+	email := core.OutgoingEmail{
+		From:      "me@example.com",
+		To:        "you@example.com",
+		Subject:   "Status",
+		Body:      "# Update\n\nIt's **fixed**.\n\n<script>alert(1)</script>\n",
+		Markdown:  true,
+		MessageId: "status@example.com",
+	}
+
+	raw, err := Build(email, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error building the message: %v", err)
+	}
+	if !strings.Contains(string(raw), "multipart/alternative") {
+		t.Errorf("Expected a multipart/alternative message, got:\n%s", raw)
+	}
+
+	types, contents := readParts(t, raw)
+	if len(types) != 2 || types[0] != "text/plain" || types[1] != "text/html" {
+		t.Fatalf("Expected the text then the HTML, got %v", types)
+	}
+	if strings.ReplaceAll(contents[0], "\r\n", "\n") != email.Body {
+		t.Errorf("Expected the original Markdown as the text, got %q", contents[0])
+	}
+	if !strings.Contains(contents[1], "<h1>Update</h1>") || !strings.Contains(contents[1], "<strong>fixed</strong>") {
+		t.Errorf("Expected the Markdown to be rendered, got %q", contents[1])
+	}
+	if strings.Contains(contents[1], "<script>") {
+		t.Errorf("Expected raw HTML to be left out, got %q", contents[1])
+	}
+
+	parsed, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing the message: %v", err)
+	}
+	if parsed.Body != email.Body || !parsed.Markdown {
+		t.Errorf("Expected the Markdown body to round-trip, got %+v", parsed)
+	}
+}
+
+func TestBuildAndParse_MarkdownWithAttachments(t *testing.T) {
+	email := core.OutgoingEmail{
+		From:        "me@example.com",
+		To:          "you@example.com",
+		Body:        "See *attached*.\n",
+		Markdown:    true,
+		MessageId:   "attached@example.com",
+		Attachments: []core.Attachment{{Filename: "a.txt", ContentType: "text/plain", Data: []byte("a")}},
+	}
+
+	raw, err := Build(email, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error building the message: %v", err)
+	}
+	if !strings.Contains(string(raw), "multipart/mixed") || !strings.Contains(string(raw), "multipart/alternative") {
+		t.Errorf("Expected the alternatives inside a multipart/mixed message, got:\n%s", raw)
+	}
+
+	parsed, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing the message: %v", err)
+	}
+	if parsed.Body != email.Body || !parsed.Markdown || len(parsed.Attachments) != 1 {
+		t.Errorf("Expected the email to round-trip, got %+v", parsed)
+	}
+}
+
+func TestBuild_PlainTextHasNoHtml(t *testing.T) {
+	raw, err := Build(core.OutgoingEmail{From: "me@example.com", Body: "*not markdown*", MessageId: "a@example.com"}, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(string(raw), "text/html") {
+		t.Errorf("Expected only plain text, got:\n%s", raw)
+	}
+}
@@ -0,0 +1,429 @@
+// Package message converts between outgoing emails and the RFC 5322 messages that are sent and stored on servers.
+package message
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	gomessage "github.com/emersion/go-message"
+	// registers decoders for charsets other than UTF-8, like ISO-8859-1
+	_ "github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/mail"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/flowed"
+)
+
+// Build writes an email as an RFC 5322 message, which is plain text unless it has attachments, in which case it's
+// multipart/mixed with the text first. Bodies written in Markdown are sent as a multipart/alternative of the text and
+// the HTML it renders to, and a calendar object goes in the same multipart/alternative after them, as RFC 6047 has it.
+// The email must have a sender and a message ID, but it can have no recipients if it's a draft. The Bcc recipients are
+// left out, since the message is what everyone it's sent to gets.
+func Build(email core.OutgoingEmail, date time.Time) ([]byte, error) {
+	return build(email, date, false)
+}
+
+// BuildDraft writes a draft like Build, but keeps the Bcc recipients in a Bcc field, so they're still there when the
+// draft is opened again.
+func BuildDraft(email core.OutgoingEmail, date time.Time) ([]byte, error) {
+	return build(email, date, true)
+}
+
+func build(email core.OutgoingEmail, date time.Time, withBcc bool) ([]byte, error) {
+	if email.MessageId == "" {
+		return nil, errors.New("email has no message ID")
+	}
+
+	from, err := mail.ParseAddress(email.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", email.From, err)
+	}
+
+	var header mail.Header
+	header.SetDate(date)
+	header.SetAddressList("From", []*mail.Address{from})
+	if email.ReplyTo != "" {
+		replyTo, err := mail.ParseAddressList(email.ReplyTo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reply-to address %q: %w", email.ReplyTo, err)
+		}
+		header.SetAddressList("Reply-To", replyTo)
+	}
+	recipients := []struct{ key, value string }{{"To", email.To}, {"Cc", email.Cc}}
+	if withBcc {
+		recipients = append(recipients, struct{ key, value string }{"Bcc", email.Bcc})
+	}
+	for _, field := range recipients {
+		if strings.TrimSpace(field.value) == "" {
+			continue
+		}
+		addresses, err := mail.ParseAddressList(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipients %q: %w", field.value, err)
+		}
+		header.SetAddressList(field.key, addresses)
+	}
+	header.SetSubject(email.Subject)
+	header.SetMessageID(email.MessageId)
+	if email.InReplyTo != "" {
+		header.SetMsgIDList("In-Reply-To", []string{email.InReplyTo})
+	}
+	header.SetMsgIDList("References", email.References)
+
+	var html []byte
+	if email.Markdown {
+		html, err = RenderMarkdown(email.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var b bytes.Buffer
+	if len(email.Attachments) == 0 {
+		if html != nil || email.Calendar != nil {
+			writer, err := mail.CreateInlineWriter(&b, header)
+			if err != nil {
+				return nil, err
+			}
+			if err := writeAlternatives(writer, email.Body, html, email.Calendar); err != nil {
+				return nil, err
+			}
+			return b.Bytes(), nil
+		}
+		contentType, params := textContentType()
+		header.SetContentType(contentType, params)
+		writer, err := mail.CreateSingleInlineWriter(&b, header)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeAndClose(writer, []byte(flowed.Encode(email.Body, flowed.LineLength))); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	writer, err := mail.CreateWriter(&b, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if html != nil || email.Calendar != nil {
+		inline, err := writer.CreateInline()
+		if err != nil {
+			return nil, err
+		}
+		if err := writeAlternatives(inline, email.Body, html, email.Calendar); err != nil {
+			return nil, err
+		}
+	} else {
+		text, err := writer.CreateSingleInline(textHeader())
+		if err != nil {
+			return nil, err
+		}
+		if err := writeAndClose(text, []byte(flowed.Encode(email.Body, flowed.LineLength))); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, attachment := range email.Attachments {
+		var attachmentHeader mail.AttachmentHeader
+		contentType, params, err := mime.ParseMediaType(attachment.ContentType)
+		if err != nil {
+			contentType, params = "application/octet-stream", nil
+		}
+		attachmentHeader.SetContentType(contentType, params)
+		attachmentHeader.SetFilename(attachment.Filename)
+		part, err := writer.CreateAttachment(attachmentHeader)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeAndClose(part, attachment.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeAlternatives writes the plain-text version of the body, then the HTML and calendar versions if there are any,
+// as a multipart/alternative, with the plain text first since clients show the last version they understand.
+func writeAlternatives(writer *mail.InlineWriter, text string, html []byte, calendar *core.CalendarPart) error {
+	textPart, err := writer.CreatePart(textHeader())
+	if err != nil {
+		return err
+	}
+	if err := writeAndClose(textPart, []byte(flowed.Encode(text, flowed.LineLength))); err != nil {
+		return err
+	}
+
+	if html != nil {
+		var htmlHeader mail.InlineHeader
+		htmlHeader.SetContentType("text/html", map[string]string{"charset": "utf-8"})
+		htmlPart, err := writer.CreatePart(htmlHeader)
+		if err != nil {
+			return err
+		}
+		if err := writeAndClose(htmlPart, html); err != nil {
+			return err
+		}
+	}
+
+	if calendar != nil {
+		var calendarHeader mail.InlineHeader
+		calendarHeader.SetContentType("text/calendar", map[string]string{"charset": "utf-8", "method": calendar.Method})
+		calendarPart, err := writer.CreatePart(calendarHeader)
+		if err != nil {
+			return err
+		}
+		if err := writeAndClose(calendarPart, calendar.Data); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+func textHeader() mail.InlineHeader {
+	var header mail.InlineHeader
+	header.SetContentType(textContentType())
+	return header
+}
+
+// textContentType is the type of the plain-text body, which is sent as format=flowed (RFC 3676) so that clients can
+// rewrap its paragraphs to fit.
+func textContentType() (string, map[string]string) {
+	return "text/plain", map[string]string{"charset": "utf-8", "format": "flowed"}
+}
+
+func writeAndClose(writer io.WriteCloser, data []byte) error {
+	if _, err := writer.Write(data); err != nil {
+		_ = writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// Parse reads an RFC 5322 message back into an outgoing email, e.g. to carry on editing a draft.
+// The body is taken from the first plain-text part, and any attachments are kept.
+func Parse(r io.Reader) (*core.OutgoingEmail, error) {
+	reader, err := mail.CreateReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	email := &core.OutgoingEmail{}
+	if from, err := reader.Header.AddressList("From"); err == nil {
+		email.From = formatAddressList(from)
+	}
+	if replyTo, err := reader.Header.AddressList("Reply-To"); err == nil {
+		email.ReplyTo = formatAddressList(replyTo)
+	}
+	if to, err := reader.Header.AddressList("To"); err == nil {
+		email.To = formatAddressList(to)
+	}
+	if cc, err := reader.Header.AddressList("Cc"); err == nil {
+		email.Cc = formatAddressList(cc)
+	}
+	if bcc, err := reader.Header.AddressList("Bcc"); err == nil {
+		email.Bcc = formatAddressList(bcc)
+	}
+	email.Subject, _ = reader.Header.Subject()
+	email.MessageId, _ = reader.Header.MessageID()
+	if inReplyTo, err := reader.Header.MsgIDList("In-Reply-To"); err == nil && len(inReplyTo) > 0 {
+		email.InReplyTo = inReplyTo[0]
+	}
+	email.References, _ = reader.Header.MsgIDList("References")
+
+	foundBody := false
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch header := part.Header.(type) {
+		case *mail.InlineHeader:
+			contentType, params, _ := header.ContentType()
+			// an HTML version means the body was written in Markdown, since that's the only way one is added
+			if contentType == "text/html" {
+				email.Markdown = true
+			}
+			if foundBody || (contentType != "text/plain" && contentType != "") {
+				continue
+			}
+			body, err := io.ReadAll(part.Body)
+			if err != nil {
+				return nil, err
+			}
+			email.Body = decodeText(body, params)
+			foundBody = true
+		case *mail.AttachmentHeader:
+			data, err := io.ReadAll(part.Body)
+			if err != nil {
+				return nil, err
+			}
+			filename, _ := header.Filename()
+			contentType, params, _ := header.ContentType()
+			email.Attachments = append(email.Attachments, core.Attachment{
+				Filename:    filename,
+				ContentType: mime.FormatMediaType(contentType, params),
+				Data:        data,
+			})
+		}
+	}
+	return email, nil
+}
+
+// ParseBody reads the text of a received message to show it: the first plain-text part, decoded from its transfer
+// encoding and charset, or the first other text part if there isn't one. isFlowed is set if it was format=flowed, in
+// which case its paragraphs have been joined back into single lines to be wrapped to fit.
+func ParseBody(r io.Reader) (body string, isFlowed bool, err error) {
+	reader, err := mail.CreateReader(r)
+	if err != nil && !gomessage.IsUnknownCharset(err) {
+		return "", false, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	fallback, foundFallback := "", false
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !gomessage.IsUnknownCharset(err) {
+			return "", false, err
+		}
+		header, ok := part.Header.(*mail.InlineHeader)
+		if !ok {
+			continue
+		}
+
+		contentType, params, _ := header.ContentType()
+		if contentType != "text/plain" && contentType != "" && (foundFallback || !strings.HasPrefix(contentType, "text/")) {
+			continue
+		}
+		data, err := io.ReadAll(part.Body)
+		if err != nil {
+			return "", false, err
+		}
+		if contentType != "text/plain" && contentType != "" {
+			fallback, foundFallback = strings.ReplaceAll(string(data), "\r\n", "\n"), true
+			continue
+		}
+		return decodeText(data, params), strings.EqualFold(params["format"], "flowed"), nil
+	}
+	return fallback, false, nil
+}
+
+// decodeText normalises the line endings of a plain-text part, joining its lines back together if it's flowed.
+func decodeText(body []byte, params map[string]string) string {
+	text := strings.ReplaceAll(string(body), "\r\n", "\n")
+	if strings.EqualFold(params["format"], "flowed") {
+		return flowed.Decode(text, strings.EqualFold(params["delsp"], "yes"))
+	}
+	return text
+}
+
+// DetectContentType works out the MIME type of an attachment from its file extension, or its contents if the
+// extension isn't recognised.
+func DetectContentType(filename string, data []byte) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(filename)); contentType != "" {
+		return contentType
+	}
+	return http.DetectContentType(data)
+}
+
+// attachmentWords are the words that suggest an email should have an attachment.
+var attachmentWords = regexp.MustCompile(`(?i)\b(attach(ed|ing|ment|ments)?|enclosed)\b`)
+
+// MentionsAttachment reports whether the body talks about attachments, ignoring quoted lines from the email being
+// replied to.
+func MentionsAttachment(body string) bool {
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), ">") {
+			continue
+		}
+		if attachmentWords.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// Envelope returns the bare addresses of the sender and recipients, including the Bcc ones, which are given to the
+// SMTP server separately from the message.
+func Envelope(email core.OutgoingEmail) (from string, recipients []string, err error) {
+	sender, err := mail.ParseAddress(email.From)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid sender %q: %w", email.From, err)
+	}
+	for _, list := range []string{email.To, email.Cc, email.Bcc} {
+		addresses, err := Addresses(list)
+		if err != nil {
+			return "", nil, err
+		}
+		recipients = append(recipients, addresses...)
+	}
+	if len(recipients) == 0 {
+		return "", nil, errors.New("email has no recipients")
+	}
+	return sender.Address, recipients, nil
+}
+
+// Addresses returns the bare addresses in a list of them, like the value of a To header, which may be empty.
+func Addresses(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	parsed, err := mail.ParseAddressList(list)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipients %q: %w", list, err)
+	}
+	addresses := make([]string, 0, len(parsed))
+	for _, address := range parsed {
+		addresses = append(addresses, address.Address)
+	}
+	return addresses, nil
+}
+
+// GenerateMessageId creates a unique message ID using the domain of the sender's address.
+func GenerateMessageId(from string) string {
+	domain := "localhost"
+	if address, err := mail.ParseAddress(from); err == nil {
+		if _, host, ok := strings.Cut(address.Address, "@"); ok {
+			domain = host
+		}
+	}
+
+	random := make([]byte, 16)
+	_, _ = rand.Read(random)
+	return fmt.Sprintf("%d.%s@%s", time.Now().UnixNano(), hex.EncodeToString(random), domain)
+}
+
+func formatAddressList(addresses []*mail.Address) string {
+	formatted := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if address.Name == "" {
+			formatted = append(formatted, address.Address)
+		} else {
+			formatted = append(formatted, address.String())
+		}
+	}
+	return strings.Join(formatted, ", ")
+}
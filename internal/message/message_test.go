@@ -0,0 +1,211 @@
+package message
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+func TestBuildAndParse(t *testing.T) {
+	email := core.OutgoingEmail{
+		From:       "Me <me@example.com>",
+		ReplyTo:    "support@example.com",
+		To:         "you@example.com, Them <them@example.com>",
+		Subject:    "Re: Café plans",
+		Body:       "Sounds good — see you there.\n\n> Shall we meet at 10?\n",
+		MessageId:  "abc@example.com",
+		InReplyTo:  "question@example.com",
+		References: []string{"start@example.com", "question@example.com"},
+	}
+
+	raw, err := Build(email, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Unexpected error building the message: %v", err)
+	}
+	if !strings.Contains(string(raw), "\r\n") || strings.Contains(strings.ReplaceAll(string(raw), "\r\n", ""), "\n") {
+		t.Error("Expected every line to end with CRLF")
+	}
+	if !strings.Contains(string(raw), "Message-Id: <abc@example.com>") {
+		t.Errorf("Expected the message ID header, got:\n%s", raw)
+	}
+
+	parsed, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing the message: %v", err)
+	}
+	if parsed.From != `"Me" <me@example.com>` {
+		t.Errorf("Expected the sender to round-trip, got '%s'", parsed.From)
+	}
+	if parsed.ReplyTo != "support@example.com" {
+		t.Errorf("Expected the reply-to address to round-trip, got '%s'", parsed.ReplyTo)
+	}
+	if parsed.To != `you@example.com, "Them" <them@example.com>` {
+		t.Errorf("Expected the recipients to round-trip, got '%s'", parsed.To)
+	}
+	if parsed.Subject != email.Subject || parsed.Body != email.Body || parsed.MessageId != email.MessageId {
+		t.Errorf("Expected the email to round-trip, got %+v", parsed)
+	}
+	if parsed.InReplyTo != email.InReplyTo || !slices.Equal(parsed.References, email.References) {
+		t.Errorf("Expected the threading headers to round-trip, got %+v", parsed)
+	}
+}
+
+func TestBuild_RequiresMessageId(t *testing.T) {
+	_, err := Build(core.OutgoingEmail{From: "me@example.com"}, time.Now())
+	if err == nil {
+		t.Error("Expected an error for an email without a message ID")
+	}
+}
+
+func TestGenerateMessageId(t *testing.T) {
+	id := GenerateMessageId("Me <me@example.com>")
+	if !strings.HasSuffix(id, "@example.com") {
+		t.Errorf("Expected the sender's domain, got '%s'", id)
+	}
+	if id == GenerateMessageId("me@example.com") {
+		t.Error("Expected message IDs to be unique")
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	from, recipients, err := Envelope(core.OutgoingEmail{
+		From: "Me <me@example.com>",
+		To:   "you@example.com, Them <them@example.com>",
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if from != "me@example.com" {
+		t.Errorf("Expected the bare sender address, got '%s'", from)
+	}
+	if !slices.Equal(recipients, []string{"you@example.com", "them@example.com"}) {
+		t.Errorf("Expected the bare recipient addresses, got %v", recipients)
+	}
+}
+
+func TestEnvelope_CcAndBcc(t *testing.T) {
+	_, recipients, err := Envelope(core.OutgoingEmail{
+		From: "me@example.com",
+		Cc:   "Them <them@example.com>",
+		Bcc:  "boss@example.com",
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !slices.Equal(recipients, []string{"them@example.com", "boss@example.com"}) {
+		t.Errorf("Expected the Cc and Bcc recipients, got %v", recipients)
+	}
+}
+
+func TestBuild_BccIsLeftOut(t *testing.T) {
+	email := core.OutgoingEmail{
+		From:      "me@example.com",
+		To:        "you@example.com",
+		Cc:        "Them <them@example.com>",
+		Bcc:       "boss@example.com",
+		Subject:   "Plans",
+		MessageId: "plans@example.com",
+	}
+
+	raw, err := Build(email, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(raw), "Cc: \"Them\" <them@example.com>\r\n") {
+		t.Errorf("Expected a Cc field, got:\n%s", raw)
+	}
+	if strings.Contains(string(raw), "boss@example.com") {
+		t.Errorf("Expected the Bcc recipients to be left out, got:\n%s", raw)
+	}
+
+	raw, err = BuildDraft(email, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	parsed, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if parsed.Cc != `"Them" <them@example.com>` || parsed.Bcc != "boss@example.com" {
+		t.Errorf("Expected the draft to keep its Cc and Bcc recipients, got %q and %q", parsed.Cc, parsed.Bcc)
+	}
+}
+
+func TestEnvelope_RequiresRecipients(t *testing.T) {
+	_, _, err := Envelope(core.OutgoingEmail{From: "me@example.com"})
+	if err == nil {
+		t.Error("Expected an error for an email without recipients")
+	}
+}
+
+func TestBuildAndParse_Attachments(t *testing.T) {
+	email := core.OutgoingEmail{
+		From:      "me@example.com",
+		To:        "you@example.com",
+		Subject:   "Report",
+		Body:      "Here's the report.\n",
+		MessageId: "report@example.com",
+		Attachments: []core.Attachment{
+			{Filename: "report.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.4 not really")},
+			{Filename: "notes.txt", ContentType: "text/plain; charset=utf-8", Data: []byte("line one\nline two\n")},
+		},
+	}
+
+	raw, err := Build(email, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error building the message: %v", err)
+	}
+	if !strings.Contains(string(raw), "multipart/mixed") {
+		t.Errorf("Expected a multipart/mixed message, got:\n%s", raw)
+	}
+
+	parsed, err := Parse(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Unexpected error parsing the message: %v", err)
+	}
+	if parsed.Body != email.Body {
+		t.Errorf("Expected the body to round-trip, got '%s'", parsed.Body)
+	}
+	if len(parsed.Attachments) != 2 {
+		t.Fatalf("Expected 2 attachments, got %d", len(parsed.Attachments))
+	}
+	for i, attachment := range parsed.Attachments {
+		want := email.Attachments[i]
+		if attachment.Filename != want.Filename || attachment.ContentType != want.ContentType || !bytes.Equal(attachment.Data, want.Data) {
+			t.Errorf("Expected attachment %d to round-trip as %+v, got %+v", i, want, attachment)
+		}
+	}
+}
+
+func TestDetectContentType(t *testing.T) {
+	if got := DetectContentType("photo.png", nil); got != "image/png" {
+		t.Errorf("Expected the type to come from the extension, got '%s'", got)
+	}
+	if got := DetectContentType("README", []byte("just some text")); got != "text/plain; charset=utf-8" {
+		t.Errorf("Expected the type to come from the contents, got '%s'", got)
+	}
+}
+
+func TestMentionsAttachment(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{"I've attached the slides.", true},
+		{"See the Attachment below", true},
+		{"Please find enclosed my CV", true},
+		{"Thanks!\n\n> I've attached the slides.\n", false},
+		{"See you at the station", false},
+	}
+	for _, test := range tests {
+		if got := MentionsAttachment(test.body); got != test.want {
+			t.Errorf("MentionsAttachment(%q) = %v, want %v", test.body, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package message
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	gomessage "github.com/emersion/go-message"
+	"github.com/emersion/go-message/mail"
+)
+
+// Part is one of the parts of a received message, like its text or an attachment, decoded from its transfer
+// encoding.
+type Part struct {
+	ContentType string
+	Params      map[string]string
+	// Filename is the name an attachment was sent with, if it has one
+	Filename string
+	Data     []byte
+}
+
+// ParseParts reads every part of a message that isn't just holding other parts, in order.
+func ParseParts(r io.Reader) ([]Part, error) {
+	reader, err := mail.CreateReader(r)
+	if err != nil && !gomessage.IsUnknownCharset(err) {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	var parts []Part
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return parts, nil
+		}
+		if err != nil && !gomessage.IsUnknownCharset(err) {
+			return nil, err
+		}
+
+		var (
+			contentType string
+			params      map[string]string
+			filename    string
+		)
+		switch header := part.Header.(type) {
+		case *mail.InlineHeader:
+			contentType, params, _ = header.ContentType()
+		case *mail.AttachmentHeader:
+			contentType, params, _ = header.ContentType()
+			filename, _ = header.Filename()
+		}
+		if contentType == "" {
+			contentType = "text/plain"
+		}
+		if filename == "" {
+			filename = params["name"]
+		}
+		if _, ok := params["charset"]; ok && strings.HasPrefix(contentType, "text/") && !gomessage.IsUnknownCharset(err) {
+			// text has already been converted to UTF-8
+			params["charset"] = "utf-8"
+		}
+
+		data, err := io.ReadAll(part.Body)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, Part{
+			ContentType: contentType,
+			Params:      params,
+			Filename:    filename,
+			Data:        data,
+		})
+	}
+}
+
+// FindCalendar returns the message's first text/calendar part, like a meeting invitation, or nil if it doesn't have
+// one. Some clients attach invitations as .ics files instead, so application/ics is looked for too.
+func FindCalendar(r io.Reader) ([]byte, error) {
+	parts, err := ParseParts(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		if part.ContentType == "text/calendar" || part.ContentType == "application/ics" {
+			return part.Data, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,94 @@
+package message
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+func TestParseParts(t *testing.T) {
+	raw := "Content-Type: multipart/mixed; boundary=outer\r\n" +
+		"\r\n" +
+		"--outer\r\n" +
+		"Content-Type: multipart/alternative; boundary=inner\r\n" +
+		"\r\n" +
+		"--inner\r\n" +
+		"Content-Type: text/plain; charset=iso-8859-1\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Caf=E9\r\n" +
+		"--inner\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"\r\n" +
+		"<p>Café</p>\r\n" +
+		"--inner--\r\n" +
+		"--outer\r\n" +
+		"Content-Type: application/pdf\r\n" +
+		"Content-Disposition: attachment; filename=\"menu.pdf\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"JVBERi0xLjQ=\r\n" +
+		"--outer--\r\n"
+
+	parts, err := ParseParts(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(parts) != 3 {
+		t.Fatalf("Expected 3 parts, got %+v", parts)
+	}
+	if parts[0].ContentType != "text/plain" || string(parts[0].Data) != "Café" || parts[0].Params["charset"] != "utf-8" {
+		t.Errorf("Expected the text to be decoded to UTF-8, got %+v", parts[0])
+	}
+	if parts[1].ContentType != "text/html" {
+		t.Errorf("Expected the HTML part second, got %+v", parts[1])
+	}
+	if parts[2].ContentType != "application/pdf" || parts[2].Filename != "menu.pdf" || string(parts[2].Data) != "%PDF-1.4" {
+		t.Errorf("Expected the decoded attachment, got %+v", parts[2])
+	}
+}
+
+func TestParseParts_SinglePart(t *testing.T) {
+	parts, err := ParseParts(strings.NewReader("Subject: Hi\r\n\r\nHello\r\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(parts) != 1 || parts[0].ContentType != "text/plain" || string(parts[0].Data) != "Hello\r\n" {
+		t.Errorf("Expected the body as a single plain-text part, got %+v", parts)
+	}
+}
+
+func TestFindCalendar(t *testing.T) {
+	email := core.OutgoingEmail{
+		From:      "me@example.com",
+		To:        "alice@example.com",
+		Subject:   "Accepted: Standup",
+		Body:      "I'll be there.",
+		MessageId: "reply@example.com",
+		Calendar:  &core.CalendarPart{Method: "REPLY", Data: []byte("BEGIN:VCALENDAR\r\nMETHOD:REPLY\r\nEND:VCALENDAR\r\n")},
+	}
+	raw, err := Build(email, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error building the message: %v", err)
+	}
+	if !strings.Contains(string(raw), "multipart/alternative") || !strings.Contains(string(raw), "method=REPLY") {
+		t.Errorf("Expected the calendar as an alternative with its method, got:\n%s", raw)
+	}
+
+	calendar, err := FindCalendar(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(calendar) != string(email.Calendar.Data) {
+		t.Errorf("Expected the calendar part, got %q", calendar)
+	}
+
+	calendar, err = FindCalendar(strings.NewReader("Subject: Hi\r\n\r\nHello\r\n"))
+	if err != nil || calendar != nil {
+		t.Errorf("Expected no calendar in a plain email, got %q, %v", calendar, err)
+	}
+}
@@ -0,0 +1,165 @@
+package message
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"mime"
+	"strings"
+
+	"github.com/emersion/go-message/textproto"
+)
+
+// CanonicalLineEndings makes every line end in CRLF, as signatures over MIME entities are made and checked with
+// (RFC 3156 section 5, RFC 8551 section 3.1.1). Messages that are stored with bare LFs are converted, and ones that
+// already use CRLF are left alone.
+func CanonicalLineEndings(raw []byte) []byte {
+	if bytes.Contains(raw, []byte("\r\n")) {
+		return raw
+	}
+	return bytes.ReplaceAll(raw, []byte("\n"), []byte("\r\n"))
+}
+
+// SplitContent separates a message into the header fields about the message, like From and Subject, and its content:
+// the fields that describe the body, like Content-Type, followed by the body itself. The content is a MIME entity of
+// its own, which is what's signed or encrypted to protect a message.
+func SplitContent(raw []byte) (textproto.Header, []byte, error) {
+	header, body, err := SplitEntity(raw)
+	if err != nil {
+		return textproto.Header{}, nil, err
+	}
+
+	// the fields are copied as they were written, in the same order
+	var entity bytes.Buffer
+	hasContentType := false
+	fields := header.Fields()
+	for fields.Next() {
+		if isContentField(fields.Key()) {
+			hasContentType = hasContentType || strings.EqualFold(fields.Key(), "Content-Type")
+			entity.Write(mustRaw(fields))
+			fields.Del()
+		}
+	}
+	if !hasContentType {
+		// a message without a Content-Type is plain text, which has to be said once it's an entity of its own
+		entity.WriteString("Content-Type: text/plain; charset=us-ascii\r\n")
+	}
+	header.Del("Mime-Version")
+	entity.WriteString("\r\n")
+	entity.Write(body)
+	return header, entity.Bytes(), nil
+}
+
+// SplitEntity separates a MIME entity into its header and its body, leaving the body exactly as it is.
+func SplitEntity(raw []byte) (textproto.Header, []byte, error) {
+	reader := bufio.NewReader(bytes.NewReader(CanonicalLineEndings(raw)))
+	header, err := textproto.ReadHeader(reader)
+	if err != nil {
+		return textproto.Header{}, nil, err
+	}
+	var body bytes.Buffer
+	if _, err := reader.WriteTo(&body); err != nil {
+		return textproto.Header{}, nil, err
+	}
+	return header, body.Bytes(), nil
+}
+
+// JoinContent puts a message back together from its header and content, as SplitContent separated them. It's also
+// how protected content that has been decrypted or verified takes the place of what protected it.
+func JoinContent(header textproto.Header, content []byte) ([]byte, error) {
+	header = header.Copy()
+	header.Set("Mime-Version", "1.0")
+	var b bytes.Buffer
+	if err := textproto.WriteHeader(&b, header); err != nil {
+		return nil, err
+	}
+	// the content starts with its own header fields, which carry on from the message's
+	b.Truncate(b.Len() - len("\r\n"))
+	b.Write(content)
+	return b.Bytes(), nil
+}
+
+// BuildMultipart writes a multipart message with the given parts, each a MIME entity written exactly as it is, so
+// signed parts aren't changed in any way.
+func BuildMultipart(header textproto.Header, mediaType string, params map[string]string, parts ...[]byte) ([]byte, error) {
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	boundary := hex.EncodeToString(random)
+	for _, part := range parts {
+		if bytes.Contains(part, []byte("--"+boundary)) {
+			return nil, errors.New("part contains the boundary")
+		}
+	}
+
+	withBoundary := map[string]string{"boundary": boundary}
+	for name, value := range params {
+		withBoundary[name] = value
+	}
+	header = header.Copy()
+	header.Set("Content-Type", mime.FormatMediaType(mediaType, withBoundary))
+	header.Set("Mime-Version", "1.0")
+
+	var b bytes.Buffer
+	if err := textproto.WriteHeader(&b, header); err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		b.WriteString("--" + boundary + "\r\n")
+		b.Write(part)
+		// the CRLF before a boundary belongs to the boundary, not the part
+		b.WriteString("\r\n")
+	}
+	b.WriteString("--" + boundary + "--\r\n")
+	return b.Bytes(), nil
+}
+
+// SplitMultipart returns the parts of a multipart body byte for byte, as RFC 2046 section 5.1.1 delimits them, which
+// is what signatures over a part are checked against. The body must have CRLF line endings.
+func SplitMultipart(body []byte, boundary string) ([][]byte, error) {
+	delimiter := "--" + boundary
+	var parts [][]byte
+	start := -1
+	for offset := 0; offset < len(body); {
+		end := bytes.Index(body[offset:], []byte("\r\n"))
+		lineEnd := len(body)
+		next := len(body)
+		if end != -1 {
+			lineEnd = offset + end
+			next = lineEnd + 2
+		}
+		line := string(body[offset:lineEnd])
+		if strings.HasPrefix(line, delimiter) {
+			rest := strings.TrimRight(line[len(delimiter):], " \t")
+			if rest == "" || rest == "--" {
+				if start != -1 {
+					// the part ends before the CRLF that comes before the delimiter
+					partEnd := max(offset-2, start)
+					parts = append(parts, body[start:partEnd])
+				}
+				if rest == "--" {
+					return parts, nil
+				}
+				start = next
+			}
+		}
+		offset = next
+	}
+	return nil, errors.New("multipart body isn't closed by its boundary")
+}
+
+func isContentField(key string) bool {
+	return strings.HasPrefix(strings.ToLower(key), "content-")
+}
+
+func mustRaw(fields textproto.HeaderFields) []byte {
+	raw, err := fields.Raw()
+	if err != nil {
+		// only fields without a raw form fail, which are written out from their key and value
+		return []byte(fields.Key() + ": " + fields.Value() + "\r\n")
+	}
+	return raw
+}
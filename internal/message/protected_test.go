@@ -0,0 +1,119 @@
+package message
+
+import (
+	"mime"
+	"strings"
+	"testing"
+)
+
+func TestSplitContent(t *testing.T) {
+	raw := "From: alice@example.com\n" +
+		"Content-Type: text/plain; charset=utf-8\n" +
+		"Subject: Hello\n" +
+		"MIME-Version: 1.0\n" +
+		"Content-Transfer-Encoding: quoted-printable\n" +
+		"\n" +
+		"Hi Bob=\n" +
+		"\n"
+
+	header, content, err := SplitContent([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Hi Bob=\r\n" +
+		"\r\n"
+	if string(content) != want {
+		t.Errorf("expected content %q, got %q", want, content)
+	}
+	if header.Get("Subject") != "Hello" || header.Has("Content-Type") || header.Has("Mime-Version") {
+		t.Errorf("expected only the fields about the message to be left, got %v", header.Map())
+	}
+
+	joined, err := JoinContent(header, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(joined), "\r\n"+want) || !strings.Contains(string(joined), "Subject: Hello\r\n") {
+		t.Errorf("expected the content to carry on from the header, got %q", joined)
+	}
+}
+
+func TestSplitContent_NoContentType(t *testing.T) {
+	_, content, err := SplitContent([]byte("Subject: Hi\r\n\r\nHello\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "Content-Type: text/plain; charset=us-ascii\r\n\r\nHello\r\n" {
+		t.Errorf("expected the content to say it's plain text, got %q", content)
+	}
+}
+
+func TestSplitMultipart(t *testing.T) {
+	body := "This is a preamble\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"First \r\n" +
+		"\r\n" +
+		"--b \r\n" +
+		"\r\n" +
+		"Second\r\n" +
+		"--bb\r\n" +
+		"--b--\r\n" +
+		"An epilogue\r\n"
+
+	parts, err := SplitMultipart([]byte(body), "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the trailing whitespace and blank lines are kept, but not the CRLF before each delimiter
+	want := []string{"Content-Type: text/plain\r\n\r\nFirst \r\n", "\r\nSecond\r\n--bb"}
+	if len(parts) != len(want) {
+		t.Fatalf("expected %d parts, got %q", len(want), parts)
+	}
+	for i := range want {
+		if string(parts[i]) != want[i] {
+			t.Errorf("part %d: expected %q, got %q", i, want[i], parts[i])
+		}
+	}
+
+	if _, err := SplitMultipart([]byte("--b\r\nUnfinished\r\n"), "b"); err == nil {
+		t.Error("expected an error for a body without its closing delimiter")
+	}
+}
+
+func TestBuildMultipart(t *testing.T) {
+	first := []byte("Content-Type: text/plain\r\n\r\nHello\r\n")
+	second := []byte("Content-Type: application/pgp-signature\r\n\r\nsignature")
+
+	from, _, err := SplitContent([]byte("From: alice@example.com\r\n\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := BuildMultipart(from, "multipart/signed", map[string]string{"protocol": "application/pgp-signature"}, first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, body, err := SplitEntity(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("From") != "alice@example.com" || mediaType != "multipart/signed" || params["protocol"] != "application/pgp-signature" {
+		t.Errorf("unexpected header %v", header.Map())
+	}
+	parts, err := SplitMultipart(body, params["boundary"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || string(parts[0]) != string(first) || string(parts[1]) != string(second) {
+		t.Errorf("expected the parts back exactly, got %q", parts)
+	}
+}
@@ -0,0 +1,356 @@
+// Package outbox queues emails on disk until they've been sent, so they aren't lost if sending fails or the app is
+// closed first.
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+type Status string
+
+const (
+	// StatusQueued entries are sent when their next attempt is due.
+	StatusQueued Status = "queued"
+	// StatusSending entries are being sent by a worker, and can't be changed until it has finished.
+	StatusSending Status = "sending"
+	// StatusFailed entries couldn't be sent, and wait for the user to edit or resend them.
+	StatusFailed Status = "failed"
+)
+
+// Entries are stored as <id>.json, and renamed to <id>.sending while a worker sends them. Renaming is atomic, so only
+// one worker can claim an entry, and an entry can't be cancelled once it's being sent.
+const (
+	entryExtension   = ".json"
+	sendingExtension = ".sending"
+)
+
+var (
+	ErrNotFound = errors.New("outbox entry not found")
+	ErrSending  = errors.New("the email is already being sent")
+)
+
+// Entry is an email waiting in the outbox.
+type Entry struct {
+	Id    string
+	Email core.OutgoingEmail
+	// ReplyTo is the email being replied to, which is flagged as answered once the reply has been sent
+	ReplyTo core.EmailId
+
+	Status   Status
+	QueuedAt time.Time
+	// SendAt is when the email was scheduled to be sent, which is after QueuedAt for emails that are sent later or
+	// after a delay to allow undoing them
+	SendAt time.Time
+	// NextAttempt is when the email should next be sent, which is SendAt until an attempt fails
+	NextAttempt time.Time
+	Attempts    int
+	// LastError is why the last attempt failed, if it did
+	LastError string
+}
+
+// Outbox stores each entry as a JSON file in a directory.
+type Outbox struct {
+	dir string
+	// mu stops the worker and the UI from writing the same entry at the same time
+	mu sync.Mutex
+	// changed wakes up the worker when entries are added or changed
+	changed chan struct{}
+}
+
+func New(dir string) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Outbox{
+		dir:     dir,
+		changed: make(chan struct{}, 1),
+	}, nil
+}
+
+// Enqueue adds an email to the outbox, to be sent at sendAt, or as soon as possible if it's zero.
+func (o *Outbox) Enqueue(email core.OutgoingEmail, replyTo core.EmailId, sendAt time.Time) (*Entry, error) {
+	now := time.Now()
+	if sendAt.IsZero() {
+		sendAt = now
+	}
+	entry := Entry{
+		Id:          strconv.FormatInt(now.UnixNano(), 10),
+		Email:       email,
+		ReplyTo:     replyTo,
+		Status:      StatusQueued,
+		QueuedAt:    now,
+		SendAt:      sendAt,
+		NextAttempt: sendAt,
+	}
+	if err := o.write(entry, true); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Update saves an entry, replacing the previous version.
+// It returns ErrNotFound if the entry has been removed in the meantime, e.g. because it was sent or deleted, and
+// ErrSending if it's being sent.
+func (o *Outbox) Update(entry Entry) error {
+	return o.write(entry, false)
+}
+
+// Requeue saves an entry to be sent again at SendAt, or straight away if that has passed, e.g. after it failed and was
+// edited.
+func (o *Outbox) Requeue(entry Entry) error {
+	entry.Status = StatusQueued
+	entry.NextAttempt = time.Now()
+	if entry.SendAt.After(entry.NextAttempt) {
+		entry.NextAttempt = entry.SendAt
+	}
+	entry.Attempts = 0
+	entry.LastError = ""
+	return o.Update(entry)
+}
+
+// Resend queues a failed entry to be sent again straight away.
+func (o *Outbox) Resend(id string) error {
+	entry, err := o.Get(id)
+	if err != nil {
+		return err
+	}
+	return o.Requeue(*entry)
+}
+
+func (o *Outbox) write(entry Entry, create bool) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	path, err := o.path(entry.Id)
+	if err != nil {
+		return err
+	}
+	if !create {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if _, err := os.Stat(o.sendingPath(entry.Id)); err == nil {
+				return ErrSending
+			}
+			return ErrNotFound
+		}
+	}
+	return o.writeFile(path, entry)
+}
+
+// writeFile writes an entry to a temporary file first and renames it, so a crash while saving leaves the previous
+// version intact.
+func (o *Outbox) writeFile(path string, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(o.dir, ".entry-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return err
+	}
+
+	o.notify()
+	return nil
+}
+
+func (o *Outbox) Get(id string) (*Entry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	path, err := o.path(id)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := readEntry(path)
+	if errors.Is(err, ErrNotFound) {
+		return readEntry(o.sendingPath(id))
+	}
+	return entry, err
+}
+
+// List reads every entry, including the ones being sent, oldest first.
+func (o *Outbox) List() ([]Entry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var paths []string
+	for _, extension := range []string{entryExtension, sendingExtension} {
+		matches, err := filepath.Glob(filepath.Join(o.dir, "*"+extension))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+
+	entries := []Entry{}
+	for _, path := range paths {
+		entry, err := readEntry(path)
+		if errors.Is(err, ErrNotFound) {
+			// sent or claimed since the directory was listed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return a.QueuedAt.Compare(b.QueuedAt)
+	})
+	return entries, nil
+}
+
+func (o *Outbox) Remove(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	path, err := o.path(id)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(o.sendingPath(id)); err == nil {
+			return ErrSending
+		}
+		return ErrNotFound
+	}
+	o.notify()
+	return err
+}
+
+// Cancel removes a queued entry before it's sent, returning it so it can be kept somewhere else, like the drafts.
+func (o *Outbox) Cancel(id string) (*Entry, error) {
+	entry, err := o.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.Remove(id); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// claim marks an entry as being sent, so it can't be changed or claimed by another worker until it's finished.
+func (o *Outbox) claim(id string) (*Entry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	path, err := o.path(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(path, o.sendingPath(id)); errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return readEntry(o.sendingPath(id))
+}
+
+// finish removes a claimed entry once it has been sent.
+func (o *Outbox) finish(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return os.Remove(o.sendingPath(id))
+}
+
+// release saves a claimed entry that couldn't be sent, so it can be tried again or edited.
+func (o *Outbox) release(entry Entry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	path, err := o.path(entry.Id)
+	if err != nil {
+		return err
+	}
+	if err := o.writeFile(path, entry); err != nil {
+		return err
+	}
+	return os.Remove(o.sendingPath(entry.Id))
+}
+
+// recover queues any entries left claimed by a worker that stopped while sending them.
+// They might have been sent already, but sending them again is better than losing them.
+func (o *Outbox) recover() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(o.dir, "*"+sendingExtension))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, path := range paths {
+		errs = append(errs, os.Rename(path, strings.TrimSuffix(path, sendingExtension)+entryExtension))
+	}
+	return errors.Join(errs...)
+}
+
+// Changed receives a value whenever entries are added or changed.
+func (o *Outbox) Changed() <-chan struct{} {
+	return o.changed
+}
+
+func (o *Outbox) notify() {
+	select {
+	case o.changed <- struct{}{}:
+	default:
+		// the worker is already due to check the outbox
+	}
+}
+
+// path returns the file for an entry, checking the id can't be used to reach files outside the directory.
+func (o *Outbox) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", fmt.Errorf("invalid outbox entry id %q", id)
+	}
+	return filepath.Join(o.dir, id+entryExtension), nil
+}
+
+// sendingPath returns the file for an entry while it's being sent. The id must already have been checked by path.
+func (o *Outbox) sendingPath(id string) string {
+	return filepath.Join(o.dir, id+sendingExtension)
+}
+
+func readEntry(path string) (*Entry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("reading outbox entry %s: %w", filepath.Base(path), err)
+	}
+	if filepath.Ext(path) == sendingExtension {
+		entry.Status = StatusSending
+	}
+	return &entry, nil
+}
@@ -0,0 +1,117 @@
+package outbox
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+func newOutbox(t *testing.T) *Outbox {
+	outbox, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return outbox
+}
+
+func TestOutbox_EntriesSurviveReopening(t *testing.T) {
+	dir := t.TempDir()
+	first, _ := New(dir)
+	if _, err := first.Enqueue(core.OutgoingEmail{Subject: "Hello"}, "4", time.Time{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	second, _ := New(dir)
+	entries, err := second.List()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+	if entries[0].Email.Subject != "Hello" || entries[0].ReplyTo != "4" || entries[0].Status != StatusQueued {
+		t.Errorf("Expected the queued email to be read back, got %+v", entries[0])
+	}
+}
+
+func TestOutbox_UpdateDoesNotRecreateRemovedEntry(t *testing.T) {
+	outbox := newOutbox(t)
+	entry, _ := outbox.Enqueue(core.OutgoingEmail{}, "", time.Time{})
+	if err := outbox.Remove(entry.Id); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := outbox.Update(*entry); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	entries, _ := outbox.List()
+	if len(entries) != 0 {
+		t.Errorf("Expected the entry to stay removed, got %d entries", len(entries))
+	}
+}
+
+func TestOutbox_ResendResetsFailedEntry(t *testing.T) {
+	outbox := newOutbox(t)
+	entry, _ := outbox.Enqueue(core.OutgoingEmail{}, "", time.Time{})
+	entry.Status = StatusFailed
+	entry.Attempts = 3
+	entry.LastError = "rejected"
+	_ = outbox.Update(*entry)
+
+	if err := outbox.Resend(entry.Id); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	resent, _ := outbox.Get(entry.Id)
+	if resent.Status != StatusQueued || resent.Attempts != 0 || resent.LastError != "" {
+		t.Errorf("Expected the entry to be queued again from scratch, got %+v", resent)
+	}
+}
+
+func TestOutbox_RejectsInvalidIds(t *testing.T) {
+	outbox := newOutbox(t)
+
+	if _, err := outbox.Get("../secrets"); err == nil {
+		t.Error("Expected an error for an id containing a path")
+	}
+}
+
+func TestOutbox_EntriesBeingSentCantBeCancelled(t *testing.T) {
+	outbox := newOutbox(t)
+	entry, _ := outbox.Enqueue(core.OutgoingEmail{}, "", time.Time{})
+	if _, err := outbox.claim(entry.Id); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := outbox.Cancel(entry.Id); !errors.Is(err, ErrSending) {
+		t.Errorf("Expected ErrSending, got %v", err)
+	}
+	if _, err := outbox.claim(entry.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the entry not to be claimed twice, got %v", err)
+	}
+
+	entries, _ := outbox.List()
+	if len(entries) != 1 || entries[0].Status != StatusSending {
+		t.Errorf("Expected the entry to be listed as sending, got %+v", entries)
+	}
+}
+
+func TestOutbox_RecoverQueuesInterruptedEntries(t *testing.T) {
+	outbox := newOutbox(t)
+	entry, _ := outbox.Enqueue(core.OutgoingEmail{}, "", time.Time{})
+	_, _ = outbox.claim(entry.Id)
+
+	if err := outbox.recover(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	recovered, err := outbox.Get(entry.Id)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if recovered.Status != StatusQueued {
+		t.Errorf("Expected the entry to be queued again, got %s", recovered.Status)
+	}
+}
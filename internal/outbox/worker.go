@@ -0,0 +1,193 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+const (
+	// initialBackoff is how long to wait before retrying the first time an email can't be sent, which doubles after
+	// each failed attempt up to maxBackoff.
+	initialBackoff = 30 * time.Second
+	maxBackoff     = time.Hour
+	// maxAttempts is how many times an email is tried before it's marked as failed.
+	maxAttempts = 10
+	// idleInterval is how often the outbox is checked when nothing is due, in case entries were changed by another
+	// process.
+	idleInterval = time.Minute
+)
+
+type EventKind int
+
+const (
+	EventSent EventKind = iota
+	EventRetrying
+	EventFailed
+)
+
+// Event describes what happened when the worker tried to send an email.
+type Event struct {
+	Kind  EventKind
+	Entry Entry
+	// Error is why sending failed, or a problem after sending (e.g. saving the sent copy) for EventSent
+	Error error
+}
+
+// Describe summarises the event for the status bar.
+func (e Event) Describe() string {
+	subject := e.Entry.Email.Subject
+	if subject == "" {
+		subject = "(no subject)"
+	}
+	switch e.Kind {
+	case EventSent:
+		var saveSentErr *core.SaveSentError
+		if errors.As(e.Error, &saveSentErr) {
+			return fmt.Sprintf("Sent %q, but saving a copy of it failed: %v", subject, saveSentErr.Err)
+		}
+		return fmt.Sprintf("Sent %q", subject)
+	case EventRetrying:
+		return fmt.Sprintf("Couldn't send %q, retrying at %s: %v", subject, e.Entry.NextAttempt.Format(time.Kitchen), e.Error)
+	default:
+		return fmt.Sprintf("Couldn't send %q, see the outbox: %v", subject, e.Error)
+	}
+}
+
+// Worker sends the emails in the outbox in the background.
+type Worker struct {
+	outbox  *Outbox
+	backend core.EmailBackend
+	notify  func(Event)
+	now     func() time.Time
+}
+
+// NewWorker creates a worker which calls notify after each attempt to send an email. notify can be nil.
+func NewWorker(outbox *Outbox, backend core.EmailBackend, notify func(Event)) *Worker {
+	if notify == nil {
+		notify = func(Event) {}
+	}
+	return &Worker{
+		outbox:  outbox,
+		backend: backend,
+		notify:  notify,
+		now:     time.Now,
+	}
+}
+
+// Run sends emails as they become due until the context is cancelled.
+// Only one worker should run for an outbox at a time, since emails that another worker is sending are queued again
+// when it starts.
+func (w *Worker) Run(ctx context.Context) {
+	// like reading the outbox, this only fails if the outbox directory can't be accessed, in which case there's nothing
+	// to send anyway
+	_ = w.outbox.recover()
+	for {
+		next := w.SendDue()
+
+		wait := idleInterval
+		if !next.IsZero() {
+			wait = min(max(next.Sub(w.now()), 0), idleInterval)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-w.outbox.Changed():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// SendDue tries to send every queued email whose next attempt is due, and returns when the next remaining one is due,
+// or zero if there aren't any.
+func (w *Worker) SendDue() time.Time {
+	entries, err := w.outbox.List()
+	if err != nil {
+		// the outbox will be read again the next time the worker wakes up
+		return time.Time{}
+	}
+
+	var next time.Time
+	for _, entry := range entries {
+		if entry.Status != StatusQueued {
+			continue
+		}
+		if entry.NextAttempt.After(w.now()) {
+			if next.IsZero() || entry.NextAttempt.Before(next) {
+				next = entry.NextAttempt
+			}
+			continue
+		}
+
+		w.send(entry)
+		if updated, err := w.outbox.Get(entry.Id); err == nil && updated.Status == StatusQueued {
+			if next.IsZero() || updated.NextAttempt.Before(next) {
+				next = updated.NextAttempt
+			}
+		}
+	}
+	return next
+}
+
+func (w *Worker) send(queued Entry) {
+	entry, err := w.outbox.claim(queued.Id)
+	if err != nil {
+		// it was cancelled, deleted or claimed by another worker since the outbox was listed
+		return
+	}
+
+	err = w.backend.SendEmail(entry.Email)
+
+	var saveSentErr *core.SaveSentError
+	if err == nil || errors.As(err, &saveSentErr) {
+		// the email has been sent even if saving a copy of it failed, so it mustn't be sent again
+		_ = w.outbox.finish(entry.Id)
+		if entry.ReplyTo != "" {
+			_ = w.backend.AddFlags([]core.EmailId{entry.ReplyTo}, core.FlagAnswered)
+		}
+		w.notify(Event{Kind: EventSent, Entry: *entry, Error: err})
+		return
+	}
+
+	entry.Attempts++
+	entry.LastError = err.Error()
+	kind := EventFailed
+	if IsTemporary(err) && entry.Attempts < maxAttempts {
+		kind = EventRetrying
+		entry.Status = StatusQueued
+		entry.NextAttempt = w.now().Add(backoff(entry.Attempts))
+	} else {
+		entry.Status = StatusFailed
+	}
+	if releaseErr := w.outbox.release(*entry); releaseErr != nil {
+		err = errors.Join(err, releaseErr)
+	}
+	w.notify(Event{Kind: kind, Entry: *entry, Error: err})
+}
+
+// backoff returns how long to wait after the given number of failed attempts.
+func backoff(attempts int) time.Duration {
+	wait := initialBackoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxBackoff)
+}
+
+// IsTemporary reports whether sending might succeed if it's tried again later, e.g. because the server replied with a
+// 4xx code or couldn't be reached.
+func IsTemporary(err error) bool {
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) && temporary.Temporary() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
@@ -0,0 +1,182 @@
+package outbox
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-smtp"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+// mockBackend returns the next error from errs for each email that's sent. Any other calls panic, except flagging
+// emails as answered.
+type mockBackend struct {
+	core.EmailBackend
+
+	errs     []error
+	sent     []core.OutgoingEmail
+	answered []core.EmailId
+}
+
+func (m *mockBackend) SendEmail(email core.OutgoingEmail) error {
+	m.sent = append(m.sent, email)
+	if len(m.errs) == 0 {
+		return nil
+	}
+	err := m.errs[0]
+	m.errs = m.errs[1:]
+	return err
+}
+
+func (m *mockBackend) AddFlags(ids []core.EmailId, flags ...core.Flag) error {
+	if slices.Contains(flags, core.FlagAnswered) {
+		m.answered = append(m.answered, ids...)
+	}
+	return nil
+}
+
+func newWorker(t *testing.T, backend *mockBackend) (*Worker, *[]Event) {
+	events := &[]Event{}
+	worker := NewWorker(newOutbox(t), backend, func(event Event) {
+		*events = append(*events, event)
+	})
+	return worker, events
+}
+
+func TestWorker_SendsAndRemovesEntry(t *testing.T) {
+	backend := &mockBackend{}
+	worker, events := newWorker(t, backend)
+	_, _ = worker.outbox.Enqueue(core.OutgoingEmail{Subject: "Hello"}, "9", time.Time{})
+
+	next := worker.SendDue()
+
+	if !next.IsZero() {
+		t.Errorf("Expected nothing else to be due, got %v", next)
+	}
+	if len(backend.sent) != 1 {
+		t.Fatalf("Expected the email to be sent, got %d", len(backend.sent))
+	}
+	entries, _ := worker.outbox.List()
+	if len(entries) != 0 {
+		t.Errorf("Expected the outbox to be empty, got %d entries", len(entries))
+	}
+	if len(backend.answered) != 1 || backend.answered[0] != "9" {
+		t.Errorf("Expected the original email to be flagged as answered, got %v", backend.answered)
+	}
+	if len(*events) != 1 || (*events)[0].Kind != EventSent {
+		t.Errorf("Expected a sent event, got %+v", *events)
+	}
+}
+
+func TestWorker_RetriesTemporaryErrorsWithBackoff(t *testing.T) {
+	temporary := &smtp.SMTPError{Code: 451, Message: "try again later"}
+	backend := &mockBackend{errs: []error{temporary, temporary}}
+	worker, events := newWorker(t, backend)
+	entry, _ := worker.outbox.Enqueue(core.OutgoingEmail{}, "", time.Time{})
+	now := time.Now()
+	worker.now = func() time.Time { return now }
+
+	next := worker.SendDue()
+
+	if !next.Equal(now.Add(initialBackoff)) {
+		t.Errorf("Expected a retry after %v, got %v", initialBackoff, next.Sub(now))
+	}
+	if (*events)[0].Kind != EventRetrying {
+		t.Errorf("Expected a retrying event, got %+v", (*events)[0])
+	}
+
+	// not due yet, so nothing is sent
+	worker.SendDue()
+	if len(backend.sent) != 1 {
+		t.Fatalf("Expected 1 attempt before the retry is due, got %d", len(backend.sent))
+	}
+
+	now = next
+	next = worker.SendDue()
+	if !next.Equal(now.Add(2 * initialBackoff)) {
+		t.Errorf("Expected the backoff to double, got %v", next.Sub(now))
+	}
+
+	now = next
+	worker.SendDue()
+	if _, err := worker.outbox.Get(entry.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the entry to be removed once it was sent, got %v", err)
+	}
+}
+
+func TestWorker_MarksPermanentErrorsAsFailed(t *testing.T) {
+	backend := &mockBackend{errs: []error{&smtp.SMTPError{Code: 550, Message: "no such user"}}}
+	worker, events := newWorker(t, backend)
+	entry, _ := worker.outbox.Enqueue(core.OutgoingEmail{}, "", time.Time{})
+
+	worker.SendDue()
+	worker.SendDue()
+
+	failed, _ := worker.outbox.Get(entry.Id)
+	if failed.Status != StatusFailed || failed.LastError == "" {
+		t.Errorf("Expected the entry to be marked as failed, got %+v", failed)
+	}
+	if len(backend.sent) != 1 {
+		t.Errorf("Expected failed emails not to be sent again, got %d attempts", len(backend.sent))
+	}
+	if (*events)[0].Kind != EventFailed {
+		t.Errorf("Expected a failed event, got %+v", (*events)[0])
+	}
+}
+
+func TestWorker_FailingToSaveSentCopyCountsAsSent(t *testing.T) {
+	backend := &mockBackend{errs: []error{&core.SaveSentError{Err: errors.New("mailbox is full")}}}
+	worker, events := newWorker(t, backend)
+	_, _ = worker.outbox.Enqueue(core.OutgoingEmail{}, "", time.Time{})
+
+	worker.SendDue()
+	worker.SendDue()
+
+	if len(backend.sent) != 1 {
+		t.Errorf("Expected the email not to be sent again, got %d attempts", len(backend.sent))
+	}
+	if (*events)[0].Kind != EventSent || (*events)[0].Error == nil {
+		t.Errorf("Expected a sent event with a warning, got %+v", (*events)[0])
+	}
+}
+
+func TestIsTemporary(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&smtp.SMTPError{Code: 421}, true},
+		{&smtp.SMTPError{Code: 554}, false},
+		{errors.New("invalid recipients"), false},
+	}
+	for _, test := range tests {
+		if got := IsTemporary(test.err); got != test.want {
+			t.Errorf("IsTemporary(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestWorker_WaitsUntilScheduledTime(t *testing.T) {
+	backend := &mockBackend{}
+	worker, _ := newWorker(t, backend)
+	sendAt := time.Now().Add(time.Hour)
+	_, _ = worker.outbox.Enqueue(core.OutgoingEmail{}, "", sendAt)
+
+	next := worker.SendDue()
+
+	if len(backend.sent) != 0 {
+		t.Fatal("Expected the email not to be sent before it's due")
+	}
+	if !next.Equal(sendAt) {
+		t.Errorf("Expected the worker to wake up at %v, got %v", sendAt, next)
+	}
+
+	worker.now = func() time.Time { return sendAt }
+	worker.SendDue()
+	if len(backend.sent) != 1 {
+		t.Errorf("Expected the email to be sent once it's due, got %d", len(backend.sent))
+	}
+}
@@ -0,0 +1,157 @@
+// Package pgp protects emails with OpenPGP as PGP/MIME (RFC 3156): it verifies and decrypts the emails that are
+// received, and signs and encrypts the ones that are sent.
+package pgp
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+// config is used for everything that's signed and encrypted. SHA-256 is chosen rather than left to the library's
+// default because it's named in the micalg parameter of signed emails.
+var config = &packet.Config{DefaultHash: crypto.SHA256}
+
+var errNoKeyring = errors.New("no OpenPGP keyring is configured")
+
+// Keyring holds the keys of the people emails are exchanged with, used to verify their signatures and encrypt to
+// them, and the user's own private keys, used to decrypt and sign.
+type Keyring struct {
+	entities openpgp.EntityList
+}
+
+// NewKeyring makes a keyring from keys that have already been read, with any private keys decrypted.
+func NewKeyring(entities ...*openpgp.Entity) *Keyring {
+	return &Keyring{entities: entities}
+}
+
+// Open reads a keyring file, like one exported with "gpg --export-secret-keys --armor" followed by the public keys of
+// others, either armored or binary. Private keys protected by a passphrase are decrypted with the passphrase.
+func Open(path string, passphrase []byte) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entities openpgp.EntityList
+	if bytes.Contains(data, []byte("-----BEGIN PGP")) {
+		entities, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	} else {
+		entities, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading keyring %s: %w", path, err)
+	}
+
+	for _, entity := range entities {
+		if !hasEncryptedPrivateKey(entity) {
+			continue
+		}
+		if passphrase == nil {
+			return nil, fmt.Errorf("the private key for %s needs a passphrase", describeEntity(entity))
+		}
+		if err := entity.DecryptPrivateKeys(passphrase); err != nil {
+			return nil, fmt.Errorf("decrypting the private key for %s: %w", describeEntity(entity), err)
+		}
+	}
+	return &Keyring{entities: entities}, nil
+}
+
+func hasEncryptedPrivateKey(entity *openpgp.Entity) bool {
+	if entity.PrivateKey != nil && entity.PrivateKey.Encrypted {
+		return true
+	}
+	for _, subkey := range entity.Subkeys {
+		if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+			return true
+		}
+	}
+	return false
+}
+
+// Check reports why an email from the address to the recipients can't be protected as asked, like a recipient
+// without a key, so it can be fixed before the email is sent. It's safe to call on a nil keyring.
+func (k *Keyring) Check(from string, recipients []string, protection core.Protection) error {
+	if !protection.Enabled() {
+		return nil
+	}
+	if k == nil {
+		return errNoKeyring
+	}
+	if protection.Sign {
+		if _, err := k.signer(from); err != nil {
+			return err
+		}
+	}
+	if protection.Encrypt {
+		if _, err := k.recipients(append([]string{from}, recipients...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signer finds the user's own key for an address, to sign what they send from it.
+func (k *Keyring) signer(address string) (*openpgp.Entity, error) {
+	for _, entity := range k.find(address) {
+		if entity.PrivateKey != nil {
+			return entity, nil
+		}
+	}
+	return nil, fmt.Errorf("no private key for %s", address)
+}
+
+// recipients finds a key to encrypt to for each address, failing with all the addresses that have no key rather than
+// sending any of them something they can't read.
+func (k *Keyring) recipients(addresses []string) ([]*openpgp.Entity, error) {
+	var entities []*openpgp.Entity
+	var missing []string
+	for _, address := range addresses {
+		found := false
+		for _, entity := range k.find(address) {
+			if _, ok := entity.EncryptionKey(time.Now()); ok {
+				entities = append(entities, entity)
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, address)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("no key to encrypt to %s", strings.Join(missing, ", "))
+	}
+	return entities, nil
+}
+
+// find returns the keys with an identity for the address.
+func (k *Keyring) find(address string) []*openpgp.Entity {
+	var entities []*openpgp.Entity
+	for _, entity := range k.entities {
+		for _, identity := range entity.Identities {
+			if identity.UserId != nil && strings.EqualFold(identity.UserId.Email, address) {
+				entities = append(entities, entity)
+				break
+			}
+		}
+	}
+	return entities
+}
+
+// describeEntity names a key by its primary identity, like "Alice <alice@example.com>", or its key ID if it has none.
+func describeEntity(entity *openpgp.Entity) string {
+	if identity := entity.PrimaryIdentity(); identity != nil {
+		return identity.Name
+	}
+	return entity.PrimaryKey.KeyIdString()
+}
@@ -0,0 +1,87 @@
+package pgp
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+func writeKeyring(t *testing.T, armored bool, passphrase []byte) string {
+	t.Helper()
+	alice, bob := newEntity(t, "Alice", "alice@example.com"), newEntity(t, "Bob", "bob@example.com")
+	if passphrase != nil {
+		if err := alice.EncryptPrivateKeys(passphrase, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := alice.SerializePrivateWithoutSigning(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.Serialize(&b); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	if armored {
+		var armoredData bytes.Buffer
+		writer, err := armor.Encode(&armoredData, "PGP PRIVATE KEY BLOCK", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		data = armoredData.Bytes()
+	}
+
+	path := filepath.Join(t.TempDir(), "keyring.asc")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpen(t *testing.T) {
+	for _, armored := range []bool{true, false} {
+		keyring, err := Open(writeKeyring(t, armored, nil), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := keyring.signer("ALICE@example.com"); err != nil {
+			t.Errorf("expected to sign as Alice, got %v", err)
+		}
+		if _, err := keyring.signer("bob@example.com"); err == nil {
+			t.Error("expected not to be able to sign as Bob with only his public key")
+		}
+		if recipients, err := keyring.recipients([]string{"bob@example.com"}); err != nil || len(recipients) != 1 {
+			t.Errorf("expected to encrypt to Bob, got %v", err)
+		}
+	}
+}
+
+func TestOpen_Passphrase(t *testing.T) {
+	path := writeKeyring(t, true, []byte("correct horse"))
+
+	if _, err := Open(path, nil); err == nil {
+		t.Error("expected an error without the passphrase")
+	}
+	if _, err := Open(path, []byte("wrong")); err == nil {
+		t.Error("expected an error with the wrong passphrase")
+	}
+	keyring, err := Open(path, []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Protect(buildEmail(t), keyring, "alice@example.com", nil, core.Protection{Sign: true}); err != nil {
+		t.Errorf("expected to sign with the decrypted key, got %v", err)
+	}
+}
@@ -0,0 +1,303 @@
+package pgp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/emersion/go-message/textproto"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/message"
+)
+
+const (
+	signatureProtocol  = "application/pgp-signature"
+	encryptionProtocol = "application/pgp-encrypted"
+	// maxNesting is how many layers of signing and encryption are taken off, like a signed email that was then
+	// encrypted.
+	maxNesting = 3
+)
+
+// Result is what was found by taking the protection off an email.
+type Result struct {
+	// Encrypted is set when the email was encrypted, and so has been decrypted.
+	Encrypted bool
+	// Signature is the email's signature, or nil if it isn't signed.
+	Signature *Signature
+	// Raw is the email as if it had never been protected: its own header with the content that was signed or
+	// encrypted.
+	Raw []byte
+	// Err is why the email couldn't be decrypted, or its protection taken off at all, in which case Raw is nil.
+	Err error
+}
+
+// Signature is an OpenPGP signature on an email.
+type Signature struct {
+	// Signer names the key that made the signature, like "Alice <alice@example.com>", if it's in the keyring.
+	Signer string
+	// Err is why the signature couldn't be verified, or nil if it's valid.
+	Err error
+}
+
+// Valid reports whether the signature was verified and made by a key for the sender.
+func (s Signature) Valid() bool {
+	return s.Err == nil
+}
+
+// Unwrap verifies a signed PGP/MIME email, or decrypts an encrypted one, returning nil if the email isn't protected
+// with OpenPGP. A signature that doesn't verify, or an email that can't be decrypted, is described in the result.
+func Unwrap(raw []byte, keyring *Keyring) *Result {
+	header, content, err := message.SplitContent(raw)
+	if err != nil {
+		// it isn't a readable email, let alone a protected one
+		return nil
+	}
+	result := &Result{}
+	content, err = result.unwrap(content, keyring, senderAddress(header))
+	if !result.Encrypted && result.Signature == nil && err == nil {
+		return nil
+	}
+	if err == nil {
+		result.Raw, err = message.JoinContent(header, content)
+	}
+	result.Err = err
+	return result
+}
+
+// unwrap takes each layer of protection off the content in turn, returning what's inside.
+func (r *Result) unwrap(content []byte, keyring *Keyring, sender string) ([]byte, error) {
+	for range maxNesting {
+		header, body, err := message.SplitEntity(content)
+		if err != nil {
+			return nil, err
+		}
+		mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+		if err != nil {
+			return content, nil
+		}
+
+		switch {
+		case mediaType == "multipart/signed" && strings.EqualFold(params["protocol"], signatureProtocol):
+			parts, err := message.SplitMultipart(body, params["boundary"])
+			if err != nil {
+				return nil, err
+			}
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("signed email has %d parts rather than 2", len(parts))
+			}
+			_, signature, err := message.SplitEntity(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			r.Signature = verify(keyring, parts[0], signature, sender)
+			content = parts[0]
+		case mediaType == "multipart/encrypted" && strings.EqualFold(params["protocol"], encryptionProtocol):
+			r.Encrypted = true
+			parts, err := message.SplitMultipart(body, params["boundary"])
+			if err != nil {
+				return nil, err
+			}
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("encrypted email has %d parts rather than 2", len(parts))
+			}
+			_, ciphertext, err := message.SplitEntity(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			content, err = r.decrypt(keyring, ciphertext, sender)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return content, nil
+		}
+	}
+	return content, nil
+}
+
+// verify checks a detached signature over the signed part.
+func verify(keyring *Keyring, signed, signature []byte, sender string) *Signature {
+	if keyring == nil {
+		return &Signature{Err: errNoKeyring}
+	}
+	signer, err := openpgp.CheckArmoredDetachedSignature(keyring.entities, bytes.NewReader(signed), bytes.NewReader(signature), config)
+	if err != nil {
+		return &Signature{Signer: keyring.issuer(signature), Err: describeSignatureError(err)}
+	}
+	return checkSigner(signer, sender)
+}
+
+// issuer names the key a signature says it was made by, even though it didn't verify, or nothing if the key isn't in
+// the keyring.
+func (k *Keyring) issuer(signature []byte) string {
+	block, err := armor.Decode(bytes.NewReader(signature))
+	if err != nil {
+		return ""
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return ""
+	}
+	sig, ok := p.(*packet.Signature)
+	if !ok || sig.IssuerKeyId == nil {
+		return ""
+	}
+	keys := k.entities.KeysById(*sig.IssuerKeyId)
+	if len(keys) == 0 {
+		return ""
+	}
+	return describeEntity(keys[0].Entity)
+}
+
+// decrypt decrypts an armored message with the user's private keys, checking its signature if it was signed as it
+// was encrypted.
+func (r *Result) decrypt(keyring *Keyring, ciphertext []byte, sender string) ([]byte, error) {
+	if keyring == nil {
+		return nil, errNoKeyring
+	}
+	block, err := armor.Decode(bytes.NewReader(ciphertext))
+	if err != nil {
+		return nil, fmt.Errorf("reading the encrypted message: %w", err)
+	}
+	details, err := openpgp.ReadMessage(block.Body, keyring.entities, nil, config)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting: %w", err)
+	}
+	plaintext, err := io.ReadAll(details.UnverifiedBody)
+	if err != nil {
+		// the message was changed after it was encrypted, or the signature is bad, so none of it can be trusted
+		return nil, fmt.Errorf("decrypting: %w", err)
+	}
+
+	if details.IsSigned {
+		switch {
+		case details.SignedBy == nil:
+			r.Signature = &Signature{Err: fmt.Errorf("signed by unknown key %016X", details.SignedByKeyId)}
+		case details.SignatureError != nil:
+			r.Signature = &Signature{Signer: describeEntity(details.SignedBy.Entity), Err: describeSignatureError(details.SignatureError)}
+		default:
+			r.Signature = checkSigner(details.SignedBy.Entity, sender)
+		}
+	}
+	return message.CanonicalLineEndings(plaintext), nil
+}
+
+// checkSigner makes sure a valid signature was made by a key for the sender, rather than anyone else with a key in
+// the keyring.
+func checkSigner(signer *openpgp.Entity, sender string) *Signature {
+	signature := &Signature{Signer: describeEntity(signer)}
+	for _, identity := range signer.Identities {
+		if identity.UserId != nil && strings.EqualFold(identity.UserId.Email, sender) {
+			return signature
+		}
+	}
+	signature.Err = fmt.Errorf("the key isn't for the sender, %s", sender)
+	return signature
+}
+
+func describeSignatureError(err error) error {
+	if errors.Is(err, pgperrors.ErrUnknownIssuer) {
+		return errors.New("signed by a key that isn't in the keyring")
+	}
+	return err
+}
+
+func senderAddress(header textproto.Header) string {
+	address, err := mail.ParseAddress(header.Get("From"))
+	if err != nil {
+		return ""
+	}
+	return address.Address
+}
+
+// Protect signs and encrypts a built email with PGP/MIME, as the email's protection asks. Emails that are encrypted
+// are encrypted to the sender as well as the recipients, so the copy that's kept can be read.
+func Protect(raw []byte, keyring *Keyring, from string, recipients []string, protection core.Protection) ([]byte, error) {
+	if !protection.Enabled() {
+		return raw, nil
+	}
+	if keyring == nil {
+		return nil, errNoKeyring
+	}
+	header, content, err := message.SplitContent(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var signer *openpgp.Entity
+	if protection.Sign {
+		signer, err = keyring.signer(from)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !protection.Encrypt {
+		var signature bytes.Buffer
+		if err := openpgp.ArmoredDetachSign(&signature, signer, bytes.NewReader(content), config); err != nil {
+			return nil, fmt.Errorf("signing: %w", err)
+		}
+		signaturePart := "Content-Type: application/pgp-signature; name=\"signature.asc\"\r\n" +
+			"Content-Description: OpenPGP digital signature\r\n" +
+			"Content-Disposition: attachment; filename=\"signature.asc\"\r\n" +
+			"\r\n" + string(message.CanonicalLineEndings(signature.Bytes())) + "\r\n"
+		params := map[string]string{"protocol": signatureProtocol, "micalg": "pgp-sha256"}
+		return message.BuildMultipart(header, "multipart/signed", params, content, []byte(signaturePart))
+	}
+
+	to, err := keyring.recipients(append([]string{from}, recipients...))
+	if err != nil {
+		return nil, err
+	}
+	var ciphertext bytes.Buffer
+	armored, err := armor.Encode(&ciphertext, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := openpgp.Encrypt(armored, unique(to), signer, nil, config)
+	if err != nil {
+		return nil, fmt.Errorf("encrypting: %w", err)
+	}
+	if _, err := plaintext.Write(content); err != nil {
+		return nil, err
+	}
+	if err := plaintext.Close(); err != nil {
+		return nil, err
+	}
+	if err := armored.Close(); err != nil {
+		return nil, err
+	}
+
+	versionPart := "Content-Type: application/pgp-encrypted\r\n" +
+		"Content-Description: PGP/MIME version identification\r\n" +
+		"\r\n" +
+		"Version: 1\r\n"
+	encryptedPart := "Content-Type: application/octet-stream; name=\"encrypted.asc\"\r\n" +
+		"Content-Description: OpenPGP encrypted message\r\n" +
+		"Content-Disposition: inline; filename=\"encrypted.asc\"\r\n" +
+		"\r\n" + string(message.CanonicalLineEndings(ciphertext.Bytes())) + "\r\n"
+	params := map[string]string{"protocol": encryptionProtocol}
+	return message.BuildMultipart(header, "multipart/encrypted", params, []byte(versionPart), []byte(encryptedPart))
+}
+
+// unique removes keys that are there more than once, like when the sender is also a recipient.
+func unique(entities []*openpgp.Entity) []*openpgp.Entity {
+	var result []*openpgp.Entity
+	seen := make(map[*openpgp.Entity]bool)
+	for _, entity := range entities {
+		if !seen[entity] {
+			seen[entity] = true
+			result = append(result, entity)
+		}
+	}
+	return result
+}
@@ -0,0 +1,196 @@
+package pgp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/message"
+)
+
+// newEntity generates a throwaway key for an address.
+func newEntity(t *testing.T, name, address string) *openpgp.Entity {
+	t.Helper()
+	entity, err := openpgp.NewEntity(name, "", address, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entity
+}
+
+// publicOnly is how someone else's key is kept in a keyring, without its private key.
+func publicOnly(t *testing.T, entity *openpgp.Entity) *openpgp.Entity {
+	t.Helper()
+	var b bytes.Buffer
+	if err := entity.Serialize(&b); err != nil {
+		t.Fatal(err)
+	}
+	entities, err := openpgp.ReadKeyRing(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entities[0]
+}
+
+func buildEmail(t *testing.T) []byte {
+	t.Helper()
+	raw, err := message.Build(core.OutgoingEmail{
+		From:      "Alice <alice@example.com>",
+		To:        "bob@example.com",
+		Subject:   "Launch codes",
+		Body:      "The code is 1234.\nDon't tell anyone.",
+		MessageId: "launch@example.com",
+	}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func parseBody(t *testing.T, raw []byte) string {
+	t.Helper()
+	body, _, err := message.ParseBody(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestSigned(t *testing.T) {
+	alice, bob := newEntity(t, "Alice", "alice@example.com"), newEntity(t, "Bob", "bob@example.com")
+	signed, err := Protect(buildEmail(t), NewKeyring(alice), "alice@example.com", []string{"bob@example.com"}, core.Protection{Sign: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(signed, []byte("multipart/signed")) || !bytes.Contains(signed, []byte("The code is 1234.")) {
+		t.Fatalf("expected a signed email that can still be read, got %s", signed)
+	}
+
+	result := Unwrap(signed, NewKeyring(bob, publicOnly(t, alice)))
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if result.Encrypted || result.Signature == nil || !result.Signature.Valid() || result.Signature.Signer != "Alice <alice@example.com>" {
+		t.Fatalf("expected a valid signature from Alice, got %+v", result.Signature)
+	}
+	if body := parseBody(t, result.Raw); body != "The code is 1234.\nDon't tell anyone." {
+		t.Errorf("unexpected body %q", body)
+	}
+
+	tampered := bytes.Replace(signed, []byte("1234"), []byte("4321"), 1)
+	if result := Unwrap(tampered, NewKeyring(publicOnly(t, alice))); result.Err != nil || result.Signature.Valid() ||
+		result.Signature.Signer != "Alice <alice@example.com>" {
+		t.Errorf("expected changing the body to break the signature, got %+v", result)
+	}
+
+	if result := Unwrap(signed, NewKeyring(bob)); result.Err != nil || result.Signature.Valid() ||
+		result.Signature.Err.Error() != "signed by a key that isn't in the keyring" {
+		t.Errorf("expected the signer to be unknown, got %+v", result)
+	}
+
+	if result := Unwrap(signed, nil); result.Err != nil || result.Signature.Valid() {
+		t.Errorf("expected the signature not to be verified without a keyring, got %+v", result)
+	}
+}
+
+func TestSigned_NotTheSender(t *testing.T) {
+	alice, mallory := newEntity(t, "Alice", "alice@example.com"), newEntity(t, "Mallory", "mallory@example.net")
+	// Mallory signs an email with their own key, but it says it's from Alice
+	signed, err := Protect(buildEmail(t), NewKeyring(mallory), "mallory@example.net", nil, core.Protection{Sign: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := Unwrap(signed, NewKeyring(publicOnly(t, alice), publicOnly(t, mallory)))
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if result.Signature.Valid() || result.Signature.Signer != "Mallory <mallory@example.net>" ||
+		!strings.Contains(result.Signature.Err.Error(), "alice@example.com") {
+		t.Errorf("expected a signature by someone other than the sender not to count, got %+v", result.Signature)
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	alice, bob := newEntity(t, "Alice", "alice@example.com"), newEntity(t, "Bob", "bob@example.com")
+	encrypted, err := Protect(buildEmail(t), NewKeyring(alice, publicOnly(t, bob)), "alice@example.com", []string{"bob@example.com"},
+		core.Protection{Sign: true, Encrypt: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(encrypted, []byte("1234")) || !bytes.Contains(encrypted, []byte("Subject: Launch codes")) {
+		t.Fatalf("expected only the body to be encrypted, got %s", encrypted)
+	}
+
+	// both the recipient and the sender, reading their sent copy, can decrypt it
+	for _, keyring := range []*Keyring{NewKeyring(bob, publicOnly(t, alice)), NewKeyring(alice)} {
+		result := Unwrap(encrypted, keyring)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if !result.Encrypted || result.Signature == nil || !result.Signature.Valid() {
+			t.Errorf("expected an encrypted email with a valid signature, got %+v", result)
+		}
+		if body := parseBody(t, result.Raw); body != "The code is 1234.\nDon't tell anyone." {
+			t.Errorf("unexpected body %q", body)
+		}
+	}
+
+	eve := newEntity(t, "Eve", "eve@example.org")
+	if result := Unwrap(encrypted, NewKeyring(eve)); result.Err == nil || !result.Encrypted || result.Raw != nil {
+		t.Errorf("expected an error saying the email is encrypted for someone else, got %+v", result)
+	}
+}
+
+func TestEncrypted_Unsigned(t *testing.T) {
+	alice, bob := newEntity(t, "Alice", "alice@example.com"), newEntity(t, "Bob", "bob@example.com")
+	encrypted, err := Protect(buildEmail(t), NewKeyring(publicOnly(t, alice), publicOnly(t, bob)), "alice@example.com",
+		[]string{"bob@example.com"}, core.Protection{Encrypt: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := Unwrap(encrypted, NewKeyring(bob))
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if !result.Encrypted || result.Signature != nil {
+		t.Errorf("expected an encrypted email without a signature, got %+v", result)
+	}
+}
+
+func TestProtect_Errors(t *testing.T) {
+	alice := newEntity(t, "Alice", "alice@example.com")
+	keyring := NewKeyring(publicOnly(t, alice))
+
+	if _, err := Protect(buildEmail(t), keyring, "alice@example.com", nil, core.Protection{Sign: true}); err == nil {
+		t.Error("expected an error signing without a private key")
+	}
+	if err := keyring.Check("alice@example.com", nil, core.Protection{Sign: true}); err == nil || err.Error() != "no private key for alice@example.com" {
+		t.Errorf("expected the check to find there's no private key, got %v", err)
+	}
+	if err := (*Keyring)(nil).Check("alice@example.com", nil, core.Protection{Encrypt: true}); err == nil {
+		t.Error("expected the check to fail without a keyring")
+	}
+	_, err := Protect(buildEmail(t), keyring, "alice@example.com", []string{"bob@example.com", "carol@example.com"}, core.Protection{Encrypt: true})
+	if err == nil || err.Error() != "no key to encrypt to bob@example.com, carol@example.com" {
+		t.Errorf("expected an error naming the recipients without keys, got %v", err)
+	}
+	if _, err := Protect(buildEmail(t), nil, "alice@example.com", nil, core.Protection{Sign: true}); err == nil {
+		t.Error("expected an error without a keyring")
+	}
+
+	raw := buildEmail(t)
+	if unprotected, err := Protect(raw, nil, "alice@example.com", nil, core.Protection{}); err != nil || !bytes.Equal(unprotected, raw) {
+		t.Errorf("expected an unprotected email to be left alone, got %v", err)
+	}
+}
+
+func TestUnwrap_NotProtected(t *testing.T) {
+	if result := Unwrap(buildEmail(t), NewKeyring()); result != nil {
+		t.Errorf("expected nothing for an unprotected email, got %+v", result)
+	}
+}
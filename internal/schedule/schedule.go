@@ -0,0 +1,151 @@
+// Package schedule understands the times people type when scheduling an email, like "tomorrow 9am" or "in 2h".
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultHour is when emails are sent if only a day is given, e.g. "tomorrow".
+const defaultHour = 9
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+var units = map[string]time.Duration{
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+}
+
+// Parse works out the time described by text, relative to now. It understands:
+//   - a delay, like "in 2h", "in 30 minutes" or "in 3 days"
+//   - a day, like "today", "tomorrow", "friday" or "2025-06-01", which is at 9am unless a time is also given
+//   - a time, like "9am", "5:30pm", "17:00" or "noon", which is today if it's still to come or tomorrow otherwise
+//
+// The time must be in the future.
+func Parse(text string, now time.Time) (time.Time, error) {
+	fields := strings.Fields(strings.ToLower(text))
+	if len(fields) == 0 {
+		return time.Time{}, errors.New("no time given")
+	}
+
+	if fields[0] == "in" {
+		delay, err := parseDelay(fields[1:])
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(delay), nil
+	}
+
+	todayMidnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var (
+		day                  time.Time
+		hour, minute         int
+		hasDay, hasTimeOfDay bool
+	)
+	for _, field := range fields {
+		if d, ok := parseDay(field, todayMidnight); ok {
+			if hasDay {
+				return time.Time{}, unknownField(field)
+			}
+			day, hasDay = d, true
+			continue
+		}
+		if h, m, ok := parseTimeOfDay(field); ok {
+			if hasTimeOfDay {
+				return time.Time{}, unknownField(field)
+			}
+			hour, minute, hasTimeOfDay = h, m, true
+			continue
+		}
+		return time.Time{}, unknownField(field)
+	}
+
+	if !hasTimeOfDay {
+		hour = defaultHour
+	}
+	if !hasDay {
+		day = todayMidnight
+		if !time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, now.Location()).After(now) {
+			day = day.AddDate(0, 0, 1)
+		}
+	}
+
+	result := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, now.Location())
+	if !result.After(now) {
+		return time.Time{}, fmt.Errorf("%s is in the past", result.Format("Mon, 2 Jan 15:04"))
+	}
+	return result, nil
+}
+
+func unknownField(field string) error {
+	return fmt.Errorf("don't understand %q", field)
+}
+
+// parseDelay understands "2h", "2 hours" and anything else time.ParseDuration does.
+func parseDelay(fields []string) (time.Duration, error) {
+	text := strings.Join(fields, "")
+	if text == "" {
+		return 0, errors.New("no delay given")
+	}
+
+	if digits := strings.IndexFunc(text, func(r rune) bool { return r < '0' || r > '9' }); digits > 0 {
+		if unit, ok := units[text[digits:]]; ok {
+			count, err := strconv.Atoi(text[:digits])
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+
+	delay, err := time.ParseDuration(text)
+	if err != nil || delay <= 0 {
+		return 0, fmt.Errorf("don't understand the delay %q", strings.Join(fields, " "))
+	}
+	return delay, nil
+}
+
+// parseDay returns midnight at the start of the day described by field.
+// Weekdays are the next one after today, so "monday" on a Monday is a week away.
+func parseDay(field string, today time.Time) (time.Time, bool) {
+	switch field {
+	case "today":
+		return today, true
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true
+	}
+	if weekday, ok := weekdays[field]; ok {
+		days := (int(weekday)-int(today.Weekday())+6)%7 + 1
+		return today.AddDate(0, 0, days), true
+	}
+	if date, err := time.ParseInLocation("2006-01-02", field, today.Location()); err == nil {
+		return date, true
+	}
+	return time.Time{}, false
+}
+
+// parseTimeOfDay understands 12-hour times like "9am" and "5:30pm", 24-hour times like "17:00", and "noon".
+func parseTimeOfDay(field string) (hour, minute int, ok bool) {
+	if field == "noon" {
+		return 12, 0, true
+	}
+
+	for _, layout := range []string{"3pm", "3:04pm", "15:04"} {
+		if parsed, err := time.Parse(layout, field); err == nil {
+			return parsed.Hour(), parsed.Minute(), true
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,49 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	// a Wednesday afternoon
+	now := time.Date(2025, 6, 4, 15, 20, 0, 0, time.UTC)
+
+	tests := []struct {
+		text string
+		want time.Time
+	}{
+		{"tomorrow 9am", time.Date(2025, 6, 5, 9, 0, 0, 0, time.UTC)},
+		{"9am tomorrow", time.Date(2025, 6, 5, 9, 0, 0, 0, time.UTC)},
+		{"Tomorrow", time.Date(2025, 6, 5, 9, 0, 0, 0, time.UTC)},
+		{"5:30pm", time.Date(2025, 6, 4, 17, 30, 0, 0, time.UTC)},
+		{"9:15", time.Date(2025, 6, 5, 9, 15, 0, 0, time.UTC)},
+		{"fri noon", time.Date(2025, 6, 6, 12, 0, 0, 0, time.UTC)},
+		{"wednesday 14:00", time.Date(2025, 6, 11, 14, 0, 0, 0, time.UTC)},
+		{"2025-07-01 08:45", time.Date(2025, 7, 1, 8, 45, 0, 0, time.UTC)},
+		{"in 2h", now.Add(2 * time.Hour)},
+		{"in 30 minutes", now.Add(30 * time.Minute)},
+		{"in 3 days", now.AddDate(0, 0, 3)},
+		{"in 1h30m", now.Add(90 * time.Minute)},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.text, now)
+		if err != nil {
+			t.Errorf("Parse(%q) returned an error: %v", test.text, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("Parse(%q) = %v, want %v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	now := time.Date(2025, 6, 4, 15, 20, 0, 0, time.UTC)
+
+	for _, text := range []string{"", "soon", "today 9am", "2025-01-01", "in", "in -5m", "tomorrow friday"} {
+		if got, err := Parse(text, now); err == nil {
+			t.Errorf("Parse(%q) = %v, expected an error", text, got)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package senderauth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	gomessage "github.com/emersion/go-message"
+	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-message/textproto"
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/bengesoff/mail-tui/internal/links"
+)
+
+// addressPattern finds something that looks like an email address, like one put in a sender's name to impersonate
+// someone.
+var addressPattern = regexp.MustCompile(`[^\s<>"'()@,;:]+@[^\s<>"'()@,;:]+\.[^\s<>"'()@,;:]+`)
+
+// Options changes how emails are checked.
+type Options struct {
+	// Resolver looks up DKIM keys to verify signatures locally. Without one, only the server's results are used.
+	Resolver Resolver
+	// TrustedServers are the authserv-ids of the servers whose Authentication-Results are believed. Without any, only
+	// the topmost one is, since that's the one the receiving server added, but that's only safe if the server always
+	// adds one: otherwise the sender could have added it.
+	TrustedServers []string
+}
+
+// Report is what was found out about who sent an email.
+type Report struct {
+	// FromAddress is the address in the From header, and FromDomain its domain.
+	FromAddress string
+	FromDomain  string
+	// Results are the server's checks, from the Authentication-Results headers that are trusted.
+	Results []Result
+	// Signatures are the DKIM signatures verified locally, if there was a resolver to look up their keys with.
+	Signatures []Signature
+	// Checked is whether there was anything to show where the email came from: results from the server, or signatures
+	// that were verified locally.
+	Checked bool
+	// Aligned is whether a check that passed was for the From domain, so the domain people see really sent it.
+	Aligned bool
+	// Warnings describe what looks like it's been spoofed.
+	Warnings []string
+}
+
+// Check looks at an email's source for signs it isn't from who it says it is.
+func Check(ctx context.Context, raw []byte, options Options) (Report, error) {
+	header, err := textproto.ReadHeader(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		return Report{}, err
+	}
+	mailHeader := mail.Header{Header: gomessage.Header{Header: header}}
+
+	var report Report
+	from, err := mailHeader.AddressList("From")
+	if err != nil || len(from) == 0 {
+		report.Warnings = append(report.Warnings, "The sender's address can't be read")
+	} else {
+		report.FromAddress = from[0].Address
+		_, report.FromDomain, _ = strings.Cut(strings.ToLower(from[0].Address), "@")
+		if warning := impersonationWarning(from[0]); warning != "" {
+			report.Warnings = append(report.Warnings, warning)
+		}
+	}
+
+	report.Results = trustedResults(header, options.TrustedServers)
+	if options.Resolver != nil {
+		if report.Signatures, err = VerifyDKIM(ctx, options.Resolver, raw); err != nil {
+			return Report{}, err
+		}
+	}
+	report.Checked = len(report.Results) > 0 || len(report.Signatures) > 0
+	if report.Checked && report.FromDomain != "" {
+		report.checkAlignment()
+	} else if report.FromDomain != "" {
+		// an email nothing vouches for is what a spoofed one looks like, so it's not left to a grey "Not checked"
+		reason := "the server didn't check it and it isn't signed"
+		if options.Resolver == nil {
+			reason = "the server didn't check it and signatures aren't being verified"
+		}
+		report.Warnings = append(report.Warnings, fmt.Sprintf("Nothing shows this email really came from %s: %s", report.FromDomain, reason))
+	}
+
+	// the email can still be checked without its links if they can't be found
+	found, _ := links.FromMessage(bytes.NewReader(raw))
+	if warning := linksWarning(found); warning != "" {
+		report.Warnings = append(report.Warnings, warning)
+	}
+	return report, nil
+}
+
+// trustedResults reads the Authentication-Results fields added by the trusted servers, or the topmost one.
+func trustedResults(header textproto.Header, trusted []string) []Result {
+	var results []Result
+	fields := header.FieldsByKey("Authentication-Results")
+	for fields.Next() {
+		parsed, err := ParseResults(fields.Value())
+		if len(trusted) == 0 {
+			if err == nil {
+				results = parsed.Methods
+			}
+			break
+		}
+		if err != nil {
+			continue
+		}
+		for _, server := range trusted {
+			if strings.EqualFold(parsed.Server, server) {
+				results = append(results, parsed.Methods...)
+			}
+		}
+	}
+	return results
+}
+
+// checkAlignment decides whether any check that passed vouches for the From domain, following DMARC's relaxed
+// alignment where subdomains of the same organisation count, and warns if none does.
+func (r *Report) checkAlignment() {
+	for _, result := range r.Results {
+		if result.Method != "dmarc" {
+			continue
+		}
+		switch result.Value {
+		case "pass":
+			r.Aligned = true
+			return
+		case "fail":
+			r.Warnings = append(r.Warnings, fmt.Sprintf("%s failed DMARC, so this email probably isn't from them", r.FromDomain))
+			return
+		}
+	}
+
+	for _, result := range r.Results {
+		if !result.Passed() {
+			continue
+		}
+		var domain string
+		switch result.Method {
+		case "spf":
+			domain = addressDomain(result.Properties["smtp.mailfrom"])
+		case "dkim":
+			domain = result.Properties["header.d"]
+			if domain == "" {
+				domain = addressDomain(result.Properties["header.i"])
+			}
+		}
+		if domain != "" && aligned(domain, r.FromDomain) {
+			r.Aligned = true
+			return
+		}
+	}
+	for _, signature := range r.Signatures {
+		if signature.Valid() && aligned(signature.Domain, r.FromDomain) {
+			r.Aligned = true
+			return
+		}
+	}
+	r.Warnings = append(r.Warnings, fmt.Sprintf("Nothing shows this email really came from %s: no SPF or DKIM check for it passed", r.FromDomain))
+}
+
+// addressDomain returns the domain of an address, or the value itself if it's already a domain.
+func addressDomain(address string) string {
+	if _, domain, found := strings.Cut(address, "@"); found {
+		return domain
+	}
+	return address
+}
+
+// aligned reports whether two domains belong to the same organisation, like "mail.example.co.uk" and "example.co.uk".
+func aligned(domain, from string) bool {
+	domain, from = strings.ToLower(strings.TrimSuffix(domain, ".")), strings.ToLower(strings.TrimSuffix(from, "."))
+	if domain == from {
+		return true
+	}
+	domainOrganisation, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return false
+	}
+	fromOrganisation, err := publicsuffix.EffectiveTLDPlusOne(from)
+	return err == nil && domainOrganisation == fromOrganisation
+}
+
+// impersonationWarning warns about a sender's name that shows a different address to the one it's really from, like
+// "ceo@example.com <someone@elsewhere.example>", which some clients show only the name of.
+func impersonationWarning(from *mail.Address) string {
+	for _, shown := range addressPattern.FindAllString(from.Name, -1) {
+		if !strings.EqualFold(shown, from.Address) {
+			return fmt.Sprintf("The sender's name shows %s, but it's really from %s", shown, from.Address)
+		}
+	}
+	return ""
+}
+
+// linksWarning warns about links whose text looks like it goes to a different site to the one it really does.
+func linksWarning(found []links.Link) string {
+	var mismatched []links.Link
+	for _, link := range found {
+		if link.Mismatched() {
+			mismatched = append(mismatched, link)
+		}
+	}
+	if len(mismatched) == 0 {
+		return ""
+	}
+	first := mismatched[0]
+	warning := fmt.Sprintf("A link shows %q but goes to %s", strings.TrimSpace(first.Text), first.Host())
+	switch len(mismatched) {
+	case 1:
+	case 2:
+		warning += ", and another link goes somewhere other than it shows"
+	default:
+		warning += fmt.Sprintf(", and %d more links go somewhere other than they show", len(mismatched)-1)
+	}
+	return warning
+}
@@ -0,0 +1,125 @@
+package senderauth
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func checkEmail(t *testing.T, header string, options Options) Report {
+	t.Helper()
+	raw := strings.ReplaceAll(header, "\n", "\r\n") + "\r\nHello\r\n"
+	report, err := Check(context.Background(), []byte(raw), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestCheck_Aligned(t *testing.T) {
+	report := checkEmail(t, "Authentication-Results: mx.example.net; spf=pass smtp.mailfrom=bounces@mail.example.co.uk; dkim=fail header.d=example.co.uk\n"+
+		"From: Alice <alice@example.co.uk>\n", Options{})
+
+	if !report.Checked || !report.Aligned || len(report.Warnings) != 0 {
+		t.Errorf("expected the subdomain's SPF pass to vouch for the sender, got %+v", report)
+	}
+	if report.FromDomain != "example.co.uk" || len(report.Results) != 2 {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+func TestCheck_NotAligned(t *testing.T) {
+	tests := map[string]string{
+		// a pass for a different organisation doesn't count, even one on the same public suffix
+		"spf=pass smtp.mailfrom=other.co.uk; dkim=pass header.d=attacker.example":    "Nothing shows this email really came from example.co.uk",
+		"dmarc=fail header.from=example.co.uk; spf=pass smtp.mailfrom=example.co.uk": "example.co.uk failed DMARC",
+	}
+	for results, want := range tests {
+		report := checkEmail(t, "Authentication-Results: mx.example.net; "+results+"\nFrom: CEO <ceo@example.co.uk>\n", Options{})
+		if report.Aligned || len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], want) {
+			t.Errorf("%s: expected a warning containing %q, got %+v", results, want, report)
+		}
+	}
+}
+
+func TestCheck_OnlyTrustedResults(t *testing.T) {
+	// the sender added their own results below the server's
+	header := "Authentication-Results: mx.example.net; spf=fail smtp.mailfrom=example.com\n" +
+		"Authentication-Results: mx.example.net; dmarc=pass header.from=example.com\n" +
+		"Authentication-Results: fake.example.org; dmarc=pass header.from=example.com\n" +
+		"From: ceo@example.com\n"
+
+	if report := checkEmail(t, header, Options{}); report.Aligned || len(report.Results) != 1 {
+		t.Errorf("expected only the topmost results to be used, got %+v", report)
+	}
+	if report := checkEmail(t, header, Options{TrustedServers: []string{"MX.example.net"}}); !report.Aligned || len(report.Results) != 2 {
+		t.Errorf("expected both of the trusted server's results to be used, got %+v", report)
+	}
+}
+
+func TestCheck_Unchecked(t *testing.T) {
+	report := checkEmail(t, "From: alice@example.com\n", Options{})
+	want := "Nothing shows this email really came from example.com: the server didn't check it and signatures aren't being verified"
+	if report.Checked || len(report.Warnings) != 1 || report.Warnings[0] != want {
+		t.Errorf("expected nothing to go on and a warning saying so, got %+v", report)
+	}
+
+	report = checkEmail(t, "From: alice@example.com\n", Options{Resolver: stubResolver{}})
+	want = "Nothing shows this email really came from example.com: the server didn't check it and it isn't signed"
+	if report.Checked || len(report.Warnings) != 1 || report.Warnings[0] != want {
+		t.Errorf("expected a warning that the email isn't signed, got %+v", report)
+	}
+}
+
+func TestCheck_LocalDKIM(t *testing.T) {
+	email, resolver := signedEd25519Email(t)
+
+	report, err := Check(context.Background(), []byte(email), Options{Resolver: resolver})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Checked || !report.Aligned || len(report.Signatures) != 1 {
+		t.Errorf("expected the local signature to vouch for the sender, got %+v", report)
+	}
+
+	report, err = Check(context.Background(), []byte(email), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked || len(report.Signatures) != 0 {
+		t.Errorf("expected signatures not to be verified without a resolver, got %+v", report)
+	}
+}
+
+func TestCheck_ImpersonatedName(t *testing.T) {
+	// the attacker's domain passes its checks, so the name is the only giveaway
+	report := checkEmail(t, "Authentication-Results: mx.example.net; dmarc=pass header.from=attacker.example\n"+
+		`From: "ceo@example.com" <ceo.example.com@attacker.example>`+"\n", Options{})
+	if len(report.Warnings) != 1 || report.Warnings[0] != "The sender's name shows ceo@example.com, but it's really from ceo.example.com@attacker.example" {
+		t.Errorf("expected a warning about the name, got %+v", report.Warnings)
+	}
+
+	report = checkEmail(t, "Authentication-Results: mx.example.net; dmarc=pass header.from=example.com\n"+
+		`From: "alice@example.com" <ALICE@example.com>`+"\n", Options{})
+	if len(report.Warnings) != 0 {
+		t.Errorf("expected no warning when the name is the same address, got %+v", report.Warnings)
+	}
+}
+
+func TestCheck_MismatchedLinks(t *testing.T) {
+	raw := "Authentication-Results: mx.example.net; dmarc=pass header.from=example.com\r\n" +
+		"From: bank@example.com\r\n" +
+		"Content-Type: text/html\r\n" +
+		"\r\n" +
+		`<a href="https://example.com/account">Your account</a> ` +
+		`<a href="https://login.attacker.example/">https://www.example.com/login</a> ` +
+		`<a href="https://attacker.example/">paypal.com</a>`
+	report, err := Check(context.Background(), []byte(raw), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `A link shows "https://www.example.com/login" but goes to login.attacker.example, and another link goes somewhere other than it shows`
+	if len(report.Warnings) != 1 || report.Warnings[0] != want {
+		t.Errorf("expected a warning about the links, got %+v", report.Warnings)
+	}
+}
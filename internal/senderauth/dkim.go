@@ -0,0 +1,56 @@
+package senderauth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+// maxSignatures stops an email with lots of signatures from making lots of DNS lookups.
+const maxSignatures = 5
+
+// Resolver looks up the DNS TXT records that DKIM keys are published in. *net.Resolver is one.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Signature is the outcome of verifying one DKIM-Signature.
+type Signature struct {
+	// Domain is who signed the email, from the signature's d= tag.
+	Domain string
+	// Err is why the signature isn't valid, or nil if it is.
+	Err error
+}
+
+// Valid reports whether the signature was verified.
+func (s Signature) Valid() bool {
+	return s.Err == nil
+}
+
+// VerifyDKIM verifies the DKIM signatures in an email's source, looking up the signers' keys with the resolver.
+// rsa-sha1 signatures are refused since it's no longer safe, and so are signatures with a body length (l=), since
+// anything can be added to the body after the part they cover.
+func VerifyDKIM(ctx context.Context, resolver Resolver, raw []byte) ([]Signature, error) {
+	verifications, err := dkim.VerifyWithOptions(bytes.NewReader(raw), &dkim.VerifyOptions{
+		LookupTXT: func(name string) ([]string, error) {
+			records, err := resolver.LookupTXT(ctx, name)
+			if err != nil {
+				return nil, fmt.Errorf("looking up %s: %w", name, err)
+			}
+			return records, nil
+		},
+		MaxVerifications: maxSignatures,
+	})
+	if err != nil && !errors.Is(err, dkim.ErrTooManySignatures) {
+		return nil, err
+	}
+
+	signatures := make([]Signature, 0, len(verifications))
+	for _, verification := range verifications {
+		signatures = append(signatures, Signature{Domain: verification.Domain, Err: verification.Err})
+	}
+	return signatures, nil
+}
@@ -0,0 +1,180 @@
+package senderauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// stubResolver serves DKIM keys from a map instead of DNS.
+type stubResolver map[string]string
+
+func (r stubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	record, ok := r[name]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return []string{record}, nil
+}
+
+func hashBody(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// signedEd25519Email signs an email with relaxed canonicalization. The data that's signed is written out by hand
+// rather than canonicalized by the code being tested.
+func signedEd25519Email(t *testing.T) (string, stubResolver) {
+	t.Helper()
+	return signedEd25519EmailWithTags(t, "")
+}
+
+// signedEd25519EmailWithTags signs an email with extra tags, like "l=12; ", added to the signature.
+func signedEd25519EmailWithTags(t *testing.T, tags string) (string, stubResolver) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodyHash := hashBody("Hello Bob,\r\n")
+	signatureField := "v=1; a=ed25519-sha256; c=relaxed/relaxed; d=example.com; s=brisbane; " + tags + "\r\n" +
+		"\th=from:to:subject; bh=" + bodyHash + ";\r\n b="
+	signed := "from:Alice <alice@example.com>\r\n" +
+		"to:bob@example.com\r\n" +
+		"subject:Quarterly results\r\n" +
+		"dkim-signature:v=1; a=ed25519-sha256; c=relaxed/relaxed; d=example.com; s=brisbane; " + tags + "h=from:to:subject; bh=" +
+		bodyHash + "; b="
+	digest := sha256.Sum256([]byte(signed))
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(private, digest[:]))
+
+	email := "DKIM-Signature: " + signatureField + signature[:20] + "\r\n " + signature[20:] + "\r\n" +
+		"Received: from mail.example.com\r\n" +
+		"From: Alice   <alice@example.com>\r\n" +
+		"To: bob@example.com \r\n" +
+		"Subject: Quarterly\r\n\t results\r\n" +
+		"\r\n" +
+		"Hello \t Bob, \r\n\r\n\r\n"
+	resolver := stubResolver{"brisbane._domainkey.example.com": "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(public)}
+	return email, resolver
+}
+
+func TestVerifyDKIM_Ed25519Relaxed(t *testing.T) {
+	email, resolver := signedEd25519Email(t)
+
+	signatures, err := VerifyDKIM(context.Background(), resolver, []byte(email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signatures) != 1 || !signatures[0].Valid() {
+		t.Fatalf("expected a valid signature, got %+v", signatures)
+	}
+	if signatures[0].Domain != "example.com" {
+		t.Errorf("unexpected signer %+v", signatures[0])
+	}
+
+	// headers that aren't signed can change, but signed ones and the body can't
+	tests := []struct {
+		old, replacement string
+		valid            bool
+	}{
+		{"Received: from mail.example.com", "Received: from relay.example.net", true},
+		{"Subject: Quarterly", "Subject: Yearly", false},
+		{"Hello", "Goodbye", false},
+	}
+	for _, test := range tests {
+		changed := strings.Replace(email, test.old, test.replacement, 1)
+		signatures, err := VerifyDKIM(context.Background(), resolver, []byte(changed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if valid := signatures[0].Valid(); valid != test.valid {
+			t.Errorf("replacing %q: expected valid to be %t, got error %v", test.old, test.valid, signatures[0].Err)
+		}
+	}
+}
+
+func TestVerifyDKIM_RSASimple(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	field := "DKIM-Signature: v=1; a=rsa-sha256; d=mail.example.com; s=sel; h=from:subject:subject; bh=" +
+		hashBody("Hi  there\r\n") + "; b="
+	// the second "subject" in h= is for a Subject that doesn't exist, so it adds nothing
+	signed := "From: alice@example.com\r\nSubject: Hello\r\n" + field
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, private, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := field + base64.StdEncoding.EncodeToString(signature) + "\r\n" +
+		"From: alice@example.com\r\n" +
+		"Subject: Hello\r\n" +
+		"\r\n" +
+		"Hi  there\r\n\r\n"
+	resolver := stubResolver{"sel._domainkey.mail.example.com": "v=DKIM1; p=" + base64.StdEncoding.EncodeToString(public)}
+
+	signatures, err := VerifyDKIM(context.Background(), resolver, []byte(email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signatures) != 1 || !signatures[0].Valid() {
+		t.Fatalf("expected a valid signature, got %+v", signatures)
+	}
+
+	// "subject" is signed twice, so adding a second Subject is caught even though the first one is unchanged
+	added := strings.Replace(email, "\r\n\r\nHi", "\r\nSubject: Urgent\r\n\r\nHi", 1)
+	if signatures, _ := VerifyDKIM(context.Background(), resolver, []byte(added)); signatures[0].Valid() {
+		t.Error("expected adding a Subject to break the signature")
+	}
+}
+
+func TestVerifyDKIM_Errors(t *testing.T) {
+	email, resolver := signedEd25519Email(t)
+
+	signatures, _ := VerifyDKIM(context.Background(), stubResolver{}, []byte(email))
+	if len(signatures) != 1 || signatures[0].Valid() || !strings.Contains(signatures[0].Err.Error(), "brisbane._domainkey.example.com") {
+		t.Errorf("expected the key not to be found, got %+v", signatures)
+	}
+
+	revoked := stubResolver{"brisbane._domainkey.example.com": "v=DKIM1; k=ed25519; p="}
+	if signatures, _ := VerifyDKIM(context.Background(), revoked, []byte(email)); signatures[0].Valid() {
+		t.Error("expected a revoked key not to verify")
+	}
+
+	sha1 := strings.Replace(email, "a=ed25519-sha256", "a=rsa-sha1", 1)
+	if signatures, _ := VerifyDKIM(context.Background(), resolver, []byte(sha1)); signatures[0].Valid() {
+		t.Error("expected rsa-sha1 to be refused")
+	}
+
+	unsigned := "From: alice@example.com\r\n\r\nHello\r\n"
+	if signatures, err := VerifyDKIM(context.Background(), resolver, []byte(unsigned)); err != nil || len(signatures) != 0 {
+		t.Errorf("expected no signatures, got %+v and %v", signatures, err)
+	}
+}
+
+func TestVerifyDKIM_BodyLength(t *testing.T) {
+	// the signature only covers the first 12 bytes of the body, "Hello Bob,\r\n", so more could be added after it
+	email, resolver := signedEd25519EmailWithTags(t, "l=12; ")
+	email += "Please pay the invoice at https://attacker.example/\r\n"
+
+	signatures, err := VerifyDKIM(context.Background(), resolver, []byte(email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signatures) != 1 || signatures[0].Valid() {
+		t.Errorf("expected a signature with a body length to be refused, got %+v", signatures)
+	}
+}
@@ -0,0 +1,191 @@
+// Package senderauth checks whether an email really comes from who it says it does, from the results of the receiving
+// server's SPF, DKIM and DMARC checks in its Authentication-Results header (RFC 8601), DKIM signatures verified
+// locally (RFC 6376), and the tricks phishing emails use to look like they come from someone else.
+package senderauth
+
+import (
+	"errors"
+	"strings"
+)
+
+// Results are what a server found when it checked an email, from one Authentication-Results header field.
+type Results struct {
+	// Server is the authserv-id of the server that did the checks, usually its hostname.
+	Server  string
+	Methods []Result
+}
+
+// Result is the outcome of one check, like "dkim=pass header.d=example.com".
+type Result struct {
+	// Method is the check that was done, like "spf", "dkim" or "dmarc".
+	Method string
+	// Value is its outcome, like "pass", "fail", "softfail" or "none".
+	Value  string
+	Reason string
+	// Properties say what was checked, keyed by their type and name, like "header.d" or "smtp.mailfrom".
+	Properties map[string]string
+}
+
+// Passed reports whether the check passed.
+func (r Result) Passed() bool {
+	return r.Value == "pass"
+}
+
+// ParseResults reads an Authentication-Results header field. Comments are dropped, and method and result names are
+// lowercased since they aren't case-sensitive.
+func ParseResults(value string) (Results, error) {
+	statements := splitUnquoted(stripComments(value), ';')
+	server := strings.Fields(statements[0])
+	if len(server) == 0 {
+		return Results{}, errors.New("missing the id of the server that did the checks")
+	}
+	results := Results{Server: unquote(server[0])}
+
+	for _, statement := range statements[1:] {
+		tokens := splitWords(statement)
+		if len(tokens) == 0 {
+			continue
+		}
+		if len(tokens) == 1 && strings.EqualFold(tokens[0], "none") {
+			// no checks were done
+			continue
+		}
+		method, value, ok := strings.Cut(tokens[0], "=")
+		if !ok {
+			return Results{}, errors.New("malformed result: " + strings.TrimSpace(statement))
+		}
+		// methods can have a version, like "dkim/1"
+		method, _, _ = strings.Cut(method, "/")
+		result := Result{
+			Method:     strings.ToLower(strings.TrimSpace(method)),
+			Value:      strings.ToLower(unquote(value)),
+			Properties: map[string]string{},
+		}
+		for _, token := range tokens[1:] {
+			name, value, ok := strings.Cut(token, "=")
+			if !ok {
+				continue
+			}
+			name = strings.ToLower(name)
+			if name == "reason" {
+				result.Reason = unquote(value)
+			} else {
+				result.Properties[name] = unquote(value)
+			}
+		}
+		results.Methods = append(results.Methods, result)
+	}
+	return results, nil
+}
+
+// stripComments removes the parenthesised comments servers add to explain their results, which can be nested.
+func stripComments(value string) string {
+	var b strings.Builder
+	depth := 0
+	quoted := false
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && i+1 < len(value) && (quoted || depth > 0):
+			if depth == 0 {
+				b.WriteByte(c)
+				b.WriteByte(value[i+1])
+			}
+			i++
+			continue
+		case c == '"' && depth == 0:
+			quoted = !quoted
+		case c == '(' && !quoted:
+			depth++
+			continue
+		case c == ')' && !quoted && depth > 0:
+			depth--
+			b.WriteByte(' ')
+			continue
+		}
+		if depth == 0 {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// splitUnquoted splits a value on a separator that isn't in a quoted string.
+func splitUnquoted(value string, separator byte) []string {
+	var parts []string
+	start := 0
+	quoted := false
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case separator:
+			if !quoted {
+				parts = append(parts, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// splitWords splits a statement into its words, keeping "name = value" together even with spaces around the equals
+// sign, which servers sometimes add.
+func splitWords(statement string) []string {
+	var words []string
+	for _, word := range splitQuotedFields(statement) {
+		// a value can end in "=" itself, like base64 in header.b, so only a bare "name=" is joined to the next word
+		if n := len(words); n > 0 && (strings.HasPrefix(word, "=") || strings.Index(words[n-1], "=") == len(words[n-1])-1) {
+			words[n-1] += word
+			continue
+		}
+		words = append(words, word)
+	}
+	return words
+}
+
+func splitQuotedFields(value string) []string {
+	var fields []string
+	var current strings.Builder
+	quoted := false
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && quoted && i+1 < len(value):
+			current.WriteByte(c)
+			current.WriteByte(value[i+1])
+			i++
+		case c == '"':
+			quoted = !quoted
+			current.WriteByte(c)
+		case !quoted && (c == ' ' || c == '\t' || c == '\r' || c == '\n'):
+			if current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteByte(c)
+		}
+	}
+	if current.Len() > 0 {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+	var b strings.Builder
+	for i := 1; i < len(value)-1; i++ {
+		if value[i] == '\\' && i+1 < len(value)-1 {
+			i++
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
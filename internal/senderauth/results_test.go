@@ -0,0 +1,51 @@
+package senderauth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseResults(t *testing.T) {
+	results, err := ParseResults("mx.google.com;\r\n" +
+		" dkim=pass header.i=@example.com header.s=sel1 header.b=Ab3d+xY=;\r\n" +
+		" spf=pass (google.com: domain of alice@example.com designates 192.0.2.1 as permitted sender) smtp.mailfrom=alice@example.com;\r\n" +
+		" dmarc=FAIL reason=\"policy (quarantine)\" (p=QUARANTINE sp=NONE dis=NONE) header.from=example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Results{
+		Server: "mx.google.com",
+		Methods: []Result{
+			{Method: "dkim", Value: "pass", Properties: map[string]string{"header.i": "@example.com", "header.s": "sel1", "header.b": "Ab3d+xY="}},
+			{Method: "spf", Value: "pass", Properties: map[string]string{"smtp.mailfrom": "alice@example.com"}},
+			{Method: "dmarc", Value: "fail", Reason: "policy (quarantine)", Properties: map[string]string{"header.from": "example.com"}},
+		},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("expected %+v, got %+v", want, results)
+	}
+}
+
+func TestParseResults_VersionsAndNone(t *testing.T) {
+	results, err := ParseResults(`"mx.example.com" 1; none`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.Server != "mx.example.com" || len(results.Methods) != 0 {
+		t.Errorf("expected no results from mx.example.com, got %+v", results)
+	}
+
+	results, err = ParseResults("mx.example.com; dkim/1 = neutral header.d = example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Methods) != 1 || results.Methods[0].Method != "dkim" || results.Methods[0].Value != "neutral" ||
+		results.Methods[0].Properties["header.d"] != "example.org" {
+		t.Errorf("unexpected results %+v", results.Methods)
+	}
+
+	if _, err := ParseResults(" ; spf=pass"); err == nil {
+		t.Error("expected an error without a server")
+	}
+}
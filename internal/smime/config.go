@@ -0,0 +1,164 @@
+// Package smime protects emails with S/MIME (RFC 8551): it verifies the signatures on emails that are received against
+// the trusted certificate authorities and decrypts the ones encrypted to the user's certificate, and signs the ones
+// that are sent.
+package smime
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+var errNoCertificate = errors.New("no S/MIME certificate and key are configured")
+
+// Config holds the certificates that S/MIME signatures are trusted against, and the user's own certificate and key.
+type Config struct {
+	// Trusted are the certificate authorities that signers' certificates must be issued by, or the system's if it's
+	// nil.
+	Trusted *x509.CertPool
+	// Certificate and Key are the user's own, to decrypt emails encrypted to them and sign the ones they send, which
+	// can't be done if they're nil. Chain has the certificates that issued it, which are sent along with signatures.
+	Certificate *x509.Certificate
+	Chain       []*x509.Certificate
+	Key         crypto.PrivateKey
+}
+
+// Load reads the PEM files with the trusted certificate authorities, the user's certificate followed by the ones that
+// issued it, and the user's unencrypted private key. Each path can be empty: the system's certificate authorities are
+// trusted without the first, and emails can only be verified without the others.
+func Load(trustedPath, certificatePath, keyPath string) (*Config, error) {
+	config := &Config{}
+	if trustedPath != "" {
+		certificates, err := readCertificates(trustedPath)
+		if err != nil {
+			return nil, err
+		}
+		config.Trusted = x509.NewCertPool()
+		for _, certificate := range certificates {
+			config.Trusted.AddCert(certificate)
+		}
+	}
+
+	if (certificatePath == "") != (keyPath == "") {
+		return nil, errors.New("an S/MIME certificate needs its private key, and the other way around")
+	}
+	if certificatePath == "" {
+		return config, nil
+	}
+	certificates, err := readCertificates(certificatePath)
+	if err != nil {
+		return nil, err
+	}
+	config.Certificate, config.Chain = certificates[0], certificates[1:]
+	config.Key, err = readKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certificates []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("no certificates in %s", path)
+	}
+	return certificates, nil
+}
+
+// readKey reads a private key in any of the usual PEM forms: PKCS #8, or PKCS #1 or SEC 1 for RSA and EC keys.
+func readKey(path string) (crypto.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no private key in %s", path)
+		}
+		switch block.Type {
+		case "PRIVATE KEY":
+			return x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			return x509.ParseECPrivateKey(block.Bytes)
+		case "ENCRYPTED PRIVATE KEY":
+			return nil, fmt.Errorf("the private key in %s is encrypted, which isn't supported", path)
+		}
+	}
+}
+
+// signer returns the user's certificate for signing an email from the address, which has to be one the certificate
+// is for.
+func (c *Config) signer(address string) (*x509.Certificate, error) {
+	if !c.HasKey() {
+		return nil, errNoCertificate
+	}
+	if !hasAddress(c.Certificate, address) {
+		return nil, fmt.Errorf("the S/MIME certificate isn't for %s", address)
+	}
+	return c.Certificate, nil
+}
+
+// HasKey reports whether there's a certificate and key to decrypt and sign emails with. It's safe to call on a nil
+// config.
+func (c *Config) HasKey() bool {
+	return c != nil && c.Certificate != nil && c.Key != nil
+}
+
+// Check reports why an email from the address can't be signed, so it can be fixed before the email is sent. It's
+// safe to call on a nil config.
+func (c *Config) Check(from string) error {
+	_, err := c.signer(from)
+	return err
+}
+
+// hasAddress reports whether the certificate is for the email address, either as a subject alternative name or, in
+// older certificates, in the subject itself.
+func hasAddress(certificate *x509.Certificate, address string) bool {
+	for _, candidate := range certificateAddresses(certificate) {
+		if strings.EqualFold(candidate, address) {
+			return true
+		}
+	}
+	return false
+}
+
+func certificateAddresses(certificate *x509.Certificate) []string {
+	// cloned so the addresses in the subject aren't appended to the certificate's own slice
+	addresses := slices.Clone(certificate.EmailAddresses)
+	for _, name := range certificate.Subject.Names {
+		if name.Type.Equal(oidEmailAddress) {
+			if address, ok := name.Value.(string); ok {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	return addresses
+}
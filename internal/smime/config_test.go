@@ -0,0 +1,98 @@
+package smime
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePEM(t *testing.T, name string, blocks ...*pem.Block) string {
+	t.Helper()
+	var data []byte
+	for _, block := range blocks {
+		data = append(data, pem.EncodeToMemory(block)...)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	ca := newAuthority(t, "Test CA")
+	alice := ca.issue(t, "Alice", "alice@example.com")
+	key, err := x509.MarshalPKCS8PrivateKey(alice.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustedPath := writePEM(t, "trusted.pem", &pem.Block{Type: "CERTIFICATE", Bytes: ca.certificate.Raw})
+	certificatePath := writePEM(t, "alice.pem",
+		&pem.Block{Type: "CERTIFICATE", Bytes: alice.Certificate.Raw},
+		&pem.Block{Type: "CERTIFICATE", Bytes: ca.certificate.Raw})
+	keyPath := writePEM(t, "alice.key", &pem.Block{Type: "PRIVATE KEY", Bytes: key})
+
+	config, err := Load(trustedPath, certificatePath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.Certificate.Equal(alice.Certificate) || len(config.Chain) != 1 || !config.HasKey() {
+		t.Errorf("expected Alice's certificate, its issuer and key, got %+v", config)
+	}
+	if err := config.Check("ALICE@example.com"); err != nil {
+		t.Errorf("expected to sign as Alice, got %v", err)
+	}
+	if _, err := alice.Certificate.Verify(x509.VerifyOptions{Roots: config.Trusted, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		t.Errorf("expected the test CA to be trusted, got %v", err)
+	}
+}
+
+func TestLoadRSAKeyWithoutTrusted(t *testing.T) {
+	alice := newAuthority(t, "Test CA").issue(t, "Alice", "alice@example.com")
+	certificatePath := writePEM(t, "alice.pem", &pem.Block{Type: "CERTIFICATE", Bytes: alice.Certificate.Raw})
+	keyPath := writePEM(t, "alice.key", &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(alice.Key.(*rsa.PrivateKey))})
+
+	config, err := Load("", certificatePath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Trusted != nil || len(config.Chain) != 0 || !config.HasKey() {
+		t.Errorf("expected the system's authorities to be trusted and Alice's key, got %+v", config)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	certificatePath := writePEM(t, "alice.pem")
+	if _, err := Load("", certificatePath, ""); err == nil {
+		t.Error("expected a certificate without its key to be refused")
+	}
+	keyPath := writePEM(t, "alice.key", &pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: []byte("secret")})
+	if _, err := Load(certificatePath, "", ""); err == nil {
+		t.Error("expected a file without certificates to be refused")
+	}
+	alice := newAuthority(t, "Test CA").issue(t, "Alice", "alice@example.com")
+	certificatePath = writePEM(t, "alice.pem", &pem.Block{Type: "CERTIFICATE", Bytes: alice.Certificate.Raw})
+	if _, err := Load("", certificatePath, keyPath); err == nil {
+		t.Error("expected an encrypted key to be refused")
+	}
+}
+
+func TestCertificateAddressesLeavesCertificateAlone(t *testing.T) {
+	emailAddresses := make([]string, 1, 2)
+	emailAddresses[0] = "alice@example.com"
+	certificate := &x509.Certificate{EmailAddresses: emailAddresses}
+	certificate.Subject.Names = []pkix.AttributeTypeAndValue{{Type: oidEmailAddress, Value: "alice@example.org"}}
+
+	addresses := certificateAddresses(certificate)
+
+	if len(addresses) != 2 {
+		t.Errorf("expected the address in the subject too, got %v", addresses)
+	}
+	if spare := emailAddresses[:2][1]; spare != "" {
+		t.Errorf("expected the certificate's addresses not to be written to, got %q", spare)
+	}
+}
@@ -0,0 +1,302 @@
+package smime
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message/textproto"
+	"github.com/smallstep/pkcs7"
+
+	"github.com/bengesoff/mail-tui/internal/message"
+)
+
+// maxNesting is how many layers of signing and encryption are taken off, like a signed email that was then encrypted.
+const maxNesting = 3
+
+// oidEmailAddress is the emailAddress attribute that older certificates put in their subject (RFC 2985).
+var oidEmailAddress = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+
+// Result is what was found by taking the protection off an email.
+type Result struct {
+	// Encrypted is set when the email was encrypted, and so has been decrypted.
+	Encrypted bool
+	// Signature is the email's signature, or nil if it isn't signed.
+	Signature *Signature
+	// Raw is the email as if it had never been protected: its own header with the content that was signed or
+	// encrypted.
+	Raw []byte
+	// Err is why the email couldn't be decrypted, or its protection taken off at all, in which case Raw is nil.
+	Err error
+}
+
+// Signature is an S/MIME signature on an email, and who its certificate says made it.
+type Signature struct {
+	// Signer names the certificate that made the signature, like "Alice <alice@example.com>", and Issuer the
+	// authority that issued it. Both are empty if the signature didn't come with its certificate.
+	Signer string
+	Issuer string
+	// NotBefore and NotAfter are when the signer's certificate is valid.
+	NotBefore, NotAfter time.Time
+	// Err is why the signature couldn't be verified, or nil if it's valid.
+	Err error
+}
+
+// Valid reports whether the signature was verified, by a certificate for the sender that's issued by a trusted
+// authority.
+func (s Signature) Valid() bool {
+	return s.Err == nil
+}
+
+// Unwrap verifies a signed S/MIME email, or decrypts an encrypted one, returning nil if the email isn't protected with
+// S/MIME. A signature that doesn't verify, or an email that can't be decrypted, is described in the result.
+func Unwrap(raw []byte, config *Config) *Result {
+	header, content, err := message.SplitContent(raw)
+	if err != nil {
+		// it isn't a readable email, let alone a protected one
+		return nil
+	}
+	result := &Result{}
+	content, err = result.unwrap(content, config, senderAddress(header))
+	if !result.Encrypted && result.Signature == nil && err == nil {
+		return nil
+	}
+	if err == nil {
+		result.Raw, err = message.JoinContent(header, content)
+	}
+	result.Err = err
+	return result
+}
+
+// unwrap takes each layer of protection off the content in turn, returning what's inside.
+func (r *Result) unwrap(content []byte, config *Config, sender string) ([]byte, error) {
+	for range maxNesting {
+		header, body, err := message.SplitEntity(content)
+		if err != nil {
+			return nil, err
+		}
+		mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+		if err != nil {
+			return content, nil
+		}
+
+		switch {
+		case mediaType == "multipart/signed" && isSignatureType(params["protocol"]):
+			parts, err := message.SplitMultipart(body, params["boundary"])
+			if err != nil {
+				return nil, err
+			}
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("signed email has %d parts rather than 2", len(parts))
+			}
+			signatureHeader, signature, err := message.SplitEntity(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			r.Signature = verify(config, parts[0], decodeBody(signatureHeader, signature), sender)
+			content = parts[0]
+		case mediaType == "application/pkcs7-mime" || mediaType == "application/x-pkcs7-mime":
+			p7, err := pkcs7.Parse(decodeBody(header, body))
+			if err != nil {
+				return nil, fmt.Errorf("reading the S/MIME message: %w", err)
+			}
+			if strings.EqualFold(params["smime-type"], "signed-data") || len(p7.Signers) > 0 {
+				// the signed content is inside the signature, rather than next to it
+				r.Signature = check(config, p7, sender)
+				content = message.CanonicalLineEndings(p7.Content)
+				continue
+			}
+			r.Encrypted = true
+			if !config.HasKey() {
+				return nil, errNoCertificate
+			}
+			plaintext, err := p7.Decrypt(config.Certificate, config.Key)
+			if err != nil {
+				return nil, fmt.Errorf("decrypting: %w", err)
+			}
+			content = message.CanonicalLineEndings(plaintext)
+		default:
+			return content, nil
+		}
+	}
+	return content, nil
+}
+
+// isSignatureType reports whether the protocol of a signed email is S/MIME, including the name older clients use.
+func isSignatureType(protocol string) bool {
+	return strings.EqualFold(protocol, "application/pkcs7-signature") ||
+		strings.EqualFold(protocol, "application/x-pkcs7-signature")
+}
+
+// decodeBody undoes the transfer encoding of a part holding a signature or encrypted message, which is almost always
+// base64.
+func decodeBody(header textproto.Header, body []byte) []byte {
+	if !strings.EqualFold(strings.TrimSpace(header.Get("Content-Transfer-Encoding")), "base64") {
+		return body
+	}
+	encoded := strings.Join(strings.Fields(string(body)), "")
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		// it's left for the PKCS #7 parser to say it can't be read
+		return body
+	}
+	return decoded
+}
+
+// verify checks a detached signature over the signed part.
+func verify(config *Config, signed, signature []byte, sender string) *Signature {
+	p7, err := pkcs7.Parse(signature)
+	if err != nil {
+		return &Signature{Err: fmt.Errorf("reading the signature: %w", err)}
+	}
+	p7.Content = signed
+	return check(config, p7, sender)
+}
+
+// check verifies a signature over its content, then that it was made by a certificate for the sender that a trusted
+// authority issued.
+func check(config *Config, p7 *pkcs7.PKCS7, sender string) *Signature {
+	certificate := p7.GetOnlySigner()
+	if certificate == nil {
+		return &Signature{Err: errors.New("the signature doesn't have exactly one signer with a certificate")}
+	}
+	signature := &Signature{
+		Signer:    describeCertificate(certificate),
+		Issuer:    describeName(certificate.Issuer.CommonName, certificate.Issuer.String()),
+		NotBefore: certificate.NotBefore,
+		NotAfter:  certificate.NotAfter,
+	}
+	if err := p7.Verify(); err != nil {
+		signature.Err = describeSignatureError(err)
+		return signature
+	}
+
+	// the certificate has to have been valid when the email was signed, rather than now, so old emails still verify
+	signedAt := time.Now()
+	var signingTime time.Time
+	if err := p7.UnmarshalSignedAttribute(pkcs7.OIDAttributeSigningTime, &signingTime); err == nil {
+		signedAt = signingTime
+	}
+	intermediates := x509.NewCertPool()
+	for _, other := range p7.Certificates {
+		intermediates.AddCert(other)
+	}
+	var roots *x509.CertPool
+	if config != nil {
+		roots = config.Trusted
+	}
+	_, err := certificate.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   signedAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	})
+	if err != nil {
+		signature.Err = describeCertificateError(err)
+		return signature
+	}
+	if !hasAddress(certificate, sender) {
+		signature.Err = fmt.Errorf("the certificate isn't for the sender, %s", sender)
+	}
+	return signature
+}
+
+func describeSignatureError(err error) error {
+	var mismatch *pkcs7.MessageDigestMismatchError
+	if errors.As(err, &mismatch) {
+		return errors.New("the email was changed after it was signed")
+	}
+	return err
+}
+
+func describeCertificateError(err error) error {
+	var unknownAuthority x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthority) {
+		return errors.New("the certificate isn't issued by a trusted authority")
+	}
+	var invalid x509.CertificateInvalidError
+	if errors.As(err, &invalid) && invalid.Reason == x509.Expired {
+		return errors.New("the certificate wasn't valid when the email was signed")
+	}
+	return err
+}
+
+// describeCertificate names who a certificate is for, like "Alice <alice@example.com>".
+func describeCertificate(certificate *x509.Certificate) string {
+	addresses := certificateAddresses(certificate)
+	name := certificate.Subject.CommonName
+	switch {
+	case len(addresses) == 0:
+		return describeName(name, certificate.Subject.String())
+	case name == "" || strings.EqualFold(name, addresses[0]):
+		return addresses[0]
+	default:
+		return name + " <" + addresses[0] + ">"
+	}
+}
+
+// describeName is a certificate's common name, or its whole distinguished name if it doesn't have one.
+func describeName(commonName, distinguishedName string) string {
+	if commonName != "" {
+		return commonName
+	}
+	return distinguishedName
+}
+
+func senderAddress(header textproto.Header) string {
+	address, err := mail.ParseAddress(header.Get("From"))
+	if err != nil {
+		return ""
+	}
+	return address.Address
+}
+
+// Sign signs a built email with S/MIME, as a multipart/signed email with a detached signature so it can still be read
+// without S/MIME. The user's certificate, and the ones that issued it, are sent with the signature so it can be
+// verified.
+func Sign(raw []byte, config *Config, from string) ([]byte, error) {
+	certificate, err := config.signer(from)
+	if err != nil {
+		return nil, err
+	}
+	header, content, err := message.SplitContent(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := pkcs7.NewSignedData(content)
+	if err != nil {
+		return nil, err
+	}
+	signed.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err := signed.AddSignerChain(certificate, config.Key, config.Chain, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, fmt.Errorf("signing: %w", err)
+	}
+	signed.Detach()
+	signature, err := signed.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("signing: %w", err)
+	}
+
+	var signaturePart bytes.Buffer
+	signaturePart.WriteString("Content-Type: application/pkcs7-signature; name=\"smime.p7s\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"Content-Description: S/MIME cryptographic signature\r\n" +
+		"Content-Disposition: attachment; filename=\"smime.p7s\"\r\n" +
+		"\r\n")
+	encoded := base64.StdEncoding.EncodeToString(signature)
+	for len(encoded) > 76 {
+		signaturePart.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	signaturePart.WriteString(encoded + "\r\n")
+	params := map[string]string{"protocol": "application/pkcs7-signature", "micalg": "sha-256"}
+	return message.BuildMultipart(header, "multipart/signed", params, content, signaturePart.Bytes())
+}
@@ -0,0 +1,316 @@
+package smime
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smallstep/pkcs7"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/message"
+)
+
+// authority is a throwaway certificate authority that issues certificates for the tests.
+type authority struct {
+	certificate *x509.Certificate
+	key         *rsa.PrivateKey
+}
+
+func newAuthority(t *testing.T, name string) authority {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authority{certificate: certificate, key: key}
+}
+
+// issue makes a config for someone with a certificate for their address, which trusts the authority.
+func (a authority) issue(t *testing.T, name, address string) *Config {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:   serial,
+		Subject:        pkix.Name{CommonName: name},
+		EmailAddresses: []string{address},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(12 * time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.certificate, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted := x509.NewCertPool()
+	trusted.AddCert(a.certificate)
+	return &Config{Trusted: trusted, Certificate: certificate, Key: key}
+}
+
+func buildEmail(t *testing.T) []byte {
+	t.Helper()
+	raw, err := message.Build(core.OutgoingEmail{
+		From:      "Alice <alice@example.com>",
+		To:        "bob@example.com",
+		Subject:   "Launch codes",
+		Body:      "The code is 1234.\nDon't tell anyone.",
+		MessageId: "launch@example.com",
+	}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// withContent replaces an email's content with an application/pkcs7-mime part, as encrypted emails and ones signed
+// with the content inside the signature are sent.
+func withContent(t *testing.T, raw []byte, smimeType string, der []byte) []byte {
+	t.Helper()
+	header, _, err := message.SplitContent(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "Content-Type: application/pkcs7-mime; smime-type=" + smimeType + "; name=\"smime.p7m\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" + base64.StdEncoding.EncodeToString(der) + "\r\n"
+	joined, err := message.JoinContent(header, []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return joined
+}
+
+func parseBody(t *testing.T, raw []byte) string {
+	t.Helper()
+	body, _, err := message.ParseBody(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestSigned(t *testing.T) {
+	ca := newAuthority(t, "Test CA")
+	alice, bob := ca.issue(t, "Alice", "alice@example.com"), ca.issue(t, "Bob", "bob@example.com")
+	signed, err := Sign(buildEmail(t), alice, "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(signed, []byte("application/pkcs7-signature")) || !bytes.Contains(signed, []byte("The code is 1234.")) {
+		t.Fatalf("expected a signed email that can still be read, got %s", signed)
+	}
+
+	result := Unwrap(signed, bob)
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	signature := result.Signature
+	if result.Encrypted || signature == nil || !signature.Valid() {
+		t.Fatalf("expected a valid signature, got %+v", signature)
+	}
+	if signature.Signer != "Alice <alice@example.com>" || signature.Issuer != "Test CA" {
+		t.Errorf("expected Alice's certificate from the test CA, got %+v", signature)
+	}
+	if !signature.NotAfter.Equal(alice.Certificate.NotAfter) {
+		t.Errorf("expected the certificate's validity, got %v", signature.NotAfter)
+	}
+	if body := parseBody(t, result.Raw); body != "The code is 1234.\nDon't tell anyone." {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestSignedWithChain(t *testing.T) {
+	root, intermediate := newAuthority(t, "Root CA"), newAuthority(t, "Intermediate CA")
+	// the intermediate is re-issued by the root, so only the root has to be trusted
+	template := *intermediate.certificate
+	der, err := x509.CreateCertificate(rand.Reader, &template, root.certificate, &intermediate.key.PublicKey, root.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate.certificate, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := intermediate.issue(t, "Alice", "alice@example.com")
+	alice.Chain = []*x509.Certificate{intermediate.certificate}
+
+	signed, err := Sign(buildEmail(t), alice, "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := Unwrap(signed, root.issue(t, "Bob", "bob@example.com"))
+	if result.Signature == nil || !result.Signature.Valid() || result.Signature.Issuer != "Intermediate CA" {
+		t.Fatalf("expected the chain to be verified up to the root, got %+v", result.Signature)
+	}
+}
+
+func TestSignedChanged(t *testing.T) {
+	ca := newAuthority(t, "Test CA")
+	signed, err := Sign(buildEmail(t), ca.issue(t, "Alice", "alice@example.com"), "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := bytes.Replace(signed, []byte("1234"), []byte("4321"), 1)
+
+	result := Unwrap(tampered, ca.issue(t, "Bob", "bob@example.com"))
+	if result.Signature == nil || result.Signature.Valid() || result.Signature.Signer != "Alice <alice@example.com>" {
+		t.Fatalf("expected a bad signature from Alice, got %+v", result.Signature)
+	}
+	if result.Signature.Err.Error() != "the email was changed after it was signed" {
+		t.Errorf("unexpected error %v", result.Signature.Err)
+	}
+}
+
+func TestSignedByUntrustedAuthority(t *testing.T) {
+	signed, err := Sign(buildEmail(t), newAuthority(t, "Other CA").issue(t, "Alice", "alice@example.com"), "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := Unwrap(signed, newAuthority(t, "Test CA").issue(t, "Bob", "bob@example.com"))
+	if result.Signature == nil || result.Signature.Valid() {
+		t.Fatalf("expected the signature not to be trusted, got %+v", result.Signature)
+	}
+	if result.Signature.Err.Error() != "the certificate isn't issued by a trusted authority" {
+		t.Errorf("unexpected error %v", result.Signature.Err)
+	}
+}
+
+func TestSignedByOtherAddress(t *testing.T) {
+	ca := newAuthority(t, "Test CA")
+	mallory := ca.issue(t, "Mallory", "mallory@example.com")
+	raw := bytes.Replace(buildEmail(t), []byte("Alice <alice@example.com>"), []byte("mallory@example.com"), 1)
+	signed, err := Sign(raw, mallory, "mallory@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the From field isn't part of what's signed, so it can be changed without breaking the signature
+	spoofed := bytes.Replace(signed, []byte("From: mallory@example.com"), []byte("From: alice@example.com"), 1)
+
+	result := Unwrap(spoofed, ca.issue(t, "Bob", "bob@example.com"))
+	if result.Signature == nil || result.Signature.Valid() {
+		t.Fatalf("expected Mallory's signature not to count for Alice, got %+v", result.Signature)
+	}
+	if !strings.Contains(result.Signature.Err.Error(), "isn't for the sender, alice@example.com") {
+		t.Errorf("unexpected error %v", result.Signature.Err)
+	}
+}
+
+func TestSignedOpaquely(t *testing.T) {
+	ca := newAuthority(t, "Test CA")
+	alice := ca.issue(t, "Alice", "alice@example.com")
+	raw := buildEmail(t)
+	_, content, err := message.SplitContent(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedData, err := pkcs7.NewSignedData(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signedData.AddSigner(alice.Certificate, alice.Key, pkcs7.SignerInfoConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	der, err := signedData.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := Unwrap(withContent(t, raw, "signed-data", der), ca.issue(t, "Bob", "bob@example.com"))
+	if result.Err != nil || result.Signature == nil || !result.Signature.Valid() {
+		t.Fatalf("expected a valid signature, got %+v", result)
+	}
+	if body := parseBody(t, result.Raw); body != "The code is 1234.\nDon't tell anyone." {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	ca := newAuthority(t, "Test CA")
+	bob := ca.issue(t, "Bob", "bob@example.com")
+	raw := buildEmail(t)
+	_, content, err := message.SplitContent(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := pkcs7.Encrypt(content, []*x509.Certificate{bob.Certificate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := withContent(t, raw, "enveloped-data", der)
+	if bytes.Contains(encrypted, []byte("The code is 1234.")) {
+		t.Fatal("expected the body to be encrypted")
+	}
+
+	result := Unwrap(encrypted, bob)
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if !result.Encrypted || result.Signature != nil {
+		t.Errorf("expected an encrypted email without a signature, got %+v", result)
+	}
+	if body := parseBody(t, result.Raw); body != "The code is 1234.\nDon't tell anyone." {
+		t.Errorf("unexpected body %q", body)
+	}
+
+	result = Unwrap(encrypted, ca.issue(t, "Carol", "carol@example.com"))
+	if !result.Encrypted || result.Err == nil || result.Raw != nil {
+		t.Errorf("expected someone else not to be able to decrypt it, got %+v", result)
+	}
+	result = Unwrap(encrypted, &Config{})
+	if result.Err != errNoCertificate {
+		t.Errorf("expected to be told there's no certificate to decrypt with, got %v", result.Err)
+	}
+}
+
+func TestUnprotected(t *testing.T) {
+	if result := Unwrap(buildEmail(t), nil); result != nil {
+		t.Errorf("expected nothing for an email that isn't protected, got %+v", result)
+	}
+}
+
+func TestSignNeedsCertificateForSender(t *testing.T) {
+	alice := newAuthority(t, "Test CA").issue(t, "Alice", "alice@example.com")
+	if _, err := Sign(buildEmail(t), alice, "bob@example.com"); err == nil || err.Error() != "the S/MIME certificate isn't for bob@example.com" {
+		t.Errorf("expected not to sign as someone else, got %v", err)
+	}
+	if _, err := Sign(buildEmail(t), nil, "alice@example.com"); err != errNoCertificate {
+		t.Errorf("expected to be told there's no certificate, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Package smtp submits messages to an SMTP server.
+package smtp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/emersion/go-sasl"
+	"github.com/emersion/go-smtp"
+)
+
+// Security is how the connection to the server is encrypted.
+type Security int
+
+const (
+	// SecurityAuto uses implicit TLS on port 465, the submissions port (RFC 8314), and STARTTLS on any other port.
+	SecurityAuto Security = iota
+	// SecurityTLS encrypts the connection from the start.
+	SecurityTLS
+	// SecurityStartTLS connects in plain text and then upgrades the connection with STARTTLS (RFC 3207), which
+	// fails if the server doesn't offer it.
+	SecurityStartTLS
+	// SecurityNone doesn't encrypt the connection, which is only for servers that don't need a password, or test
+	// servers on the same machine.
+	SecurityNone
+)
+
+// ParseSecurity reads a security setting: "auto" or empty, "tls", "starttls" or "none".
+func ParseSecurity(value string) (Security, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "auto":
+		return SecurityAuto, nil
+	case "tls":
+		return SecurityTLS, nil
+	case "starttls":
+		return SecurityStartTLS, nil
+	case "none":
+		return SecurityNone, nil
+	}
+	return SecurityAuto, fmt.Errorf("unknown SMTP security %q, expected auto, tls, starttls or none", value)
+}
+
+// Sender submits messages to a server.
+type Sender struct {
+	// Address is the server's hostname and port
+	Address string
+	// Username and Password are used to log in, unless the username is empty
+	Username string
+	Password string
+	// Security is how the connection is encrypted. The password is never sent over a connection that isn't, except
+	// to a server on the same machine.
+	Security Security
+
+	// tlsConfig is used in tests to trust their own server's certificate
+	tlsConfig *tls.Config
+}
+
+// Send submits a message, which is sent exactly as it is, to the recipients given in the envelope rather than the
+// message's headers.
+func (s *Sender) Send(from string, recipients []string, raw []byte) error {
+	client, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	if s.Username != "" {
+		if _, encrypted := client.TLSConnectionState(); !encrypted && !isLoopback(s.Address) {
+			return errors.New("refusing to send the SMTP password over an unencrypted connection")
+		}
+		if err := client.Auth(sasl.NewPlainClient("", s.Username, s.Password)); err != nil {
+			return err
+		}
+	}
+	if err := client.SendMail(from, recipients, bytes.NewReader(raw)); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (s *Sender) dial() (*smtp.Client, error) {
+	security := s.Security
+	if security == SecurityAuto {
+		security = SecurityStartTLS
+		if _, port, err := net.SplitHostPort(s.Address); err == nil && port == "465" {
+			security = SecurityTLS
+		}
+	}
+	switch security {
+	case SecurityTLS:
+		return smtp.DialTLS(s.Address, s.tlsConfig)
+	case SecurityNone:
+		return smtp.Dial(s.Address)
+	default:
+		return smtp.DialStartTLS(s.Address, s.tlsConfig)
+	}
+}
+
+// isLoopback reports whether the server is on the same machine, where nothing can listen in on the connection.
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,200 @@
+package smtp
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-sasl"
+	"github.com/emersion/go-smtp"
+)
+
+// session records the message it receives.
+type session struct {
+	received *received
+}
+
+type received struct {
+	username   string
+	password   string
+	from       string
+	recipients []string
+	data       []byte
+}
+
+func (s *session) Reset() {}
+
+func (s *session) Logout() error {
+	return nil
+}
+
+func (s *session) AuthMechanisms() []string {
+	return []string{sasl.Plain}
+}
+
+func (s *session) Auth(mech string) (sasl.Server, error) {
+	return sasl.NewPlainServer(func(identity, username, password string) error {
+		if password != "secret" {
+			return errors.New("wrong password")
+		}
+		s.received.username, s.received.password = username, password
+		return nil
+	}), nil
+}
+
+func (s *session) Mail(from string, opts *smtp.MailOptions) error {
+	s.received.from = from
+	return nil
+}
+
+func (s *session) Rcpt(to string, opts *smtp.RcptOptions) error {
+	s.received.recipients = append(s.received.recipients, to)
+	return nil
+}
+
+func (s *session) Data(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	s.received.data = data
+	return err
+}
+
+// startServer starts a server for the tests to send to, which encrypts connections with STARTTLS or from the start
+// if it's given a certificate. The client config trusts it.
+func startServer(t *testing.T, security Security) (string, *received, *tls.Config) {
+	t.Helper()
+	var (
+		serverConfig, clientConfig *tls.Config
+		listener                   net.Listener
+		err                        error
+	)
+	if security != SecurityNone {
+		serverConfig, clientConfig = newTLSConfigs(t)
+	}
+	if security == SecurityTLS {
+		listener, err = tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	} else {
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	result := &received{}
+	server := smtp.NewServer(smtp.BackendFunc(func(c *smtp.Conn) (smtp.Session, error) {
+		return &session{received: result}, nil
+	}))
+	server.Domain = "localhost"
+	server.TLSConfig = serverConfig
+	server.AllowInsecureAuth = true
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	return listener.Addr().String(), result, clientConfig
+}
+
+// newTLSConfigs generates a throwaway certificate for 127.0.0.1, returning the server's config and a client config
+// that trusts it.
+func newTLSConfigs(t *testing.T) (*tls.Config, *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(certificate)
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	return server, &tls.Config{RootCAs: roots}
+}
+
+func TestSender_Send(t *testing.T) {
+	address, result, _ := startServer(t, SecurityNone)
+	raw := []byte("From: me@example.com\r\nTo: you@example.com\r\nSubject: Hi\r\n\r\nHello\r\n")
+
+	sender := &Sender{Address: address, Security: SecurityNone}
+	err := sender.Send("me@example.com", []string{"you@example.com", "them@example.com"}, raw)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.from != "me@example.com" {
+		t.Errorf("Expected the envelope sender to be me@example.com, got '%s'", result.from)
+	}
+	if !slices.Equal(result.recipients, []string{"you@example.com", "them@example.com"}) {
+		t.Errorf("Expected both recipients, got %v", result.recipients)
+	}
+	if !bytes.Equal(result.data, raw) {
+		t.Errorf("Expected the message to be sent unchanged, got:\n%s", result.data)
+	}
+}
+
+func TestSender_Send_Encrypted(t *testing.T) {
+	for _, security := range []Security{SecurityStartTLS, SecurityTLS} {
+		address, result, tlsConfig := startServer(t, security)
+
+		sender := &Sender{Address: address, Username: "me", Password: "secret", Security: security, tlsConfig: tlsConfig}
+		if err := sender.Send("me@example.com", []string{"you@example.com"}, []byte("Subject: Hi\r\n\r\nHello\r\n")); err != nil {
+			t.Fatalf("Unexpected error with security %d: %v", security, err)
+		}
+		if result.username != "me" || result.password != "secret" {
+			t.Errorf("Expected to log in with security %d, got %+v", security, result)
+		}
+	}
+}
+
+func TestSender_Send_NoDowngrade(t *testing.T) {
+	// the server doesn't offer STARTTLS, which isn't a reason to send the password in plain text
+	address, result, _ := startServer(t, SecurityNone)
+
+	sender := &Sender{Address: address, Username: "me", Password: "secret"}
+	err := sender.Send("me@example.com", []string{"you@example.com"}, []byte("Subject: Hi\r\n\r\nHello\r\n"))
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") || result.password != "" {
+		t.Errorf("Expected the connection to fail without STARTTLS, got %v", err)
+	}
+}
+
+func TestParseSecurity(t *testing.T) {
+	for value, want := range map[string]Security{"": SecurityAuto, "TLS": SecurityTLS, "starttls": SecurityStartTLS, "none": SecurityNone} {
+		if security, err := ParseSecurity(value); err != nil || security != want {
+			t.Errorf("Expected %q to be %d, got %d and %v", value, want, security, err)
+		}
+	}
+	if _, err := ParseSecurity("ssl"); err == nil {
+		t.Error("Expected an unknown security to be refused")
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	for address, want := range map[string]bool{"localhost:1025": true, "127.0.0.1:25": true, "[::1]:25": true, "smtp.example.com:587": false, "10.0.0.1:25": false} {
+		if isLoopback(address) != want {
+			t.Errorf("Expected isLoopback(%q) to be %v", address, want)
+		}
+	}
+}
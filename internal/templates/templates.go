@@ -0,0 +1,92 @@
+// Package templates loads the text/template files used to start writing emails that are sent often, like
+// acknowledging an incident.
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Extension is the file extension of templates in the templates directory.
+const Extension = ".tmpl"
+
+// Template is a named template, whose name is its filename without the extension.
+type Template struct {
+	Name     string
+	template *template.Template
+}
+
+// Data is what templates can refer to, like {{.RecipientName}} or {{.Date.Format "2 Jan 2006"}}.
+type Data struct {
+	// RecipientName is the name of the first recipient, or the first part of their address if it has no name
+	RecipientName    string
+	RecipientAddress string
+	// Subject is the subject of the email being replied to, or what's been typed so far for a new email
+	Subject string
+	// Date is when the template is used
+	Date time.Time
+	// SenderName is the name of the identity the email is from
+	SenderName string
+}
+
+// Load parses every template in a directory, which has none if it doesn't exist. They're read again each time, so
+// changes are picked up without restarting.
+// Templates that fail to parse are left out and reported in the error, alongside the ones that did parse.
+func Load(dir string) ([]Template, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		loaded []Template
+		errs   []error
+	)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != Extension {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), Extension)
+		parsed, err := template.New(name).Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		loaded = append(loaded, Template{Name: name, template: parsed})
+	}
+	slices.SortFunc(loaded, func(a, b Template) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return loaded, errors.Join(errs...)
+}
+
+// Execute fills in the template. If it starts with a "Subject:" line, that's returned as the subject, and the rest,
+// after an optional blank line, as the body.
+func (t Template) Execute(data Data) (subject, body string, err error) {
+	var b bytes.Buffer
+	if err := t.template.Execute(&b, data); err != nil {
+		return "", "", fmt.Errorf("template %q: %w", t.Name, err)
+	}
+
+	body = b.String()
+	if first, rest, ok := strings.Cut(body, "\n"); ok && strings.HasPrefix(first, "Subject:") {
+		subject = strings.TrimSpace(strings.TrimPrefix(first, "Subject:"))
+		body = strings.TrimPrefix(rest, "\n")
+	}
+	return subject, body, nil
+}
@@ -0,0 +1,79 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTemplate(t *testing.T, dir, filename, contents string) {
+	if err := os.WriteFile(filepath.Join(dir, filename), []byte(contents), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "thanks.tmpl", "Thanks {{.RecipientName}}!")
+	writeTemplate(t, dir, "incident.tmpl", "Subject: Re: {{.Subject}}\n\nHi {{.RecipientName}},\n\nWe're looking into it as of {{.Date.Format \"15:04\"}}.\n")
+	writeTemplate(t, dir, "broken.tmpl", "{{.RecipientName")
+	writeTemplate(t, dir, "notes.txt", "not a template")
+
+	loaded, err := Load(dir)
+
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Expected the broken template to be reported, got %v", err)
+	}
+	if len(loaded) != 2 || loaded[0].Name != "incident" || loaded[1].Name != "thanks" {
+		t.Fatalf("Expected the templates that parsed in order, got %+v", loaded)
+	}
+
+	subject, body, err := loaded[0].Execute(Data{
+		RecipientName: "Bob",
+		Subject:       "Site down",
+		Date:          time.Date(2025, 1, 2, 9, 30, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if subject != "Re: Site down" {
+		t.Errorf("Expected the subject line to be taken out, got %q", subject)
+	}
+	if body != "Hi Bob,\n\nWe're looking into it as of 09:30.\n" {
+		t.Errorf("Expected the body to be filled in, got %q", body)
+	}
+}
+
+func TestLoad_MissingDirectory(t *testing.T) {
+	loaded, err := Load(filepath.Join(t.TempDir(), "templates"))
+	if err != nil || len(loaded) != 0 {
+		t.Errorf("Expected no templates, got %+v and %v", loaded, err)
+	}
+}
+
+func TestLoad_PicksUpChanges(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "hello.tmpl", "Hello")
+	first, _ := Load(dir)
+
+	writeTemplate(t, dir, "hello.tmpl", "Hello again")
+	second, _ := Load(dir)
+
+	_, before, _ := first[0].Execute(Data{})
+	_, after, _ := second[0].Execute(Data{})
+	if before != "Hello" || after != "Hello again" {
+		t.Errorf("Expected the changed template to be loaded, got %q then %q", before, after)
+	}
+}
+
+func TestTemplate_ExecuteUnknownField(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "typo.tmpl", "Hi {{.RecipientNmae}}")
+	loaded, _ := Load(dir)
+
+	if _, _, err := loaded[0].Execute(Data{}); err == nil || !strings.Contains(err.Error(), "typo") {
+		t.Errorf("Expected an error naming the template, got %v", err)
+	}
+}
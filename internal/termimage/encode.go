@@ -0,0 +1,156 @@
+package termimage
+
+import (
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/ansi/kitty"
+)
+
+// HalfBlockLines draws an image in the given number of columns and rows, with two pixels in each cell.
+func HalfBlockLines(img image.Image, columns, rows int) []string {
+	scaled := scale(img, columns, rows*2)
+	lines := make([]string, rows)
+	for row := range rows {
+		var b strings.Builder
+		for x := range columns {
+			top, bottom := scaled.NRGBAAt(x, row*2), scaled.NRGBAAt(x, row*2+1)
+			switch {
+			case transparent(top) && transparent(bottom):
+				b.WriteString(" ")
+			case transparent(bottom):
+				fmt.Fprintf(&b, "\x1b[38;2;%d;%d;%dm▀\x1b[39m", top.R, top.G, top.B)
+			case transparent(top):
+				fmt.Fprintf(&b, "\x1b[38;2;%d;%d;%dm▄\x1b[39m", bottom.R, bottom.G, bottom.B)
+			default:
+				fmt.Fprintf(&b, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀\x1b[39;49m",
+					top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+			}
+		}
+		lines[row] = b.String()
+	}
+	return lines
+}
+
+// SixelLines draws an image in the given number of columns and rows. The whole image is drawn from the first line,
+// and the cursor is put back afterwards so the lines below it, which are left empty for it, are drawn as normal.
+func SixelLines(img image.Image, columns, rows int) []string {
+	lines := make([]string, rows)
+	lines[0] = ansi.SaveCursor + encodeSixel(img, columns*cellWidth, rows*cellHeight) + ansi.RestoreCursor
+	return lines
+}
+
+// encodeSixel encodes an image as a Sixel sequence of the given size in pixels, with its colours reduced to a 256-colour
+// palette. Transparent pixels are left as the background.
+func encodeSixel(img image.Image, width, height int) string {
+	scaled := scale(img, width, height)
+	paletted := image.NewPaletted(scaled.Bounds(), palette.Plan9)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), scaled, image.Point{})
+
+	var b strings.Builder
+	// P2=1 leaves pixels that aren't set as they were, rather than filling them with the background colour
+	b.WriteString("\x1bP0;1;0q")
+	fmt.Fprintf(&b, `"1;1;%d;%d`, width, height)
+
+	used := map[uint8]bool{}
+	for y := range height {
+		for x := range width {
+			if !transparent(scaled.NRGBAAt(x, y)) {
+				used[paletted.ColorIndexAt(x, y)] = true
+			}
+		}
+	}
+	for index := range palette.Plan9 {
+		if used[uint8(index)] {
+			r, g, bl, _ := palette.Plan9[index].RGBA()
+			// Sixel colours are percentages
+			fmt.Fprintf(&b, "#%d;2;%d;%d;%d", index, r*100/0xffff, g*100/0xffff, bl*100/0xffff)
+		}
+	}
+
+	// each line of a Sixel image is a band of six rows of pixels, drawn over once for each colour in it
+	for top := 0; top < height; top += 6 {
+		bands := map[uint8][]byte{}
+		for x := range width {
+			for dy := 0; dy < 6 && top+dy < height; dy++ {
+				if transparent(scaled.NRGBAAt(x, top+dy)) {
+					continue
+				}
+				index := paletted.ColorIndexAt(x, top+dy)
+				if bands[index] == nil {
+					bands[index] = make([]byte, width)
+				}
+				bands[index][x] |= 1 << dy
+			}
+		}
+		indexes := make([]int, 0, len(bands))
+		for index := range bands {
+			indexes = append(indexes, int(index))
+		}
+		slices.Sort(indexes)
+		for i, index := range indexes {
+			if i > 0 {
+				// goes back to the start of the band to draw the next colour over it
+				b.WriteByte('$')
+			}
+			fmt.Fprintf(&b, "#%d", index)
+			writeSixels(&b, bands[uint8(index)])
+		}
+		b.WriteByte('-')
+	}
+	b.WriteString("\x1b\\")
+	return b.String()
+}
+
+// writeSixels writes a band's columns of pixels, shortening runs of the same column with "!".
+func writeSixels(b *strings.Builder, columns []byte) {
+	for x := 0; x < len(columns); {
+		run := 1
+		for x+run < len(columns) && columns[x+run] == columns[x] {
+			run++
+		}
+		sixel := byte('?' + columns[x])
+		if run > 3 {
+			fmt.Fprintf(b, "!%d%c", run, sixel)
+		} else {
+			b.WriteString(strings.Repeat(string(sixel), run))
+		}
+		x += run
+	}
+}
+
+// TransmitKitty sends an image to the terminal with the Kitty graphics protocol, to be shown wherever its
+// placeholders are. The id links the image to its placeholders, and a new image with the same id replaces it.
+func TransmitKitty(w io.Writer, img image.Image, id, columns, rows int) error {
+	return ansi.WriteKittyGraphics(w, scale(img, columns*cellWidth, rows*cellHeight), &kitty.Options{
+		Action:           kitty.TransmitAndPut,
+		Transmission:     kitty.Direct,
+		Format:           kitty.PNG,
+		ID:               id,
+		Columns:          columns,
+		Rows:             rows,
+		VirtualPlacement: true,
+		// the terminal's replies would otherwise be read as key presses
+		Quite: 2,
+		Chunk: true,
+	})
+}
+
+// KittyLines fills the given number of columns and rows with placeholders for the image with the id, which has to be
+// under 256 since it's given as the placeholders' colour. Only the first cell of each row says which row it's in,
+// and the cells after it are taken to be the next columns along.
+func KittyLines(id, columns, rows int) []string {
+	lines := make([]string, rows)
+	for row := range rows {
+		lines[row] = fmt.Sprintf("\x1b[38;5;%dm%c%c%c%s\x1b[39m",
+			id, kitty.Placeholder, kitty.Diacritic(row), kitty.Diacritic(0),
+			strings.Repeat(string(kitty.Placeholder), columns-1))
+	}
+	return lines
+}
@@ -0,0 +1,128 @@
+// Package termimage draws images in the terminal, with the Kitty or Sixel graphics protocols where the terminal has
+// them, or otherwise with coloured half-block characters which work nearly everywhere.
+package termimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"strings"
+)
+
+type Protocol int
+
+const (
+	// Off doesn't show images at all
+	Off Protocol = iota
+	// HalfBlock draws two pixels in each character cell with "▀", coloured in with the foreground and background
+	HalfBlock
+	// Sixel draws the pixels into the cells, for terminals like foot, WezTerm and xterm
+	Sixel
+	// Kitty sends the image to the terminal and places it with Unicode placeholder characters, for kitty and Ghostty
+	Kitty
+)
+
+// cellWidth and cellHeight are roughly how many pixels a character cell takes up, which is all that's needed to keep
+// images in proportion, since cells are about twice as tall as they're wide.
+const (
+	cellWidth  = 8
+	cellHeight = 16
+)
+
+// ParseProtocol reads the protocol from a setting: "off", "auto" to detect it, "kitty", "sixel" or "halfblock".
+func ParseProtocol(setting string) (Protocol, error) {
+	switch strings.ToLower(setting) {
+	case "", "off":
+		return Off, nil
+	case "auto":
+		return Detect(), nil
+	case "kitty":
+		return Kitty, nil
+	case "sixel":
+		return Sixel, nil
+	case "halfblock":
+		return HalfBlock, nil
+	}
+	return Off, fmt.Errorf("unknown image protocol %q, expected off, auto, kitty, sixel or halfblock", setting)
+}
+
+// Detect works out the best protocol the terminal has from the environment, falling back to half-blocks.
+func Detect() Protocol {
+	term := os.Getenv("TERM")
+	program := os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || term == "xterm-ghostty" || program == "ghostty":
+		return Kitty
+	case strings.HasPrefix(term, "foot") || strings.HasPrefix(term, "mlterm") || strings.HasPrefix(term, "contour") ||
+		program == "WezTerm" || program == "iTerm.app" || program == "mintty":
+		return Sixel
+	}
+	return HalfBlock
+}
+
+func (p Protocol) String() string {
+	switch p {
+	case HalfBlock:
+		return "halfblock"
+	case Sixel:
+		return "sixel"
+	case Kitty:
+		return "kitty"
+	}
+	return "off"
+}
+
+// Fit works out how many columns and rows to show an image in, keeping it in proportion within the space it has.
+// Images aren't made any bigger than their own size.
+func Fit(bounds image.Rectangle, maxColumns, maxRows int) (columns, rows int) {
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 || maxColumns <= 0 || maxRows <= 0 {
+		return 0, 0
+	}
+	columns = min(maxColumns, (width+cellWidth-1)/cellWidth)
+	rows = max((columns*cellWidth*height/width+cellHeight-1)/cellHeight, 1)
+	if rows > maxRows {
+		rows = maxRows
+		columns = max(rows*cellHeight*width/height/cellWidth, 1)
+	}
+	return columns, rows
+}
+
+// scale resizes an image by averaging the pixels that end up in each new one, which keeps small details like text
+// readable when shrinking a lot.
+func scale(img image.Image, width, height int) *image.NRGBA {
+	scaled := image.NewNRGBA(image.Rect(0, 0, width, height))
+	bounds := img.Bounds()
+	for y := 0; y < height; y++ {
+		top := bounds.Min.Y + y*bounds.Dy()/height
+		bottom := max(bounds.Min.Y+(y+1)*bounds.Dy()/height, top+1)
+		for x := 0; x < width; x++ {
+			left := bounds.Min.X + x*bounds.Dx()/width
+			right := max(bounds.Min.X+(x+1)*bounds.Dx()/width, left+1)
+
+			var r, g, b, a, count uint64
+			for sy := top; sy < bottom; sy++ {
+				for sx := left; sx < right; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					count++
+				}
+			}
+			// the colours are premultiplied by alpha, so they're divided by it to get them back
+			pixel := color.NRGBA{A: uint8(a / count >> 8)}
+			if a > 0 {
+				pixel.R = uint8(r * 0xffff / a >> 8)
+				pixel.G = uint8(g * 0xffff / a >> 8)
+				pixel.B = uint8(b * 0xffff / a >> 8)
+			}
+			scaled.SetNRGBA(x, y, pixel)
+		}
+	}
+	return scaled
+}
+
+// transparent reports whether a pixel is see-through enough to leave the terminal's background showing.
+func transparent(pixel color.NRGBA) bool {
+	return pixel.A < 0x80
+}
@@ -0,0 +1,153 @@
+package termimage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/ansi/kitty"
+)
+
+func solid(width, height int, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestParseProtocol(t *testing.T) {
+	tests := map[string]Protocol{
+		"":          Off,
+		"off":       Off,
+		"kitty":     Kitty,
+		"Sixel":     Sixel,
+		"halfblock": HalfBlock,
+	}
+	for setting, expected := range tests {
+		protocol, err := ParseProtocol(setting)
+		if err != nil {
+			t.Fatalf("%q: %v", setting, err)
+		}
+		if protocol != expected {
+			t.Errorf("%q: expected %v, got %v", setting, expected, protocol)
+		}
+	}
+
+	if _, err := ParseProtocol("iterm"); err == nil {
+		t.Error("expected an error for an unknown protocol")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	t.Setenv("KITTY_WINDOW_ID", "")
+	t.Setenv("TERM_PROGRAM", "")
+
+	t.Setenv("TERM", "xterm-kitty")
+	if protocol := Detect(); protocol != Kitty {
+		t.Errorf("expected kitty, got %v", protocol)
+	}
+	t.Setenv("TERM", "foot")
+	if protocol := Detect(); protocol != Sixel {
+		t.Errorf("expected sixel, got %v", protocol)
+	}
+	t.Setenv("TERM", "xterm-256color")
+	if protocol := Detect(); protocol != HalfBlock {
+		t.Errorf("expected halfblock, got %v", protocol)
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name                string
+		width, height       int
+		maxColumns, maxRows int
+		columns, rows       int
+	}{
+		{"small images aren't made bigger", 16, 16, 80, 20, 2, 1},
+		{"wide images fill the width", 1600, 400, 80, 20, 80, 10},
+		{"tall images fill the height", 400, 1600, 80, 20, 10, 20},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			columns, rows := Fit(image.Rect(0, 0, test.width, test.height), test.maxColumns, test.maxRows)
+			if columns != test.columns || rows != test.rows {
+				t.Errorf("expected %dx%d, got %dx%d", test.columns, test.rows, columns, rows)
+			}
+		})
+	}
+}
+
+func TestScaleAverages(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	img.Set(1, 0, color.NRGBA{B: 255, A: 255})
+
+	pixel := scale(img, 1, 1).NRGBAAt(0, 0)
+	if pixel.R != 127 || pixel.B != 127 || pixel.A != 255 {
+		t.Errorf("expected an even mix of red and blue, got %v", pixel)
+	}
+}
+
+func TestHalfBlockLines(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	img.Set(0, 1, color.NRGBA{G: 255, A: 255})
+
+	lines := HalfBlockLines(img, 2, 1)
+	if len(lines) != 1 {
+		t.Fatalf("expected one line, got %d", len(lines))
+	}
+	if width := ansi.StringWidth(lines[0]); width != 2 {
+		t.Errorf("expected the line to be 2 columns wide, got %d", width)
+	}
+	if !strings.HasPrefix(lines[0], "\x1b[38;2;255;0;0m\x1b[48;2;0;255;0m▀") {
+		t.Errorf("expected a red over green cell first, got %q", lines[0])
+	}
+	// the second column is transparent, so the background shows through
+	if !strings.HasSuffix(lines[0], " ") {
+		t.Errorf("expected a blank cell for the transparent pixels, got %q", lines[0])
+	}
+}
+
+func TestSixelLines(t *testing.T) {
+	lines := SixelLines(solid(8, 12, color.White), 1, 2)
+	if len(lines) != 2 || lines[1] != "" {
+		t.Fatalf("expected the image on the first line and the second left empty, got %q", lines)
+	}
+	sequence := lines[0]
+	if !strings.HasPrefix(sequence, "\x1b7\x1bP0;1;0q\"1;1;8;32") || !strings.HasSuffix(sequence, "\x1b\\\x1b8") {
+		t.Errorf("expected a Sixel sequence with the cursor saved around it, got %q", sequence)
+	}
+	// 32 rows of pixels is 5 full bands of 8 white columns, and a last band of 2 rows
+	if count := strings.Count(sequence, "!8~-"); count != 5 {
+		t.Errorf("expected 5 full bands, got %d in %q", count, sequence)
+	}
+	if !strings.Contains(sequence, "!8B-") {
+		t.Errorf("expected the last band to have 2 rows, got %q", sequence)
+	}
+}
+
+func TestKitty(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := TransmitKitty(&buffer, solid(4, 4, color.Black), 7, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+	transmitted := buffer.String()
+	if !strings.HasPrefix(transmitted, "\x1b_G") || !strings.Contains(transmitted, "i=7") ||
+		!strings.Contains(transmitted, "U=1") {
+		t.Errorf("expected a virtual placement for image 7, got %q", transmitted)
+	}
+
+	lines := KittyLines(7, 3, 2)
+	expected := "\x1b[38;5;7m" + string([]rune{kitty.Placeholder, kitty.Diacritic(1), kitty.Diacritic(0)}) +
+		strings.Repeat(string(kitty.Placeholder), 2) + "\x1b[39m"
+	if lines[1] != expected {
+		t.Errorf("expected %q, got %q", expected, lines[1])
+	}
+}
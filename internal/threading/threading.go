@@ -0,0 +1,268 @@
+// Package threading reconstructs conversations from the threading headers of emails,
+// using Jamie Zawinski's algorithm (https://www.jwz.org/doc/threading.html).
+// It's used when the server can't thread messages itself.
+package threading
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+type container struct {
+	email    *core.EmailMetadata
+	parent   *container
+	children []*container
+}
+
+func (c *container) isAncestorOf(other *container) bool {
+	for p := other; p != nil; p = p.parent {
+		if p == c {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *container) addChild(child *container) {
+	if child.parent != nil {
+		child.parent.removeChild(child)
+	}
+	child.parent = c
+	c.children = append(c.children, child)
+}
+
+func (c *container) removeChild(child *container) {
+	c.children = slices.DeleteFunc(c.children, func(other *container) bool {
+		return other == child
+	})
+	child.parent = nil
+}
+
+// Thread groups the emails into conversation trees.
+// Threads are sorted so the most recently active come first, and replies within a thread are sorted oldest first.
+func Thread(emails []core.EmailMetadata) []*core.Thread {
+	idTable := map[string]*container{}
+	// containers in the order they were created, so the output doesn't depend on map iteration order
+	var ordered []*container
+
+	getContainer := func(id string) *container {
+		c, ok := idTable[id]
+		if !ok {
+			c = &container{}
+			idTable[id] = c
+			ordered = append(ordered, c)
+		}
+		return c
+	}
+
+	for i := range emails {
+		email := &emails[i]
+
+		messageId := email.MessageId
+		if messageId == "" || (idTable[messageId] != nil && idTable[messageId].email != nil) {
+			// missing or duplicate message IDs get a unique one, so the message still shows up
+			messageId = fmt.Sprintf("mail-tui-synthetic-%s-%d", email.Id, i)
+		}
+		messageContainer := getContainer(messageId)
+		messageContainer.email = email
+
+		references := email.References
+		if len(email.InReplyTo) > 0 && !slices.Contains(references, email.InReplyTo[0]) {
+			references = append(slices.Clone(references), email.InReplyTo[0])
+		}
+
+		// link the references together in order, without overriding links that have already been made
+		var previous *container
+		for _, reference := range references {
+			referenceContainer := getContainer(reference)
+			if previous != nil && referenceContainer.parent == nil &&
+				!referenceContainer.isAncestorOf(previous) {
+				previous.addChild(referenceContainer)
+			}
+			previous = referenceContainer
+		}
+
+		// the message's own parent is always the last reference, which takes precedence over any earlier guesses
+		if previous != nil && messageContainer.isAncestorOf(previous) {
+			previous = nil
+		}
+		if previous != nil {
+			previous.addChild(messageContainer)
+		} else if messageContainer.parent != nil {
+			messageContainer.parent.removeChild(messageContainer)
+		}
+	}
+
+	var roots []*container
+	for _, c := range ordered {
+		if c.parent == nil {
+			roots = append(roots, c)
+		}
+	}
+
+	roots = pruneEmptyContainers(roots, true)
+	roots = groupBySubject(roots)
+
+	threads := make([]*core.Thread, 0, len(roots))
+	for _, root := range roots {
+		threads = append(threads, toThread(root))
+	}
+	SortNewestFirst(threads)
+	return threads
+}
+
+// SortNewestFirst sorts threads so the ones with the most recent activity come first.
+// Replies within each thread are sorted oldest first.
+func SortNewestFirst(threads []*core.Thread) {
+	for _, thread := range threads {
+		sortReplies(thread)
+	}
+	slices.SortStableFunc(threads, func(a, b *core.Thread) int {
+		return b.LatestSentAt().Compare(a.LatestSentAt())
+	})
+}
+
+func sortReplies(thread *core.Thread) {
+	for _, child := range thread.Children {
+		sortReplies(child)
+	}
+	slices.SortStableFunc(thread.Children, func(a, b *core.Thread) int {
+		return earliestSentAt(a).Compare(earliestSentAt(b))
+	})
+}
+
+func earliestSentAt(thread *core.Thread) time.Time {
+	if thread.Email != nil {
+		return thread.Email.SentAt
+	}
+	var earliest time.Time
+	for _, email := range thread.Emails() {
+		if earliest.IsZero() || email.SentAt.Before(earliest) {
+			earliest = email.SentAt
+		}
+	}
+	return earliest
+}
+
+// pruneEmptyContainers removes placeholders that have no children, and replaces placeholders with their children
+// unless that would promote several children to the root set.
+func pruneEmptyContainers(containers []*container, isRoot bool) []*container {
+	var result []*container
+	for _, c := range containers {
+		c.children = pruneEmptyContainers(c.children, false)
+		for _, child := range c.children {
+			child.parent = c
+		}
+
+		switch {
+		case c.email != nil:
+			result = append(result, c)
+		case len(c.children) == 0:
+			// nothing to show, so drop it
+		case !isRoot || len(c.children) == 1:
+			for _, child := range c.children {
+				child.parent = c.parent
+			}
+			result = append(result, c.children...)
+		default:
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// groupBySubject merges root threads that share a subject, to catch replies from clients that don't set the
+// threading headers.
+func groupBySubject(roots []*container) []*container {
+	subjectTable := map[string]*container{}
+	for _, root := range roots {
+		subject := containerSubject(root)
+		if subject == "" {
+			continue
+		}
+		existing, ok := subjectTable[subject]
+		if !ok ||
+			(root.email == nil && existing.email != nil) ||
+			(existing.email != nil && isReply(existing.email.Subject) && root.email != nil && !isReply(root.email.Subject)) {
+			subjectTable[subject] = root
+		}
+	}
+
+	var result []*container
+	for _, root := range roots {
+		subject := containerSubject(root)
+		target, ok := subjectTable[subject]
+		if subject == "" || !ok || target == root {
+			result = append(result, root)
+			continue
+		}
+
+		switch {
+		case target.email == nil && root.email == nil:
+			for _, child := range slices.Clone(root.children) {
+				target.addChild(child)
+			}
+		case target.email == nil:
+			target.addChild(root)
+		case root.email != nil && !isReply(target.email.Subject) && isReply(root.email.Subject):
+			target.addChild(root)
+		default:
+			// neither is obviously the parent of the other, so make them siblings under a placeholder
+			placeholder := &container{}
+			index := slices.Index(result, target)
+			placeholder.addChild(target)
+			placeholder.addChild(root)
+			subjectTable[subject] = placeholder
+			if index >= 0 {
+				result[index] = placeholder
+			} else {
+				result = append(result, placeholder)
+			}
+		}
+	}
+	return result
+}
+
+func containerSubject(c *container) string {
+	if c.email != nil {
+		return BaseSubject(c.email.Subject)
+	}
+	if len(c.children) > 0 && c.children[0].email != nil {
+		return BaseSubject(c.children[0].email.Subject)
+	}
+	return ""
+}
+
+var (
+	subjectPrefix = regexp.MustCompile(`(?i)^\s*((re|fwd?|aw|sv)(\[\d+\])?:\s*|\[[^\]]*\]\s*)`)
+	replyPrefix   = regexp.MustCompile(`(?i)^\s*(\[[^\]]*\]\s*)*(re|fwd?|aw|sv)(\[\d+\])?:`)
+)
+
+// BaseSubject strips reply and forward prefixes, and mailing list tags, from a subject.
+func BaseSubject(subject string) string {
+	for {
+		stripped := subjectPrefix.ReplaceAllString(subject, "")
+		if stripped == subject {
+			break
+		}
+		subject = stripped
+	}
+	return strings.ToLower(strings.TrimSpace(subject))
+}
+
+func isReply(subject string) bool {
+	return replyPrefix.MatchString(subject)
+}
+
+func toThread(c *container) *core.Thread {
+	thread := &core.Thread{Email: c.email}
+	for _, child := range c.children {
+		thread.Children = append(thread.Children, toThread(child))
+	}
+	return thread
+}
@@ -0,0 +1,179 @@
+package threading
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+var baseTime = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func email(id, messageId, subject string, minutes int, references ...string) core.EmailMetadata {
+	return core.EmailMetadata{
+		Id:         core.EmailId(id),
+		MessageId:  messageId,
+		Subject:    subject,
+		SentAt:     baseTime.Add(time.Duration(minutes) * time.Minute),
+		References: references,
+	}
+}
+
+func ids(thread *core.Thread) []core.EmailId {
+	var result []core.EmailId
+	for _, email := range thread.Emails() {
+		result = append(result, email.Id)
+	}
+	return result
+}
+
+func assertIds(t *testing.T, thread *core.Thread, expected ...core.EmailId) {
+	t.Helper()
+	actual := ids(thread)
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected thread %v, got %v", expected, actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatalf("Expected thread %v, got %v", expected, actual)
+		}
+	}
+}
+
+func TestThread_ReplyChain(t *testing.T) {
+	threads := Thread([]core.EmailMetadata{
+		email("3", "c@x", "Re: Lunch", 20, "a@x", "b@x"),
+		email("1", "a@x", "Lunch", 0),
+		email("2", "b@x", "Re: Lunch", 10, "a@x"),
+	})
+
+	if len(threads) != 1 {
+		t.Fatalf("Expected 1 thread, got %d", len(threads))
+	}
+	root := threads[0]
+	if root.Email == nil || root.Email.Id != "1" {
+		t.Fatalf("Expected root to be email 1, got %+v", root.Email)
+	}
+	if len(root.Children) != 1 || len(root.Children[0].Children) != 1 {
+		t.Fatalf("Expected a linear chain of replies")
+	}
+	assertIds(t, root, "1", "2", "3")
+}
+
+func TestThread_InReplyToOnly(t *testing.T) {
+	reply := email("2", "b@x", "Re: Question", 10)
+	reply.InReplyTo = []string{"a@x"}
+
+	threads := Thread([]core.EmailMetadata{
+		email("1", "a@x", "Question", 0),
+		reply,
+	})
+
+	if len(threads) != 1 {
+		t.Fatalf("Expected 1 thread, got %d", len(threads))
+	}
+	assertIds(t, threads[0], "1", "2")
+}
+
+func TestThread_MissingParentKeepsSiblingsTogether(t *testing.T) {
+	threads := Thread([]core.EmailMetadata{
+		email("1", "b@x", "Re: Plans", 10, "missing@x"),
+		email("2", "c@x", "Re: Plans", 20, "missing@x"),
+	})
+
+	if len(threads) != 1 {
+		t.Fatalf("Expected 1 thread, got %d", len(threads))
+	}
+	if threads[0].Email != nil {
+		t.Errorf("Expected a placeholder root for the missing message")
+	}
+	assertIds(t, threads[0], "1", "2")
+}
+
+func TestThread_MissingParentWithSingleChildIsPromoted(t *testing.T) {
+	threads := Thread([]core.EmailMetadata{
+		email("1", "b@x", "Re: Plans", 10, "missing@x"),
+	})
+
+	if len(threads) != 1 {
+		t.Fatalf("Expected 1 thread, got %d", len(threads))
+	}
+	if threads[0].Email == nil || threads[0].Email.Id != "1" {
+		t.Errorf("Expected the only child to be promoted to the root")
+	}
+}
+
+func TestThread_GroupsBySubjectWithoutHeaders(t *testing.T) {
+	threads := Thread([]core.EmailMetadata{
+		email("2", "b@x", "RE: [team] Offsite", 10),
+		email("1", "a@x", "[team] Offsite", 0),
+		email("3", "c@x", "Something else", 5),
+	})
+
+	if len(threads) != 2 {
+		t.Fatalf("Expected 2 threads, got %d", len(threads))
+	}
+	// the offsite thread has the most recent activity
+	assertIds(t, threads[0], "1", "2")
+	assertIds(t, threads[1], "3")
+}
+
+func TestThread_SortsNewestThreadFirst(t *testing.T) {
+	threads := Thread([]core.EmailMetadata{
+		email("1", "a@x", "Old", 0),
+		email("2", "b@x", "New", 30),
+		email("3", "c@x", "Re: Old", 60, "a@x"),
+	})
+
+	if len(threads) != 2 {
+		t.Fatalf("Expected 2 threads, got %d", len(threads))
+	}
+	assertIds(t, threads[0], "1", "3")
+	assertIds(t, threads[1], "2")
+}
+
+func TestThread_IgnoresReferenceLoops(t *testing.T) {
+	threads := Thread([]core.EmailMetadata{
+		email("1", "a@x", "Loop", 0, "b@x"),
+		email("2", "b@x", "Re: Loop", 10, "a@x"),
+	})
+
+	if len(threads) != 1 {
+		t.Fatalf("Expected 1 thread, got %d", len(threads))
+	}
+	if threads[0].Size() != 2 {
+		t.Errorf("Expected both emails in the thread, got %d", threads[0].Size())
+	}
+}
+
+func TestThread_DuplicateMessageIds(t *testing.T) {
+	threads := Thread([]core.EmailMetadata{
+		email("1", "a@x", "First", 0),
+		email("2", "a@x", "Second", 10),
+	})
+
+	total := 0
+	for _, thread := range threads {
+		total += thread.Size()
+	}
+	if total != 2 {
+		t.Errorf("Expected both emails to be kept, got %d", total)
+	}
+}
+
+func TestBaseSubject(t *testing.T) {
+	tests := map[string]string{
+		"Hello":                "hello",
+		"Re: Hello":            "hello",
+		"RE: Fwd: Hello":       "hello",
+		"[list] Re: Hello":     "hello",
+		"Re[2]: Hello":         "hello",
+		"  aw:  sv: Hello   ":  "hello",
+		"Regarding the report": "regarding the report",
+	}
+	for input, expected := range tests {
+		if actual := BaseSubject(input); actual != expected {
+			t.Errorf("BaseSubject(%q): expected %q, got %q", input, expected, actual)
+		}
+	}
+}
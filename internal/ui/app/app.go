@@ -3,11 +3,17 @@ package app
 import (
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/bengesoff/mail-tui/internal/contacts"
 	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/outbox"
 	"github.com/bengesoff/mail-tui/internal/ui"
+	"github.com/bengesoff/mail-tui/internal/ui/contact_list"
+	"github.com/bengesoff/mail-tui/internal/ui/draft_list"
 	"github.com/bengesoff/mail-tui/internal/ui/email_composer"
 	"github.com/bengesoff/mail-tui/internal/ui/email_list"
 	"github.com/bengesoff/mail-tui/internal/ui/email_viewer"
+	"github.com/bengesoff/mail-tui/internal/ui/outbox_list"
+	"github.com/bengesoff/mail-tui/internal/undo"
 )
 
 type ViewName string
@@ -16,6 +22,9 @@ const (
 	ListViewName     ViewName = "email_list"
 	ViewerViewName   ViewName = "email_viewer"
 	ComposerViewName ViewName = "email_composer"
+	DraftsViewName   ViewName = "draft_list"
+	OutboxViewName   ViewName = "outbox_list"
+	ContactsViewName ViewName = "contact_list"
 )
 
 type AppModel struct {
@@ -23,14 +32,26 @@ type AppModel struct {
 	emailViewer   *email_viewer.EmailViewerModel
 	emailList     *email_list.EmailListModel
 	emailComposer *email_composer.EmailComposerModel
+	draftList     *draft_list.DraftListModel
+	outboxList    *outbox_list.OutboxListModel
+	contactList   *contact_list.ContactListModel
+
+	backend core.EmailBackend
+	// undoStack records the operations made from the other views, so they can be undone from any of them
+	undoStack *undo.Stack
 }
 
-func NewAppModel(backend core.EmailBackend) *AppModel {
+func NewAppModel(backend core.EmailBackend, outbox *outbox.Outbox, contacts *contacts.Book, composerOptions email_composer.Options, viewerOptions email_viewer.Options) *AppModel {
 	return &AppModel{
 		activeView:    ListViewName,
-		emailViewer:   email_viewer.NewEmailViewerModel(backend),
+		emailViewer:   email_viewer.NewEmailViewerModel(backend, viewerOptions),
 		emailList:     email_list.NewEmailListModel(backend),
-		emailComposer: email_composer.NewEmailComposerModel(backend),
+		emailComposer: email_composer.NewEmailComposerModel(backend, outbox, contacts, composerOptions),
+		draftList:     draft_list.NewDraftListModel(backend),
+		outboxList:    outbox_list.NewOutboxListModel(outbox),
+		contactList:   contact_list.NewContactListModel(contacts),
+		backend:       backend,
+		undoStack:     &undo.Stack{},
 	}
 }
 
@@ -63,6 +84,15 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case ComposerViewName:
 				m.emailComposer, cmd = m.emailComposer.Update(msg)
 				commands = append(commands, cmd)
+			case DraftsViewName:
+				m.draftList, cmd = m.draftList.Update(msg)
+				commands = append(commands, cmd)
+			case OutboxViewName:
+				m.outboxList, cmd = m.outboxList.Update(msg)
+				commands = append(commands, cmd)
+			case ContactsViewName:
+				m.contactList, cmd = m.contactList.Update(msg)
+				commands = append(commands, cmd)
 			}
 		}
 	case ui.ShowEmailListMessage:
@@ -77,6 +107,22 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.activeView = ComposerViewName
 		m.emailComposer, cmd = m.emailComposer.Update(msg)
 		commands = append(commands, cmd)
+	case ui.ShowDraftListMessage:
+		m.activeView = DraftsViewName
+		m.draftList, cmd = m.draftList.Update(msg)
+		commands = append(commands, cmd)
+	case ui.ShowOutboxMessage:
+		m.activeView = OutboxViewName
+		m.outboxList, cmd = m.outboxList.Update(msg)
+		commands = append(commands, cmd)
+	case ui.ShowContactListMessage:
+		m.activeView = ContactsViewName
+		m.contactList, cmd = m.contactList.Update(msg)
+		commands = append(commands, cmd)
+	case ui.RecordUndoMessage:
+		m.undoStack.Push(msg.Operation)
+	case ui.UndoMessage:
+		commands = append(commands, m.undo())
 	default:
 		m.emailList, cmd = m.emailList.Update(msg)
 		commands = append(commands, cmd)
@@ -84,11 +130,34 @@ func (m AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		commands = append(commands, cmd)
 		m.emailComposer, cmd = m.emailComposer.Update(msg)
 		commands = append(commands, cmd)
+		m.draftList, cmd = m.draftList.Update(msg)
+		commands = append(commands, cmd)
+		m.outboxList, cmd = m.outboxList.Update(msg)
+		commands = append(commands, cmd)
+		m.contactList, cmd = m.contactList.Update(msg)
+		commands = append(commands, cmd)
 	}
 
 	return m, tea.Batch(commands...)
 }
 
+// undo reverses the most recent operation on the undo stack.
+func (m AppModel) undo() tea.Cmd {
+	operation, ok := m.undoStack.Pop()
+	if !ok {
+		return func() tea.Msg {
+			return ui.UndoneMessage{Error: undo.ErrNothingToUndo}
+		}
+	}
+
+	return func() tea.Msg {
+		return ui.UndoneMessage{
+			Operation: operation,
+			Error:     operation.Undo(m.backend),
+		}
+	}
+}
+
 func (m AppModel) View() string {
 	switch m.activeView {
 	case ListViewName:
@@ -97,6 +166,12 @@ func (m AppModel) View() string {
 		return m.emailViewer.View()
 	case ComposerViewName:
 		return m.emailComposer.View()
+	case DraftsViewName:
+		return m.draftList.View()
+	case OutboxViewName:
+		return m.outboxList.View()
+	case ContactsViewName:
+		return m.contactList.View()
 	default:
 		return "Unknown view " + string(m.activeView)
 	}
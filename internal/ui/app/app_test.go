@@ -1,16 +1,21 @@
 package app
 
 import (
+	"errors"
 	"testing"
 
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/bengesoff/mail-tui/internal/backend/fake"
+	"github.com/bengesoff/mail-tui/internal/core"
 	"github.com/bengesoff/mail-tui/internal/ui"
+	"github.com/bengesoff/mail-tui/internal/ui/email_composer"
+	"github.com/bengesoff/mail-tui/internal/ui/email_viewer"
+	"github.com/bengesoff/mail-tui/internal/undo"
 )
 
 func TestModel_InitialState(t *testing.T) {
-	m := NewAppModel(fake.NewFakeBackend())
+	m := NewAppModel(fake.NewFakeBackend(), nil, nil, email_composer.Options{}, email_viewer.Options{})
 
 	if m.activeView != ListViewName {
 		t.Errorf("Expected initial view to be '%s', got '%s'", ListViewName, m.activeView)
@@ -30,7 +35,7 @@ func TestModel_InitialState(t *testing.T) {
 }
 
 func TestModel_Init(t *testing.T) {
-	m := NewAppModel(fake.NewFakeBackend())
+	m := NewAppModel(fake.NewFakeBackend(), nil, nil, email_composer.Options{}, email_viewer.Options{})
 	cmd := m.Init()
 
 	if cmd == nil {
@@ -46,7 +51,7 @@ func TestModel_Init(t *testing.T) {
 }
 
 func TestModel_Update_ShowEmailListMessage(t *testing.T) {
-	m := NewAppModel(fake.NewFakeBackend())
+	m := NewAppModel(fake.NewFakeBackend(), nil, nil, email_composer.Options{}, email_viewer.Options{})
 	m.activeView = ViewerViewName // Start with viewer view
 
 	updatedModel, _ := m.Update(ui.ShowEmailListMessage{})
@@ -58,7 +63,7 @@ func TestModel_Update_ShowEmailListMessage(t *testing.T) {
 }
 
 func TestModel_Update_ShowEmailViewerMessage(t *testing.T) {
-	m := NewAppModel(fake.NewFakeBackend())
+	m := NewAppModel(fake.NewFakeBackend(), nil, nil, email_composer.Options{}, email_viewer.Options{})
 
 	updatedModel, _ := m.Update(ui.ShowEmailViewerMessage{EmailId: "test-id"})
 	updated := updatedModel.(AppModel)
@@ -69,7 +74,7 @@ func TestModel_Update_ShowEmailViewerMessage(t *testing.T) {
 }
 
 func TestModel_Update_ShowEmailComposerMessage(t *testing.T) {
-	m := NewAppModel(fake.NewFakeBackend())
+	m := NewAppModel(fake.NewFakeBackend(), nil, nil, email_composer.Options{}, email_viewer.Options{})
 
 	updatedModel, _ := m.Update(ui.ShowEmailComposerMessage{})
 	updated := updatedModel.(AppModel)
@@ -80,7 +85,7 @@ func TestModel_Update_ShowEmailComposerMessage(t *testing.T) {
 }
 
 func TestModel_Update_CtrlC(t *testing.T) {
-	m := NewAppModel(fake.NewFakeBackend())
+	m := NewAppModel(fake.NewFakeBackend(), nil, nil, email_composer.Options{}, email_viewer.Options{})
 
 	keyMsg := tea.KeyMsg{Type: tea.KeyCtrlC}
 	_, cmd := m.Update(keyMsg)
@@ -92,7 +97,7 @@ func TestModel_Update_CtrlC(t *testing.T) {
 }
 
 func TestModel_ViewSwitching_Sequence(t *testing.T) {
-	m := NewAppModel(fake.NewFakeBackend())
+	m := NewAppModel(fake.NewFakeBackend(), nil, nil, email_composer.Options{}, email_viewer.Options{})
 
 	// Should start with list view
 	if m.activeView != ListViewName {
@@ -139,3 +144,39 @@ func TestModel_ViewSwitching_Sequence(t *testing.T) {
 		t.Errorf("Expected view to be '%s' after ShowEmailListMessage from composer, got '%s'", ListViewName, updatedModel.activeView)
 	}
 }
+
+type fakeOperation struct {
+	undone bool
+}
+
+func (o *fakeOperation) Describe() string {
+	return "Archived 1 email"
+}
+
+func (o *fakeOperation) Undo(backend core.EmailBackend) error {
+	o.undone = true
+	return nil
+}
+
+func TestModel_Update_Undo(t *testing.T) {
+	m := NewAppModel(fake.NewFakeBackend(), nil, nil, email_composer.Options{}, email_viewer.Options{})
+	operation := &fakeOperation{}
+
+	updatedModel, _ := m.Update(ui.RecordUndoMessage{Operation: operation})
+	updatedModel, cmd := updatedModel.Update(ui.UndoMessage{})
+
+	msg, ok := cmd().(ui.UndoneMessage)
+	if !ok {
+		t.Fatal("Expected undoing to return an UndoneMessage")
+	}
+	if msg.Error != nil || !operation.undone {
+		t.Errorf("Expected the operation to be undone, got error %v", msg.Error)
+	}
+
+	// the operation has been popped, so there's nothing left to undo
+	_, cmd = updatedModel.Update(ui.UndoMessage{})
+
+	if msg := cmd().(ui.UndoneMessage); !errors.Is(msg.Error, undo.ErrNothingToUndo) {
+		t.Errorf("Expected nothing to undo, got %v", msg.Error)
+	}
+}
@@ -0,0 +1,325 @@
+package contact_list
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/emersion/go-message/mail"
+
+	"github.com/bengesoff/mail-tui/internal/contacts"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+var (
+	labelStyle   = lipgloss.NewStyle().Bold(true)
+	focusedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	blurredStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+)
+
+// contactsChangedMessage reports that the address book has been changed and saved, or failed to be.
+type contactsChangedMessage struct {
+	status string
+	error  error
+}
+
+// ContactListModel lists the contacts in the address book, so they can be edited, deleted or imported from a vCard
+// file.
+type ContactListModel struct {
+	book *contacts.Book
+
+	// editing is the address of the contact being edited, if any
+	editing      string
+	nameInput    textinput.Model
+	addressInput textinput.Model
+	// importing is set while the path of a vCard file to import is being typed in
+	importing   bool
+	importInput textinput.Model
+	error       string
+
+	list list.Model
+}
+
+type contactItem struct {
+	contacts.Contact
+}
+
+func (i contactItem) Title() string {
+	if i.Name == "" {
+		return i.Address
+	}
+	return i.Name
+}
+
+func (i contactItem) Description() string {
+	if i.Count == 0 {
+		return i.Address + " • imported"
+	}
+	return fmt.Sprintf("%s • %d emails, last %s", i.Address, i.Count, i.LastSeen.Format("Mon, 2 Jan 2006"))
+}
+
+func (i contactItem) FilterValue() string {
+	return i.Name + " " + i.Address
+}
+
+// NewContactListModel lists the contacts in the book, which can be nil if there's no address book.
+func NewContactListModel(book *contacts.Book) *ContactListModel {
+	contactList := list.New([]list.Item{}, list.NewDefaultDelegate(), 0, 0)
+	contactList.Title = "Contacts"
+	contactList.SetStatusBarItemName("contact", "contacts")
+	contactList.StatusMessageLifetime = 3 * time.Second
+	contactList.DisableQuitKeybindings()
+	contactList.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{
+			key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "edit contact")),
+			key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "delete contact")),
+			key.NewBinding(key.WithKeys("i"), key.WithHelp("i", "import vCard")),
+			key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back")),
+		}
+	}
+	// d is used for deleting contacts rather than paging
+	contactList.KeyMap.NextPage.SetKeys("right", "l", "pgdown", "f")
+
+	nameInput := textinput.New()
+	nameInput.Placeholder = "Alice Smith"
+	nameInput.CharLimit = 256
+	nameInput.Width = 50
+
+	addressInput := textinput.New()
+	addressInput.Placeholder = "alice@example.com"
+	addressInput.CharLimit = 256
+	addressInput.Width = 50
+
+	importInput := textinput.New()
+	importInput.Placeholder = "~/contacts.vcf"
+	importInput.CharLimit = 1024
+	importInput.Width = 50
+
+	return &ContactListModel{
+		book:         book,
+		nameInput:    nameInput,
+		addressInput: addressInput,
+		importInput:  importInput,
+		list:         contactList,
+	}
+}
+
+func (m *ContactListModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *ContactListModel) Update(msg tea.Msg) (*ContactListModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ui.ShowContactListMessage:
+		m.editing = ""
+		m.importing = false
+		m.error = ""
+		if m.book == nil {
+			m.error = "there's no address book"
+			return m, nil
+		}
+		return m, m.loadContacts()
+	case contactsChangedMessage:
+		if msg.error != nil {
+			return m, m.list.NewStatusMessage(msg.error.Error())
+		}
+		return m, tea.Batch(m.list.NewStatusMessage(msg.status), m.loadContacts())
+	case tea.WindowSizeMsg:
+		m.list.SetSize(msg.Width, msg.Height)
+	case tea.KeyMsg:
+		if m.editing != "" {
+			return m, m.updateEditing(msg)
+		}
+		if m.importing {
+			return m, m.updateImporting(msg)
+		}
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+		switch msg.String() {
+		case "esc":
+			if m.list.FilterState() == list.Unfiltered {
+				return m, showEmailList
+			}
+		case "q":
+			return m, showEmailList
+		case "e", "enter":
+			selectedItem, ok := m.list.SelectedItem().(contactItem)
+			if !ok {
+				return m, nil
+			}
+			m.editing = selectedItem.Address
+			m.nameInput.SetValue(selectedItem.Name)
+			m.addressInput.SetValue(selectedItem.Address)
+			m.addressInput.Blur()
+			return m, m.nameInput.Focus()
+		case "d":
+			selectedItem, ok := m.list.SelectedItem().(contactItem)
+			if !ok {
+				return m, nil
+			}
+			return m, m.deleteContact(selectedItem.Contact)
+		case "i":
+			m.importing = true
+			m.importInput.SetValue("")
+			return m, m.importInput.Focus()
+		}
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+// updateEditing handles keys while a contact is being edited, where tab switches between the name and the address.
+func (m *ContactListModel) updateEditing(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		m.editing = ""
+		return nil
+	case "tab", "shift+tab":
+		if m.nameInput.Focused() {
+			m.nameInput.Blur()
+			return m.addressInput.Focus()
+		}
+		m.addressInput.Blur()
+		return m.nameInput.Focus()
+	case "enter":
+		address, err := mail.ParseAddress(strings.TrimSpace(m.addressInput.Value()))
+		if err != nil {
+			return m.list.NewStatusMessage("Invalid address: " + err.Error())
+		}
+		previous := m.editing
+		m.editing = ""
+		return m.updateContact(previous, contacts.Contact{
+			Name:    strings.TrimSpace(m.nameInput.Value()),
+			Address: address.Address,
+		})
+	}
+
+	var cmd tea.Cmd
+	if m.nameInput.Focused() {
+		m.nameInput, cmd = m.nameInput.Update(msg)
+	} else {
+		m.addressInput, cmd = m.addressInput.Update(msg)
+	}
+	return cmd
+}
+
+// updateImporting handles keys while the path of a vCard file is being typed in.
+func (m *ContactListModel) updateImporting(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		m.importing = false
+		return nil
+	case "enter":
+		m.importing = false
+		return m.importContacts(m.importInput.Value())
+	}
+
+	var cmd tea.Cmd
+	m.importInput, cmd = m.importInput.Update(msg)
+	return cmd
+}
+
+func (m *ContactListModel) View() string {
+	if m.error != "" {
+		return "Error loading contacts: " + m.error
+	}
+
+	if m.editing != "" {
+		var b strings.Builder
+		b.WriteString(labelStyle.Render("Edit contact"))
+		b.WriteString("\n\n")
+		b.WriteString(labelStyle.Render("Name:"))
+		b.WriteString("\n")
+		b.WriteString(m.nameInput.View())
+		b.WriteString("\n\n")
+		b.WriteString(labelStyle.Render("Address:"))
+		b.WriteString("\n")
+		b.WriteString(m.addressInput.View())
+		b.WriteString("\n\n")
+		b.WriteString(blurredStyle.Render("Tab: Switch field • Enter: Save • Esc: Cancel"))
+		return b.String()
+	}
+
+	if m.importing {
+		var b strings.Builder
+		b.WriteString(labelStyle.Render("Import contacts from a vCard file"))
+		b.WriteString("\n\n")
+		b.WriteString(focusedStyle.Render(m.importInput.View()))
+		b.WriteString("\n\n")
+		b.WriteString(blurredStyle.Render("Enter: Import • Esc: Cancel"))
+		return b.String()
+	}
+
+	return m.list.View()
+}
+
+func (m *ContactListModel) loadContacts() tea.Cmd {
+	all := m.book.List()
+	items := make([]list.Item, 0, len(all))
+	for _, contact := range all {
+		items = append(items, contactItem{contact})
+	}
+	return m.list.SetItems(items)
+}
+
+func (m *ContactListModel) updateContact(previous string, contact contacts.Contact) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.book.Update(previous, contact); err != nil {
+			return contactsChangedMessage{error: fmt.Errorf("Failed to update contact: %w", err)}
+		}
+		return m.save("Updated " + contact.String())
+	}
+}
+
+func (m *ContactListModel) deleteContact(contact contacts.Contact) tea.Cmd {
+	return func() tea.Msg {
+		if err := m.book.Delete(contact.Address); err != nil {
+			return contactsChangedMessage{error: fmt.Errorf("Failed to delete contact: %w", err)}
+		}
+		return m.save("Deleted " + contact.String())
+	}
+}
+
+func (m *ContactListModel) importContacts(path string) tea.Cmd {
+	return func() tea.Msg {
+		path = strings.TrimSpace(path)
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = home + "/" + rest
+			}
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return contactsChangedMessage{error: fmt.Errorf("Failed to import contacts: %w", err)}
+		}
+		defer func() { _ = file.Close() }()
+
+		imported, err := m.book.Import(file)
+		if err != nil {
+			return contactsChangedMessage{error: fmt.Errorf("Failed to import contacts: %w", err)}
+		}
+		return m.save(fmt.Sprintf("Imported %d contacts", imported))
+	}
+}
+
+// save writes the address book to its file after it has been changed.
+func (m *ContactListModel) save(status string) contactsChangedMessage {
+	if err := m.book.Save(); err != nil {
+		return contactsChangedMessage{error: fmt.Errorf("Failed to save contacts: %w", err)}
+	}
+	return contactsChangedMessage{status: status}
+}
+
+func showEmailList() tea.Msg {
+	return ui.ShowEmailListMessage{}
+}
@@ -0,0 +1,104 @@
+package contact_list
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/contacts"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+func openContactList(t *testing.T) (*ContactListModel, *contacts.Book, string) {
+	path := filepath.Join(t.TempDir(), "contacts.json")
+	book, err := contacts.Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	book.Record("alice", "alice@example.com", time.Now())
+
+	model := NewContactListModel(book)
+	model.list.StatusMessageLifetime = time.Millisecond
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	model, _ = model.Update(ui.ShowContactListMessage{})
+	return model, book, path
+}
+
+func typeText(model *ContactListModel, text string) *ContactListModel {
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)})
+	return model
+}
+
+func TestContactListModel_EditContact(t *testing.T) {
+	model, _, path := openContactList(t)
+
+	model = typeText(model, "e")
+	model.nameInput.SetValue("Alice Smith")
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model, _ = model.Update(cmd())
+
+	items := model.list.Items()
+	if len(items) != 1 || items[0].(contactItem).Name != "Alice Smith" {
+		t.Errorf("Expected the contact to be renamed, got %+v", items)
+	}
+	reopened, _ := contacts.Open(path)
+	if got := reopened.List(); len(got) != 1 || got[0].Name != "Alice Smith" || !got[0].Edited {
+		t.Errorf("Expected the change to be saved, got %+v", got)
+	}
+}
+
+func TestContactListModel_EditRejectsInvalidAddress(t *testing.T) {
+	model, book, _ := openContactList(t)
+
+	model = typeText(model, "e")
+	model.addressInput.SetValue("not an address")
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if model.editing == "" {
+		t.Error("Expected to carry on editing")
+	}
+	if got := book.List(); got[0].Address != "alice@example.com" {
+		t.Errorf("Expected the contact to be unchanged, got %+v", got)
+	}
+}
+
+func TestContactListModel_DeleteContact(t *testing.T) {
+	model, book, _ := openContactList(t)
+
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	model, _ = model.Update(cmd())
+
+	if len(book.List()) != 0 || len(model.list.Items()) != 0 {
+		t.Error("Expected the contact to be deleted")
+	}
+}
+
+func TestContactListModel_ImportVCard(t *testing.T) {
+	model, book, _ := openContactList(t)
+	vcf := filepath.Join(t.TempDir(), "contacts.vcf")
+	if err := os.WriteFile(vcf, []byte("BEGIN:VCARD\nFN:Bob\nEMAIL:bob@example.com\nEND:VCARD\n"), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	model = typeText(model, "i")
+	model = typeText(model, vcf)
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model, _ = model.Update(cmd())
+
+	if len(book.List()) != 2 || len(model.list.Items()) != 2 {
+		t.Errorf("Expected Bob to be imported, got %+v", book.List())
+	}
+}
+
+func TestContactListModel_EscGoesBack(t *testing.T) {
+	model, _, _ := openContactList(t)
+
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+
+	if _, ok := cmd().(ui.ShowEmailListMessage); !ok {
+		t.Error("Expected esc to go back to the email list")
+	}
+}
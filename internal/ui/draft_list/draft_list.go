@@ -0,0 +1,172 @@
+package draft_list
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+type draftsLoadedMessage struct {
+	drafts []core.Draft
+	error  error
+}
+
+type draftDeletedMessage struct {
+	error error
+}
+
+// DraftListModel lists the saved drafts, so they can be reopened in the composer or deleted.
+type DraftListModel struct {
+	backend core.EmailBackend
+
+	loading bool
+	error   string
+
+	list list.Model
+}
+
+type draftItem struct {
+	core.Draft
+}
+
+func (i draftItem) Title() string {
+	if i.Subject == "" {
+		return "(no subject)"
+	}
+	return i.Subject
+}
+
+func (i draftItem) Description() string {
+	to := i.To
+	if to == "" {
+		to = "no recipients"
+	}
+	return fmt.Sprintf("To: %s • saved %s", to, i.SavedAt.Format("Mon, 2 Jan 15:04"))
+}
+
+func (i draftItem) FilterValue() string {
+	return i.Subject + " " + i.To
+}
+
+func NewDraftListModel(backend core.EmailBackend) *DraftListModel {
+	draftList := list.New([]list.Item{}, list.NewDefaultDelegate(), 0, 0)
+	draftList.Title = "Drafts"
+	draftList.SetStatusBarItemName("draft", "drafts")
+	draftList.StatusMessageLifetime = 3 * time.Second
+	draftList.DisableQuitKeybindings()
+	draftList.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{
+			key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "edit draft")),
+			key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "delete draft")),
+			key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back")),
+		}
+	}
+	// d is used for deleting drafts rather than paging
+	draftList.KeyMap.NextPage.SetKeys("right", "l", "pgdown", "f")
+
+	return &DraftListModel{
+		backend: backend,
+		list:    draftList,
+	}
+}
+
+func (m *DraftListModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *DraftListModel) Update(msg tea.Msg) (*DraftListModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ui.ShowDraftListMessage:
+		m.loading = true
+		m.error = ""
+		return m, m.loadDrafts()
+	case draftsLoadedMessage:
+		m.loading = false
+		if msg.error != nil {
+			m.error = msg.error.Error()
+			return m, nil
+		}
+		items := make([]list.Item, 0, len(msg.drafts))
+		for _, draft := range msg.drafts {
+			items = append(items, draftItem{draft})
+		}
+		return m, m.list.SetItems(items)
+	case draftDeletedMessage:
+		if msg.error != nil {
+			return m, m.list.NewStatusMessage("Failed to delete draft: " + msg.error.Error())
+		}
+		return m, tea.Batch(m.list.NewStatusMessage("Deleted draft"), m.loadDrafts())
+	case tea.WindowSizeMsg:
+		m.list.SetSize(msg.Width, msg.Height)
+	case tea.KeyMsg:
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+		switch msg.String() {
+		case "esc":
+			if m.list.FilterState() == list.Unfiltered {
+				return m, showEmailList
+			}
+		case "q":
+			return m, showEmailList
+		case "enter":
+			selectedItem, ok := m.list.SelectedItem().(draftItem)
+			if !ok {
+				return m, nil
+			}
+			return m, func() tea.Msg {
+				return ui.ShowEmailComposerMessage{Draft: &selectedItem.Draft}
+			}
+		case "d":
+			selectedItem, ok := m.list.SelectedItem().(draftItem)
+			if !ok {
+				return m, nil
+			}
+			return m, m.deleteDraft(selectedItem.Id)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m *DraftListModel) View() string {
+	if m.loading {
+		return "Loading drafts..."
+	}
+
+	if m.error != "" {
+		return "Error loading drafts: " + m.error
+	}
+
+	return m.list.View()
+}
+
+func (m *DraftListModel) loadDrafts() tea.Cmd {
+	return func() tea.Msg {
+		drafts, err := m.backend.ListDrafts()
+		return draftsLoadedMessage{
+			drafts: drafts,
+			error:  err,
+		}
+	}
+}
+
+func (m *DraftListModel) deleteDraft(id core.EmailId) tea.Cmd {
+	return func() tea.Msg {
+		return draftDeletedMessage{
+			error: m.backend.DeleteDraft(id),
+		}
+	}
+}
+
+func showEmailList() tea.Msg {
+	return ui.ShowEmailListMessage{}
+}
@@ -0,0 +1,53 @@
+package draft_list
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+type mockBackend struct {
+	core.EmailBackend
+
+	drafts []core.Draft
+}
+
+func (m *mockBackend) ListDrafts() ([]core.Draft, error) {
+	return m.drafts, nil
+}
+
+func TestDraftListModel_EnterOpensDraft(t *testing.T) {
+	backend := &mockBackend{drafts: []core.Draft{
+		{Id: "1", OutgoingEmail: core.OutgoingEmail{Subject: "Newest"}},
+		{Id: "2", OutgoingEmail: core.OutgoingEmail{Subject: "Oldest"}},
+	}}
+	model := NewDraftListModel(backend)
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+
+	model, cmd := model.Update(ui.ShowDraftListMessage{})
+	model, _ = model.Update(cmd())
+
+	if len(model.list.Items()) != 2 {
+		t.Fatalf("Expected 2 drafts, got %d", len(model.list.Items()))
+	}
+
+	_, cmd = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	msg, ok := cmd().(ui.ShowEmailComposerMessage)
+	if !ok || msg.Draft == nil || msg.Draft.Id != "1" {
+		t.Errorf("Expected the selected draft to open in the composer, got %+v", msg)
+	}
+}
+
+func TestDraftListModel_EscGoesBack(t *testing.T) {
+	model := NewDraftListModel(&mockBackend{})
+
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+
+	if _, ok := cmd().(ui.ShowEmailListMessage); !ok {
+		t.Error("Expected esc to go back to the email list")
+	}
+}
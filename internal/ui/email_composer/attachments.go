@@ -0,0 +1,163 @@
+package email_composer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/filepicker"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/message"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+// attachmentSizeLimit is the most that attachments can add up to before a warning is shown, since many servers reject
+// larger emails (Gmail's limit is 25 MB).
+const attachmentSizeLimit = 25 * 1000 * 1000
+
+type attachmentLoadedMessage struct {
+	generation int
+	attachment *core.Attachment
+	error      error
+}
+
+// openFilePicker shows the file picker, starting in the directory the last attachment came from.
+func (m *EmailComposerModel) openFilePicker() tea.Cmd {
+	if m.pickerDirectory == "" {
+		m.pickerDirectory, _ = os.UserHomeDir()
+	}
+
+	picker := filepicker.New()
+	picker.CurrentDirectory = m.pickerDirectory
+	picker.AutoHeight = false
+	picker.SetHeight(max(m.height-6, 5))
+	// esc closes the picker instead of going up a directory
+	picker.KeyMap.Back.SetKeys("h", "backspace", "left")
+
+	m.filePicker = picker
+	m.picking = true
+	return m.filePicker.Init()
+}
+
+// updateFilePicker handles keys while the file picker is showing, loading the file once one is chosen.
+func (m *EmailComposerModel) updateFilePicker(msg tea.KeyMsg) tea.Cmd {
+	if msg.String() == "esc" {
+		m.picking = false
+		return nil
+	}
+
+	var cmd tea.Cmd
+	m.filePicker, cmd = m.filePicker.Update(msg)
+	if selected, path := m.filePicker.DidSelectFile(msg); selected {
+		m.picking = false
+		m.pickerDirectory = filepath.Dir(path)
+		return m.loadAttachment(path)
+	}
+	return cmd
+}
+
+func (m *EmailComposerModel) loadAttachment(path string) tea.Cmd {
+	generation := m.generation
+	return func() tea.Msg {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return attachmentLoadedMessage{generation: generation, error: err}
+		}
+		filename := filepath.Base(path)
+		return attachmentLoadedMessage{
+			generation: generation,
+			attachment: &core.Attachment{
+				Filename:    filename,
+				ContentType: message.DetectContentType(filename, data),
+				Data:        data,
+			},
+		}
+	}
+}
+
+func (m *EmailComposerModel) handleAttachmentLoaded(msg attachmentLoadedMessage) {
+	if msg.error != nil {
+		m.status = "Failed to attach file: " + msg.error.Error()
+		return
+	}
+	m.draft.Attachments = append(m.draft.Attachments, *msg.attachment)
+	m.status = fmt.Sprintf("Attached %s (%s)", msg.attachment.Filename, ui.FormatSize(len(msg.attachment.Data)))
+	if m.attachmentsSize() > attachmentSizeLimit {
+		m.status += fmt.Sprintf(" — attachments are over %s, so the email might be rejected", ui.FormatSize(attachmentSizeLimit))
+	}
+}
+
+// updateAttachments handles keys while the attachments are focused, which are chosen with the arrow keys and removed
+// with delete or backspace.
+func (m *EmailComposerModel) updateAttachments(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "up", "k":
+		m.attachmentIndex = max(m.attachmentIndex-1, 0)
+	case "down", "j":
+		m.attachmentIndex = min(m.attachmentIndex+1, len(m.draft.Attachments)-1)
+	case "delete", "backspace", "d", "x":
+		if m.attachmentIndex >= len(m.draft.Attachments) {
+			return
+		}
+		removed := m.draft.Attachments[m.attachmentIndex]
+		m.draft.Attachments = slices.Delete(slices.Clone(m.draft.Attachments), m.attachmentIndex, m.attachmentIndex+1)
+		m.attachmentIndex = max(min(m.attachmentIndex, len(m.draft.Attachments)-1), 0)
+		m.status = "Removed " + removed.Filename
+		if len(m.draft.Attachments) == 0 {
+			m.handleNavigation("tab")
+		}
+	}
+}
+
+func (m *EmailComposerModel) attachmentsSize() int {
+	size := 0
+	for _, attachment := range m.draft.Attachments {
+		size += len(attachment.Data)
+	}
+	return size
+}
+
+// attachmentsSummary identifies the attachments, to tell whether they've changed since the draft was saved.
+func (m *EmailComposerModel) attachmentsSummary() string {
+	var b strings.Builder
+	for _, attachment := range m.draft.Attachments {
+		fmt.Fprintf(&b, "%s:%d\n", attachment.Filename, len(attachment.Data))
+	}
+	return b.String()
+}
+
+func (m *EmailComposerModel) attachmentsView() string {
+	var b strings.Builder
+	b.WriteString(labelStyle.Render("Attachments:"))
+	b.WriteString("\n")
+	for i, attachment := range m.draft.Attachments {
+		line := fmt.Sprintf("📎 %s (%s, %s)", attachment.Filename, attachment.ContentType, ui.FormatSize(len(attachment.Data)))
+		if m.focusIndex == attachmentsField && i == m.attachmentIndex {
+			b.WriteString(focusedStyle.Render("> " + line))
+		} else {
+			b.WriteString(blurredStyle.Render("  " + line))
+		}
+		b.WriteString("\n")
+	}
+	if size := m.attachmentsSize(); size > attachmentSizeLimit {
+		b.WriteString(focusedStyle.Render(fmt.Sprintf("⚠ Attachments add up to %s, over the %s many servers accept", ui.FormatSize(size), ui.FormatSize(attachmentSizeLimit))))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (m *EmailComposerModel) filePickerView() string {
+	var b strings.Builder
+	b.WriteString(labelStyle.Render("Attach a file"))
+	b.WriteString("\n")
+	b.WriteString(blurredStyle.Render(m.filePicker.CurrentDirectory))
+	b.WriteString("\n\n")
+	b.WriteString(m.filePicker.View())
+	b.WriteString("\n")
+	b.WriteString(blurredStyle.Render("Enter: Attach file or open directory • h: Up a directory • Esc: Cancel"))
+	return b.String()
+}
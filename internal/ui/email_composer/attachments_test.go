@@ -0,0 +1,110 @@
+package email_composer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+func TestEmailComposerModel_AttachFileWithPicker(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "report.pdf"), []byte("%PDF-1.4"), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	model := openComposer(&mockBackend{}, nil, ui.ShowEmailComposerMessage{})
+	model.pickerDirectory = dir
+	model.focusIndex = attachButton
+
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if !model.picking {
+		t.Fatal("Expected the file picker to open")
+	}
+	// the picker lists the directory, then enter chooses the only file in it
+	model, _ = model.Update(cmd())
+	model, cmd = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model, _ = model.Update(cmd())
+
+	if model.picking {
+		t.Error("Expected the file picker to close once a file was chosen")
+	}
+	if len(model.draft.Attachments) != 1 {
+		t.Fatalf("Expected 1 attachment, got %d", len(model.draft.Attachments))
+	}
+	attachment := model.draft.Attachments[0]
+	if attachment.Filename != "report.pdf" || attachment.ContentType != "application/pdf" || string(attachment.Data) != "%PDF-1.4" {
+		t.Errorf("Expected the file to be attached, got %+v", attachment)
+	}
+	if model.contents() == model.savedContents {
+		t.Error("Expected attaching a file to count as a change to the draft")
+	}
+}
+
+func TestEmailComposerModel_RemoveAttachment(t *testing.T) {
+	draft := &core.Draft{OutgoingEmail: core.OutgoingEmail{Attachments: []core.Attachment{
+		{Filename: "one.txt", Data: []byte("1")},
+		{Filename: "two.txt", Data: []byte("2")},
+	}}}
+	model := openComposer(&mockBackend{}, nil, ui.ShowEmailComposerMessage{Draft: draft})
+	model.focusIndex = bodyField
+	model.handleNavigation("tab")
+	if model.focusIndex != attachmentsField {
+		t.Fatalf("Expected the attachments to be focused, got %d", model.focusIndex)
+	}
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyDelete})
+
+	if len(model.draft.Attachments) != 1 || model.draft.Attachments[0].Filename != "one.txt" {
+		t.Errorf("Expected the second attachment to be removed, got %+v", model.draft.Attachments)
+	}
+	if len(draft.Attachments) != 2 {
+		t.Error("Expected the original draft not to be changed")
+	}
+}
+
+func TestEmailComposerModel_AttachmentsAreSkippedWhenThereAreNone(t *testing.T) {
+	model := openComposer(&mockBackend{}, nil, ui.ShowEmailComposerMessage{})
+	model.focusIndex = bodyField
+
+	model.handleNavigation("tab")
+
+	if model.focusIndex != submitButton {
+		t.Errorf("Expected the send button to be focused, got %d", model.focusIndex)
+	}
+}
+
+func TestEmailComposerModel_WarnsAboutMissingAttachment(t *testing.T) {
+	queue := newOutbox(t)
+	model := openComposer(&mockBackend{}, queue, ui.ShowEmailComposerMessage{})
+	model.toInput.SetValue("friend@example.com")
+	model.bodyInput.SetValue("I've attached the photos from the weekend.")
+
+	if cmd := model.sendEmail(time.Time{}); cmd != nil {
+		t.Fatal("Expected the email not to be sent straight away")
+	}
+	if !strings.Contains(model.status, "mentions an attachment") {
+		t.Errorf("Expected a warning about the missing attachment, got '%s'", model.status)
+	}
+
+	if cmd := model.sendEmail(time.Time{}); cmd == nil {
+		t.Error("Expected sending again to send the email anyway")
+	}
+}
+
+func TestEmailComposerModel_WarnsAboutLargeAttachments(t *testing.T) {
+	draft := &core.Draft{OutgoingEmail: core.OutgoingEmail{Attachments: []core.Attachment{
+		{Filename: "video.mp4", ContentType: "video/mp4", Data: make([]byte, attachmentSizeLimit+1)},
+	}}}
+	model := openComposer(&mockBackend{}, nil, ui.ShowEmailComposerMessage{Draft: draft})
+
+	if view := model.View(); !strings.Contains(view, "over the 25.0 MB") {
+		t.Errorf("Expected a warning about the size of the attachments, got:\n%s", view)
+	}
+}
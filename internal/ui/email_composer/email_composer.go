@@ -1,22 +1,43 @@
 package email_composer
 
 import (
+	"errors"
+	"fmt"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/charmbracelet/bubbles/filepicker"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/emersion/go-message/mail"
 
+	"github.com/bengesoff/mail-tui/internal/contacts"
 	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/identity"
+	"github.com/bengesoff/mail-tui/internal/message"
+	"github.com/bengesoff/mail-tui/internal/outbox"
+	"github.com/bengesoff/mail-tui/internal/pgp"
+	"github.com/bengesoff/mail-tui/internal/schedule"
+	"github.com/bengesoff/mail-tui/internal/smime"
+	"github.com/bengesoff/mail-tui/internal/templates"
 	"github.com/bengesoff/mail-tui/internal/ui"
+	"github.com/bengesoff/mail-tui/internal/undo"
 )
 
 const (
-	toField = iota
+	fromField = iota
+	toField
+	ccField
+	bccField
 	subjectField
 	bodyField
+	attachmentsField
 	submitButton
+	sendLaterButton
+	attachButton
 )
 
 var (
@@ -36,31 +57,132 @@ var (
 				Foreground(lipgloss.AdaptiveColor{Light: "#FFFFFF", Dark: "#000000"})
 )
 
-type emailSentMessage struct {
+// autosaveInterval is how often drafts are saved while they're being written.
+const autosaveInterval = 30 * time.Second
+
+// Options configures how emails are composed and sent.
+type Options struct {
+	// UndoSendDelay is how long emails wait in the outbox after pressing Send, during which sending can be undone
+	UndoSendDelay time.Duration
+	// Identities are who emails can be sent as, and the first one is used by default. If there aren't any, the backend
+	// fills in the sender.
+	Identities []identity.Identity
+	// Markdown starts new emails off written in Markdown
+	Markdown bool
+	// TemplateDir is where the templates for writing emails are loaded from
+	TemplateDir string
+	// Keyring signs and encrypts emails with OpenPGP, which can't be done if it's nil
+	Keyring *pgp.Keyring
+	// SMIME signs emails with the user's S/MIME certificate, which can't be done if it's nil or has no key
+	SMIME *smime.Config
+}
+
+type emailQueuedMessage struct {
+	generation int
+	entry      *outbox.Entry
+	// scheduled is set when the email was scheduled to be sent later, rather than sent straight away
+	scheduled bool
+	error     error
+}
+
+type autosaveMessage struct {
+	generation int
+}
+
+type draftSavedMessage struct {
+	generation int
+	draft      *core.Draft
+	// contents is what was saved, which might have changed since
+	contents draftContents
+	// close is set when the draft was saved because the composer is being closed
+	close bool
 	error error
 }
 
+// draftContents is what the user has typed, used to tell whether the draft has changed since it was saved.
+type draftContents struct {
+	from        string
+	to          string
+	cc          string
+	bcc         string
+	subject     string
+	body        string
+	markdown    bool
+	attachments string
+}
+
 type EmailComposerModel struct {
 	backend core.EmailBackend
-
-	sending bool
-	error   string
+	outbox  *outbox.Outbox
+	options Options
+	// replyTo is the email being replied to, if any
+	replyTo *core.Email
+	// draft holds everything about the email that isn't typed in, like its id once it has been saved
+	draft core.Draft
+	// savedContents is what was last saved, so drafts are only saved again when they change
+	savedContents draftContents
+	savingDraft   bool
+	// closing is set when esc was pressed while a draft was being saved, so the composer closes once it's saved
+	closing bool
+	// discard is set when saving the draft failed while closing, so pressing esc again closes without saving it
+	discard bool
+	// generation identifies the email being written, so autosaves and saves for previous emails are ignored
+	generation int
+	// queued is the outbox entry being edited, if the email failed to send
+	queued *outbox.Entry
+	// queueing is set while the email is being added to the outbox, so it isn't added twice
+	queueing bool
+	// scheduling is set while the time to send the email later is being typed in
+	scheduling bool
+	// picking is set while a file to attach is being chosen
+	picking bool
+	// pickerDirectory is where the file picker starts, which is wherever the last attachment came from
+	pickerDirectory string
+	// attachmentIndex is the attachment that's chosen while the attachments are focused
+	attachmentIndex int
+	// contacts suggests addresses while the To, Cc or Bcc field is being typed in, if there's an address book
+	contacts *contacts.Book
+	// suggestions are the contacts matching the address being typed, and suggestionIndex is the one that's chosen
+	suggestions     []contacts.Contact
+	suggestionIndex int
+	// previewing is set while the rendered Markdown in preview is shown instead of the body
+	previewing bool
+	preview    string
+	// choosingTemplate is set while a template is being chosen from templates, and templateIndex is the one that's chosen
+	choosingTemplate bool
+	templates        []templates.Template
+	templateIndex    int
+	// identityIndex is which of the identities the email is from
+	identityIndex int
+	// signature is the signature that was added to the body, so it can be swapped when the identity changes
+	signature string
+	// quote is the quoted email when replying, which the signature goes above
+	quote string
+	// attachmentWarned is set once the user has been warned that the email mentions an attachment but has none, so
+	// sending again sends it anyway
+	attachmentWarned bool
+
+	status string
 
 	focusIndex int
 
 	toInput   textinput.Model
+	ccInput   textinput.Model
+	bccInput  textinput.Model
 	subInput  textinput.Model
 	bodyInput textarea.Model
+	// sendAtInput is where the time to send the email later is typed in, like "tomorrow 9am"
+	sendAtInput textinput.Model
+	filePicker  filepicker.Model
 
 	width  int
 	height int
 }
 
-func NewEmailComposerModel(backend core.EmailBackend) *EmailComposerModel {
-	toInput := textinput.New()
-	toInput.Placeholder = "recipient@example.com"
-	toInput.CharLimit = 256
-	toInput.Width = 50
+func NewEmailComposerModel(backend core.EmailBackend, outbox *outbox.Outbox, contacts *contacts.Book, options Options) *EmailComposerModel {
+	toInput := newAddressInput("recipient@example.com")
+	ccInput := newAddressInput("copied@example.com")
+	bccInput := newAddressInput("hidden@example.com")
 
 	subInput := textinput.New()
 	subInput.Placeholder = "Email subject"
@@ -72,17 +194,36 @@ func NewEmailComposerModel(backend core.EmailBackend) *EmailComposerModel {
 	bodyInput.SetWidth(50)
 	bodyInput.SetHeight(10)
 
+	sendAtInput := textinput.New()
+	sendAtInput.Placeholder = "tomorrow 9am, fri 5pm, in 2h..."
+	sendAtInput.CharLimit = 64
+	sendAtInput.Width = 50
+
 	return &EmailComposerModel{
-		backend:    backend,
-		focusIndex: toField,
-		toInput:    toInput,
-		subInput:   subInput,
-		bodyInput:  bodyInput,
-		width:      80,
-		height:     24,
+		backend:     backend,
+		outbox:      outbox,
+		contacts:    contacts,
+		options:     options,
+		focusIndex:  toField,
+		toInput:     toInput,
+		ccInput:     ccInput,
+		bccInput:    bccInput,
+		subInput:    subInput,
+		bodyInput:   bodyInput,
+		sendAtInput: sendAtInput,
+		width:       80,
+		height:      24,
 	}
 }
 
+func newAddressInput(placeholder string) textinput.Model {
+	input := textinput.New()
+	input.Placeholder = placeholder
+	input.CharLimit = 256
+	input.Width = 50
+	return input
+}
+
 func (m *EmailComposerModel) Init() tea.Cmd {
 	return tea.Batch(
 		textinput.Blink,
@@ -95,52 +236,223 @@ func (m *EmailComposerModel) Update(msg tea.Msg) (*EmailComposerModel, tea.Cmd)
 
 	switch msg := msg.(type) {
 	case ui.ShowEmailComposerMessage:
+		m.generation++
+		m.replyTo = msg.ReplyTo
+		m.draft = core.Draft{}
+		m.savingDraft = false
+		m.closing = false
+		m.discard = false
+		m.queued = msg.Queued
+		m.queueing = false
+		m.scheduling = false
+		m.sendAtInput.SetValue("")
+		m.picking = false
+		m.attachmentIndex = 0
+		m.attachmentWarned = false
+		m.suggestions = nil
+		m.choosingTemplate = false
+		m.previewing = false
+		m.draft.Markdown = m.options.Markdown
+		m.signature = ""
+		m.quote = ""
+		m.status = ""
 		m.toInput.SetValue("")
+		m.ccInput.SetValue("")
+		m.bccInput.SetValue("")
 		m.subInput.SetValue("")
 		m.bodyInput.SetValue("")
 		m.focusIndex = toField
-		cmd := m.updateFieldFocus()
-		return m, cmd
+		if msg.ReplyTo != nil {
+			m.toInput.SetValue(msg.ReplyTo.From)
+			m.subInput.SetValue(replySubject(msg.ReplyTo.Subject))
+			m.quote = quoteReply(msg.ReplyTo)
+			m.bodyInput.SetValue(m.quote)
+			moveToTop(&m.bodyInput)
+			m.focusIndex = bodyField
+			m.draft.InReplyTo = msg.ReplyTo.MessageId
+			m.draft.References = replyReferences(msg.ReplyTo)
+			if list := msg.ReplyTo.List; msg.ReplyToList && list != nil && list.Post != "" {
+				m.toInput.SetValue(list.Post)
+			}
+			if msg.ReplyTo.Encrypted && m.options.Keyring != nil {
+				// the reply quotes the email, so it's kept as private as the email was
+				m.draft.OpenPGP = core.Protection{Sign: true, Encrypt: true}
+			}
+		}
+		if msg.Draft != nil {
+			m.draft = *msg.Draft
+			m.toInput.SetValue(msg.Draft.To)
+			m.ccInput.SetValue(msg.Draft.Cc)
+			m.bccInput.SetValue(msg.Draft.Bcc)
+			m.subInput.SetValue(msg.Draft.Subject)
+			m.bodyInput.SetValue(msg.Draft.Body)
+			m.status = "Editing draft saved " + msg.Draft.SavedAt.Format("Mon, 2 Jan 15:04")
+		}
+		if msg.Queued != nil {
+			m.draft.OutgoingEmail = msg.Queued.Email
+			m.toInput.SetValue(msg.Queued.Email.To)
+			m.ccInput.SetValue(msg.Queued.Email.Cc)
+			m.bccInput.SetValue(msg.Queued.Email.Bcc)
+			m.subInput.SetValue(msg.Queued.Email.Subject)
+			m.bodyInput.SetValue(msg.Queued.Email.Body)
+			m.status = "Failed to send: " + msg.Queued.LastError
+		}
+		if msg.Compose != nil {
+			m.draft.OutgoingEmail = *msg.Compose
+			m.toInput.SetValue(msg.Compose.To)
+			m.ccInput.SetValue(msg.Compose.Cc)
+			m.bccInput.SetValue(msg.Compose.Bcc)
+			m.subInput.SetValue(msg.Compose.Subject)
+			m.bodyInput.SetValue(msg.Compose.Body)
+			m.focusIndex = bodyField
+		}
+		switch {
+		case msg.Draft != nil:
+			m.chooseIdentity(nil, msg.Draft.From)
+		case msg.Queued != nil:
+			m.chooseIdentity(nil, msg.Queued.Email.From)
+		case msg.Compose != nil:
+			// the email is sent as it was asked for, without a signature
+			m.chooseIdentity(nil, msg.Compose.From)
+		default:
+			m.chooseIdentity(msg.ReplyTo, "")
+			if current, ok := m.currentIdentity(); ok {
+				m.insertSignature(current.Signature)
+			}
+		}
+		// whatever is filled in to start with counts as saved, so closing without typing anything doesn't save a draft
+		m.savedContents = m.contents()
+		return m, tea.Batch(m.updateFieldFocus(), m.scheduleAutosave())
 
-	case emailSentMessage:
-		m.sending = false
+	case emailQueuedMessage:
+		if msg.generation != m.generation {
+			return m, nil
+		}
+		m.queueing = false
 		if msg.error != nil {
-			m.error = msg.error.Error()
-		} else {
-			m.error = ""
-			return m, func() tea.Msg {
-				return ui.ShowEmailListMessage{}
-			}
+			// the email stays in the composer, so it isn't lost and sending can be tried again
+			m.status = "Failed to queue email: " + msg.error.Error()
+			return m, nil
+		}
+		return m, m.handleQueued(msg)
+
+	case autosaveMessage:
+		if msg.generation != m.generation {
+			return m, nil
 		}
+		return m, tea.Batch(m.saveDraft(false), m.scheduleAutosave())
+
+	case draftSavedMessage:
+		if msg.generation != m.generation {
+			return m, nil
+		}
+		return m, m.handleDraftSaved(msg)
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
 		m.updateSizes()
 
+	case templatesLoadedMessage:
+		if msg.generation != m.generation {
+			return m, nil
+		}
+		m.handleTemplatesLoaded(msg)
+		return m, nil
+
+	case attachmentLoadedMessage:
+		if msg.generation != m.generation {
+			return m, nil
+		}
+		m.handleAttachmentLoaded(msg)
+		return m, nil
+
 	case tea.KeyMsg:
+		if m.picking {
+			return m, m.updateFilePicker(msg)
+		}
+		if m.scheduling {
+			return m, m.updateSchedule(msg)
+		}
+		if m.choosingTemplate {
+			return m, m.updateTemplates(msg)
+		}
 		switch msg.String() {
-		case "esc":
-			return m, func() tea.Msg {
-				return ui.ShowEmailListMessage{}
+		case "ctrl+t":
+			return m, m.openTemplates()
+		case "ctrl+k":
+			m.toggleMarkdown()
+			return m, nil
+		case "ctrl+r":
+			m.togglePreview()
+			return m, nil
+		case "ctrl+s":
+			m.toggleSign()
+			return m, nil
+		case "ctrl+x":
+			m.toggleEncrypt()
+			return m, nil
+		}
+		if m.previewing {
+			// the body can't be edited while it's being previewed
+			if msg.String() == "esc" {
+				m.previewing = false
 			}
+			return m, nil
+		}
+		if m.focusIndex == attachmentsField && msg.String() != "esc" && msg.String() != "tab" && msg.String() != "shift+tab" {
+			m.updateAttachments(msg)
+			return m, nil
+		}
+		if m.focusIndex == fromField && msg.String() != "esc" && msg.String() != "tab" && msg.String() != "shift+tab" {
+			m.updateFrom(msg)
+			return m, nil
+		}
+		if m.addressInput() != nil && len(m.suggestions) > 0 {
+			if msg.String() == "esc" {
+				// esc hides the suggestions before it closes the composer
+				m.suggestions = nil
+				return m, nil
+			}
+			if m.updateSuggestionKeys(msg) {
+				return m, nil
+			}
+		}
+		switch msg.String() {
+		case "esc":
+			return m, m.cancel()
 
 		case "tab", "shift+tab":
 			return m, m.handleNavigation(msg.String())
 
 		case "enter":
-			if m.focusIndex == submitButton {
-				m.sending = true
-				commands = append(commands, m.sendEmail())
+			switch m.focusIndex {
+			case submitButton:
+				commands = append(commands, m.sendEmail(time.Time{}))
+			case sendLaterButton:
+				m.scheduling = true
+				m.sendAtInput.SetValue("")
+				commands = append(commands, m.sendAtInput.Focus())
+			case attachButton:
+				commands = append(commands, m.openFilePicker())
 			}
 		}
 	}
 
 	var cmd tea.Cmd
+	if m.picking {
+		// the picker reads directories in the background, so it needs the results
+		m.filePicker, cmd = m.filePicker.Update(msg)
+		commands = append(commands, cmd)
+	}
 	switch m.focusIndex {
-	case toField:
-		m.toInput, cmd = m.toInput.Update(msg)
+	case toField, ccField, bccField:
+		input := m.addressInput()
+		*input, cmd = input.Update(msg)
 		commands = append(commands, cmd)
+		if _, ok := msg.(tea.KeyMsg); ok {
+			m.updateSuggestions()
+		}
 	case subjectField:
 		m.subInput, cmd = m.subInput.Update(msg)
 		commands = append(commands, cmd)
@@ -153,61 +465,62 @@ func (m *EmailComposerModel) Update(msg tea.Msg) (*EmailComposerModel, tea.Cmd)
 }
 
 func (m *EmailComposerModel) handleNavigation(key string) tea.Cmd {
-	switch key {
-	case "shift+tab":
-		m.focusIndex--
-		if m.focusIndex < 0 {
-			m.focusIndex = submitButton
+	// the suggestions are for the field that's being left
+	m.suggestions = nil
+	for {
+		switch key {
+		case "shift+tab":
+			m.focusIndex--
+			if m.focusIndex < 0 {
+				m.focusIndex = attachButton
+			}
+		case "tab":
+			m.focusIndex++
+			if m.focusIndex > attachButton {
+				m.focusIndex = fromField
+			}
 		}
-	case "tab":
-		m.focusIndex++
-		if m.focusIndex > submitButton {
-			m.focusIndex = toField
+		if m.canFocus(m.focusIndex) {
+			break
 		}
 	}
 
 	return m.updateFieldFocus()
 }
 
+// canFocus reports whether a field can be focused. The attachments can only be focused when there are some, and the
+// From field when there's more than one identity to choose between.
+func (m *EmailComposerModel) canFocus(field int) bool {
+	switch field {
+	case attachmentsField:
+		return len(m.draft.Attachments) > 0
+	case fromField:
+		return len(m.options.Identities) > 1
+	default:
+		return true
+	}
+}
+
 func (m *EmailComposerModel) updateFieldFocus() tea.Cmd {
 	var cmds []tea.Cmd
 
-	switch m.focusIndex {
-	case toField:
-		cmds = append(cmds, m.toInput.Focus())
-		m.subInput.Blur()
-		m.bodyInput.Blur()
-		m.toInput.PromptStyle = focusedStyle
-		m.toInput.TextStyle = focusedStyle
-		m.subInput.PromptStyle = blurredStyle
-		m.subInput.TextStyle = blurredStyle
-
-	case subjectField:
-		cmds = append(cmds, m.subInput.Focus())
-		m.toInput.Blur()
-		m.bodyInput.Blur()
-		m.toInput.PromptStyle = blurredStyle
-		m.toInput.TextStyle = blurredStyle
-		m.subInput.PromptStyle = focusedStyle
-		m.subInput.TextStyle = focusedStyle
+	inputs := map[int]*textinput.Model{toField: &m.toInput, ccField: &m.ccInput, bccField: &m.bccInput, subjectField: &m.subInput}
+	for field, input := range inputs {
+		if field == m.focusIndex {
+			cmds = append(cmds, input.Focus())
+			input.PromptStyle = focusedStyle
+			input.TextStyle = focusedStyle
+		} else {
+			input.Blur()
+			input.PromptStyle = blurredStyle
+			input.TextStyle = blurredStyle
+		}
+	}
 
-	case bodyField:
+	if m.focusIndex == bodyField {
 		cmds = append(cmds, m.bodyInput.Focus())
-		m.toInput.Blur()
-		m.subInput.Blur()
-		m.toInput.PromptStyle = blurredStyle
-		m.toInput.TextStyle = blurredStyle
-		m.subInput.PromptStyle = blurredStyle
-		m.subInput.TextStyle = blurredStyle
-
-	case submitButton:
-		m.toInput.Blur()
-		m.subInput.Blur()
+	} else {
 		m.bodyInput.Blur()
-		m.toInput.PromptStyle = blurredStyle
-		m.toInput.TextStyle = blurredStyle
-		m.subInput.PromptStyle = blurredStyle
-		m.subInput.TextStyle = blurredStyle
 	}
 
 	return tea.Batch(cmds...)
@@ -215,9 +528,14 @@ func (m *EmailComposerModel) updateFieldFocus() tea.Cmd {
 
 func (m *EmailComposerModel) updateSizes() {
 	m.toInput.Width = m.width
+	m.ccInput.Width = m.width
+	m.bccInput.Width = m.width
 	m.subInput.Width = m.width
 
-	usedHeight := 15 // height used by title and other fields
+	usedHeight := 21 // height used by title and other fields
+	if len(m.options.Identities) > 0 {
+		usedHeight += 3
+	}
 	bodyHeight := max(m.height-usedHeight, 5)
 
 	m.bodyInput.SetWidth(m.width)
@@ -225,12 +543,8 @@ func (m *EmailComposerModel) updateSizes() {
 }
 
 func (m *EmailComposerModel) View() string {
-	if m.sending {
-		return "Email sending..."
-	}
-
-	if m.error != "" {
-		return "Error sending email: " + m.error
+	if m.picking {
+		return m.filePickerView()
 	}
 
 	var b strings.Builder
@@ -238,42 +552,391 @@ func (m *EmailComposerModel) View() string {
 	b.WriteString(labelStyle.Render("Compose Email"))
 	b.WriteString("\n\n")
 
-	b.WriteString(labelStyle.Render("To:"))
-	b.WriteString("\n")
-	b.WriteString(m.toInput.View())
-	b.WriteString("\n\n")
+	if len(m.options.Identities) > 0 {
+		b.WriteString(labelStyle.Render("From:"))
+		b.WriteString("\n")
+		b.WriteString(m.fromView())
+		b.WriteString("\n\n")
+	}
+
+	b.WriteString(m.addressView("To:", toField, m.toInput))
+	b.WriteString(m.addressView("Cc:", ccField, m.ccInput))
+	b.WriteString(m.addressView("Bcc:", bccField, m.bccInput))
 
 	b.WriteString(labelStyle.Render("Subject:"))
 	b.WriteString("\n")
 	b.WriteString(m.subInput.View())
 	b.WriteString("\n\n")
 
-	b.WriteString(labelStyle.Render("Body:"))
-	b.WriteString("\n")
-	b.WriteString(m.bodyInput.View())
+	switch {
+	case m.previewing:
+		b.WriteString(labelStyle.Render("Body (preview):"))
+		b.WriteString("\n")
+		b.WriteString(m.previewView())
+	case m.draft.Markdown:
+		b.WriteString(labelStyle.Render("Body (Markdown):"))
+		b.WriteString("\n")
+		b.WriteString(m.bodyInput.View())
+	default:
+		b.WriteString(labelStyle.Render("Body:"))
+		b.WriteString("\n")
+		b.WriteString(m.bodyInput.View())
+	}
 	b.WriteString("\n\n")
 
-	if m.focusIndex == submitButton {
-		b.WriteString(focusedButtonStyle.Render("Send"))
-	} else {
-		b.WriteString(buttonStyle.Render("Send"))
+	if len(m.draft.Attachments) > 0 {
+		b.WriteString(m.attachmentsView())
+		b.WriteString("\n")
 	}
+
+	b.WriteString(m.protectionView())
+
+	b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top,
+		m.renderButton("Send", submitButton),
+		" ",
+		m.renderButton("Send later", sendLaterButton),
+		" ",
+		m.renderButton("Attach", attachButton),
+	))
 	b.WriteString("\n\n")
 
-	b.WriteString(blurredStyle.Render("Tab/Shift+Tab: Navigate • Enter: Send • Esc: Cancel"))
+	if m.choosingTemplate && len(m.templates) > 0 {
+		b.WriteString(m.templatesView())
+	}
+
+	if m.scheduling {
+		b.WriteString(labelStyle.Render("Send at:"))
+		b.WriteString("\n")
+		b.WriteString(m.sendAtInput.View())
+		b.WriteString("\n")
+		b.WriteString(blurredStyle.Render("Enter: Schedule • Esc: Cancel"))
+		b.WriteString("\n\n")
+	}
+
+	help := "Tab/Shift+Tab: Navigate • Enter: Send • Ctrl+T: Template • Ctrl+K: Markdown • Ctrl+R: Preview • "
+	if m.options.Keyring != nil {
+		help += "Ctrl+S: Sign • Ctrl+X: Encrypt • "
+	} else if m.options.SMIME.HasKey() {
+		help += "Ctrl+S: Sign • "
+	}
+	b.WriteString(blurredStyle.Render(help + "Esc: Save draft and close"))
+	if m.status != "" {
+		b.WriteString("\n")
+		b.WriteString(blurredStyle.Render(m.status))
+	}
+
+	return b.String()
+}
 
+// addressView renders an address field, with the suggestions below it while it's being typed in.
+func (m *EmailComposerModel) addressView(label string, field int, input textinput.Model) string {
+	var b strings.Builder
+	b.WriteString(labelStyle.Render(label))
+	b.WriteString("\n")
+	b.WriteString(input.View())
+	b.WriteString("\n")
+	if m.focusIndex == field {
+		b.WriteString(m.suggestionsView())
+	}
+	b.WriteString("\n")
 	return b.String()
 }
 
-func (m *EmailComposerModel) sendEmail() tea.Cmd {
+func (m *EmailComposerModel) renderButton(label string, index int) string {
+	if m.focusIndex == index {
+		return focusedButtonStyle.Render(label)
+	}
+	return buttonStyle.Render(label)
+}
+
+// updateSchedule handles typing in the time to send the email later.
+func (m *EmailComposerModel) updateSchedule(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		m.scheduling = false
+		m.sendAtInput.Blur()
+		return nil
+	case "enter":
+		sendAt, err := schedule.Parse(m.sendAtInput.Value(), time.Now())
+		if err != nil {
+			m.status = "Can't schedule the email: " + err.Error()
+			return nil
+		}
+		cmd := m.sendEmail(sendAt)
+		if cmd != nil {
+			m.scheduling = false
+			m.sendAtInput.Blur()
+		}
+		return cmd
+	}
+
+	var cmd tea.Cmd
+	m.sendAtInput, cmd = m.sendAtInput.Update(msg)
+	return cmd
+}
+
+// sendEmail adds the email to the outbox, which sends it in the background at sendAt.
+// If sendAt is zero, it's sent once the delay for undoing it has passed.
+func (m *EmailComposerModel) sendEmail(sendAt time.Time) tea.Cmd {
+	if m.queueing {
+		return nil
+	}
+	if err := checkRecipients(m.toInput.Value(), m.ccInput.Value(), m.bccInput.Value()); err != nil {
+		m.status = "Can't send the email: " + err.Error()
+		return nil
+	}
+	if err := m.checkProtection(); err != nil {
+		m.status = "Can't send the email: " + err.Error()
+		return nil
+	}
+	if len(m.draft.Attachments) == 0 && message.MentionsAttachment(m.bodyInput.Value()) && !m.attachmentWarned {
+		m.attachmentWarned = true
+		m.status = "The email mentions an attachment, but nothing is attached — send again to send it anyway"
+		return nil
+	}
+	m.queueing = true
+	m.status = "Queueing email..."
+
+	scheduled := !sendAt.IsZero()
+	if !scheduled {
+		sendAt = time.Now().Add(m.options.UndoSendDelay)
+	}
+
+	draft := m.currentDraft()
+	queued := m.queued
+	generation := m.generation
+	var replyTo core.EmailId
+	if m.replyTo != nil {
+		replyTo = m.replyTo.Id
+	}
+
 	return func() tea.Msg {
-		err := m.backend.SendEmail(core.OutgoingEmail{
-			To:      m.toInput.Value(),
-			Subject: m.subInput.Value(),
-			Body:    m.bodyInput.Value(),
-		})
-		return emailSentMessage{
-			error: err,
+		var (
+			entry *outbox.Entry
+			err   error
+		)
+		if queued != nil {
+			edited := *queued
+			entry = &edited
+			entry.Email = draft.OutgoingEmail
+			entry.SendAt = sendAt
+			err = m.outbox.Requeue(*entry)
+		} else {
+			entry, err = m.outbox.Enqueue(draft.OutgoingEmail, replyTo, sendAt)
+		}
+		if err != nil {
+			return emailQueuedMessage{generation: generation, error: err}
+		}
+		// the email is safely in the outbox, so failing to tidy up the draft isn't worth reporting
+		if draft.Id != "" {
+			_ = m.backend.DeleteDraft(draft.Id)
 		}
+		return emailQueuedMessage{generation: generation, entry: entry, scheduled: scheduled}
+	}
+}
+
+// handleQueued closes the composer once the email is in the outbox, and records it so sending it can be undone until it
+// has gone.
+func (m *EmailComposerModel) handleQueued(msg emailQueuedMessage) tea.Cmd {
+	subject := msg.entry.Email.Subject
+	if subject == "" {
+		subject = "(no subject)"
+	}
+
+	var description string
+	switch {
+	case msg.scheduled:
+		description = fmt.Sprintf("Scheduled %q for %s", subject, msg.entry.SendAt.Format("Mon, 2 Jan 15:04"))
+	case m.options.UndoSendDelay > 0:
+		description = fmt.Sprintf("Sending %q in %s", subject, m.options.UndoSendDelay)
+	default:
+		description = fmt.Sprintf("Sending %q", subject)
+	}
+
+	operation := undo.Send{
+		Description: description,
+		Outbox:      m.outbox,
+		Id:          msg.entry.Id,
+	}
+	return tea.Batch(
+		m.close(description+" — u to undo"),
+		func() tea.Msg {
+			return ui.RecordUndoMessage{Operation: operation}
+		},
+	)
+}
+
+// checkRecipients catches mistakes in the To, Cc and Bcc recipients before the email is queued, rather than when it
+// fails to send. The email only needs recipients in one of them.
+func checkRecipients(lists ...string) error {
+	empty := true
+	for _, list := range lists {
+		if strings.TrimSpace(list) == "" {
+			continue
+		}
+		empty = false
+		if _, err := mail.ParseAddressList(list); err != nil {
+			return fmt.Errorf("invalid recipients: %w", err)
+		}
+	}
+	if empty {
+		return errors.New("it has no recipients")
+	}
+	return nil
+}
+
+// currentDraft combines what has been typed with the rest of the draft.
+func (m *EmailComposerModel) currentDraft() core.Draft {
+	draft := m.draft
+	if current, ok := m.currentIdentity(); ok {
+		draft.From = current.From()
+		draft.ReplyTo = current.ReplyTo
+	}
+	draft.To = m.toInput.Value()
+	draft.Cc = m.ccInput.Value()
+	draft.Bcc = m.bccInput.Value()
+	draft.Subject = m.subInput.Value()
+	draft.Body = m.bodyInput.Value()
+	return draft
+}
+
+func (m *EmailComposerModel) contents() draftContents {
+	var from string
+	if current, ok := m.currentIdentity(); ok {
+		from = current.From()
+	}
+	return draftContents{
+		from:        from,
+		to:          m.toInput.Value(),
+		cc:          m.ccInput.Value(),
+		bcc:         m.bccInput.Value(),
+		subject:     m.subInput.Value(),
+		body:        m.bodyInput.Value(),
+		markdown:    m.draft.Markdown,
+		attachments: m.attachmentsSummary(),
+	}
+}
+
+func (m *EmailComposerModel) scheduleAutosave() tea.Cmd {
+	generation := m.generation
+	return tea.Tick(autosaveInterval, func(time.Time) tea.Msg {
+		return autosaveMessage{generation: generation}
+	})
+}
+
+// saveDraft saves the draft if it has changed since it was last saved.
+// When closing, the composer closes once the draft has been saved.
+func (m *EmailComposerModel) saveDraft(close bool) tea.Cmd {
+	contents := m.contents()
+	if m.savingDraft || contents == m.savedContents {
+		return nil
+	}
+	m.savingDraft = true
+
+	draft := m.currentDraft()
+	generation := m.generation
+	return func() tea.Msg {
+		saved, err := m.backend.SaveDraft(draft)
+		return draftSavedMessage{
+			generation: generation,
+			draft:      saved,
+			contents:   contents,
+			close:      close,
+			error:      err,
+		}
+	}
+}
+
+func (m *EmailComposerModel) handleDraftSaved(msg draftSavedMessage) tea.Cmd {
+	m.savingDraft = false
+	closing := msg.close || m.closing
+	m.closing = false
+
+	if msg.error != nil {
+		m.status = "Failed to save draft: " + msg.error.Error()
+		if closing {
+			m.discard = true
+			m.status += " - press esc again to discard it"
+		}
+		return nil
+	}
+
+	m.draft.Id = msg.draft.Id
+	m.draft.From = msg.draft.From
+	m.draft.MessageId = msg.draft.MessageId
+	m.savedContents = msg.contents
+	m.status = "Draft saved at " + msg.draft.SavedAt.Format("15:04")
+
+	if closing {
+		return m.cancel()
+	}
+	return nil
+}
+
+// cancel saves the draft and closes the composer once it has been saved.
+// If the draft hasn't changed, or saving it failed and esc was pressed again, the composer closes straight away.
+func (m *EmailComposerModel) cancel() tea.Cmd {
+	if m.discard || m.contents() == m.savedContents {
+		return m.close("")
+	}
+	if m.savingDraft {
+		m.closing = true
+		m.status = "Saving draft..."
+		return nil
+	}
+	m.status = "Saving draft..."
+	return m.saveDraft(true)
+}
+
+// close goes back to the email list, stopping any autosaves for the email that was being written.
+func (m *EmailComposerModel) close(status string) tea.Cmd {
+	m.generation++
+	m.discard = false
+	return func() tea.Msg {
+		return ui.ShowEmailListMessage{Status: status}
+	}
+}
+
+// replySubject adds a "Re:" prefix to the subject, unless it already has one.
+func replySubject(subject string) string {
+	if strings.HasPrefix(strings.ToLower(subject), "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
+
+// quoteReply quotes the original email below an attribution line, leaving space above it for the reply.
+func quoteReply(email *core.Email) string {
+	var b strings.Builder
+	b.WriteString("\n\n")
+	b.WriteString(fmt.Sprintf("On %s, %s wrote:\n", email.SentAt.Format("Mon, 2 Jan 2006 at 15:04"), email.From))
+	for _, line := range strings.Split(strings.TrimRight(email.Body, "\n"), "\n") {
+		if strings.HasPrefix(line, ">") {
+			b.WriteString(">" + line + "\n")
+		} else {
+			b.WriteString("> " + line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// moveToTop moves the cursor to the start of the text, which is where replies and new emails are written.
+func moveToTop(input *textarea.Model) {
+	for input.Line() > 0 {
+		input.CursorUp()
+	}
+	input.CursorStart()
+}
+
+// replyReferences builds the References header for a reply, which is the original's references followed by the
+// original itself.
+func replyReferences(email *core.Email) []string {
+	references := slices.Clone(email.References)
+	if len(references) == 0 && len(email.InReplyTo) > 0 {
+		references = append(references, email.InReplyTo[0])
+	}
+	if email.MessageId != "" {
+		references = append(references, email.MessageId)
 	}
+	return references
 }
@@ -0,0 +1,365 @@
+package email_composer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/outbox"
+	"github.com/bengesoff/mail-tui/internal/schedule"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+// mockBackend records the drafts that are saved and deleted. Any other calls panic.
+type mockBackend struct {
+	core.EmailBackend
+
+	saveErr error
+	saved   []core.Draft
+	deleted []core.EmailId
+	sent    []core.OutgoingEmail
+}
+
+func (m *mockBackend) SaveDraft(draft core.Draft) (*core.Draft, error) {
+	if m.saveErr != nil {
+		return nil, m.saveErr
+	}
+	m.saved = append(m.saved, draft)
+	draft.Id = core.EmailId(string(rune('0' + len(m.saved))))
+	draft.SavedAt = time.Now()
+	return &draft, nil
+}
+
+func (m *mockBackend) DeleteDraft(id core.EmailId) error {
+	m.deleted = append(m.deleted, id)
+	return nil
+}
+
+func (m *mockBackend) SendEmail(email core.OutgoingEmail) error {
+	m.sent = append(m.sent, email)
+	return nil
+}
+
+// findMessage runs a command and returns the message of type T it produces, looking inside batches.
+func findMessage[T tea.Msg](t *testing.T, cmd tea.Cmd) T {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("Expected a command")
+	}
+	msg := cmd()
+	if found, ok := msg.(T); ok {
+		return found
+	}
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		for _, cmd := range batch {
+			if cmd == nil {
+				continue
+			}
+			if found, ok := cmd().(T); ok {
+				return found
+			}
+		}
+	}
+	var zero T
+	t.Fatalf("Expected a %T message", zero)
+	return zero
+}
+
+func newOutbox(t *testing.T) *outbox.Outbox {
+	queue, err := outbox.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return queue
+}
+
+func openComposer(backend *mockBackend, queue *outbox.Outbox, msg ui.ShowEmailComposerMessage) *EmailComposerModel {
+	model := NewEmailComposerModel(backend, queue, nil, Options{})
+	model, _ = model.Update(msg)
+	return model
+}
+
+func typeText(model *EmailComposerModel, text string) *EmailComposerModel {
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)})
+	return model
+}
+
+func TestEmailComposerModel_EscClosesUnchangedEmail(t *testing.T) {
+	backend := &mockBackend{}
+	model := openComposer(backend, nil, ui.ShowEmailComposerMessage{})
+
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+
+	if _, ok := cmd().(ui.ShowEmailListMessage); !ok {
+		t.Error("Expected the composer to close straight away")
+	}
+	if len(backend.saved) != 0 {
+		t.Errorf("Expected no draft to be saved, got %d", len(backend.saved))
+	}
+}
+
+func TestEmailComposerModel_EscSavesDraft(t *testing.T) {
+	backend := &mockBackend{}
+	model := openComposer(backend, nil, ui.ShowEmailComposerMessage{})
+	model = typeText(model, "friend@example.com")
+
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	msg, ok := cmd().(draftSavedMessage)
+	if !ok {
+		t.Fatal("Expected the draft to be saved")
+	}
+	if len(backend.saved) != 1 || backend.saved[0].To != "friend@example.com" {
+		t.Errorf("Expected the typed recipient to be saved, got %+v", backend.saved)
+	}
+
+	_, cmd = model.Update(msg)
+
+	if _, ok := cmd().(ui.ShowEmailListMessage); !ok {
+		t.Error("Expected the composer to close once the draft was saved")
+	}
+}
+
+func TestEmailComposerModel_AutosaveReplacesDraft(t *testing.T) {
+	backend := &mockBackend{}
+	model := openComposer(backend, nil, ui.ShowEmailComposerMessage{})
+	model = typeText(model, "a")
+
+	model, cmd := model.Update(autosaveMessage{generation: model.generation})
+	model, _ = model.Update(cmd().(tea.BatchMsg)[0]())
+
+	if model.draft.Id != "1" {
+		t.Fatalf("Expected the draft id to be kept, got '%s'", model.draft.Id)
+	}
+
+	// nothing has changed, so there's nothing to save
+	if cmd := model.saveDraft(false); cmd != nil {
+		t.Error("Expected an unchanged draft not to be saved again")
+	}
+
+	model = typeText(model, "b")
+	model, _ = model.Update(model.saveDraft(false)())
+
+	if backend.saved[1].Id != "1" {
+		t.Errorf("Expected the second save to replace the first draft, got id '%s'", backend.saved[1].Id)
+	}
+}
+
+func TestEmailComposerModel_AutosaveForPreviousEmailIsIgnored(t *testing.T) {
+	model := openComposer(&mockBackend{}, nil, ui.ShowEmailComposerMessage{})
+	generation := model.generation
+	model, _ = model.Update(ui.ShowEmailComposerMessage{})
+
+	_, cmd := model.Update(autosaveMessage{generation: generation})
+
+	if cmd != nil {
+		t.Error("Expected the old autosave timer to stop")
+	}
+}
+
+func TestEmailComposerModel_FailedSaveCanBeDiscarded(t *testing.T) {
+	model := openComposer(&mockBackend{saveErr: errors.New("connection lost")}, nil, ui.ShowEmailComposerMessage{})
+	model = typeText(model, "a")
+
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	model, cmd = model.Update(cmd())
+
+	if cmd != nil {
+		t.Fatal("Expected the composer to stay open after failing to save")
+	}
+
+	_, cmd = model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+
+	if _, ok := cmd().(ui.ShowEmailListMessage); !ok {
+		t.Error("Expected pressing esc again to discard the draft")
+	}
+}
+
+func TestEmailComposerModel_SendingQueuesEmailAndDeletesDraft(t *testing.T) {
+	backend := &mockBackend{}
+	queue := newOutbox(t)
+	draft := &core.Draft{Id: "7", OutgoingEmail: core.OutgoingEmail{To: "friend@example.com", Subject: "Plans"}}
+	model := openComposer(backend, queue, ui.ShowEmailComposerMessage{Draft: draft})
+
+	if model.toInput.Value() != "friend@example.com" || model.subInput.Value() != "Plans" {
+		t.Fatal("Expected the draft to be loaded into the form")
+	}
+
+	_, cmd := model.Update(model.sendEmail(time.Time{})())
+
+	findMessage[ui.ShowEmailListMessage](t, cmd)
+	if record := findMessage[ui.RecordUndoMessage](t, cmd); record.Operation == nil {
+		t.Error("Expected sending to be recorded so it can be undone")
+	}
+	entries, _ := queue.List()
+	if len(entries) != 1 || entries[0].Email.Subject != "Plans" {
+		t.Fatalf("Expected the email to be queued, got %+v", entries)
+	}
+	if len(backend.sent) != 0 {
+		t.Error("Expected the email to be left for the outbox to send")
+	}
+	if len(backend.deleted) != 1 || backend.deleted[0] != "7" {
+		t.Errorf("Expected the draft to be deleted after queueing, got %v", backend.deleted)
+	}
+}
+
+func TestEmailComposerModel_InvalidRecipientsAreNotQueued(t *testing.T) {
+	queue := newOutbox(t)
+	model := openComposer(&mockBackend{}, queue, ui.ShowEmailComposerMessage{})
+	model = typeText(model, "not an address")
+
+	if cmd := model.sendEmail(time.Time{}); cmd != nil {
+		t.Fatal("Expected nothing to be queued")
+	}
+	if !strings.Contains(model.status, "invalid recipients") {
+		t.Errorf("Expected the problem to be shown, got '%s'", model.status)
+	}
+	entries, _ := queue.List()
+	if len(entries) != 0 {
+		t.Errorf("Expected an empty outbox, got %d entries", len(entries))
+	}
+}
+
+func TestEmailComposerModel_ResendingFailedEmailReplacesIt(t *testing.T) {
+	queue := newOutbox(t)
+	entry, _ := queue.Enqueue(core.OutgoingEmail{To: "typo@example.con", Subject: "Plans"}, "3", time.Time{})
+	entry.Status = outbox.StatusFailed
+	entry.LastError = "no such domain"
+	_ = queue.Update(*entry)
+
+	model := openComposer(&mockBackend{}, queue, ui.ShowEmailComposerMessage{Queued: entry})
+	model.toInput.SetValue("friend@example.com")
+	model.Update(model.sendEmail(time.Time{})())
+
+	entries, _ := queue.List()
+	if len(entries) != 1 {
+		t.Fatalf("Expected the failed email to be replaced, got %d entries", len(entries))
+	}
+	if entries[0].Status != outbox.StatusQueued || entries[0].Email.To != "friend@example.com" {
+		t.Errorf("Expected the edited email to be queued again, got %+v", entries[0])
+	}
+	if entries[0].ReplyTo != "3" {
+		t.Errorf("Expected the email being replied to to be kept, got '%s'", entries[0].ReplyTo)
+	}
+}
+
+func TestEmailComposerModel_CcAndBcc(t *testing.T) {
+	queue := newOutbox(t)
+	model := openComposer(&mockBackend{}, queue, ui.ShowEmailComposerMessage{})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyTab})
+	model = typeText(model, "Them <them@example.com>")
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyTab})
+	model = typeText(model, "boss@example.com")
+
+	// the Bcc recipients are enough on their own
+	model.ccInput.SetValue("")
+	model.Update(model.sendEmail(time.Time{})())
+
+	entries, _ := queue.List()
+	if len(entries) != 1 {
+		t.Fatalf("Expected the email to be queued, got %d entries", len(entries))
+	}
+	if email := entries[0].Email; email.To != "" || email.Cc != "" || email.Bcc != "boss@example.com" {
+		t.Errorf("Expected the email to be sent to the Bcc recipient, got %+v", email)
+	}
+
+	model = openComposer(&mockBackend{}, newOutbox(t), ui.ShowEmailComposerMessage{
+		Draft: &core.Draft{OutgoingEmail: core.OutgoingEmail{Cc: "them@example.com", Bcc: "boss@example.com"}},
+	})
+	if model.ccInput.Value() != "them@example.com" || model.bccInput.Value() != "boss@example.com" {
+		t.Errorf("Expected the draft's Cc and Bcc recipients, got %q and %q", model.ccInput.Value(), model.bccInput.Value())
+	}
+	model.bccInput.SetValue("not an address")
+	if model.sendEmail(time.Time{}) != nil || !strings.Contains(model.status, "invalid recipients") {
+		t.Errorf("Expected invalid Bcc recipients to stop the email being sent, got %q", model.status)
+	}
+}
+
+func TestEmailComposerModel_SendingWaitsForUndoDelay(t *testing.T) {
+	queue := newOutbox(t)
+	model := NewEmailComposerModel(&mockBackend{}, queue, nil, Options{UndoSendDelay: time.Minute})
+	model, _ = model.Update(ui.ShowEmailComposerMessage{})
+	model = typeText(model, "friend@example.com")
+
+	before := time.Now()
+	_, cmd := model.Update(model.sendEmail(time.Time{})())
+
+	entries, _ := queue.List()
+	if len(entries) != 1 || entries[0].NextAttempt.Before(before.Add(time.Minute)) {
+		t.Fatalf("Expected the email to be sent after the undo delay, got %+v", entries)
+	}
+	msg := findMessage[ui.ShowEmailListMessage](t, cmd)
+	if !strings.Contains(msg.Status, "in 1m0s — u to undo") {
+		t.Errorf("Expected the status to say sending can be undone, got '%s'", msg.Status)
+	}
+}
+
+func TestEmailComposerModel_SendLater(t *testing.T) {
+	queue := newOutbox(t)
+	model := openComposer(&mockBackend{}, queue, ui.ShowEmailComposerMessage{})
+	model = typeText(model, "friend@example.com")
+	model.focusIndex = sendLaterButton
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if !model.scheduling {
+		t.Fatal("Expected to be asked when to send the email")
+	}
+	model = typeText(model, "tomorrow 9am")
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model.Update(cmd())
+
+	entries, _ := queue.List()
+	if len(entries) != 1 {
+		t.Fatalf("Expected the email to be queued, got %d entries", len(entries))
+	}
+	want, _ := schedule.Parse("tomorrow 9am", time.Now())
+	if !entries[0].SendAt.Equal(want) {
+		t.Errorf("Expected the email to be sent at %v, got %v", want, entries[0].SendAt)
+	}
+}
+
+func TestEmailComposerModel_InvalidScheduleKeepsComposerOpen(t *testing.T) {
+	queue := newOutbox(t)
+	model := openComposer(&mockBackend{}, queue, ui.ShowEmailComposerMessage{})
+	model = typeText(model, "friend@example.com")
+	model.focusIndex = sendLaterButton
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model = typeText(model, "whenever")
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if cmd != nil {
+		t.Error("Expected nothing to be queued")
+	}
+	if !model.scheduling || !strings.Contains(model.status, "don't understand") {
+		t.Errorf("Expected the problem to be shown, got '%s'", model.status)
+	}
+
+	// esc only closes the prompt, not the composer
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if model.scheduling {
+		t.Error("Expected esc to cancel scheduling")
+	}
+}
+
+func TestEmailComposerModel_ReplyToList(t *testing.T) {
+	replyTo := &core.Email{EmailMetadata: core.EmailMetadata{
+		From:      "bob@example.com",
+		Subject:   "Release notes",
+		MessageId: "notes@example.com",
+		List:      &core.MailingList{Id: "announce.example.com", Post: "announce@example.com"},
+	}}
+
+	model := openComposer(&mockBackend{}, nil, ui.ShowEmailComposerMessage{ReplyTo: replyTo, ReplyToList: true})
+	if draft := model.currentDraft(); draft.To != "announce@example.com" || draft.Subject != "Re: Release notes" || draft.InReplyTo != "notes@example.com" {
+		t.Errorf("Expected a reply to the list, got to %q about %q in reply to %q", draft.To, draft.Subject, draft.InReplyTo)
+	}
+
+	model = openComposer(&mockBackend{}, nil, ui.ShowEmailComposerMessage{ReplyTo: replyTo})
+	if draft := model.currentDraft(); draft.To != "bob@example.com" {
+		t.Errorf("Expected a reply to the sender, got to %q", draft.To)
+	}
+}
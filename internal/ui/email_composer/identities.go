@@ -0,0 +1,131 @@
+package email_composer
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/emersion/go-message/mail"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/identity"
+)
+
+// signatureDelimiter separates the signature from the rest of the body, as RFC 3676 describes, so other clients can
+// recognise it.
+const signatureDelimiter = "-- \n"
+
+// chooseIdentity picks who an email is from once the composer has been filled in: the sender of a draft or queued
+// email, whichever identity the email being replied to was sent to, or otherwise the first identity.
+func (m *EmailComposerModel) chooseIdentity(replyTo *core.Email, from string) {
+	m.identityIndex = 0
+	identities := m.options.Identities
+	chosen, ok := identity.Identity{}, false
+	if from != "" {
+		if address, err := mail.ParseAddress(from); err == nil {
+			chosen, ok = identity.Find(identities, address.Address)
+		}
+	} else if replyTo != nil {
+		chosen, ok = identity.ForReply(identities, replyTo)
+	}
+	if !ok {
+		return
+	}
+	for i, candidate := range identities {
+		if candidate.Address == chosen.Address {
+			m.identityIndex = i
+		}
+	}
+	// a draft already has its signature, which is swapped rather than added again if the identity changes
+	if block := signatureBlock(chosen.Signature); block != "" && signatureIndex(m.bodyInput.Value(), block) != -1 {
+		m.signature = block
+	}
+}
+
+// currentIdentity is who the email is from, or false if there aren't any identities, in which case the backend fills
+// in the sender.
+func (m *EmailComposerModel) currentIdentity() (identity.Identity, bool) {
+	if len(m.options.Identities) == 0 {
+		return identity.Identity{}, false
+	}
+	return m.options.Identities[m.identityIndex], true
+}
+
+// updateFrom handles keys while the From field is focused, where the arrow keys switch between the identities.
+func (m *EmailComposerModel) updateFrom(msg tea.KeyMsg) {
+	count := len(m.options.Identities)
+	switch msg.String() {
+	case "left", "h":
+		m.selectIdentity((m.identityIndex + count - 1) % count)
+	case "right", "l", " ":
+		m.selectIdentity((m.identityIndex + 1) % count)
+	}
+}
+
+// selectIdentity switches who the email is from, swapping the signature for the new identity's.
+func (m *EmailComposerModel) selectIdentity(index int) {
+	m.identityIndex = index
+	m.insertSignature(m.options.Identities[index].Signature)
+	m.status = "Sending as " + m.options.Identities[index].From()
+}
+
+// insertSignature replaces the signature in the body, or adds it if there isn't one yet, above the quoted email when
+// replying and at the end otherwise. A signature that has been edited is left alone.
+func (m *EmailComposerModel) insertSignature(signature string) {
+	block := signatureBlock(signature)
+	body := m.bodyInput.Value()
+	switch index := signatureIndex(body, m.signature); {
+	case m.signature != "" && index == -1:
+		return
+	case m.signature != "":
+		body = body[:index] + block + body[index+len(m.signature):]
+	case m.quote != "" && strings.Contains(body, m.quote):
+		index := strings.Index(body, m.quote)
+		body = body[:index] + block + body[index:]
+	default:
+		body = strings.TrimRight(body, "\n") + block
+	}
+	m.signature = block
+	m.bodyInput.SetValue(body)
+	moveToTop(&m.bodyInput)
+}
+
+// signatureBlock formats a signature to go in the body, leaving a blank line above it.
+func signatureBlock(signature string) string {
+	signature = strings.TrimRight(signature, "\n")
+	if signature == "" {
+		return ""
+	}
+	return "\n\n" + signatureDelimiter + signature
+}
+
+// signatureIndex finds a signature in the body, which must be followed by the end of a line so a signature that had
+// more added to it isn't mistaken for the original.
+func signatureIndex(body, block string) int {
+	offset := 0
+	for {
+		index := strings.Index(body[offset:], block)
+		if index == -1 {
+			return -1
+		}
+		end := offset + index + len(block)
+		if end == len(body) || body[end] == '\n' {
+			return offset + index
+		}
+		offset += index + 1
+	}
+}
+
+func (m *EmailComposerModel) fromView() string {
+	current, ok := m.currentIdentity()
+	if !ok {
+		return ""
+	}
+	from := current.From()
+	if len(m.options.Identities) > 1 {
+		from = "◀ " + from + " ▶"
+	}
+	if m.focusIndex == fromField {
+		return focusedStyle.Render("> " + from)
+	}
+	return blurredStyle.Render("> " + from)
+}
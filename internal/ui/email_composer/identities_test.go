@@ -0,0 +1,137 @@
+package email_composer
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/identity"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+var testIdentities = []identity.Identity{
+	{Name: "Alice Smith", Address: "alice@example.com", Signature: "Alice"},
+	{Name: "Support", Address: "support@work.example.com", ReplyTo: "help@work.example.com", Signature: "The support team\nExample Ltd"},
+}
+
+func openComposerWithIdentities(msg ui.ShowEmailComposerMessage) (*EmailComposerModel, *mockBackend) {
+	backend := &mockBackend{}
+	model := NewEmailComposerModel(backend, nil, nil, Options{Identities: testIdentities})
+	model, _ = model.Update(msg)
+	return model, backend
+}
+
+func TestEmailComposerModel_NewEmailUsesFirstIdentity(t *testing.T) {
+	model, _ := openComposerWithIdentities(ui.ShowEmailComposerMessage{})
+
+	draft := model.currentDraft()
+	if draft.From != `"Alice Smith" <alice@example.com>` || draft.ReplyTo != "" {
+		t.Errorf("Expected the first identity, got %q replying to %q", draft.From, draft.ReplyTo)
+	}
+	if draft.Body != "\n\n-- \nAlice" {
+		t.Errorf("Expected the signature below a space for the email, got %q", draft.Body)
+	}
+}
+
+func TestEmailComposerModel_SwitchingIdentitySwapsSignature(t *testing.T) {
+	model, _ := openComposerWithIdentities(ui.ShowEmailComposerMessage{})
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyShiftTab})
+	if model.focusIndex != fromField {
+		t.Fatalf("Expected the From field to be focused, got %d", model.focusIndex)
+	}
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRight})
+
+	draft := model.currentDraft()
+	if draft.From != `"Support" <support@work.example.com>` || draft.ReplyTo != "help@work.example.com" {
+		t.Errorf("Expected the support identity, got %q replying to %q", draft.From, draft.ReplyTo)
+	}
+	if draft.Body != "\n\n-- \nThe support team\nExample Ltd" {
+		t.Errorf("Expected the signature to be swapped, got %q", draft.Body)
+	}
+	if model.contents() == model.savedContents {
+		t.Error("Expected changing the identity to count as a change to the draft")
+	}
+}
+
+func TestEmailComposerModel_ReplyUsesIdentityItWasSentTo(t *testing.T) {
+	model, _ := openComposerWithIdentities(ui.ShowEmailComposerMessage{ReplyTo: &core.Email{
+		EmailMetadata: core.EmailMetadata{From: "bob@example.com", To: "Support <support@work.example.com>", Subject: "Help"},
+		Body:          "It's broken",
+	}})
+
+	draft := model.currentDraft()
+	if draft.From != `"Support" <support@work.example.com>` {
+		t.Errorf("Expected the identity the email was sent to, got %q", draft.From)
+	}
+	signature := strings.Index(draft.Body, "-- \nThe support team")
+	quote := strings.Index(draft.Body, "> It's broken")
+	if signature == -1 || quote == -1 || signature > quote {
+		t.Errorf("Expected the signature above the quoted email, got %q", draft.Body)
+	}
+	if model.bodyInput.Line() != 0 {
+		t.Errorf("Expected the cursor to be at the top for writing the reply, got line %d", model.bodyInput.Line())
+	}
+}
+
+func TestEmailComposerModel_DraftKeepsItsIdentity(t *testing.T) {
+	draft := &core.Draft{Id: "1", OutgoingEmail: core.OutgoingEmail{
+		From: "Support <support@work.example.com>",
+		Body: "Hello\n\n-- \nThe support team\nExample Ltd",
+	}}
+	model, _ := openComposerWithIdentities(ui.ShowEmailComposerMessage{Draft: draft})
+
+	if model.identityIndex != 1 {
+		t.Errorf("Expected the draft's identity, got %d", model.identityIndex)
+	}
+
+	model.selectIdentity(0)
+
+	if body := model.bodyInput.Value(); body != "Hello\n\n-- \nAlice" {
+		t.Errorf("Expected the draft's signature to be swapped, got %q", body)
+	}
+}
+
+func TestEmailComposerModel_EditedSignatureIsKept(t *testing.T) {
+	model, _ := openComposerWithIdentities(ui.ShowEmailComposerMessage{})
+	model.bodyInput.SetValue("Hi\n\n-- \nAlice (on holiday)")
+
+	model.selectIdentity(1)
+
+	if body := model.bodyInput.Value(); body != "Hi\n\n-- \nAlice (on holiday)" {
+		t.Errorf("Expected the edited signature to be left alone, got %q", body)
+	}
+}
+
+func TestEmailComposerModel_FromHiddenWithoutIdentities(t *testing.T) {
+	model := openComposer(&mockBackend{}, nil, ui.ShowEmailComposerMessage{})
+
+	if strings.Contains(model.View(), "From:") {
+		t.Error("Expected no From field without identities")
+	}
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyShiftTab})
+	if model.focusIndex == fromField {
+		t.Error("Expected the From field to be skipped")
+	}
+	if model.currentDraft().From != "" || model.currentDraft().Body != "" {
+		t.Errorf("Expected the backend to fill in the sender, got %+v", model.currentDraft())
+	}
+}
+
+func TestEmailComposerModel_ComposedEmailHasNoSignature(t *testing.T) {
+	model, _ := openComposerWithIdentities(ui.ShowEmailComposerMessage{Compose: &core.OutgoingEmail{
+		From:    "support@work.example.com",
+		To:      "leave@lists.example.com",
+		Subject: "unsubscribe",
+	}})
+
+	draft := model.currentDraft()
+	if draft.From != `"Support" <support@work.example.com>` || draft.To != "leave@lists.example.com" || draft.Subject != "unsubscribe" {
+		t.Errorf("Expected the email as it was asked for, got %+v", draft.OutgoingEmail)
+	}
+	if draft.Body != "" {
+		t.Errorf("Expected no signature, got %q", draft.Body)
+	}
+}
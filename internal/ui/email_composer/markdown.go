@@ -0,0 +1,65 @@
+package email_composer
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// toggleMarkdown switches between writing the body as plain text and as Markdown, which is sent with an HTML version.
+func (m *EmailComposerModel) toggleMarkdown() {
+	m.draft.Markdown = !m.draft.Markdown
+	if m.draft.Markdown {
+		m.status = "Writing in Markdown — ctrl+r to preview"
+	} else {
+		m.previewing = false
+		m.status = "Writing in plain text"
+	}
+}
+
+// togglePreview switches between editing the body and seeing how its Markdown renders.
+func (m *EmailComposerModel) togglePreview() {
+	if m.previewing {
+		m.previewing = false
+		return
+	}
+	if !m.draft.Markdown {
+		m.status = "Only Markdown can be previewed — ctrl+k to write in Markdown"
+		return
+	}
+
+	preview, err := renderPreview(m.bodyInput.Value(), m.width)
+	if err != nil {
+		m.status = "Failed to preview the Markdown: " + err.Error()
+		return
+	}
+	m.preview = preview
+	m.previewing = true
+}
+
+// renderPreview renders Markdown for the terminal, which is close to how the HTML version looks.
+func renderPreview(body string, width int) (string, error) {
+	style := styles.LightStyle
+	if lipgloss.HasDarkBackground() {
+		style = styles.DarkStyle
+	}
+	renderer, err := glamour.NewTermRenderer(
+		glamour.WithStandardStyle(style),
+		glamour.WithWordWrap(width),
+	)
+	if err != nil {
+		return "", err
+	}
+	return renderer.Render(body)
+}
+
+// previewView shows the rendered Markdown in place of the body, cut off at the body's height.
+func (m *EmailComposerModel) previewView() string {
+	lines := strings.Split(strings.Trim(m.preview, "\n"), "\n")
+	if height := m.bodyInput.Height(); len(lines) > height {
+		lines = append(lines[:height-1], blurredStyle.Render("…"))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,74 @@
+package email_composer
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+func TestEmailComposerModel_MarkdownPreview(t *testing.T) {
+	model := openComposer(&mockBackend{}, nil, ui.ShowEmailComposerMessage{})
+	model.bodyInput.SetValue("# Release notes\n\n- **faster** sending")
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if model.previewing {
+		t.Error("Expected plain text not to be previewed")
+	}
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlK})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+
+	if !model.previewing {
+		t.Fatalf("Expected the Markdown to be previewed, status %q", model.status)
+	}
+	view := ansi.Strip(model.View())
+	if !strings.Contains(view, "Release notes") || strings.Contains(view, "**faster**") {
+		t.Errorf("Expected the rendered Markdown in place of the body, got:\n%s", view)
+	}
+
+	model = typeText(model, "x")
+	if model.bodyInput.Value() != "# Release notes\n\n- **faster** sending" {
+		t.Errorf("Expected the body not to change while previewing, got %q", model.bodyInput.Value())
+	}
+
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if model.previewing || cmd != nil {
+		t.Error("Expected esc to go back to editing without closing the composer")
+	}
+}
+
+func TestEmailComposerModel_MarkdownIsSent(t *testing.T) {
+	queue := newOutbox(t)
+	model := NewEmailComposerModel(&mockBackend{}, queue, nil, Options{Markdown: true})
+	model, _ = model.Update(ui.ShowEmailComposerMessage{})
+	model.toInput.SetValue("bob@example.com")
+	model.bodyInput.SetValue("*Hi*")
+
+	if model.contents() == model.savedContents {
+		t.Error("Expected the body to count as a change")
+	}
+	model.focusIndex = submitButton
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	findMessage[emailQueuedMessage](t, cmd)
+
+	entries, _ := queue.List()
+	if len(entries) != 1 || !entries[0].Email.Markdown {
+		t.Errorf("Expected the email to be queued as Markdown, got %+v", entries)
+	}
+}
+
+func TestEmailComposerModel_DraftKeepsMarkdown(t *testing.T) {
+	model := NewEmailComposerModel(&mockBackend{}, nil, nil, Options{Markdown: true})
+	draft := &core.Draft{Id: "1", OutgoingEmail: core.OutgoingEmail{Body: "plain"}}
+
+	model, _ = model.Update(ui.ShowEmailComposerMessage{Draft: draft})
+
+	if model.draft.Markdown {
+		t.Error("Expected a plain-text draft to stay plain text")
+	}
+}
@@ -0,0 +1,100 @@
+package email_composer
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// toggleSign moves on to the next way of signing the email: with the sender's OpenPGP key, with their S/MIME
+// certificate, then not at all, skipping the ones that aren't configured. Emails encrypted with OpenPGP can only be
+// signed with it too.
+func (m *EmailComposerModel) toggleSign() {
+	canSignSMIME := m.options.SMIME.HasKey()
+	if m.options.Keyring == nil && !canSignSMIME {
+		m.status = "No OpenPGP keyring or S/MIME certificate is configured — start with --pgp-keyring or --smime-cert to sign emails"
+		return
+	}
+	switch {
+	case !m.draft.OpenPGP.Sign && !m.draft.SignSMIME && m.options.Keyring != nil:
+		m.draft.OpenPGP.Sign = true
+		m.status = "The email will be signed with OpenPGP"
+	case !m.draft.SignSMIME && canSignSMIME && !m.draft.OpenPGP.Encrypt:
+		m.draft.OpenPGP.Sign = false
+		m.draft.SignSMIME = true
+		m.status = "The email will be signed with S/MIME"
+	default:
+		m.draft.OpenPGP.Sign = false
+		m.draft.SignSMIME = false
+		m.status = "The email won't be signed"
+	}
+}
+
+// toggleEncrypt switches whether the email is encrypted with OpenPGP, so only the recipients can read it.
+func (m *EmailComposerModel) toggleEncrypt() {
+	if m.options.Keyring == nil {
+		m.status = "No OpenPGP keyring is configured — start with --pgp-keyring to encrypt emails"
+		return
+	}
+	m.draft.OpenPGP.Encrypt = !m.draft.OpenPGP.Encrypt
+	switch {
+	case m.draft.OpenPGP.Encrypt && m.draft.SignSMIME:
+		// it's still signed, but with OpenPGP as the signature is encrypted along with the rest of the email
+		m.draft.SignSMIME = false
+		m.draft.OpenPGP.Sign = true
+		m.status = "The email will be encrypted and signed with OpenPGP, rather than signed with S/MIME"
+	case m.draft.OpenPGP.Encrypt:
+		m.status = "The email will be encrypted with OpenPGP"
+	default:
+		m.status = "The email won't be encrypted"
+	}
+}
+
+// checkProtection makes sure the email can be signed and encrypted as asked before it's queued, as there's no one to
+// ask once it's being sent in the background. Emails without a sender have it filled in by the backend, which checks
+// them when they're sent instead.
+func (m *EmailComposerModel) checkProtection() error {
+	draft := m.currentDraft()
+	if (!draft.OpenPGP.Enabled() && !draft.SignSMIME) || draft.From == "" {
+		return nil
+	}
+	from, err := mail.ParseAddress(draft.From)
+	if err != nil {
+		return err
+	}
+	if draft.SignSMIME {
+		return m.options.SMIME.Check(from.Address)
+	}
+	// the Bcc recipients need keys too, since they get the same encrypted email
+	var recipients []string
+	for _, list := range []string{draft.To, draft.Cc, draft.Bcc} {
+		if strings.TrimSpace(list) == "" {
+			continue
+		}
+		addresses, err := mail.ParseAddressList(list)
+		if err != nil {
+			return err
+		}
+		for _, address := range addresses {
+			recipients = append(recipients, address.Address)
+		}
+	}
+	return m.options.Keyring.Check(from.Address, recipients, draft.OpenPGP)
+}
+
+// protectionView says how the email will be protected, or nothing if it won't be.
+func (m *EmailComposerModel) protectionView() string {
+	if m.draft.SignSMIME {
+		return labelStyle.Render("S/MIME:") + " signed\n\n"
+	}
+	var protections []string
+	if m.draft.OpenPGP.Sign {
+		protections = append(protections, "signed")
+	}
+	if m.draft.OpenPGP.Encrypt {
+		protections = append(protections, "encrypted")
+	}
+	if len(protections) == 0 {
+		return ""
+	}
+	return labelStyle.Render("OpenPGP:") + " " + strings.Join(protections, " and ") + "\n\n"
+}
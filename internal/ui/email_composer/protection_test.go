@@ -0,0 +1,177 @@
+package email_composer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/pgp"
+	"github.com/bengesoff/mail-tui/internal/smime"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+// openComposerWithKeyring opens the composer with a throwaway key for Alice, the first identity, and none for anyone
+// else.
+func openComposerWithKeyring(t *testing.T, msg ui.ShowEmailComposerMessage) *EmailComposerModel {
+	t.Helper()
+	alice, err := openpgp.NewEntity("Alice Smith", "", "alice@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := NewEmailComposerModel(&mockBackend{}, newOutbox(t), nil, Options{Identities: testIdentities, Keyring: pgp.NewKeyring(alice)})
+	model, _ = model.Update(msg)
+	return model
+}
+
+func TestEmailComposerModel_SignAndEncrypt(t *testing.T) {
+	model := openComposerWithKeyring(t, ui.ShowEmailComposerMessage{})
+	model.toInput.SetValue("alice@example.com")
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlX})
+	if view := ansi.Strip(model.View()); !strings.Contains(view, "OpenPGP: signed and encrypted") {
+		t.Errorf("Expected the protection to be shown, got:\n%s", view)
+	}
+
+	model.focusIndex = submitButton
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	findMessage[emailQueuedMessage](t, cmd)
+	entries, _ := model.outbox.List()
+	if len(entries) != 1 || entries[0].Email.OpenPGP != (core.Protection{Sign: true, Encrypt: true}) {
+		t.Errorf("Expected the email to be queued to be signed and encrypted, got %+v", entries)
+	}
+}
+
+func TestEmailComposerModel_MissingKeyIsNotQueued(t *testing.T) {
+	model := openComposerWithKeyring(t, ui.ShowEmailComposerMessage{})
+	model.toInput.SetValue("Bob <bob@example.com>")
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlX})
+
+	model.focusIndex = submitButton
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd != nil || model.status != "Can't send the email: no key to encrypt to bob@example.com" {
+		t.Errorf("Expected the email not to be queued without Bob's key, got status %q", model.status)
+	}
+}
+
+func TestEmailComposerModel_ReplyToEncryptedEmailIsEncrypted(t *testing.T) {
+	replyTo := &core.Email{EmailMetadata: core.EmailMetadata{From: "bob@example.com", Subject: "Secrets"}, Body: "Shh", Encrypted: true}
+	model := openComposerWithKeyring(t, ui.ShowEmailComposerMessage{ReplyTo: replyTo})
+	if model.draft.OpenPGP != (core.Protection{Sign: true, Encrypt: true}) {
+		t.Errorf("Expected the reply to be signed and encrypted, got %+v", model.draft.OpenPGP)
+	}
+}
+
+func TestEmailComposerModel_ProtectionNeedsKeyring(t *testing.T) {
+	model := openComposer(&mockBackend{}, nil, ui.ShowEmailComposerMessage{})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	if model.draft.OpenPGP.Sign || !strings.Contains(model.status, "--pgp-keyring") {
+		t.Errorf("Expected to be told there's no keyring, got %q", model.status)
+	}
+}
+
+// newSMIMEConfig generates a throwaway self-signed S/MIME certificate for an address, which is all the composer needs
+// to check before an email is queued.
+func newSMIMEConfig(t *testing.T, address string) *smime.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: "Alice Smith"},
+		EmailAddresses: []string{address},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &smime.Config{Certificate: certificate, Key: key}
+}
+
+func TestEmailComposerModel_SignWithSMIME(t *testing.T) {
+	alice, err := openpgp.NewEntity("Alice Smith", "", "alice@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := Options{Identities: testIdentities, Keyring: pgp.NewKeyring(alice), SMIME: newSMIMEConfig(t, "alice@example.com")}
+	model := NewEmailComposerModel(&mockBackend{}, newOutbox(t), nil, options)
+	model, _ = model.Update(ui.ShowEmailComposerMessage{})
+	model.toInput.SetValue("bob@example.com")
+
+	// signing goes from OpenPGP to S/MIME to not signing at all
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	if !model.draft.SignSMIME || model.draft.OpenPGP.Sign {
+		t.Errorf("Expected the email to be signed with S/MIME instead of OpenPGP, got %+v", model.draft.OutgoingEmail)
+	}
+	if view := ansi.Strip(model.View()); !strings.Contains(view, "S/MIME: signed") {
+		t.Errorf("Expected the signature to be shown, got:\n%s", view)
+	}
+
+	model.focusIndex = submitButton
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	findMessage[emailQueuedMessage](t, cmd)
+	entries, _ := model.outbox.List()
+	if len(entries) != 1 || !entries[0].Email.SignSMIME || entries[0].Email.OpenPGP.Enabled() {
+		t.Errorf("Expected the email to be queued to be signed with S/MIME, got %+v", entries)
+	}
+}
+
+func TestEmailComposerModel_EncryptingSwitchesToOpenPGPSignature(t *testing.T) {
+	alice, err := openpgp.NewEntity("Alice Smith", "", "alice@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := Options{Identities: testIdentities, Keyring: pgp.NewKeyring(alice), SMIME: newSMIMEConfig(t, "alice@example.com")}
+	model := NewEmailComposerModel(&mockBackend{}, newOutbox(t), nil, options)
+	model, _ = model.Update(ui.ShowEmailComposerMessage{})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlX})
+	if model.draft.SignSMIME || model.draft.OpenPGP != (core.Protection{Sign: true, Encrypt: true}) {
+		t.Errorf("Expected the email to be signed and encrypted with OpenPGP, got %+v", model.draft.OutgoingEmail)
+	}
+	// it can't be signed with S/MIME while it's encrypted with OpenPGP
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	if model.draft.SignSMIME || model.draft.OpenPGP.Sign {
+		t.Errorf("Expected the email not to be signed, got %+v", model.draft.OutgoingEmail)
+	}
+}
+
+func TestEmailComposerModel_SMIMECertificateForOtherAddressIsNotQueued(t *testing.T) {
+	options := Options{Identities: testIdentities, SMIME: newSMIMEConfig(t, "someone@example.com")}
+	model := NewEmailComposerModel(&mockBackend{}, newOutbox(t), nil, options)
+	model, _ = model.Update(ui.ShowEmailComposerMessage{})
+	model.toInput.SetValue("bob@example.com")
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	if !model.draft.SignSMIME {
+		t.Fatalf("Expected to sign with S/MIME without an OpenPGP keyring, got status %q", model.status)
+	}
+
+	model.focusIndex = submitButton
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd != nil || model.status != "Can't send the email: the S/MIME certificate isn't for alice@example.com" {
+		t.Errorf("Expected the email not to be queued, got status %q", model.status)
+	}
+}
@@ -0,0 +1,97 @@
+package email_composer
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/contacts"
+)
+
+// maxSuggestions is how many contacts are suggested at once while typing an address.
+const maxSuggestions = 5
+
+// addressInput returns the To, Cc or Bcc field if one of them is focused, or nil otherwise.
+func (m *EmailComposerModel) addressInput() *textinput.Model {
+	switch m.focusIndex {
+	case toField:
+		return &m.toInput
+	case ccField:
+		return &m.ccInput
+	case bccField:
+		return &m.bccInput
+	}
+	return nil
+}
+
+// updateSuggestions suggests contacts matching the address being typed, which is the last one in the list.
+func (m *EmailComposerModel) updateSuggestions() {
+	m.suggestions = nil
+	m.suggestionIndex = 0
+	input := m.addressInput()
+	if m.contacts == nil || input == nil {
+		return
+	}
+
+	_, typing := splitLastAddress(input.Value())
+	if typing == "" {
+		return
+	}
+	for _, contact := range m.contacts.Suggest(typing, maxSuggestions) {
+		// the address has already been filled in
+		if strings.EqualFold(contact.String(), typing) || strings.EqualFold(contact.Address, typing) {
+			m.suggestions = nil
+			return
+		}
+		m.suggestions = append(m.suggestions, contact)
+	}
+}
+
+// updateSuggestionKeys handles choosing a suggestion with the arrow keys and enter, returning false for other keys.
+func (m *EmailComposerModel) updateSuggestionKeys(msg tea.KeyMsg) bool {
+	switch msg.String() {
+	case "down", "ctrl+n":
+		m.suggestionIndex = min(m.suggestionIndex+1, len(m.suggestions)-1)
+	case "up", "ctrl+p":
+		m.suggestionIndex = max(m.suggestionIndex-1, 0)
+	case "enter":
+		m.acceptSuggestion(m.suggestions[m.suggestionIndex])
+	default:
+		return false
+	}
+	return true
+}
+
+// acceptSuggestion replaces the address being typed with the contact, ready for the next address to be typed.
+func (m *EmailComposerModel) acceptSuggestion(contact contacts.Contact) {
+	input := m.addressInput()
+	previous, _ := splitLastAddress(input.Value())
+	input.SetValue(previous + contact.String() + ", ")
+	input.CursorEnd()
+	m.suggestions = nil
+	m.suggestionIndex = 0
+}
+
+func (m *EmailComposerModel) suggestionsView() string {
+	var b strings.Builder
+	for i, contact := range m.suggestions {
+		if i == m.suggestionIndex {
+			b.WriteString(focusedStyle.Render("> " + contact.String()))
+		} else {
+			b.WriteString(blurredStyle.Render("  " + contact.String()))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// splitLastAddress splits a list of addresses into the complete ones, including the trailing comma and space, and the
+// one still being typed.
+func splitLastAddress(value string) (previous, typing string) {
+	index := strings.LastIndex(value, ",")
+	if index == -1 {
+		return "", strings.TrimSpace(value)
+	}
+	return strings.TrimRight(value[:index+1], " ") + " ", strings.TrimSpace(value[index+1:])
+}
@@ -0,0 +1,94 @@
+package email_composer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/contacts"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+func openComposerWithContacts(t *testing.T) *EmailComposerModel {
+	book, err := contacts.Open(filepath.Join(t.TempDir(), "contacts.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	book.Record("Alice Smith", "alice@example.com", time.Now())
+	book.Record("Alan Jones", "alan@example.com", time.Now().AddDate(-1, 0, 0))
+	book.Record("Bob", "bob@example.com", time.Now())
+
+	model := NewEmailComposerModel(&mockBackend{}, nil, book, Options{})
+	model, _ = model.Update(ui.ShowEmailComposerMessage{})
+	return model
+}
+
+func TestEmailComposerModel_SuggestsContactsForLastAddress(t *testing.T) {
+	model := openComposerWithContacts(t)
+
+	model = typeText(model, "bob@example.com, al")
+
+	// contacts starting with what's typed come first
+	if len(model.suggestions) < 2 || model.suggestions[0].Address != "alice@example.com" || model.suggestions[1].Address != "alan@example.com" {
+		t.Fatalf("Expected Alice then Alan to be suggested first, got %+v", model.suggestions)
+	}
+	if !strings.Contains(model.View(), "Alan Jones") {
+		t.Error("Expected the suggestions to be shown")
+	}
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if cmd != nil {
+		t.Error("Expected enter to choose the suggestion rather than send the email")
+	}
+	if got, want := model.toInput.Value(), `bob@example.com, "Alan Jones" <alan@example.com>, `; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if len(model.suggestions) != 0 {
+		t.Errorf("Expected the suggestions to be hidden, got %+v", model.suggestions)
+	}
+}
+
+func TestEmailComposerModel_EscHidesSuggestions(t *testing.T) {
+	model := openComposerWithContacts(t)
+	model = typeText(model, "al")
+
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+
+	if cmd != nil || len(model.suggestions) != 0 {
+		t.Error("Expected esc to hide the suggestions without closing the composer")
+	}
+}
+
+func TestEmailComposerModel_NoSuggestionsWithoutAddressBook(t *testing.T) {
+	model := openComposer(&mockBackend{}, nil, ui.ShowEmailComposerMessage{})
+
+	model = typeText(model, "al")
+
+	if len(model.suggestions) != 0 {
+		t.Errorf("Expected no suggestions, got %+v", model.suggestions)
+	}
+}
+
+func TestEmailComposerModel_SuggestsContactsInCc(t *testing.T) {
+	model := openComposerWithContacts(t)
+	model = typeText(model, "al")
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyTab})
+
+	if len(model.suggestions) != 0 {
+		t.Fatalf("Expected the To field's suggestions to be hidden, got %+v", model.suggestions)
+	}
+	model = typeText(model, "bo")
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if got, want := model.ccInput.Value(), `"Bob" <bob@example.com>, `; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if model.toInput.Value() != "al" {
+		t.Errorf("Expected the To field to be left alone, got %q", model.toInput.Value())
+	}
+}
@@ -0,0 +1,127 @@
+package email_composer
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/emersion/go-message/mail"
+
+	"github.com/bengesoff/mail-tui/internal/templates"
+)
+
+type templatesLoadedMessage struct {
+	generation int
+	templates  []templates.Template
+	error      error
+}
+
+// openTemplates loads the templates to choose from. They're loaded every time, so changes to them are picked up.
+func (m *EmailComposerModel) openTemplates() tea.Cmd {
+	if m.options.TemplateDir == "" {
+		m.status = "No templates directory has been configured"
+		return nil
+	}
+	m.choosingTemplate = true
+	m.templates = nil
+	m.templateIndex = 0
+	m.status = "Loading templates..."
+
+	dir := m.options.TemplateDir
+	generation := m.generation
+	return func() tea.Msg {
+		loaded, err := templates.Load(dir)
+		return templatesLoadedMessage{generation: generation, templates: loaded, error: err}
+	}
+}
+
+func (m *EmailComposerModel) handleTemplatesLoaded(msg templatesLoadedMessage) {
+	m.templates = msg.templates
+	m.templateIndex = 0
+	m.status = ""
+	if msg.error != nil {
+		// the templates that did load can still be used
+		m.status = "Some templates failed to load: " + msg.error.Error()
+	}
+	if len(m.templates) == 0 {
+		m.choosingTemplate = false
+		if msg.error == nil {
+			m.status = "There are no templates in " + m.options.TemplateDir
+		}
+	}
+}
+
+// updateTemplates handles keys while a template is being chosen.
+func (m *EmailComposerModel) updateTemplates(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		m.choosingTemplate = false
+	case "up", "k":
+		m.templateIndex = max(m.templateIndex-1, 0)
+	case "down", "j":
+		m.templateIndex = max(min(m.templateIndex+1, len(m.templates)-1), 0)
+	case "enter":
+		if m.templateIndex >= len(m.templates) {
+			return nil
+		}
+		m.choosingTemplate = false
+		return m.applyTemplate(m.templates[m.templateIndex])
+	}
+	return nil
+}
+
+// applyTemplate inserts the filled-in template into the body where the cursor is, and fills in the subject if the
+// template has one and there isn't one yet.
+func (m *EmailComposerModel) applyTemplate(template templates.Template) tea.Cmd {
+	subject, body, err := template.Execute(m.templateData())
+	if err != nil {
+		m.status = "Failed to use template: " + err.Error()
+		return nil
+	}
+
+	if subject != "" && strings.TrimSpace(m.subInput.Value()) == "" {
+		m.subInput.SetValue(subject)
+	}
+	m.bodyInput.InsertString(body)
+	m.status = "Used template " + template.Name
+	m.focusIndex = bodyField
+	return m.updateFieldFocus()
+}
+
+func (m *EmailComposerModel) templateData() templates.Data {
+	data := templates.Data{
+		Subject: m.subInput.Value(),
+		Date:    time.Now(),
+	}
+	if m.replyTo != nil {
+		data.Subject = m.replyTo.Subject
+	}
+	if current, ok := m.currentIdentity(); ok {
+		data.SenderName = current.Name
+	}
+	if recipients, err := mail.ParseAddressList(m.toInput.Value()); err == nil && len(recipients) > 0 {
+		data.RecipientAddress = recipients[0].Address
+		data.RecipientName = recipients[0].Name
+		if data.RecipientName == "" {
+			data.RecipientName, _, _ = strings.Cut(recipients[0].Address, "@")
+		}
+	}
+	return data
+}
+
+func (m *EmailComposerModel) templatesView() string {
+	var b strings.Builder
+	b.WriteString(labelStyle.Render("Templates:"))
+	b.WriteString("\n")
+	for i, template := range m.templates {
+		if i == m.templateIndex {
+			b.WriteString(focusedStyle.Render("> " + template.Name))
+		} else {
+			b.WriteString(blurredStyle.Render("  " + template.Name))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(blurredStyle.Render("Enter: Use template • Esc: Cancel"))
+	b.WriteString("\n\n")
+	return b.String()
+}
@@ -0,0 +1,111 @@
+package email_composer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+func openComposerWithTemplates(t *testing.T, msg ui.ShowEmailComposerMessage) (*EmailComposerModel, string) {
+	dir := t.TempDir()
+	incident := "Subject: Incident acknowledged\nHi {{.RecipientName}},\n\nWe've seen \"{{.Subject}}\" and are on it.\n{{.SenderName}}"
+	if err := os.WriteFile(filepath.Join(dir, "incident.tmpl"), []byte(incident), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	model := NewEmailComposerModel(&mockBackend{}, nil, nil, Options{Identities: testIdentities, TemplateDir: dir})
+	model, _ = model.Update(msg)
+	return model, dir
+}
+
+func chooseTemplate(t *testing.T, model *EmailComposerModel) *EmailComposerModel {
+	t.Helper()
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+	model, _ = model.Update(findMessage[templatesLoadedMessage](t, cmd))
+	if !model.choosingTemplate {
+		t.Fatalf("Expected the templates to be shown, status %q", model.status)
+	}
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	return model
+}
+
+func TestEmailComposerModel_TemplateForReply(t *testing.T) {
+	model, _ := openComposerWithTemplates(t, ui.ShowEmailComposerMessage{ReplyTo: &core.Email{
+		EmailMetadata: core.EmailMetadata{From: "Bob Jones <bob@example.com>", To: "alice@example.com", Subject: "Site down"},
+		Body:          "Help",
+	}})
+
+	model = chooseTemplate(t, model)
+
+	body := model.bodyInput.Value()
+	if !strings.HasPrefix(body, "Hi Bob Jones,\n\nWe've seen \"Site down\" and are on it.\nAlice Smith") {
+		t.Errorf("Expected the template at the top of the reply, got %q", body)
+	}
+	if !strings.Contains(body, "-- \nAlice") || !strings.Contains(body, "> Help") {
+		t.Errorf("Expected the signature and quote to be kept, got %q", body)
+	}
+	if model.subInput.Value() != "Re: Site down" {
+		t.Errorf("Expected the reply's subject to be kept, got %q", model.subInput.Value())
+	}
+	if model.focusIndex != bodyField {
+		t.Errorf("Expected the body to be focused, got %d", model.focusIndex)
+	}
+}
+
+func TestEmailComposerModel_TemplateFillsEmptySubject(t *testing.T) {
+	model, _ := openComposerWithTemplates(t, ui.ShowEmailComposerMessage{})
+	model = typeText(model, "carol@example.com")
+
+	model = chooseTemplate(t, model)
+
+	if model.subInput.Value() != "Incident acknowledged" {
+		t.Errorf("Expected the template's subject, got %q", model.subInput.Value())
+	}
+	if !strings.HasPrefix(model.bodyInput.Value(), "Hi carol,") {
+		t.Errorf("Expected the recipient's name from their address, got %q", model.bodyInput.Value())
+	}
+}
+
+func TestEmailComposerModel_TemplatesAreReloaded(t *testing.T) {
+	model, dir := openComposerWithTemplates(t, ui.ShowEmailComposerMessage{})
+	if err := os.WriteFile(filepath.Join(dir, "incident.tmpl"), []byte("Changed"), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	model = chooseTemplate(t, model)
+
+	if !strings.HasPrefix(model.bodyInput.Value(), "Changed") {
+		t.Errorf("Expected the changed template, got %q", model.bodyInput.Value())
+	}
+}
+
+func TestEmailComposerModel_MovingBeforeTemplatesLoad(t *testing.T) {
+	model, _ := openComposerWithTemplates(t, ui.ShowEmailComposerMessage{})
+
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	model, _ = model.Update(findMessage[templatesLoadedMessage](t, cmd))
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if !strings.HasPrefix(model.bodyInput.Value(), "Hi ,") {
+		t.Errorf("Expected the first template to be used, got %q", model.bodyInput.Value())
+	}
+}
+
+func TestEmailComposerModel_NoTemplates(t *testing.T) {
+	model := NewEmailComposerModel(&mockBackend{}, nil, nil, Options{TemplateDir: t.TempDir()})
+	model, _ = model.Update(ui.ShowEmailComposerMessage{})
+
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+	model, _ = model.Update(findMessage[templatesLoadedMessage](t, cmd))
+
+	if model.choosingTemplate || !strings.Contains(model.status, "no templates") {
+		t.Errorf("Expected a message saying there are no templates, got %q", model.status)
+	}
+}
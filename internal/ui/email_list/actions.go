@@ -0,0 +1,361 @@
+package email_list
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/ui"
+	"github.com/bengesoff/mail-tui/internal/undo"
+)
+
+type moveAction int
+
+const (
+	actionMove moveAction = iota
+	actionCopy
+	actionArchive
+	actionDelete
+)
+
+// progress describes the action while it's running, e.g. "Moving 2 emails to Work…".
+func (a moveAction) progress(count int, mailbox string) string {
+	switch a {
+	case actionMove:
+		return fmt.Sprintf("Moving %s to %s…", pluralise(count), mailbox)
+	case actionCopy:
+		return fmt.Sprintf("Copying %s to %s…", pluralise(count), mailbox)
+	case actionArchive:
+		return fmt.Sprintf("Archiving %s…", pluralise(count))
+	case actionDelete:
+		return fmt.Sprintf("Deleting %s…", pluralise(count))
+	default:
+		return ""
+	}
+}
+
+// describe summarises the action for the status bar, e.g. "Moved 2 emails to Work".
+func (a moveAction) describe(count int, mailbox string) string {
+	switch a {
+	case actionMove:
+		return fmt.Sprintf("Moved %s to %s", pluralise(count), mailbox)
+	case actionCopy:
+		return fmt.Sprintf("Copied %s to %s", pluralise(count), mailbox)
+	case actionArchive:
+		return fmt.Sprintf("Archived %s", pluralise(count))
+	case actionDelete:
+		return fmt.Sprintf("Deleted %s", pluralise(count))
+	default:
+		return ""
+	}
+}
+
+// pluralise counts emails, e.g. "1 email" or "2 emails".
+func pluralise(count int) string {
+	if count == 1 {
+		return "1 email"
+	}
+	return fmt.Sprintf("%d emails", count)
+}
+
+func (a moveAction) verb() string {
+	switch a {
+	case actionMove:
+		return "move"
+	case actionCopy:
+		return "copy"
+	case actionArchive:
+		return "archive"
+	case actionDelete:
+		return "delete"
+	default:
+		return ""
+	}
+}
+
+type mailboxesLoadedMessage struct {
+	mailboxes []core.Mailbox
+	error     error
+}
+
+type emailsMovedMessage struct {
+	action  moveAction
+	ids     []core.EmailId
+	mailbox string
+	result  *core.MoveResult
+	error   error
+}
+
+type flagsChangedMessage struct {
+	flag core.Flag
+	set  bool
+	// previous records whether each email had the flag before, so the change can be rolled back
+	previous map[core.EmailId]bool
+	error    error
+}
+
+// toggleSelectedFlag sets a flag on the selected emails, or clears it if they all have it already.
+// \Seen is the other way round, since the list shows whether emails are unread: if any of the emails are unread they're
+// all marked as read, otherwise they're all marked as unread.
+// The list is updated straight away, and the emails that couldn't be updated are rolled back afterwards.
+func (m *EmailListModel) toggleSelectedFlag(flag core.Flag) tea.Cmd {
+	ids := m.selectedIds()
+	if len(ids) == 0 {
+		return nil
+	}
+
+	emails := m.emailsById()
+	set := false
+	for _, id := range ids {
+		if email, ok := emails[id]; ok && !email.HasFlag(flag) {
+			set = true
+			break
+		}
+	}
+
+	previous := m.setFlagLocally(ids, flag, set)
+
+	update := func() tea.Msg {
+		var err error
+		if set {
+			err = m.backend.AddFlags(ids, flag)
+		} else {
+			err = m.backend.RemoveFlags(ids, flag)
+		}
+		return flagsChangedMessage{
+			flag:     flag,
+			set:      set,
+			previous: previous,
+			error:    err,
+		}
+	}
+	return tea.Batch(update, m.startProgress(fmt.Sprintf("Updating %s…", pluralise(len(ids)))))
+}
+
+// handleFlagsChanged reports the result of changing flags, rolling back any emails that couldn't be updated.
+func (m *EmailListModel) handleFlagsChanged(msg flagsChangedMessage) tea.Cmd {
+	m.stopProgress()
+
+	var bulkError *core.BulkError
+	if msg.error != nil && !errors.As(msg.error, &bulkError) {
+		for id, had := range msg.previous {
+			m.setFlagLocally([]core.EmailId{id}, msg.flag, had)
+		}
+		return m.list.NewStatusMessage("Failed to update flags: " + msg.error.Error())
+	}
+
+	updated := maps.Clone(msg.previous)
+	var failed int
+	if bulkError != nil {
+		for _, id := range bulkError.Failed {
+			if had, ok := msg.previous[id]; ok {
+				m.setFlagLocally([]core.EmailId{id}, msg.flag, had)
+				delete(updated, id)
+				failed++
+			}
+		}
+	}
+	if len(updated) == 0 {
+		return m.list.NewStatusMessage("Failed to update flags: " + msg.error.Error())
+	}
+
+	return m.recordUndo(undo.FlagChange{
+		Description: describeFlagChange(msg.flag, msg.set, len(updated)),
+		Flag:        msg.flag,
+		Previous:    updated,
+	}, failed)
+}
+
+// describeFlagChange summarises a flag change for the status bar, e.g. "Marked 2 emails as read".
+func describeFlagChange(flag core.Flag, set bool, count int) string {
+	switch {
+	case flag == core.FlagSeen && set:
+		return fmt.Sprintf("Marked %s as read", pluralise(count))
+	case flag == core.FlagSeen:
+		return fmt.Sprintf("Marked %s as unread", pluralise(count))
+	case flag == core.FlagFlagged && set:
+		return fmt.Sprintf("Starred %s", pluralise(count))
+	case flag == core.FlagFlagged:
+		return fmt.Sprintf("Unstarred %s", pluralise(count))
+	default:
+		return fmt.Sprintf("Updated %s", pluralise(count))
+	}
+}
+
+// recordUndo adds an operation to the undo stack, and says how to undo it in the status bar.
+func (m *EmailListModel) recordUndo(operation undo.Operation, failed int) tea.Cmd {
+	status := operation.Describe()
+	if failed > 0 {
+		status += fmt.Sprintf(" (%d failed)", failed)
+	}
+	return tea.Batch(
+		m.list.NewStatusMessage(status+" — u to undo"),
+		func() tea.Msg {
+			return ui.RecordUndoMessage{Operation: operation}
+		},
+	)
+}
+
+// handleUndone shows the result of undoing an operation, and reloads the threads to show what changed.
+func (m *EmailListModel) handleUndone(msg ui.UndoneMessage) tea.Cmd {
+	if errors.Is(msg.Error, undo.ErrNothingToUndo) {
+		return m.list.NewStatusMessage("Nothing to undo")
+	}
+	if msg.Error != nil {
+		return tea.Batch(m.list.NewStatusMessage("Failed to undo: "+msg.Error.Error()), m.loadThreads())
+	}
+	return tea.Batch(m.list.NewStatusMessage("Undone: "+msg.Operation.Describe()), m.loadThreads())
+}
+
+// emailsById indexes the emails in the loaded threads.
+func (m *EmailListModel) emailsById() map[core.EmailId]*core.EmailMetadata {
+	emails := map[core.EmailId]*core.EmailMetadata{}
+	for _, thread := range m.threads {
+		for _, email := range thread.Emails() {
+			emails[email.Id] = &email
+		}
+	}
+	return emails
+}
+
+// setFlagLocally updates the flags of emails in the loaded threads, returning whether each email had the flag before.
+func (m *EmailListModel) setFlagLocally(ids []core.EmailId, flag core.Flag, set bool) map[core.EmailId]bool {
+	previous := map[core.EmailId]bool{}
+	var visit func(thread *core.Thread)
+	visit = func(thread *core.Thread) {
+		if thread.Email != nil && slices.Contains(ids, thread.Email.Id) {
+			previous[thread.Email.Id] = thread.Email.HasFlag(flag)
+			thread.Email.SetFlag(flag, set)
+		}
+		for _, child := range thread.Children {
+			visit(child)
+		}
+	}
+	for _, thread := range m.threads {
+		visit(thread)
+	}
+
+	m.refreshItems()
+	return previous
+}
+
+// moveSelected moves, copies or deletes the selected emails.
+// Emails that are moved away are removed from the list straight away, and restored if the move fails.
+func (m *EmailListModel) moveSelected(action moveAction, mailbox string) tea.Cmd {
+	ids := m.selectedIds()
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if action != actionCopy {
+		for _, id := range ids {
+			m.hidden[id] = true
+			m.moving[id] = true
+			delete(m.selected, id)
+		}
+		m.refreshItems()
+	}
+
+	move := func() tea.Msg {
+		var (
+			result *core.MoveResult
+			err    error
+		)
+		switch action {
+		case actionDelete:
+			result, err = m.backend.DeleteEmails(ids)
+		case actionCopy:
+			result, err = m.backend.CopyEmails(ids, mailbox)
+		default:
+			result, err = m.backend.MoveEmails(ids, mailbox)
+		}
+		return emailsMovedMessage{
+			action:  action,
+			ids:     ids,
+			mailbox: mailbox,
+			result:  result,
+			error:   err,
+		}
+	}
+	return tea.Batch(move, m.startProgress(action.progress(len(ids), mailbox)))
+}
+
+// handleEmailsMoved reports the result of moving emails, showing any emails that couldn't be moved again.
+func (m *EmailListModel) handleEmailsMoved(msg emailsMovedMessage) tea.Cmd {
+	m.stopProgress()
+	for _, id := range msg.ids {
+		delete(m.moving, id)
+	}
+
+	var bulkError *core.BulkError
+	if msg.error != nil && !errors.As(msg.error, &bulkError) {
+		for _, id := range msg.ids {
+			delete(m.hidden, id)
+		}
+		m.refreshItems()
+		return m.list.NewStatusMessage(fmt.Sprintf("Failed to %s: %s", msg.action.verb(), msg.error))
+	}
+
+	var failed int
+	if bulkError != nil {
+		for _, id := range bulkError.Failed {
+			delete(m.hidden, id)
+		}
+		failed = len(bulkError.Failed)
+		m.refreshItems()
+	}
+
+	description := msg.action.describe(len(msg.ids)-failed, msg.mailbox)
+	// copies don't change the inbox, so there's nothing to undo
+	if msg.action != actionCopy && undo.CanUndoMove(msg.result) {
+		return m.recordUndo(undo.Move{Description: description, Result: msg.result}, failed)
+	}
+	if failed > 0 {
+		description += fmt.Sprintf(" (%d failed)", failed)
+	}
+	return m.list.NewStatusMessage(description)
+}
+
+// keepPendingState carries what's hidden and selected over to threads that have just been reloaded. Emails stay hidden
+// only while they're still being moved, since the ones that were moved are gone from the reloaded threads, and the
+// selection keeps the emails that are still there.
+func (m *EmailListModel) keepPendingState() {
+	emails := m.emailsById()
+	m.hidden = maps.Clone(m.moving)
+	maps.DeleteFunc(m.selected, func(id core.EmailId, _ bool) bool {
+		_, ok := emails[id]
+		return !ok || m.hidden[id]
+	})
+}
+
+// refreshItems rebuilds the list items after the threads have changed, keeping the cursor in the same place.
+func (m *EmailListModel) refreshItems() {
+	index := m.list.Index()
+	m.setItems(m.buildItems())
+	m.list.Select(min(index, max(len(m.list.VisibleItems())-1, 0)))
+}
+
+// setItems replaces the list items.
+// If a filter is applied, the list filters the new items asynchronously, but that's done straight away instead so the
+// cursor can be placed in the filtered items.
+func (m *EmailListModel) setItems(items []list.Item) {
+	if cmd := m.list.SetItems(items); cmd != nil {
+		m.list, _ = m.list.Update(cmd())
+	}
+	m.list.Title = m.title()
+}
+
+func (m *EmailListModel) loadMailboxes() tea.Cmd {
+	return func() tea.Msg {
+		mailboxes, err := m.backend.ListMailboxes()
+		return mailboxesLoadedMessage{
+			mailboxes: mailboxes,
+			error:     err,
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package email_list
 
 import (
+	"slices"
+	"time"
+
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 
@@ -8,26 +11,50 @@ import (
 	"github.com/bengesoff/mail-tui/internal/ui"
 )
 
-type emailsLoadedMessage struct {
-	emails []core.EmailMetadata
-	error  error
+type threadsLoadedMessage struct {
+	threads []*core.Thread
+	error   error
 }
 
 type EmailListModel struct {
-	emails  []core.EmailMetadata
+	threads []*core.Thread
 	backend core.EmailBackend
 
+	// expanded holds the threads that are showing all their emails, keyed by the id of the thread's first email
+	expanded map[core.EmailId]bool
+	// hidden holds emails that are being moved away, which are shown again if moving them fails
+	hidden map[core.EmailId]bool
+	// moving holds the hidden emails whose moves are still running, which stay hidden when the threads are reloaded
+	// since the server may not have moved them yet
+	moving map[core.EmailId]bool
+	// selected holds the emails picked for a bulk action
+	selected map[core.EmailId]bool
+	// anchor is the index of the item that was last toggled, which range selections start from
+	anchor int
+	// pending counts the actions that are still running, to show a spinner while there are any
+	pending int
+
+	mailboxes []core.Mailbox
+	picker    *mailboxPicker
+
 	loading bool
 	error   string
 
-	list list.Model
+	list   list.Model
+	width  int
+	height int
 }
 
 func NewEmailListModel(backend core.EmailBackend) *EmailListModel {
 	return &EmailListModel{
-		emails:  []core.EmailMetadata{},
-		backend: backend,
-		list:    list.New([]list.Item{}, list.NewDefaultDelegate(), 0, 0),
+		threads:  []*core.Thread{},
+		backend:  backend,
+		expanded: map[core.EmailId]bool{},
+		hidden:   map[core.EmailId]bool{},
+		moving:   map[core.EmailId]bool{},
+		selected: map[core.EmailId]bool{},
+		anchor:   -1,
+		list:     newList(nil),
 	}
 }
 
@@ -38,43 +65,128 @@ func (m *EmailListModel) Init() tea.Cmd {
 func (m *EmailListModel) Update(msg tea.Msg) (*EmailListModel, tea.Cmd) {
 	var commands []tea.Cmd
 
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.picker != nil {
+		return m, m.updatePicker(keyMsg)
+	}
+
 	switch msg := msg.(type) {
 	case ui.ShowEmailListMessage:
 		m.loading = true
 		m.error = ""
-		commands = append(commands, m.loadEmails())
-	case emailsLoadedMessage:
+		m.picker = nil
+		commands = append(commands, m.loadThreads(), m.loadMailboxes())
+		if msg.Status != "" {
+			commands = append(commands, m.list.NewStatusMessage(msg.Status))
+		}
+	case threadsLoadedMessage:
 		m.loading = false
 		if msg.error != nil {
 			m.error = msg.error.Error()
 		} else {
-			m.emails = msg.emails
+			m.threads = msg.threads
+			m.keepPendingState()
+			m.anchor = -1
 			m.error = ""
-			var items []list.Item
-			for _, email := range m.emails {
-				items = append(items, &emailListItem{email})
-			}
-			m.list = newList(items)
-			commands = append(commands, tea.WindowSize())
+			m.refreshItems()
+		}
+	case mailboxesLoadedMessage:
+		// the mailboxes are only needed for moving emails, so failing to load them is reported when they're needed
+		if msg.error == nil {
+			m.mailboxes = msg.mailboxes
 		}
+	case emailsMovedMessage:
+		return m, m.handleEmailsMoved(msg)
+	case flagsChangedMessage:
+		return m, m.handleFlagsChanged(msg)
+	case ui.UndoneMessage:
+		return m, m.handleUndone(msg)
+	case ui.OutboxEventMessage:
+		return m, m.list.NewStatusMessage(msg.Event.Describe())
 	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
 		m.list.SetSize(msg.Width, msg.Height)
+		if m.picker != nil {
+			m.picker.list.SetSize(msg.Width, msg.Height)
+		}
 	case tea.KeyMsg:
+		if m.filtering() {
+			break
+		}
 		switch msg.String() {
 		case "q":
 			return m, tea.Quit
 		case tea.KeyEnter.String():
+			selectedItem, ok := m.list.SelectedItem().(*emailListItem)
+			if !ok {
+				break
+			}
 			commands = append(commands, func() tea.Msg {
-				i := m.list.GlobalIndex()
-				selectedEmail := m.emails[i]
-				return ui.ShowEmailViewerMessage{
-					EmailId: selectedEmail.Id,
+				message := ui.ShowEmailViewerMessage{
+					EmailId: selectedItem.Id,
+				}
+				// a collapsed thread opens as a conversation, otherwise just the selected email is shown
+				if selectedItem.isCollapsedThread() {
+					message.Conversation = selectedItem.conversationIds()
 				}
+				return message
 			})
+		case "tab":
+			m.toggleSelectedThread()
+			return m, nil
 		case "c":
 			commands = append(commands, func() tea.Msg {
 				return ui.ShowEmailComposerMessage{}
 			})
+		case "D":
+			commands = append(commands, func() tea.Msg {
+				return ui.ShowDraftListMessage{}
+			})
+		case "O":
+			commands = append(commands, func() tea.Msg {
+				return ui.ShowOutboxMessage{}
+			})
+		case "C":
+			commands = append(commands, func() tea.Msg {
+				return ui.ShowContactListMessage{}
+			})
+		case "d":
+			return m, m.moveSelected(actionDelete, "")
+		case "a":
+			archive, ok := core.FindMailbox(m.mailboxes, core.MailboxRoleArchive)
+			if !ok {
+				return m, m.list.NewStatusMessage("No archive mailbox found")
+			}
+			return m, m.moveSelected(actionArchive, archive.Name)
+		case "n":
+			return m, m.toggleSelectedFlag(core.FlagSeen)
+		case "s":
+			return m, m.toggleSelectedFlag(core.FlagFlagged)
+		case " ":
+			m.toggleSelection()
+			return m, nil
+		case "V":
+			m.selectRange()
+			return m, nil
+		case "*":
+			m.selectAll()
+			return m, nil
+		case "esc":
+			// esc clears the filter first if there is one, then the selection
+			if len(m.selected) > 0 && m.list.FilterState() == list.Unfiltered {
+				m.clearSelection()
+				return m, nil
+			}
+		case "u":
+			return m, func() tea.Msg {
+				return ui.UndoMessage{}
+			}
+		case "m":
+			m.openPicker(actionMove)
+			return m, nil
+		case "y":
+			m.openPicker(actionCopy)
+			return m, nil
 		}
 	}
 
@@ -94,29 +206,120 @@ func (m *EmailListModel) View() string {
 		return "Error loading emails: " + m.error
 	}
 
+	if m.picker != nil {
+		return m.picker.list.View()
+	}
+
 	return m.list.View()
 }
 
-func (m *EmailListModel) loadEmails() tea.Cmd {
+func (m *EmailListModel) loadThreads() tea.Cmd {
 	return func() tea.Msg {
-		emails, err := m.backend.ListEmails()
+		threads, err := m.backend.ListThreads()
 		if err != nil {
-			return emailsLoadedMessage{
-				emails: nil,
-				error:  err,
+			return threadsLoadedMessage{
+				threads: nil,
+				error:   err,
 			}
 		}
-		return emailsLoadedMessage{
-			emails: emails,
-			error:  nil,
+		return threadsLoadedMessage{
+			threads: threads,
+			error:   nil,
 		}
 	}
 }
 
+// toggleSelectedThread expands or collapses the thread containing the selected email,
+// keeping the thread selected.
+func (m *EmailListModel) toggleSelectedThread() {
+	selectedItem, ok := m.list.SelectedItem().(*emailListItem)
+	if !ok {
+		return
+	}
+	m.expanded[selectedItem.threadId] = !m.expanded[selectedItem.threadId]
+
+	m.setItems(m.buildItems())
+	for i, item := range m.list.VisibleItems() {
+		if item.(*emailListItem).threadId == selectedItem.threadId {
+			m.list.Select(i)
+			break
+		}
+	}
+}
+
+// buildItems flattens the threads into list items.
+// Collapsed threads are shown as a single item, and expanded threads show every email indented by its depth.
+func (m *EmailListModel) buildItems() []list.Item {
+	var items []list.Item
+	for _, thread := range m.threads {
+		conversation := thread.Emails()
+		if len(conversation) == 0 {
+			continue
+		}
+		conversation = slices.DeleteFunc(conversation, func(email core.EmailMetadata) bool {
+			return m.hidden[email.Id]
+		})
+		if len(conversation) == 0 {
+			continue
+		}
+		threadId := conversation[0].Id
+		expanded := m.expanded[threadId]
+
+		if !expanded {
+			item := &emailListItem{
+				EmailMetadata: conversation[0],
+				threadId:      threadId,
+				conversation:  conversation,
+			}
+			item.selected = m.isSelected(item)
+			items = append(items, item)
+			continue
+		}
+
+		for _, node := range flattenThread(thread, 0, m.hidden) {
+			item := &emailListItem{
+				EmailMetadata: *node.email,
+				threadId:      threadId,
+				depth:         node.depth,
+				expanded:      true,
+			}
+			if node.email.Id == threadId {
+				item.conversation = conversation
+			}
+			item.selected = m.isSelected(item)
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+type threadNode struct {
+	email *core.EmailMetadata
+	depth int
+}
+
+// flattenThread lists the emails in a thread depth-first.
+// Placeholders for missing emails and hidden emails aren't shown, so their replies are shown at their depth instead.
+func flattenThread(thread *core.Thread, depth int, hidden map[core.EmailId]bool) []threadNode {
+	var nodes []threadNode
+	childDepth := depth
+	if thread.Email != nil && !hidden[thread.Email.Id] {
+		nodes = append(nodes, threadNode{email: thread.Email, depth: depth})
+		childDepth++
+	}
+	for _, child := range thread.Children {
+		nodes = append(nodes, flattenThread(child, childDepth, hidden)...)
+	}
+	return nodes
+}
+
 func newList(items []list.Item) list.Model {
 	list := list.New(items, &listItemDelegate{}, 0, 0)
 	list.Title = "Inbox"
 	list.SetStatusBarItemName("email", "emails")
-	list.SetFilteringEnabled(false)
+	list.StatusMessageLifetime = 3 * time.Second
+	// d and u are used for deleting emails and undoing rather than paging
+	list.KeyMap.NextPage.SetKeys("right", "l", "pgdown", "f")
+	list.KeyMap.PrevPage.SetKeys("left", "h", "pgup", "b")
 	return list
 }
@@ -0,0 +1,475 @@
+package email_list
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/threading"
+	"github.com/bengesoff/mail-tui/internal/ui"
+	"github.com/bengesoff/mail-tui/internal/undo"
+)
+
+type mockBackend struct {
+	moveErr  error
+	flagsErr error
+}
+
+func partialFailure(ids ...core.EmailId) error {
+	return &core.BulkError{Failed: ids}
+}
+
+func (m *mockBackend) ListEmails() ([]core.EmailMetadata, error) {
+	return nil, nil
+}
+
+func (m *mockBackend) ListThreads() ([]*core.Thread, error) {
+	return nil, nil
+}
+
+func (m *mockBackend) GetEmail(id core.EmailId) (*core.Email, error) {
+	return nil, nil
+}
+
+func (m *mockBackend) GetRawEmail(id core.EmailId) ([]byte, error) {
+	return nil, nil
+}
+
+func (m *mockBackend) SendEmail(email core.OutgoingEmail) error {
+	return nil
+}
+
+func (m *mockBackend) SaveDraft(draft core.Draft) (*core.Draft, error) {
+	return &draft, nil
+}
+
+func (m *mockBackend) ListDrafts() ([]core.Draft, error) {
+	return nil, nil
+}
+
+func (m *mockBackend) DeleteDraft(id core.EmailId) error {
+	return nil
+}
+
+func (m *mockBackend) AddFlags(ids []core.EmailId, flags ...core.Flag) error {
+	return m.flagsErr
+}
+
+func (m *mockBackend) RemoveFlags(ids []core.EmailId, flags ...core.Flag) error {
+	return m.flagsErr
+}
+
+func (m *mockBackend) ListMailboxes() ([]core.Mailbox, error) {
+	return []core.Mailbox{{Name: "INBOX", Role: core.MailboxRoleInbox}, {Name: "Archive", Role: core.MailboxRoleArchive}}, nil
+}
+
+func (m *mockBackend) MoveEmails(ids []core.EmailId, mailbox string) (*core.MoveResult, error) {
+	return &core.MoveResult{Mailbox: mailbox}, m.moveErr
+}
+
+func (m *mockBackend) CopyEmails(ids []core.EmailId, mailbox string) (*core.MoveResult, error) {
+	return &core.MoveResult{Mailbox: mailbox}, m.moveErr
+}
+
+func (m *mockBackend) DeleteEmails(ids []core.EmailId) (*core.MoveResult, error) {
+	result := &core.MoveResult{Mailbox: "Trash", Ids: map[core.EmailId]core.EmailId{}}
+	for i, id := range ids {
+		result.Ids[id] = core.EmailId(fmt.Sprint(10 + i))
+	}
+	return result, m.moveErr
+}
+
+func (m *mockBackend) RestoreEmails(mailbox string, ids []core.EmailId) (*core.MoveResult, error) {
+	return &core.MoveResult{Mailbox: "INBOX"}, nil
+}
+
+func testThreads() []*core.Thread {
+	now := time.Now()
+	return threading.Thread([]core.EmailMetadata{
+		{Id: "1", MessageId: "1@x", Subject: "Standalone", SentAt: now},
+		{Id: "2", MessageId: "2@x", Subject: "Question", SentAt: now.Add(-2 * time.Hour), Flags: []core.Flag{core.FlagSeen}},
+		{Id: "3", MessageId: "3@x", Subject: "Re: Question", SentAt: now.Add(-1 * time.Hour), References: []string{"2@x"}},
+	})
+}
+
+func loadedModel(backend *mockBackend) *EmailListModel {
+	model := NewEmailListModel(backend)
+	// status messages wait for their lifetime before clearing, which would slow down running the commands in tests
+	model.list.StatusMessageLifetime = time.Millisecond
+	model, _ = model.Update(threadsLoadedMessage{threads: testThreads()})
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	return model
+}
+
+func keyPress(key string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+}
+
+// findMessage runs the commands in a batch in order until one returns a message of type T.
+func findMessage[T tea.Msg](t *testing.T, cmd tea.Cmd) T {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("Expected a command")
+	}
+	msg := cmd()
+	if found, ok := msg.(T); ok {
+		return found
+	}
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		for _, cmd := range batch {
+			if cmd == nil {
+				continue
+			}
+			if found, ok := cmd().(T); ok {
+				return found
+			}
+		}
+	}
+	var zero T
+	t.Fatalf("Expected a %T message", zero)
+	return zero
+}
+
+func TestEmailListModel_ThreadsLoadedMessage(t *testing.T) {
+	model := loadedModel(&mockBackend{})
+
+	items := model.list.Items()
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items for 2 collapsed threads, got %d", len(items))
+	}
+
+	thread := items[1].(*emailListItem)
+	if !thread.isCollapsedThread() {
+		t.Error("Expected the second item to be a collapsed thread")
+	}
+	if len(thread.conversation) != 2 {
+		t.Errorf("Expected the thread to contain 2 emails, got %d", len(thread.conversation))
+	}
+}
+
+func TestEmailListModel_EnterOpensConversationOldestFirst(t *testing.T) {
+	now := time.Now()
+	// the first reply has its own reply, which was sent after the second reply to the question
+	threads := threading.Thread([]core.EmailMetadata{
+		{Id: "1", MessageId: "1@x", Subject: "Question", SentAt: now.Add(-4 * time.Hour)},
+		{Id: "2", MessageId: "2@x", Subject: "Re: Question", SentAt: now.Add(-3 * time.Hour), References: []string{"1@x"}},
+		{Id: "3", MessageId: "3@x", Subject: "Re: Question", SentAt: now.Add(-1 * time.Hour), References: []string{"1@x", "2@x"}},
+		{Id: "4", MessageId: "4@x", Subject: "Re: Question", SentAt: now.Add(-2 * time.Hour), References: []string{"1@x"}},
+	})
+	model := NewEmailListModel(&mockBackend{})
+	model, _ = model.Update(threadsLoadedMessage{threads: threads})
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	message := findMessage[ui.ShowEmailViewerMessage](t, cmd)
+
+	want := []core.EmailId{"1", "2", "4", "3"}
+	if fmt.Sprint(message.Conversation) != fmt.Sprint(want) {
+		t.Errorf("Expected the conversation oldest first, %v, got %v", want, message.Conversation)
+	}
+}
+
+func TestEmailListModel_TabExpandsThread(t *testing.T) {
+	model := loadedModel(&mockBackend{})
+	model.list.Select(1)
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyTab})
+
+	items := model.list.Items()
+	if len(items) != 3 {
+		t.Fatalf("Expected 3 items after expanding the thread, got %d", len(items))
+	}
+	if reply := items[2].(*emailListItem); reply.depth != 1 {
+		t.Errorf("Expected the reply to be nested, got depth %d", reply.depth)
+	}
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyTab})
+
+	if len(model.list.Items()) != 2 {
+		t.Errorf("Expected the thread to collapse again, got %d items", len(model.list.Items()))
+	}
+}
+
+func TestEmailListModel_DeleteRemovesThreadOptimistically(t *testing.T) {
+	model := loadedModel(&mockBackend{})
+	model.list.Select(1)
+
+	model, cmd := model.Update(keyPress("d"))
+
+	if cmd == nil {
+		t.Fatal("Expected a command to delete the emails")
+	}
+	if len(model.list.Items()) != 1 {
+		t.Errorf("Expected the thread to be removed straight away, got %d items", len(model.list.Items()))
+	}
+
+	msg := findMessage[emailsMovedMessage](t, cmd)
+	if len(msg.ids) != 2 {
+		t.Errorf("Expected both emails in the thread to be deleted, got %v", msg.ids)
+	}
+
+	model, _ = model.Update(msg)
+
+	if len(model.list.Items()) != 1 {
+		t.Errorf("Expected the thread to stay removed, got %d items", len(model.list.Items()))
+	}
+}
+
+func TestEmailListModel_ReloadKeepsPendingMovesAndSelection(t *testing.T) {
+	model := loadedModel(&mockBackend{})
+
+	model, deleted := model.Update(keyPress("d"))
+	model, _ = model.Update(keyPress(" "))
+
+	// the server hasn't deleted the email yet, so it's still in the reloaded threads
+	model, _ = model.Update(threadsLoadedMessage{threads: testThreads()})
+
+	items := model.list.Items()
+	if len(items) != 1 || items[0].(*emailListItem).Id == "1" {
+		t.Fatalf("Expected the email being deleted to stay hidden, got %d items", len(items))
+	}
+	if ids := model.selectedIds(); len(ids) != 2 {
+		t.Errorf("Expected the selection to be kept, got %v", ids)
+	}
+
+	model, _ = model.Update(findMessage[emailsMovedMessage](t, deleted))
+	// the reply was moved away by another client, so it can't stay selected
+	now := time.Now()
+	model, _ = model.Update(threadsLoadedMessage{threads: threading.Thread([]core.EmailMetadata{
+		{Id: "1", MessageId: "1@x", Subject: "Standalone", SentAt: now},
+		{Id: "2", MessageId: "2@x", Subject: "Question", SentAt: now.Add(-time.Hour)},
+	})})
+
+	if len(model.hidden) != 0 || len(model.list.Items()) != 2 {
+		t.Errorf("Expected nothing to be hidden once the delete had finished, got %v", model.hidden)
+	}
+	if ids := model.selectedIds(); len(ids) != 1 || ids[0] != "2" {
+		t.Errorf("Expected only the email that's still there to stay selected, got %v", ids)
+	}
+}
+
+func TestEmailListModel_FailedMoveIsRolledBack(t *testing.T) {
+	model := loadedModel(&mockBackend{moveErr: errors.New("connection lost")})
+	model, _ = model.Update(mailboxesLoadedMessage{mailboxes: []core.Mailbox{{Name: "Archive", Role: core.MailboxRoleArchive}}})
+
+	model, cmd := model.Update(keyPress("a"))
+
+	if len(model.list.Items()) != 1 {
+		t.Fatalf("Expected the email to be removed straight away, got %d items", len(model.list.Items()))
+	}
+
+	model, _ = model.Update(findMessage[emailsMovedMessage](t, cmd))
+
+	if len(model.list.Items()) != 2 {
+		t.Errorf("Expected the email to be restored after the failure, got %d items", len(model.list.Items()))
+	}
+}
+
+func TestEmailListModel_MovePicker(t *testing.T) {
+	model := loadedModel(&mockBackend{})
+	model, _ = model.Update(mailboxesLoadedMessage{mailboxes: []core.Mailbox{
+		{Name: "INBOX", Role: core.MailboxRoleInbox},
+		{Name: "Work"},
+	}})
+
+	model, _ = model.Update(keyPress("m"))
+
+	if model.picker == nil {
+		t.Fatal("Expected the mailbox picker to be open")
+	}
+	if len(model.picker.list.Items()) != 1 {
+		t.Errorf("Expected the inbox to be excluded from the picker, got %d items", len(model.picker.list.Items()))
+	}
+
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if model.picker != nil {
+		t.Error("Expected the picker to close after choosing a mailbox")
+	}
+	msg := findMessage[emailsMovedMessage](t, cmd)
+	if msg.mailbox != "Work" {
+		t.Errorf("Expected the email to be moved to Work, got '%s'", msg.mailbox)
+	}
+}
+
+func TestEmailListModel_ToggleUnread(t *testing.T) {
+	model := loadedModel(&mockBackend{})
+	model.list.Select(1)
+
+	// one email in the thread is unread, so the whole thread is marked as read
+	model, cmd := model.Update(keyPress("n"))
+
+	thread := model.list.Items()[1].(*emailListItem)
+	if thread.unread() {
+		t.Error("Expected the thread to be marked as read straight away")
+	}
+
+	msg := findMessage[flagsChangedMessage](t, cmd)
+	if !msg.set || msg.flag != core.FlagSeen {
+		t.Errorf("Expected \\Seen to be added, got %+v", msg)
+	}
+
+	model, _ = model.Update(keyPress("n"))
+
+	thread = model.list.Items()[1].(*emailListItem)
+	if !thread.unread() {
+		t.Error("Expected the thread to be marked as unread again")
+	}
+}
+
+func TestEmailListModel_FailedStarIsRolledBack(t *testing.T) {
+	model := loadedModel(&mockBackend{flagsErr: errors.New("read-only mailbox")})
+
+	model, cmd := model.Update(keyPress("s"))
+
+	if !model.list.Items()[0].(*emailListItem).hasFlag(core.FlagFlagged) {
+		t.Fatal("Expected the email to be starred straight away")
+	}
+
+	model, _ = model.Update(findMessage[flagsChangedMessage](t, cmd))
+
+	if model.list.Items()[0].(*emailListItem).hasFlag(core.FlagFlagged) {
+		t.Error("Expected the star to be removed after the failure")
+	}
+}
+
+func TestEmailListModel_SpaceTogglesSelection(t *testing.T) {
+	model := loadedModel(&mockBackend{})
+
+	model, _ = model.Update(keyPress(" "))
+
+	if !model.list.Items()[0].(*emailListItem).selected {
+		t.Error("Expected the first email to be selected")
+	}
+	if model.list.Index() != 1 {
+		t.Errorf("Expected the cursor to move down, got %d", model.list.Index())
+	}
+
+	// selecting a collapsed thread selects every email in it
+	model, _ = model.Update(keyPress(" "))
+
+	if ids := model.selectedIds(); len(ids) != 3 {
+		t.Errorf("Expected 3 selected emails, got %v", ids)
+	}
+	if model.list.Title != "Inbox (3 selected)" {
+		t.Errorf("Expected the title to show the selection, got '%s'", model.list.Title)
+	}
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+
+	if len(model.selected) != 0 {
+		t.Errorf("Expected esc to clear the selection, got %v", model.selected)
+	}
+}
+
+func TestEmailListModel_BulkDelete(t *testing.T) {
+	model := loadedModel(&mockBackend{})
+
+	model, _ = model.Update(keyPress("*"))
+	model, cmd := model.Update(keyPress("d"))
+
+	if len(model.list.Items()) != 0 {
+		t.Errorf("Expected every email to be removed straight away, got %d items", len(model.list.Items()))
+	}
+	msg := findMessage[emailsMovedMessage](t, cmd)
+	if len(msg.ids) != 3 {
+		t.Errorf("Expected all 3 emails to be deleted in one go, got %v", msg.ids)
+	}
+	if len(model.selected) != 0 {
+		t.Errorf("Expected the deleted emails to be deselected, got %v", model.selected)
+	}
+}
+
+func TestEmailListModel_PartiallyFailedMoveIsRolledBack(t *testing.T) {
+	model := loadedModel(&mockBackend{moveErr: partialFailure("1")})
+
+	model, _ = model.Update(keyPress("*"))
+	model, cmd := model.Update(keyPress("d"))
+	model, _ = model.Update(findMessage[emailsMovedMessage](t, cmd))
+
+	items := model.list.Items()
+	if len(items) != 1 || items[0].(*emailListItem).Id != "1" {
+		t.Errorf("Expected only the email that failed to be restored, got %d items", len(items))
+	}
+}
+
+func TestEmailListModel_PartiallyFailedFlagIsRolledBack(t *testing.T) {
+	model := loadedModel(&mockBackend{flagsErr: partialFailure("3")})
+
+	model, _ = model.Update(keyPress("*"))
+	model, cmd := model.Update(keyPress("s"))
+	model, _ = model.Update(findMessage[flagsChangedMessage](t, cmd))
+
+	emails := model.emailsById()
+	if !emails["1"].HasFlag(core.FlagFlagged) || !emails["2"].HasFlag(core.FlagFlagged) {
+		t.Error("Expected the emails that were updated to stay starred")
+	}
+	if emails["3"].HasFlag(core.FlagFlagged) {
+		t.Error("Expected the email that failed to be rolled back")
+	}
+}
+
+func TestEmailListModel_DeleteCanBeUndone(t *testing.T) {
+	model := loadedModel(&mockBackend{})
+	model.list.Select(1)
+
+	model, cmd := model.Update(keyPress("d"))
+	model, cmd = model.Update(findMessage[emailsMovedMessage](t, cmd))
+
+	record := findMessage[ui.RecordUndoMessage](t, cmd)
+	move, ok := record.Operation.(undo.Move)
+	if !ok {
+		t.Fatalf("Expected the move to be recorded, got %T", record.Operation)
+	}
+	if len(move.Result.Ids) != 2 || move.Result.Mailbox != "Trash" {
+		t.Errorf("Expected the new ids in the trash to be recorded, got %+v", move.Result)
+	}
+
+	_, cmd = model.Update(keyPress("u"))
+
+	if _, ok := cmd().(ui.UndoMessage); !ok {
+		t.Error("Expected u to ask for the move to be undone")
+	}
+}
+
+func TestEmailListModel_CopyIsNotUndoable(t *testing.T) {
+	model := loadedModel(&mockBackend{})
+	model, _ = model.Update(mailboxesLoadedMessage{mailboxes: []core.Mailbox{{Name: "Work"}}})
+
+	model, _ = model.Update(keyPress("y"))
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	_, cmd = model.Update(findMessage[emailsMovedMessage](t, cmd))
+
+	if _, ok := cmd().(ui.RecordUndoMessage); ok {
+		t.Error("Expected copying not to be recorded for undo")
+	}
+}
+
+func TestEmailListModel_UndoneMessage(t *testing.T) {
+	model := loadedModel(&mockBackend{})
+
+	_, cmd := model.Update(ui.UndoneMessage{Operation: undo.Move{Description: "Deleted 2 emails"}})
+
+	findMessage[threadsLoadedMessage](t, cmd)
+}
+
+func TestListItemDelegate_ShowsMailingList(t *testing.T) {
+	model := NewEmailListModel(&mockBackend{})
+	model, _ = model.Update(threadsLoadedMessage{threads: threading.Thread([]core.EmailMetadata{
+		{Id: "1", MessageId: "1@x", Subject: "Release notes", List: &core.MailingList{Id: "announce.example.com", Name: "Announcements"}},
+	})})
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+
+	if view := ansi.Strip(model.View()); !strings.Contains(view, "[Announcements] Release notes") {
+		t.Errorf("Expected the email to be tagged with its list, got:\n%s", view)
+	}
+	if value := model.list.Items()[0].FilterValue(); !strings.Contains(value, "Announcements") {
+		t.Errorf("Expected the list name to be filterable, got %q", value)
+	}
+}
@@ -3,6 +3,8 @@ package email_list
 import (
 	"fmt"
 	"io"
+	"slices"
+	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
@@ -16,6 +18,9 @@ var (
 	emailSubjectStyle = lipgloss.NewStyle().
 				Bold(true)
 
+	listNameStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{Light: "#0087AF", Dark: "#5FAFD7"})
+
 	normalStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.AdaptiveColor{Light: "#555555", Dark: "#bbbbbb"}).
 			Padding(0, 0, 0, 2)
@@ -39,10 +44,69 @@ var (
 
 type emailListItem struct {
 	core.EmailMetadata
+
+	// threadId identifies the thread the email belongs to, using the id of the thread's first email
+	threadId core.EmailId
+	// conversation holds every email in the thread, and is only set on the thread's first item
+	conversation []core.EmailMetadata
+	// depth is how far the email is nested in an expanded thread
+	depth    int
+	expanded bool
+	// selected is whether the item has been picked for a bulk action
+	selected bool
+}
+
+// isCollapsedThread reports whether the item stands in for a whole thread of several emails.
+func (i *emailListItem) isCollapsedThread() bool {
+	return !i.expanded && len(i.conversation) > 1
+}
+
+// conversationIds returns the ids of a collapsed thread's emails, oldest first. The thread itself follows the replies,
+// so a reply to an earlier email in a branched thread comes after later emails in another branch.
+func (i *emailListItem) conversationIds() []core.EmailId {
+	emails := slices.Clone(i.conversation)
+	slices.SortStableFunc(emails, func(a, b core.EmailMetadata) int {
+		return a.SentAt.Compare(b.SentAt)
+	})
+	ids := make([]core.EmailId, 0, len(emails))
+	for _, email := range emails {
+		ids = append(ids, email.Id)
+	}
+	return ids
+}
+
+// unread reports whether the item has anything unread, which for a collapsed thread means any of its emails.
+func (i *emailListItem) unread() bool {
+	if i.isCollapsedThread() {
+		for _, email := range i.conversation {
+			if !email.IsRead() {
+				return true
+			}
+		}
+		return false
+	}
+	return !i.IsRead()
+}
+
+// hasFlag reports whether the item has a flag, which for a collapsed thread means any of its emails.
+func (i *emailListItem) hasFlag(flag core.Flag) bool {
+	if i.isCollapsedThread() {
+		for _, email := range i.conversation {
+			if email.HasFlag(flag) {
+				return true
+			}
+		}
+		return false
+	}
+	return i.HasFlag(flag)
 }
 
 func (i *emailListItem) FilterValue() string {
-	return i.Subject
+	value := i.Subject + " " + i.From
+	if i.List != nil {
+		value += " " + i.List.Label()
+	}
+	return value
 }
 
 type listItemDelegate struct{}
@@ -65,7 +129,7 @@ func (d *listItemDelegate) Render(w io.Writer, m list.Model, index int, item lis
 		return
 	}
 
-	unread := !email.IsRead
+	unread := email.unread()
 	selected := index == m.Index()
 
 	unreadIndicator := " "
@@ -73,11 +137,26 @@ func (d *listItemDelegate) Render(w io.Writer, m list.Model, index int, item lis
 		unreadIndicator = "●"
 	}
 
+	starIndicator := " "
+	if email.hasFlag(core.FlagFlagged) {
+		starIndicator = "★"
+	}
+
+	answeredIndicator := " "
+	if email.hasFlag(core.FlagAnswered) {
+		answeredIndicator = "↩"
+	}
+
 	selectedIndicator := " "
 	if selected {
 		selectedIndicator = ">"
 	}
 
+	checkIndicator := " "
+	if email.selected {
+		checkIndicator = "✓"
+	}
+
 	style := normalStyle.Render
 	if selected && unread {
 		style = selectedUnreadStyle.Render
@@ -87,15 +166,42 @@ func (d *listItemDelegate) Render(w io.Writer, m list.Model, index int, item lis
 		style = unreadStyle.Render
 	}
 
+	threadIndicator := ""
+	if len(email.conversation) > 1 {
+		if email.expanded {
+			threadIndicator = "▾ "
+		} else {
+			threadIndicator = fmt.Sprintf("▸ (%d) ", len(email.conversation))
+		}
+	}
+
+	indent := ""
+	if email.depth > 0 {
+		indent = strings.Repeat("  ", email.depth-1) + "└ "
+	}
+
+	// emails from mailing lists are tagged with the list, so they can be told apart from personal ones at a glance
+	listName := ""
+	if email.List != nil && email.List.Label() != "" {
+		listName = listNameStyle.Render("["+email.List.Label()+"]") + " "
+	}
+
 	sent, err := email.SentAt.MarshalText()
 	if err != nil {
 		return
 	}
 
-	_, _ = fmt.Fprintf(w, style("%s%s %s\n   %s (%s)"),
+	_, _ = fmt.Fprintf(w, style("%s%s%s%s%s %s%s%s%s\n      %s%s (%s)"),
 		selectedIndicator,
+		checkIndicator,
 		unreadIndicator,
+		starIndicator,
+		answeredIndicator,
+		indent,
+		threadIndicator,
+		listName,
 		emailSubjectStyle.Render(email.Subject),
+		strings.Repeat(" ", len([]rune(indent))),
 		email.From,
 		sent,
 	)
@@ -111,6 +217,34 @@ func (d *listItemDelegate) ShortHelp() []key.Binding {
 			key.WithKeys("c"),
 			key.WithHelp("c", "compose email"),
 		),
+		key.NewBinding(
+			key.WithKeys("tab"),
+			key.WithHelp("tab", "expand/collapse thread"),
+		),
+		key.NewBinding(
+			key.WithKeys("d"),
+			key.WithHelp("d", "delete"),
+		),
+		key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "archive"),
+		),
+		key.NewBinding(
+			key.WithKeys("n"),
+			key.WithHelp("n", "toggle unread"),
+		),
+		key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "toggle star"),
+		),
+		key.NewBinding(
+			key.WithKeys("u"),
+			key.WithHelp("u", "undo"),
+		),
+		key.NewBinding(
+			key.WithKeys(" "),
+			key.WithHelp("space", "select"),
+		),
 	}
 }
 
@@ -125,6 +259,72 @@ func (d *listItemDelegate) FullHelp() [][]key.Binding {
 				key.WithKeys("c"),
 				key.WithHelp("c", "compose email"),
 			),
+			key.NewBinding(
+				key.WithKeys("D"),
+				key.WithHelp("D", "drafts"),
+			),
+			key.NewBinding(
+				key.WithKeys("O"),
+				key.WithHelp("O", "outbox"),
+			),
+			key.NewBinding(
+				key.WithKeys("C"),
+				key.WithHelp("C", "contacts"),
+			),
+			key.NewBinding(
+				key.WithKeys("tab"),
+				key.WithHelp("tab", "expand/collapse thread"),
+			),
+		},
+		{
+			key.NewBinding(
+				key.WithKeys("d"),
+				key.WithHelp("d", "delete"),
+			),
+			key.NewBinding(
+				key.WithKeys("a"),
+				key.WithHelp("a", "archive"),
+			),
+			key.NewBinding(
+				key.WithKeys("m"),
+				key.WithHelp("m", "move to mailbox"),
+			),
+			key.NewBinding(
+				key.WithKeys("y"),
+				key.WithHelp("y", "copy to mailbox"),
+			),
+		},
+		{
+			key.NewBinding(
+				key.WithKeys("n"),
+				key.WithHelp("n", "toggle unread"),
+			),
+			key.NewBinding(
+				key.WithKeys("s"),
+				key.WithHelp("s", "toggle star"),
+			),
+			key.NewBinding(
+				key.WithKeys("u"),
+				key.WithHelp("u", "undo"),
+			),
+		},
+		{
+			key.NewBinding(
+				key.WithKeys(" "),
+				key.WithHelp("space", "select"),
+			),
+			key.NewBinding(
+				key.WithKeys("V"),
+				key.WithHelp("V", "select range"),
+			),
+			key.NewBinding(
+				key.WithKeys("*"),
+				key.WithHelp("*", "select all matching"),
+			),
+			key.NewBinding(
+				key.WithKeys("esc"),
+				key.WithHelp("esc", "clear selection"),
+			),
 		},
 	}
 }
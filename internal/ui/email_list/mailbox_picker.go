@@ -0,0 +1,81 @@
+package email_list
+
+import (
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+// mailboxPicker lets the user choose the mailbox to move or copy the selected emails to.
+type mailboxPicker struct {
+	action moveAction
+	list   list.Model
+}
+
+type mailboxItem struct {
+	core.Mailbox
+}
+
+func (i mailboxItem) Title() string {
+	return i.Name
+}
+
+func (i mailboxItem) Description() string {
+	return string(i.Role)
+}
+
+func (i mailboxItem) FilterValue() string {
+	return i.Name
+}
+
+func (m *EmailListModel) openPicker(action moveAction) {
+	var items []list.Item
+	for _, mailbox := range m.mailboxes {
+		if mailbox.Role == core.MailboxRoleInbox {
+			continue
+		}
+		items = append(items, mailboxItem{mailbox})
+	}
+
+	delegate := list.NewDefaultDelegate()
+	delegate.ShowDescription = false
+
+	pickerList := list.New(items, delegate, m.width, m.height)
+	pickerList.Title = "Choose a mailbox to " + action.verb() + " to"
+	pickerList.SetStatusBarItemName("mailbox", "mailboxes")
+	pickerList.DisableQuitKeybindings()
+
+	m.picker = &mailboxPicker{
+		action: action,
+		list:   pickerList,
+	}
+}
+
+func (m *EmailListModel) updatePicker(msg tea.KeyMsg) tea.Cmd {
+	// while typing a filter, keys are handled by the list itself
+	if m.picker.list.FilterState() != list.Filtering {
+		switch msg.String() {
+		case "esc":
+			if m.picker.list.FilterState() == list.Unfiltered {
+				m.picker = nil
+				return nil
+			}
+		case "q":
+			m.picker = nil
+			return nil
+		case "enter":
+			selectedItem, ok := m.picker.list.SelectedItem().(mailboxItem)
+			if !ok {
+				return nil
+			}
+			action := m.picker.action
+			m.picker = nil
+			return m.moveSelected(action, selectedItem.Name)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.picker.list, cmd = m.picker.list.Update(msg)
+	return cmd
+}
@@ -0,0 +1,142 @@
+package email_list
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+// itemIds returns the ids of the emails an item stands for, which for a collapsed thread is every email in the thread.
+func itemIds(item *emailListItem) []core.EmailId {
+	if !item.isCollapsedThread() {
+		return []core.EmailId{item.Id}
+	}
+
+	var ids []core.EmailId
+	for _, email := range item.conversation {
+		ids = append(ids, email.Id)
+	}
+	return ids
+}
+
+// isSelected reports whether every email an item stands for is part of the selection.
+func (m *EmailListModel) isSelected(item *emailListItem) bool {
+	for _, id := range itemIds(item) {
+		if !m.selected[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *EmailListModel) setSelected(item *emailListItem, selected bool) {
+	for _, id := range itemIds(item) {
+		if selected {
+			m.selected[id] = true
+		} else {
+			delete(m.selected, id)
+		}
+	}
+}
+
+// toggleSelection adds the item under the cursor to the selection, or removes it if it's already selected, then moves
+// the cursor down so several emails can be selected quickly.
+func (m *EmailListModel) toggleSelection() {
+	selectedItem, ok := m.list.SelectedItem().(*emailListItem)
+	if !ok {
+		return
+	}
+	m.setSelected(selectedItem, !m.isSelected(selectedItem))
+	m.anchor = m.list.Index()
+	m.refreshItems()
+	m.list.CursorDown()
+}
+
+// selectRange selects every item between the last item that was toggled and the cursor.
+func (m *EmailListModel) selectRange() {
+	if m.anchor < 0 {
+		m.toggleSelection()
+		return
+	}
+
+	items := m.list.VisibleItems()
+	start, end := min(m.anchor, m.list.Index()), max(m.anchor, m.list.Index())
+	for i := start; i <= end && i < len(items); i++ {
+		m.setSelected(items[i].(*emailListItem), true)
+	}
+	m.anchor = m.list.Index()
+	m.refreshItems()
+}
+
+// selectAll selects every item that's visible, which is the items matching the filter if there is one.
+// If they're all selected already, they're deselected instead.
+func (m *EmailListModel) selectAll() {
+	items := m.list.VisibleItems()
+	allSelected := true
+	for _, item := range items {
+		if !m.isSelected(item.(*emailListItem)) {
+			allSelected = false
+			break
+		}
+	}
+	for _, item := range items {
+		m.setSelected(item.(*emailListItem), !allSelected)
+	}
+	m.refreshItems()
+}
+
+func (m *EmailListModel) clearSelection() {
+	m.selected = map[core.EmailId]bool{}
+	m.anchor = -1
+	m.refreshItems()
+}
+
+// selectedIds returns the ids of the emails that actions apply to.
+// If any emails have been selected that's all of them, otherwise it's the emails under the cursor.
+func (m *EmailListModel) selectedIds() []core.EmailId {
+	if len(m.selected) > 0 {
+		var ids []core.EmailId
+		for id := range m.selected {
+			if !m.hidden[id] {
+				ids = append(ids, id)
+			}
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	selectedItem, ok := m.list.SelectedItem().(*emailListItem)
+	if !ok {
+		return nil
+	}
+	return itemIds(selectedItem)
+}
+
+func (m *EmailListModel) title() string {
+	if len(m.selected) == 0 {
+		return "Inbox"
+	}
+	return fmt.Sprintf("Inbox (%d selected)", len(m.selected))
+}
+
+// startProgress shows the spinner while bulk operations are running, along with a description of the operation.
+func (m *EmailListModel) startProgress(description string) tea.Cmd {
+	m.pending++
+	return tea.Batch(m.list.StartSpinner(), m.list.NewStatusMessage(description))
+}
+
+func (m *EmailListModel) stopProgress() {
+	m.pending = max(m.pending-1, 0)
+	if m.pending == 0 {
+		m.list.StopSpinner()
+	}
+}
+
+// filtering reports whether the user is typing a filter, in which case keys are handled by the list.
+func (m *EmailListModel) filtering() bool {
+	return m.list.FilterState() == list.Filtering
+}
@@ -1,10 +1,23 @@
 package email_viewer
 
 import (
+	"io"
+	"net/http"
+	"os"
+
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/identity"
+	"github.com/bengesoff/mail-tui/internal/links"
+	"github.com/bengesoff/mail-tui/internal/mailcap"
+	"github.com/bengesoff/mail-tui/internal/message"
+	"github.com/bengesoff/mail-tui/internal/outbox"
+	"github.com/bengesoff/mail-tui/internal/pgp"
+	"github.com/bengesoff/mail-tui/internal/senderauth"
+	"github.com/bengesoff/mail-tui/internal/smime"
+	"github.com/bengesoff/mail-tui/internal/termimage"
 	"github.com/bengesoff/mail-tui/internal/ui"
 )
 
@@ -13,24 +26,104 @@ type emailLoadedMessage struct {
 	error error
 }
 
+type conversationLoadedMessage struct {
+	emails []*core.Email
+	error  error
+}
+
 type emailMarkedReadMessage struct {
 	error error
 }
 
+type Options struct {
+	// OpenCommand opens links, which are passed to it as its last argument, like "xdg-open"
+	OpenCommand string
+	// Mailcap says which programs to view the parts of emails with
+	Mailcap *mailcap.Mailcap
+	// Images is how images attached to emails are drawn below them, which is off unless it's asked for
+	Images termimage.Protocol
+	// Outbox is where replies to invitations are queued to be sent
+	Outbox *outbox.Outbox
+	// Identities are the addresses that may have been invited to events, to reply as
+	Identities []identity.Identity
+	// SenderAuth says how to check who emails are really from, like whether to verify DKIM signatures locally
+	SenderAuth senderauth.Options
+	// Keyring verifies OpenPGP signatures and decrypts emails encrypted with OpenPGP, which are only described if it's
+	// nil
+	Keyring *pgp.Keyring
+	// SMIME verifies S/MIME signatures against its trusted authorities, and decrypts emails encrypted with S/MIME if it
+	// has a certificate and key
+	SMIME *smime.Config
+}
+
 type EmailViewerModel struct {
-	email   *core.Email
-	backend core.EmailBackend
+	email *core.Email
+	// conversation holds every email in the thread when viewing a whole conversation
+	conversation []*core.Email
+	backend      core.EmailBackend
 
 	ready   bool
 	loading bool
 	error   string
+	// showQuotes unfolds quoted text and signatures
+	showQuotes bool
+
+	// mode is whether the body, the full headers or the source of the email is being shown
+	mode viewMode
+	// raw is the source of the current email, which is fetched once when it's loaded and shared by the sender check,
+	// images, OpenPGP and S/MIME, and the headers, source, links and parts
+	raw        []byte
+	loadingRaw bool
+
+	pickingLink bool
+	links       []links.Link
+	linkIndex   int
+	linkStatus  string
+	// terminal is where escape sequences that go straight to the terminal are written, like the ones for copying
+	// links and sending images
+	terminal io.Writer
+
+	pickingPart bool
+	parts       []message.Part
+	partIndex   int
+	partStatus  string
+	// partTitle and partOutput are what's shown in partMode, from a part's copiousoutput command
+	partTitle  string
+	partOutput string
+
+	// images are the ones attached to the current email, decoded once its source has been fetched
+	images []attachedImage
+
+	// invitationStatus is shown on the current email's invitation card once it's been replied to
+	invitationStatus string
+
+	// senders is what was found out about who each of the emails is really from, once their sources have been checked
+	senders map[core.EmailId]*senderauth.Report
+	// openPGP is how each of the emails was protected with OpenPGP, once they've been verified or decrypted
+	openPGP map[core.EmailId]*pgp.Result
+	// smime is how each of the emails was protected with S/MIME, once they've been verified or decrypted
+	smime map[core.EmailId]*smime.Result
+
+	// listStatus is shown below the current email's list, like whether it's been unsubscribed from
+	listStatus            string
+	confirmingUnsubscribe bool
+	// httpClient makes the requests for one-click unsubscribing
+	httpClient *http.Client
+
+	options Options
 
 	viewport viewport.Model
 }
 
-func NewEmailViewerModel(backend core.EmailBackend) *EmailViewerModel {
+func NewEmailViewerModel(backend core.EmailBackend, options Options) *EmailViewerModel {
 	return &EmailViewerModel{
-		backend: backend,
+		backend:    backend,
+		terminal:   os.Stdout,
+		senders:    map[core.EmailId]*senderauth.Report{},
+		openPGP:    map[core.EmailId]*pgp.Result{},
+		smime:      map[core.EmailId]*smime.Result{},
+		httpClient: &http.Client{Timeout: unsubscribeTimeout},
+		options:    options,
 	}
 }
 
@@ -46,7 +139,24 @@ func (m *EmailViewerModel) Update(msg tea.Msg) (*EmailViewerModel, tea.Cmd) {
 		m.loading = true
 		m.error = ""
 		m.email = nil
-		commands = append(commands, m.loadEmail(msg.EmailId))
+		m.conversation = nil
+		m.mode = bodyMode
+		m.raw = nil
+		m.loadingRaw = false
+		m.pickingLink = false
+		m.pickingPart = false
+		m.images = nil
+		m.invitationStatus = ""
+		m.senders = map[core.EmailId]*senderauth.Report{}
+		m.openPGP = map[core.EmailId]*pgp.Result{}
+		m.smime = map[core.EmailId]*smime.Result{}
+		m.listStatus = ""
+		m.confirmingUnsubscribe = false
+		if len(msg.Conversation) > 1 {
+			commands = append(commands, m.loadConversation(msg.Conversation))
+		} else {
+			commands = append(commands, m.loadEmail(msg.EmailId))
+		}
 	case emailLoadedMessage:
 		m.loading = false
 		if msg.error != nil {
@@ -54,13 +164,59 @@ func (m *EmailViewerModel) Update(msg tea.Msg) (*EmailViewerModel, tea.Cmd) {
 		} else {
 			m.email = msg.email
 			m.error = ""
-			commands = append(commands, m.markAsRead(msg.email.Id))
+			m.loadingRaw = true
+			commands = append(commands, m.markAsRead(msg.email.Id), m.loadRawEmail(msg.email.Id))
+		}
+		err := m.updateViewportContent()
+		if err != nil {
+			m.error = err.Error()
+			return m, nil
+		}
+	case conversationLoadedMessage:
+		m.loading = false
+		if msg.error != nil {
+			m.error = msg.error.Error()
+		} else {
+			m.conversation = msg.emails
+			// the latest email is the one any actions apply to
+			m.email = msg.emails[len(msg.emails)-1]
+			m.error = ""
+			// every email's source is fetched to check its sender and decrypt it, and the latest one's for everything
+			// else too
+			m.loadingRaw = true
+			for _, email := range msg.emails {
+				if !email.IsRead() {
+					commands = append(commands, m.markAsRead(email.Id))
+				}
+				commands = append(commands, m.loadRawEmail(email.Id))
+			}
 		}
 		err := m.updateViewportContent()
 		if err != nil {
 			m.error = err.Error()
 			return m, nil
 		}
+		m.viewport.GotoTop()
+	case rawEmailLoadedMessage:
+		commands = append(commands, m.handleRawEmailLoaded(msg))
+	case invitationRepliedMessage:
+		m.handleInvitationReplied(msg)
+	case senderCheckedMessage:
+		m.handleSenderChecked(msg)
+	case unprotectedMessage:
+		commands = append(commands, m.handleUnprotected(msg))
+	case unsubscribedMessage:
+		m.handleUnsubscribed(msg)
+	case imagesDecodedMessage:
+		m.handleImagesDecoded(msg)
+	case linkOpenedMessage:
+		m.handleLinkOpened(msg)
+	case partCommandMessage:
+		commands = append(commands, m.handlePartCommand(msg))
+	case partOutputMessage:
+		m.handlePartOutput(msg)
+	case partViewedMessage:
+		m.handlePartViewed(msg)
 	case emailMarkedReadMessage:
 		if msg.error != nil {
 			m.error = "error marking email as read: " + msg.error.Error()
@@ -81,11 +237,51 @@ func (m *EmailViewerModel) Update(msg tea.Msg) (*EmailViewerModel, tea.Cmd) {
 			return m, nil
 		}
 	case tea.KeyMsg:
+		if m.pickingLink {
+			return m, m.updateLinks(msg)
+		}
+		if m.pickingPart {
+			return m, m.updateParts(msg)
+		}
+		if m.confirmingUnsubscribe {
+			return m, m.updateUnsubscribe(msg)
+		}
 		switch msg.String() {
+		case "esc":
+			if m.mode == partMode {
+				commands = append(commands, m.toggleMode(partMode))
+			}
 		case "q":
 			commands = append(commands, func() tea.Msg {
 				return ui.ShowEmailListMessage{}
 			})
+		case "z":
+			m.showQuotes = !m.showQuotes
+			if err := m.updateViewportContent(); err != nil {
+				m.error = err.Error()
+				return m, nil
+			}
+		case "H":
+			commands = append(commands, m.toggleMode(headersMode))
+		case "V":
+			commands = append(commands, m.toggleMode(sourceMode))
+		case "o":
+			commands = append(commands, m.openLinks())
+		case "a":
+			commands = append(commands, m.openParts())
+		case "Y", "M", "N":
+			commands = append(commands, m.replyToInvitation(rsvpKeys[msg.String()]))
+		case "L":
+			commands = append(commands, m.replyToList())
+		case "U":
+			m.confirmUnsubscribe()
+		case "r":
+			if m.email != nil {
+				email := m.email
+				commands = append(commands, func() tea.Msg {
+					return ui.ShowEmailComposerMessage{ReplyTo: email}
+				})
+			}
 		}
 	}
 
@@ -109,6 +305,14 @@ func (m *EmailViewerModel) View() string {
 		return "Error: " + m.error
 	}
 
+	if m.pickingLink {
+		return m.linksView()
+	}
+
+	if m.pickingPart {
+		return m.partsView()
+	}
+
 	return m.viewport.View()
 }
 
@@ -117,18 +321,37 @@ func (m *EmailViewerModel) updateViewportContent() error {
 		return nil
 	}
 
-	if m.email != nil {
-		content, err := RenderEmail(m.email, m.viewport.Width)
+	if m.mode != bodyMode && m.email != nil {
+		// only the current email's headers, source or part is shown, even in a conversation
+		content, err := m.renderRaw()
+		if err != nil {
+			return err
+		}
+		m.viewport.SetContent(content)
+	} else if len(m.conversation) > 0 {
+		content, err := RenderConversation(m.conversation, m.viewport.Width, m.renderOptions)
 		if err != nil {
 			return err
 		}
 		m.viewport.SetContent(content)
+	} else if m.email != nil {
+		content, err := RenderEmail(m.email, m.viewport.Width, m.renderOptions(m.email))
+		if err != nil {
+			return err
+		}
+		m.viewport.SetContent(content + m.renderImages())
 	} else {
 		m.viewport.SetContent("No email selected")
 	}
 	return nil
 }
 
+// renderOptions renders one of the emails being viewed along with what's been found out about it.
+func (m *EmailViewerModel) renderOptions(email *core.Email) RenderOptions {
+	return RenderOptions{ShowQuotes: m.showQuotes, InvitationStatus: m.invitationStatus, ListStatus: m.listStatus,
+		Sender: m.senders[email.Id], OpenPGP: m.openPGP[email.Id], SMIME: m.smime[email.Id]}
+}
+
 func (m *EmailViewerModel) loadEmail(emailId core.EmailId) tea.Cmd {
 	return func() tea.Msg {
 		email, err := m.backend.GetEmail(emailId)
@@ -146,9 +369,31 @@ func (m *EmailViewerModel) loadEmail(emailId core.EmailId) tea.Cmd {
 	}
 }
 
+// loadConversation fetches every email in a thread, in order.
+func (m *EmailViewerModel) loadConversation(emailIds []core.EmailId) tea.Cmd {
+	return func() tea.Msg {
+		var emails []*core.Email
+		for _, emailId := range emailIds {
+			email, err := m.backend.GetEmail(emailId)
+			if err != nil {
+				return conversationLoadedMessage{
+					emails: nil,
+					error:  err,
+				}
+			}
+			emails = append(emails, email)
+		}
+
+		return conversationLoadedMessage{
+			emails: emails,
+			error:  nil,
+		}
+	}
+}
+
 func (m *EmailViewerModel) markAsRead(emailId core.EmailId) tea.Cmd {
 	return func() tea.Msg {
-		err := m.backend.MarkAsRead(emailId)
+		err := m.backend.AddFlags([]core.EmailId{emailId}, core.FlagSeen)
 		return emailMarkedReadMessage{
 			error: err,
 		}
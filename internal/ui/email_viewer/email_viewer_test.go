@@ -14,28 +14,115 @@ import (
 
 type mockBackend struct {
 	email *core.Email
+	raw   []byte
 	err   error
+	// rawFetches counts how many times the source has been fetched
+	rawFetches int
 }
 
 func (m *mockBackend) ListEmails() ([]core.EmailMetadata, error) {
 	return nil, nil
 }
 
+func (m *mockBackend) ListThreads() ([]*core.Thread, error) {
+	return nil, nil
+}
+
 func (m *mockBackend) GetEmail(id core.EmailId) (*core.Email, error) {
 	return m.email, m.err
 }
 
+func (m *mockBackend) GetRawEmail(id core.EmailId) ([]byte, error) {
+	m.rawFetches++
+	return m.raw, m.err
+}
+
 func (m *mockBackend) SendEmail(email core.OutgoingEmail) error {
 	return nil
 }
 
-func (m *mockBackend) MarkAsRead(id core.EmailId) error {
+func (m *mockBackend) SaveDraft(draft core.Draft) (*core.Draft, error) {
+	return &draft, nil
+}
+
+func (m *mockBackend) ListDrafts() ([]core.Draft, error) {
+	return nil, nil
+}
+
+func (m *mockBackend) DeleteDraft(id core.EmailId) error {
+	return nil
+}
+
+func (m *mockBackend) AddFlags(ids []core.EmailId, flags ...core.Flag) error {
+	return nil
+}
+
+func (m *mockBackend) RemoveFlags(ids []core.EmailId, flags ...core.Flag) error {
 	return nil
 }
 
+func (m *mockBackend) ListMailboxes() ([]core.Mailbox, error) {
+	return nil, nil
+}
+
+func (m *mockBackend) MoveEmails(ids []core.EmailId, mailbox string) (*core.MoveResult, error) {
+	return nil, nil
+}
+
+func (m *mockBackend) CopyEmails(ids []core.EmailId, mailbox string) (*core.MoveResult, error) {
+	return nil, nil
+}
+
+func (m *mockBackend) DeleteEmails(ids []core.EmailId) (*core.MoveResult, error) {
+	return nil, nil
+}
+
+func (m *mockBackend) RestoreEmails(mailbox string, ids []core.EmailId) (*core.MoveResult, error) {
+	return nil, nil
+}
+
+// findMessage runs a command and returns the message of type T it produces, looking inside batches.
+func findMessage[T tea.Msg](t *testing.T, cmd tea.Cmd) T {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("Expected a command")
+	}
+	msg := cmd()
+	if found, ok := msg.(T); ok {
+		return found
+	}
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		for _, cmd := range batch {
+			if cmd == nil {
+				continue
+			}
+			if found, ok := cmd().(T); ok {
+				return found
+			}
+		}
+	}
+	var zero T
+	t.Fatalf("Expected a %T message", zero)
+	return zero
+}
+
+// newViewer opens an email in a viewer whose backend has the email's source, returning the commands that fetch it.
+func newViewer(t *testing.T, email *core.Email, raw []byte, options Options) (*EmailViewerModel, tea.Cmd) {
+	t.Helper()
+	model := NewEmailViewerModel(&mockBackend{email: email, raw: raw}, options)
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	return model.Update(emailLoadedMessage{email: email})
+}
+
+// loadSource delivers the source fetched by the commands from loading an email, returning the commands that read it.
+func loadSource(t *testing.T, model *EmailViewerModel, cmd tea.Cmd) (*EmailViewerModel, tea.Cmd) {
+	t.Helper()
+	return model.Update(findMessage[rawEmailLoadedMessage](t, cmd))
+}
+
 func TestEmailViewerModel_ShowEmailViewerMessage(t *testing.T) {
 	backend := &mockBackend{}
-	model := NewEmailViewerModel(backend)
+	model := NewEmailViewerModel(backend, Options{})
 
 	updatedModel, cmd := model.Update(ui.ShowEmailViewerMessage{EmailId: "test-id"})
 
@@ -59,7 +146,7 @@ func TestEmailViewerModel_ShowEmailViewerMessage(t *testing.T) {
 
 func TestEmailViewerModel_EmailLoadedMessage_Success(t *testing.T) {
 	backend := &mockBackend{}
-	model := NewEmailViewerModel(backend)
+	model := NewEmailViewerModel(backend, Options{})
 	model.loading = true
 
 	testEmail := &core.Email{
@@ -97,7 +184,7 @@ func TestEmailViewerModel_EmailLoadedMessage_Success(t *testing.T) {
 
 func TestEmailViewerModel_EmailLoadedMessage_Error(t *testing.T) {
 	backend := &mockBackend{}
-	model := NewEmailViewerModel(backend)
+	model := NewEmailViewerModel(backend, Options{})
 	model.loading = true
 
 	testError := errors.New("failed to load email")
@@ -122,7 +209,7 @@ func TestEmailViewerModel_EmailLoadedMessage_Error(t *testing.T) {
 
 func TestEmailViewerModel_WindowSizeMsg_FirstTime(t *testing.T) {
 	backend := &mockBackend{}
-	model := NewEmailViewerModel(backend)
+	model := NewEmailViewerModel(backend, Options{})
 
 	windowMsg := tea.WindowSizeMsg{Width: 80, Height: 24}
 	updatedModel, _ := model.Update(windowMsg)
@@ -134,7 +221,7 @@ func TestEmailViewerModel_WindowSizeMsg_FirstTime(t *testing.T) {
 
 func TestEmailViewerModel_WindowSizeMsg_SubsequentTimes(t *testing.T) {
 	backend := &mockBackend{}
-	model := NewEmailViewerModel(backend)
+	model := NewEmailViewerModel(backend, Options{})
 	model.ready = true
 
 	windowMsg := tea.WindowSizeMsg{Width: 100, Height: 30}
@@ -147,7 +234,7 @@ func TestEmailViewerModel_WindowSizeMsg_SubsequentTimes(t *testing.T) {
 
 func TestEmailViewerModel_KeyMsg_Quit(t *testing.T) {
 	backend := &mockBackend{}
-	model := NewEmailViewerModel(backend)
+	model := NewEmailViewerModel(backend, Options{})
 
 	keyMsg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'q'}}
 	_, cmd := model.Update(keyMsg)
@@ -158,9 +245,32 @@ func TestEmailViewerModel_KeyMsg_Quit(t *testing.T) {
 	}
 }
 
+func TestEmailViewerModel_KeyMsg_Reply(t *testing.T) {
+	backend := &mockBackend{}
+	model := NewEmailViewerModel(backend, Options{})
+	model.email = &core.Email{
+		EmailMetadata: core.EmailMetadata{Id: "test-123", Subject: "Test Subject"},
+	}
+
+	keyMsg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}}
+	_, cmd := model.Update(keyMsg)
+
+	if cmd == nil {
+		t.Fatal("Expected a command to be returned for 'r' key")
+	}
+
+	composerMsg, ok := cmd().(ui.ShowEmailComposerMessage)
+	if !ok {
+		t.Fatal("Expected the command to return a ShowEmailComposerMessage")
+	}
+	if composerMsg.ReplyTo != model.email {
+		t.Error("Expected the ShowEmailComposerMessage to reply to the current email")
+	}
+}
+
 func TestEmailViewerModel_View_NotReady(t *testing.T) {
 	backend := &mockBackend{}
-	model := NewEmailViewerModel(backend)
+	model := NewEmailViewerModel(backend, Options{})
 	model.ready = false
 
 	view := model.View()
@@ -172,7 +282,7 @@ func TestEmailViewerModel_View_NotReady(t *testing.T) {
 
 func TestEmailViewerModel_View_Loading(t *testing.T) {
 	backend := &mockBackend{}
-	model := NewEmailViewerModel(backend)
+	model := NewEmailViewerModel(backend, Options{})
 	model.ready = true
 	model.loading = true
 
@@ -185,7 +295,7 @@ func TestEmailViewerModel_View_Loading(t *testing.T) {
 
 func TestEmailViewerModel_View_Error(t *testing.T) {
 	backend := &mockBackend{}
-	model := NewEmailViewerModel(backend)
+	model := NewEmailViewerModel(backend, Options{})
 	model.ready = true
 	model.loading = false
 	model.error = "Connection timeout"
@@ -200,7 +310,7 @@ func TestEmailViewerModel_View_Error(t *testing.T) {
 
 func TestEmailViewerModel_View_NormalState(t *testing.T) {
 	backend := &mockBackend{}
-	model := NewEmailViewerModel(backend)
+	model := NewEmailViewerModel(backend, Options{})
 
 	// Initialize the viewport by sending a window size message
 	model, _ = model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
@@ -219,3 +329,77 @@ func TestEmailViewerModel_View_NormalState(t *testing.T) {
 		t.Error("Expected non-empty viewport view")
 	}
 }
+
+func TestEmailViewerModel_ConversationLoadedMessage(t *testing.T) {
+	backend := &mockBackend{}
+	model := NewEmailViewerModel(backend, Options{})
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	model.loading = true
+
+	first := &core.Email{
+		EmailMetadata: core.EmailMetadata{Id: "1", Subject: "Lunch", Flags: []core.Flag{core.FlagSeen}},
+		Body:          "Shall we get lunch?",
+	}
+	second := &core.Email{
+		EmailMetadata: core.EmailMetadata{Id: "2", Subject: "Re: Lunch"},
+		Body:          "Sounds good",
+	}
+
+	updatedModel, cmd := model.Update(conversationLoadedMessage{
+		emails: []*core.Email{first, second},
+		error:  nil,
+	})
+
+	if updatedModel.loading {
+		t.Error("Expected loading to be false after ConversationLoadedMessage")
+	}
+
+	if len(updatedModel.conversation) != 2 {
+		t.Errorf("Expected 2 emails in the conversation, got %d", len(updatedModel.conversation))
+	}
+
+	if updatedModel.email != second {
+		t.Error("Expected the latest email in the conversation to be the current email")
+	}
+
+	// Should return a command to mark the unread email as read
+	if cmd == nil {
+		t.Error("Expected a command to be returned")
+	}
+
+	view := updatedModel.View()
+	if !strings.Contains(view, "Message 1 of 2") {
+		t.Errorf("Expected the conversation to be rendered, got '%s'", view)
+	}
+}
+
+func TestRenderEmail_FlowedKeepsQuoteMarkers(t *testing.T) {
+	email := &core.Email{
+		EmailMetadata: core.EmailMetadata{Id: "1", Subject: "Re: Plans"},
+		Body:          "Sounds good.\n\n>> Shall we meet on Thursday to go over the roadmap for next year?",
+		Flowed:        true,
+	}
+
+	output, err := RenderEmail(email, 40, RenderOptions{ShowQuotes: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var quoted []string
+	for _, line := range strings.Split(output, "\n") {
+		for _, word := range []string{"Thursday", "roadmap", "year?"} {
+			if strings.Contains(line, word) {
+				quoted = append(quoted, line)
+				break
+			}
+		}
+	}
+	if len(quoted) < 2 {
+		t.Fatalf("Expected the quoted paragraph to be wrapped, got:\n%s", output)
+	}
+	for _, line := range quoted {
+		if !strings.Contains(line, ">> ") {
+			t.Errorf("Expected every wrapped line to keep its quote markers, got %q", line)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package email_viewer
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	// registers the formats image.Decode reads
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/message"
+	"github.com/bengesoff/mail-tui/internal/termimage"
+)
+
+const (
+	// maxImages stops an email full of images from taking forever to draw
+	maxImages = 8
+	// maxImagePixels skips images that would take too much memory to decode, since their size comes from the email
+	maxImagePixels = 50_000_000
+	// maxImageRows keeps a tall image from taking up more than a screenful
+	maxImageRows = 20
+)
+
+type attachedImage struct {
+	name  string
+	image image.Image
+	// sent is the size the image was last sent to the terminal at with the Kitty protocol, so it's only sent again
+	// when that changes
+	sent image.Point
+}
+
+type imagesDecodedMessage struct {
+	id     core.EmailId
+	images []attachedImage
+	error  error
+}
+
+// loadImages decodes the images that are parts of the email, if showing them is turned on. Only the email's own parts
+// are used, so images it links to elsewhere are never fetched, which would let the sender know it had been read.
+func (m *EmailViewerModel) loadImages(emailId core.EmailId, raw []byte) tea.Cmd {
+	if m.options.Images == termimage.Off {
+		return nil
+	}
+	return func() tea.Msg {
+		parts, err := message.ParseParts(bytes.NewReader(raw))
+		if err != nil {
+			return imagesDecodedMessage{id: emailId, error: err}
+		}
+		return imagesDecodedMessage{id: emailId, images: decodeImages(parts)}
+	}
+}
+
+// decodeImages decodes the PNG, JPEG and GIF parts, skipping any that can't be read.
+func decodeImages(parts []message.Part) []attachedImage {
+	var images []attachedImage
+	for _, part := range parts {
+		if len(images) == maxImages {
+			break
+		}
+		if !strings.HasPrefix(part.ContentType, "image/") {
+			continue
+		}
+		config, _, err := image.DecodeConfig(bytes.NewReader(part.Data))
+		if err != nil || config.Width*config.Height > maxImagePixels {
+			continue
+		}
+		decoded, _, err := image.Decode(bytes.NewReader(part.Data))
+		if err != nil {
+			continue
+		}
+		images = append(images, attachedImage{name: partName(part), image: decoded})
+	}
+	return images
+}
+
+func (m *EmailViewerModel) handleImagesDecoded(msg imagesDecodedMessage) {
+	// the viewer may have moved on to another email while this one was loading
+	if m.email == nil || msg.id != m.email.Id || len(m.conversation) > 0 {
+		return
+	}
+	if msg.error != nil || len(msg.images) == 0 {
+		// the email is still worth reading without its images, so failing to decode them isn't shown as an error
+		return
+	}
+	m.images = msg.images
+	if err := m.updateViewportContent(); err != nil {
+		m.error = err.Error()
+	}
+}
+
+// renderImages draws the images below the email, each fitted to the width of its body.
+func (m *EmailViewerModel) renderImages() string {
+	var b strings.Builder
+	for i := range m.images {
+		attached := &m.images[i]
+		columns, rows := termimage.Fit(attached.image.Bounds(), m.viewport.Width-4, maxImageRows)
+		if columns == 0 {
+			continue
+		}
+
+		var lines []string
+		switch m.options.Images {
+		case termimage.Kitty:
+			// ids start from 1, since 0 means the terminal picks one
+			id := i + 1
+			if size := image.Pt(columns, rows); attached.sent != size {
+				if err := termimage.TransmitKitty(m.terminal, attached.image, id, columns, rows); err != nil {
+					continue
+				}
+				attached.sent = size
+			}
+			lines = termimage.KittyLines(id, columns, rows)
+		case termimage.Sixel:
+			lines = termimage.SixelLines(attached.image, columns, rows)
+		default:
+			lines = termimage.HalfBlockLines(attached.image, columns, rows)
+		}
+
+		bounds := attached.image.Bounds()
+		fmt.Fprintf(&b, "\n%s\n", urlStyle.Render(fmt.Sprintf("%s (%d×%d)", attached.name, bounds.Dx(), bounds.Dy())))
+		for _, line := range lines {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	return b.String()
+}
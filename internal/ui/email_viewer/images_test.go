@@ -0,0 +1,104 @@
+package email_viewer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/message"
+	"github.com/bengesoff/mail-tui/internal/termimage"
+)
+
+func emailWithImage(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := range 16 {
+		for x := range 16 {
+			img.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return []byte("From: Bob <bob@example.com>\r\n" +
+		"Subject: Photo\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=b\r\n" +
+		"\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"Here it is\r\n" +
+		"--b\r\n" +
+		"Content-Type: image/png\r\n" +
+		"Content-Disposition: attachment; filename=red.png\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		base64.StdEncoding.EncodeToString(encoded.Bytes()) + "\r\n" +
+		"--b--\r\n")
+}
+
+func TestEmailViewerModel_ImagesOffByDefault(t *testing.T) {
+	email := &core.Email{EmailMetadata: core.EmailMetadata{Id: "1"}, Body: "Here it is"}
+	model := NewEmailViewerModel(&mockBackend{email: email, raw: emailWithImage(t)}, Options{})
+	if cmd := model.loadImages(email.Id, emailWithImage(t)); cmd != nil {
+		t.Error("Expected images not to be loaded unless they're turned on")
+	}
+}
+
+func TestEmailViewerModel_ImagesHalfBlock(t *testing.T) {
+	email := &core.Email{EmailMetadata: core.EmailMetadata{Id: "1"}, Body: "Here it is"}
+	model, cmd := newViewer(t, email, emailWithImage(t), Options{Images: termimage.HalfBlock})
+
+	model, cmd = loadSource(t, model, cmd)
+	// the images are decoded once the email's been checked for OpenPGP or S/MIME, in case they're encrypted
+	model, cmd = model.Update(findMessage[unprotectedMessage](t, cmd))
+	model, _ = model.Update(findMessage[imagesDecodedMessage](t, cmd))
+
+	if len(model.images) != 1 {
+		t.Fatalf("Expected the image to be decoded, got %d", len(model.images))
+	}
+	view := model.View()
+	if !strings.Contains(view, "red.png (16×16)") {
+		t.Errorf("Expected the image's name and size, got:\n%s", view)
+	}
+	if !strings.Contains(view, "\x1b[38;2;255;0;0m\x1b[48;2;255;0;0m▀") {
+		t.Errorf("Expected the image drawn in red half-blocks, got %q", view)
+	}
+	if model.raw == nil {
+		t.Error("Expected the source to be kept")
+	}
+}
+
+func TestEmailViewerModel_ImagesKittySentOnce(t *testing.T) {
+	email := &core.Email{EmailMetadata: core.EmailMetadata{Id: "1"}, Body: "Here it is"}
+	model, cmd := newViewer(t, email, emailWithImage(t), Options{Images: termimage.Kitty})
+	var terminal bytes.Buffer
+	model.terminal = &terminal
+
+	model, cmd = loadSource(t, model, cmd)
+	model, cmd = model.Update(findMessage[unprotectedMessage](t, cmd))
+	model, _ = model.Update(findMessage[imagesDecodedMessage](t, cmd))
+	model, _ = pressKey(model, 'z')
+
+	if count := strings.Count(terminal.String(), "\x1b_G"); count != 1 {
+		t.Errorf("Expected the image to be sent to the terminal once, got %d times", count)
+	}
+}
+
+func TestDecodeImages_SkipsUnreadable(t *testing.T) {
+	parts := []message.Part{
+		{ContentType: "image/png", Data: []byte("not a png")},
+		{ContentType: "text/plain", Data: []byte("hello")},
+	}
+	if images := decodeImages(parts); len(images) != 0 {
+		t.Errorf("Expected nothing to be decoded, got %d images", len(images))
+	}
+}
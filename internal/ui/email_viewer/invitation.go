@@ -0,0 +1,218 @@
+package email_viewer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/emersion/go-message/mail"
+	"github.com/muesli/reflow/wordwrap"
+
+	"github.com/bengesoff/mail-tui/internal/calendar"
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+type invitationRepliedMessage struct {
+	id     core.EmailId
+	status calendar.PartStat
+	error  error
+}
+
+var invitationStyle = func(width int) lipgloss.Style {
+	return lipgloss.NewStyle().
+		Width(width).
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.AdaptiveColor{Light: "#EE6FF8", Dark: "#EE6FF8"}).
+		Padding(0, 1)
+}
+
+// rsvpKeys are the keys that reply to an invitation, and what each one answers.
+var rsvpKeys = map[string]calendar.PartStat{
+	"Y": calendar.Accepted,
+	"M": calendar.Tentative,
+	"N": calendar.Declined,
+}
+
+// answers describe each reply, as in "Accepted: Standup".
+var answers = map[calendar.PartStat]string{
+	calendar.Accepted:  "Accepted",
+	calendar.Tentative: "Tentatively accepted",
+	calendar.Declined:  "Declined",
+}
+
+// renderInvitation renders the first event in an email's calendar as a card, with its times in the local time zone.
+// Nothing is rendered if the email doesn't have a calendar, and a note if it can't be read.
+func renderInvitation(email *core.Email, width int, status string) string {
+	if email.Calendar == nil {
+		return ""
+	}
+	invitations, err := calendar.Parse(email.Calendar, time.Local)
+	if err != nil {
+		note := warningStyle.Render("This email has a calendar event that can't be read: " + err.Error())
+		return invitationStyle(width).Render(note) + "\n"
+	}
+	invitation := invitations[0]
+
+	title := invitation.Summary
+	if title == "" {
+		title = "(untitled event)"
+	}
+	if invitation.Cancelled || invitation.Method == calendar.MethodCancel {
+		title += " " + warningStyle.Render("(cancelled)")
+	}
+	lines := []string{conversationHeadingStyle.Render("📅 " + title)}
+
+	rows := [][2]string{{"When", describeTimes(invitation)}}
+	if invitation.Recurrence != "" {
+		rows = append(rows, [2]string{"Repeats", invitation.Recurrence})
+	}
+	if invitation.Location != "" {
+		rows = append(rows, [2]string{"Where", invitation.Location})
+	}
+	if invitation.Organizer.Address != "" {
+		rows = append(rows, [2]string{"Organiser", invitation.Organizer.String()})
+	}
+	if len(invitation.Attendees) > 0 {
+		var attendees []string
+		for _, attendee := range invitation.Attendees {
+			name := attendee.Name
+			if name == "" {
+				name = attendee.Address
+			}
+			if attendee.Role == "OPT-PARTICIPANT" {
+				name += ", optional"
+			}
+			attendees = append(attendees, fmt.Sprintf("%s (%s)", name, attendee.Status.Describe()))
+		}
+		rows = append(rows, [2]string{"Attendees", strings.Join(attendees, ", ")})
+	}
+	for _, row := range rows {
+		label := metadataHeadingStyle.Render(fmt.Sprintf("%-10s", row[0]))
+		// values wrap onto lines lined up under the first one
+		value := wordwrap.String(row[1], max(width-14, 10))
+		lines = append(lines, label+" "+strings.ReplaceAll(value, "\n", "\n"+strings.Repeat(" ", 11)))
+	}
+
+	switch {
+	case status != "":
+		lines = append(lines, "", status)
+	case invitation.NeedsReply():
+		lines = append(lines, "", urlStyle.Render("Y: Accept • M: Tentative • N: Decline"))
+	}
+	return invitationStyle(width).Render(strings.Join(lines, "\n")) + "\n"
+}
+
+// describeTimes says when an event is, leaving out the end date when it's on the same day.
+func describeTimes(invitation calendar.Invitation) string {
+	start, end := invitation.Start, invitation.End
+	if invitation.AllDay {
+		// the end of an all-day event is the start of the day after it
+		last := end.AddDate(0, 0, -1)
+		if !last.After(start) {
+			return start.Format("Mon 2 Jan 2006") + ", all day"
+		}
+		return start.Format("Mon 2 Jan") + " – " + last.Format("Mon 2 Jan 2006")
+	}
+	zone := start.Format("MST")
+	if end.IsZero() || end.Equal(start) {
+		return start.Format("Mon 2 Jan 2006, 15:04") + " " + zone
+	}
+	if start.YearDay() == end.YearDay() && start.Year() == end.Year() {
+		return start.Format("Mon 2 Jan 2006, 15:04") + "–" + end.Format("15:04") + " " + zone
+	}
+	return start.Format("Mon 2 Jan 2006 15:04") + " – " + end.Format("Mon 2 Jan 15:04") + " " + zone
+}
+
+// replyToInvitation answers the invitation in the current email, sending the iTIP reply to the organiser through the
+// outbox like any other email. It's sent from whichever identity was invited, or else the address it was sent to.
+func (m *EmailViewerModel) replyToInvitation(status calendar.PartStat) tea.Cmd {
+	if m.email == nil || m.email.Calendar == nil {
+		return nil
+	}
+	invitations, err := calendar.Parse(m.email.Calendar, time.Local)
+	if err != nil || !invitations[0].NeedsReply() {
+		return nil
+	}
+	invitation := invitations[0]
+	if m.options.Outbox == nil {
+		m.setInvitationStatus(warningStyle.Render("Replies can't be sent without an outbox"))
+		return nil
+	}
+
+	from := ""
+	var addresses []string
+	for _, identity := range m.options.Identities {
+		addresses = append(addresses, identity.Address)
+	}
+	if recipients, err := mail.ParseAddressList(m.email.To); err == nil {
+		for _, recipient := range recipients {
+			addresses = append(addresses, recipient.Address)
+		}
+	}
+	attendee, ok := invitation.FindAttendee(addresses...)
+	if !ok {
+		m.setInvitationStatus(warningStyle.Render("None of your addresses were invited, so there's no one to reply as"))
+		return nil
+	}
+	for _, identity := range m.options.Identities {
+		if strings.EqualFold(identity.Address, attendee.Address) {
+			from = identity.From()
+		}
+	}
+
+	data, err := invitation.Reply(attendee.Address, status, time.Now())
+	if err != nil {
+		m.setInvitationStatus(warningStyle.Render("Failed to reply: " + err.Error()))
+		return nil
+	}
+	to := invitation.Organizer.String()
+	if invitation.Organizer.Address == "" {
+		to = m.email.From
+	}
+	name := attendee.Name
+	if name == "" {
+		name = attendee.Address
+	}
+	answer := answers[status]
+	references := m.email.References
+	if m.email.MessageId != "" {
+		references = append(append([]string(nil), references...), m.email.MessageId)
+	}
+	email := core.OutgoingEmail{
+		From:       from,
+		To:         to,
+		Subject:    answer + ": " + invitation.Summary,
+		Body:       fmt.Sprintf("%s has %s: %s", name, strings.ToLower(answer), invitation.Summary),
+		InReplyTo:  m.email.MessageId,
+		References: references,
+		Calendar:   &core.CalendarPart{Method: calendar.MethodReply, Data: data},
+	}
+
+	m.setInvitationStatus("Replying...")
+	id := m.email.Id
+	outbox := m.options.Outbox
+	return func() tea.Msg {
+		_, err := outbox.Enqueue(email, id, time.Time{})
+		return invitationRepliedMessage{id: id, status: status, error: err}
+	}
+}
+
+func (m *EmailViewerModel) handleInvitationReplied(msg invitationRepliedMessage) {
+	if m.email == nil || msg.id != m.email.Id {
+		return
+	}
+	if msg.error != nil {
+		m.setInvitationStatus(warningStyle.Render("Failed to queue the reply: " + msg.error.Error()))
+		return
+	}
+	m.setInvitationStatus(fmt.Sprintf("%s — the reply is in the outbox", answers[msg.status]))
+}
+
+func (m *EmailViewerModel) setInvitationStatus(status string) {
+	m.invitationStatus = status
+	if err := m.updateViewportContent(); err != nil {
+		m.error = err.Error()
+	}
+}
@@ -0,0 +1,135 @@
+package email_viewer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/bengesoff/mail-tui/internal/calendar"
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/identity"
+	"github.com/bengesoff/mail-tui/internal/outbox"
+)
+
+const invitationCalendar = "BEGIN:VCALENDAR\r\n" +
+	"PRODID:-//Example//Calendar//EN\r\n" +
+	"VERSION:2.0\r\n" +
+	"METHOD:REQUEST\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:review@example.com\r\n" +
+	"DTSTAMP:20250101T090000Z\r\n" +
+	"DTSTART:20250106T140000Z\r\n" +
+	"DTEND:20250106T150000Z\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=MO\r\n" +
+	"SUMMARY:Design review\r\n" +
+	"LOCATION:Room 4\r\n" +
+	"ORGANIZER;CN=Alice:mailto:alice@example.com\r\n" +
+	"ATTENDEE;CN=Bob;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:bob@example.com\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+// invitationEmail invites Bob to a weekly design review, with times shown in UTC.
+func invitationEmail(t *testing.T) *core.Email {
+	t.Helper()
+	previous := time.Local
+	time.Local = time.UTC
+	t.Cleanup(func() { time.Local = previous })
+
+	return &core.Email{
+		EmailMetadata: core.EmailMetadata{
+			Id:        "1",
+			From:      "Alice <alice@example.com>",
+			To:        "Bob <bob@example.com>",
+			Subject:   "Invitation: Design review",
+			MessageId: "invite@example.com",
+		},
+		Body:     "You're invited",
+		Calendar: []byte(invitationCalendar),
+	}
+}
+
+func TestEmailViewerModel_InvitationCard(t *testing.T) {
+	model, _ := newViewer(t, invitationEmail(t), nil, Options{})
+
+	view := ansi.Strip(model.View())
+	for _, want := range []string{
+		"📅 Design review",
+		"When       Mon 6 Jan 2025, 14:00–15:00 UTC",
+		"Repeats    Every week on Monday",
+		"Where      Room 4",
+		"Organiser  Alice <alice@example.com>",
+		"Attendees  Bob (hasn't replied)",
+		"Y: Accept • M: Tentative • N: Decline",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected the card to contain %q, got:\n%s", want, view)
+		}
+	}
+}
+
+func TestEmailViewerModel_ReplyToInvitation(t *testing.T) {
+	queue, err := outbox.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, _ := newViewer(t, invitationEmail(t), nil, Options{
+		Outbox:     queue,
+		Identities: []identity.Identity{{Name: "Bob Smith", Address: "BOB@example.com"}},
+	})
+
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'M'}})
+	model, _ = model.Update(findMessage[invitationRepliedMessage](t, cmd))
+
+	if view := ansi.Strip(model.View()); !strings.Contains(view, "Tentatively accepted — the reply is in the outbox") {
+		t.Errorf("Expected the card to say the reply was queued, got:\n%s", view)
+	}
+
+	entries, err := queue.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected the reply in the outbox, got %d entries", len(entries))
+	}
+	entry := entries[0]
+	if entry.ReplyTo != "1" {
+		t.Errorf("Expected the reply to be for the invitation, got %q", entry.ReplyTo)
+	}
+	email := entry.Email
+	if email.From != `"Bob Smith" <BOB@example.com>` || email.To != "Alice <alice@example.com>" {
+		t.Errorf("Expected a reply from Bob's identity to Alice, got from %q to %q", email.From, email.To)
+	}
+	if email.Subject != "Tentatively accepted: Design review" || email.InReplyTo != "invite@example.com" {
+		t.Errorf("Unexpected subject %q or in-reply-to %q", email.Subject, email.InReplyTo)
+	}
+	if email.Calendar == nil || email.Calendar.Method != calendar.MethodReply {
+		t.Fatalf("Expected an iTIP reply, got %+v", email.Calendar)
+	}
+	replies, err := calendar.Parse(email.Calendar.Data, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bob, ok := replies[0].FindAttendee("bob@example.com"); !ok || bob.Status != calendar.Tentative {
+		t.Errorf("Expected Bob's answer to be tentative, got %+v", replies[0].Attendees)
+	}
+}
+
+func TestEmailViewerModel_ReplyToInvitation_NotInvited(t *testing.T) {
+	queue, err := outbox.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, _ := newViewer(t, invitationEmail(t), nil, Options{Outbox: queue})
+	model.email.To = "carol@example.com"
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'Y'}})
+	if entries, _ := queue.List(); len(entries) != 0 {
+		t.Errorf("Expected no reply to be queued, got %d", len(entries))
+	}
+	if view := ansi.Strip(model.View()); !strings.Contains(view, "None of your addresses were invited") {
+		t.Errorf("Expected a warning on the card, got:\n%s", view)
+	}
+}
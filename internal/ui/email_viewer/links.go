@@ -0,0 +1,169 @@
+package email_viewer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/bengesoff/mail-tui/internal/links"
+)
+
+type linkOpenedMessage struct {
+	link  links.Link
+	error error
+}
+
+var (
+	hostStyle = lipgloss.NewStyle().
+			Bold(true)
+
+	urlStyle = lipgloss.NewStyle().
+			Faint(true)
+
+	warningStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.AdaptiveColor{Light: "#D70000", Dark: "#FF5F5F"})
+
+	selectedLinkStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.AdaptiveColor{Light: "#EE6FF8", Dark: "#EE6FF8"})
+)
+
+// openLinks shows the links in the current email to choose from. They're found in its source, so links in the HTML
+// version are included, which means fetching the source the first time.
+func (m *EmailViewerModel) openLinks() tea.Cmd {
+	if m.email == nil {
+		return nil
+	}
+	m.pickingLink = true
+	m.linkIndex = 0
+	m.linkStatus = ""
+	if m.raw != nil {
+		m.findLinks()
+		return nil
+	}
+	m.links = nil
+	if m.loadingRaw {
+		return nil
+	}
+	m.loadingRaw = true
+	return m.loadRawEmail(m.email.Id)
+}
+
+// findLinks lists the links in the current email's source, or just in its text if the source can't be read.
+func (m *EmailViewerModel) findLinks() {
+	found, err := links.FromMessage(bytes.NewReader(m.raw))
+	if err != nil {
+		found = links.FromText(m.email.Body)
+		m.linkStatus = "Only showing the links in the text of the email: " + err.Error()
+	}
+	m.links = found
+}
+
+// updateLinks handles keys while a link is being chosen. Links can be chosen by number as well as with the arrows.
+func (m *EmailViewerModel) updateLinks(msg tea.KeyMsg) tea.Cmd {
+	switch key := msg.String(); key {
+	case "esc", "q":
+		m.pickingLink = false
+	case "up", "k":
+		m.linkIndex = max(m.linkIndex-1, 0)
+	case "down", "j":
+		m.linkIndex = max(min(m.linkIndex+1, len(m.links)-1), 0)
+	case "enter":
+		if m.linkIndex < len(m.links) {
+			return m.openLink(m.links[m.linkIndex])
+		}
+	case "y":
+		if m.linkIndex < len(m.links) {
+			m.copyLink(m.links[m.linkIndex])
+		}
+	default:
+		if number, err := strconv.Atoi(key); err == nil && number >= 1 && number <= len(m.links) {
+			m.linkIndex = number - 1
+		}
+	}
+	return nil
+}
+
+// openLink runs the opener command with the URL as its last argument. It isn't run through a shell, so nothing in the
+// URL can be interpreted as part of the command.
+func (m *EmailViewerModel) openLink(link links.Link) tea.Cmd {
+	command := strings.Fields(m.options.OpenCommand)
+	if len(command) == 0 {
+		m.linkStatus = "No command to open links has been configured — y copies the link instead"
+		return nil
+	}
+	m.linkStatus = "Opening " + link.Host() + "..."
+	return func() tea.Msg {
+		err := exec.Command(command[0], append(command[1:], link.URL)...).Run()
+		return linkOpenedMessage{link: link, error: err}
+	}
+}
+
+func (m *EmailViewerModel) handleLinkOpened(msg linkOpenedMessage) {
+	if msg.error != nil {
+		m.linkStatus = "Failed to open the link: " + msg.error.Error()
+		return
+	}
+	m.linkStatus = "Opened " + msg.link.Host()
+}
+
+// copyLink copies the URL to the clipboard with an OSC 52 escape sequence, which the terminal handles, so it works
+// over SSH too. Terminal multiplexers need it wrapped to pass it on.
+func (m *EmailViewerModel) copyLink(link links.Link) {
+	sequence := osc52.New(link.URL)
+	if os.Getenv("TMUX") != "" {
+		sequence = sequence.Tmux()
+	} else if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		sequence = sequence.Screen()
+	}
+	if _, err := sequence.WriteTo(m.terminal); err != nil {
+		m.linkStatus = "Failed to copy the link: " + err.Error()
+		return
+	}
+	m.linkStatus = "Copied the link to " + link.Host()
+}
+
+// linksView lists the links, with the host each one really goes to shown first, and a warning when a link's text
+// looks like it goes somewhere else.
+func (m *EmailViewerModel) linksView() string {
+	var b strings.Builder
+	b.WriteString(metadataHeadingStyle.Render("Links"))
+	b.WriteString("\n\n")
+
+	switch {
+	case m.links == nil && m.loadingRaw:
+		b.WriteString("Finding links...\n")
+	case len(m.links) == 0:
+		b.WriteString("There are no links in this email\n")
+	}
+	for i, link := range m.links {
+		cursor := "  "
+		if i == m.linkIndex {
+			cursor = selectedLinkStyle.Render("> ")
+		}
+		line := fmt.Sprintf("%s%2d  %s", cursor, i+1, hostStyle.Render(link.Host()))
+		if link.Mismatched() {
+			line += "  " + warningStyle.Render(fmt.Sprintf("⚠ shown as %q", link.Text))
+		}
+		b.WriteString(ansi.Truncate(line, m.viewport.Width, "…"))
+		b.WriteString("\n")
+		b.WriteString(ansi.Truncate("      "+urlStyle.Render(link.URL), m.viewport.Width, "…"))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	if m.linkStatus != "" {
+		b.WriteString(m.linkStatus)
+		b.WriteString("\n")
+	}
+	b.WriteString(urlStyle.Render("1-9: Choose • Enter: Open • y: Copy • Esc: Back"))
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package email_viewer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+const rawEmailWithLinks = "Content-Type: multipart/alternative; boundary=b\r\n" +
+	"\r\n" +
+	"--b\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Docs: https://example.com/docs\r\n" +
+	"--b\r\n" +
+	"Content-Type: text/html\r\n" +
+	"\r\n" +
+	`<a href="https://example.com/docs">the docs</a> <a href="https://evil.example.net/">paypal.com</a>` + "\r\n" +
+	"--b--\r\n"
+
+func openLinkPicker(t *testing.T, backend *mockBackend, options Options) *EmailViewerModel {
+	t.Helper()
+	model := NewEmailViewerModel(backend, options)
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	model, load := model.Update(emailLoadedMessage{email: backend.email})
+
+	model, _ = pressKey(model, 'o')
+	if view := model.View(); !strings.Contains(view, "Finding links") {
+		t.Errorf("Expected the links to be loading, got:\n%s", view)
+	}
+	model, _ = loadSource(t, model, load)
+	return model
+}
+
+func TestEmailViewerModel_Links(t *testing.T) {
+	email := &core.Email{EmailMetadata: core.EmailMetadata{Id: "1"}, Body: "Docs: https://example.com/docs"}
+	model := openLinkPicker(t, &mockBackend{email: email, raw: []byte(rawEmailWithLinks)}, Options{})
+
+	view := ansi.Strip(model.View())
+	if !strings.Contains(view, " 1  example.com") || !strings.Contains(view, " 2  evil.example.net") {
+		t.Errorf("Expected the links to be numbered by host, got:\n%s", view)
+	}
+	if !strings.Contains(view, `⚠ shown as "paypal.com"`) {
+		t.Errorf("Expected the mismatched link to be flagged, got:\n%s", view)
+	}
+
+	model, _ = pressKey(model, '2')
+	if model.linkIndex != 1 {
+		t.Errorf("Expected the second link to be chosen, got %d", model.linkIndex)
+	}
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if model.pickingLink {
+		t.Error("Expected esc to close the links")
+	}
+}
+
+func TestEmailViewerModel_Links_SourceUnavailable(t *testing.T) {
+	email := &core.Email{EmailMetadata: core.EmailMetadata{Id: "1"}, Body: "Docs: https://example.com/docs."}
+	model := openLinkPicker(t, &mockBackend{email: email, err: errors.New("no source")}, Options{})
+
+	if len(model.links) != 1 || model.links[0].URL != "https://example.com/docs" || model.error != "" {
+		t.Errorf("Expected to fall back to the links in the text, got %+v and %q", model.links, model.error)
+	}
+}
+
+func TestEmailViewerModel_Links_Open(t *testing.T) {
+	email := &core.Email{EmailMetadata: core.EmailMetadata{Id: "1"}, Body: "Docs: https://example.com/docs"}
+	model := openLinkPicker(t, &mockBackend{email: email, raw: []byte(rawEmailWithLinks)}, Options{OpenCommand: "echo --"})
+
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	opened := findMessage[linkOpenedMessage](t, cmd)
+	if opened.error != nil || opened.link.URL != "https://example.com/docs" {
+		t.Fatalf("Expected the link to be opened, got %+v", opened)
+	}
+	model, _ = model.Update(opened)
+
+	if !strings.Contains(model.View(), "Opened example.com") {
+		t.Errorf("Expected the opened host to be shown, got:\n%s", model.View())
+	}
+}
+
+func TestEmailViewerModel_Links_OpenWithoutCommand(t *testing.T) {
+	email := &core.Email{EmailMetadata: core.EmailMetadata{Id: "1"}}
+	model := openLinkPicker(t, &mockBackend{email: email, raw: []byte(rawEmailWithLinks)}, Options{})
+
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if cmd != nil || !strings.Contains(model.linkStatus, "No command") {
+		t.Errorf("Expected to be told there's no opener, got %q", model.linkStatus)
+	}
+}
+
+func TestEmailViewerModel_Links_Copy(t *testing.T) {
+	t.Setenv("TMUX", "")
+	t.Setenv("TERM", "xterm-256color")
+	email := &core.Email{EmailMetadata: core.EmailMetadata{Id: "1"}}
+	model := openLinkPicker(t, &mockBackend{email: email, raw: []byte(rawEmailWithLinks)}, Options{})
+	var clipboard bytes.Buffer
+	model.terminal = &clipboard
+
+	model, _ = pressKey(model, 'y')
+
+	want := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte("https://example.com/docs")) + "\x07"
+	if clipboard.String() != want {
+		t.Errorf("Expected an OSC 52 sequence %q, got %q", want, clipboard.String())
+	}
+	if !strings.Contains(model.linkStatus, "Copied") {
+		t.Errorf("Expected to be told the link was copied, got %q", model.linkStatus)
+	}
+}
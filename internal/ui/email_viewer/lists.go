@@ -0,0 +1,123 @@
+package email_viewer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/identity"
+	"github.com/bengesoff/mail-tui/internal/lists"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+// unsubscribeTimeout is how long the list's server has to answer a one-click unsubscribe.
+const unsubscribeTimeout = 30 * time.Second
+
+type unsubscribedMessage struct {
+	id    core.EmailId
+	list  string
+	error error
+}
+
+// replyToList opens the composer to reply to the mailing list the current email came through, rather than its sender.
+func (m *EmailViewerModel) replyToList() tea.Cmd {
+	if m.email == nil {
+		return nil
+	}
+	list := m.email.List
+	if list == nil || list.Post == "" {
+		m.setListStatus(warningStyle.Render("This email didn't come through a list that can be posted to"))
+		return nil
+	}
+	email := m.email
+	return func() tea.Msg {
+		return ui.ShowEmailComposerMessage{ReplyTo: email, ReplyToList: true}
+	}
+}
+
+// confirmUnsubscribe asks before unsubscribing from the current email's list, saying how it'll be done.
+func (m *EmailViewerModel) confirmUnsubscribe() {
+	if m.email == nil {
+		return
+	}
+	list := m.email.List
+	if list == nil || len(list.Unsubscribe) == 0 {
+		m.setListStatus(warningStyle.Render("This email didn't come through a list that can be unsubscribed from"))
+		return
+	}
+	how := ""
+	if list.OneClick {
+		how = "with one click"
+	} else if uri, ok := lists.MailtoURI(*list); ok {
+		email, _ := lists.UnsubscribeEmail(uri)
+		how = "by emailing " + email.To
+	} else {
+		m.setListStatus(warningStyle.Render("This list can only be unsubscribed from in a browser: " + list.Unsubscribe[0]))
+		return
+	}
+	m.confirmingUnsubscribe = true
+	m.setListStatus(fmt.Sprintf("Unsubscribe from %s %s? (y/n)", list.Label(), how))
+}
+
+// updateUnsubscribe handles the answer to whether to unsubscribe.
+func (m *EmailViewerModel) updateUnsubscribe(msg tea.KeyMsg) tea.Cmd {
+	m.confirmingUnsubscribe = false
+	if msg.String() != "y" {
+		m.setListStatus("")
+		return nil
+	}
+	return m.unsubscribe()
+}
+
+// unsubscribe leaves the current email's list, with an RFC 8058 POST if it can be done in one click, or otherwise by
+// opening the email the list's mailto: URI asks for in the composer, sent from whichever identity the list sends to.
+func (m *EmailViewerModel) unsubscribe() tea.Cmd {
+	list := *m.email.List
+	if uri, ok := lists.OneClickURL(list); ok && list.OneClick {
+		m.setListStatus("Unsubscribing...")
+		id := m.email.Id
+		client := m.httpClient
+		return func() tea.Msg {
+			ctx, cancel := context.WithTimeout(context.Background(), unsubscribeTimeout)
+			defer cancel()
+			err := lists.UnsubscribeOneClick(ctx, client, uri)
+			return unsubscribedMessage{id: id, list: list.Label(), error: err}
+		}
+	}
+
+	uri, _ := lists.MailtoURI(list)
+	email, err := lists.UnsubscribeEmail(uri)
+	if err != nil {
+		m.setListStatus(warningStyle.Render("Failed to unsubscribe: " + err.Error()))
+		return nil
+	}
+	email.From = m.email.To
+	if chosen, ok := identity.ForReply(m.options.Identities, m.email); ok {
+		email.From = chosen.From()
+	}
+	m.setListStatus("")
+	return func() tea.Msg {
+		return ui.ShowEmailComposerMessage{Compose: &email}
+	}
+}
+
+func (m *EmailViewerModel) handleUnsubscribed(msg unsubscribedMessage) {
+	if m.email == nil || msg.id != m.email.Id {
+		return
+	}
+	if msg.error != nil {
+		m.setListStatus(warningStyle.Render("Failed to unsubscribe: " + msg.error.Error()))
+		return
+	}
+	m.setListStatus("Unsubscribed from " + msg.list)
+}
+
+func (m *EmailViewerModel) setListStatus(status string) {
+	m.listStatus = status
+	if err := m.updateViewportContent(); err != nil {
+		m.error = err.Error()
+	}
+}
@@ -0,0 +1,136 @@
+package email_viewer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/identity"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+func listEmail(list *core.MailingList) *core.Email {
+	return &core.Email{
+		EmailMetadata: core.EmailMetadata{
+			Id:      "1",
+			From:    "news@example.com",
+			To:      "Bob <bob@example.com>",
+			Subject: "Release notes",
+			List:    list,
+		},
+		Body: "What's new",
+	}
+}
+
+func TestEmailViewerModel_ShowsList(t *testing.T) {
+	model, _ := newViewer(t, listEmail(&core.MailingList{
+		Id:          "announce.example.com",
+		Name:        "Announcements",
+		Post:        "announce@example.com",
+		Unsubscribe: []string{"mailto:leave@example.com"},
+	}), nil, Options{})
+
+	view := ansi.Strip(model.View())
+	if !strings.Contains(view, "Announcements <announce.example.com> (L: reply to list • U: unsubscribe)") {
+		t.Errorf("Expected the list in the summary, got:\n%s", view)
+	}
+}
+
+func TestEmailViewerModel_ReplyToList(t *testing.T) {
+	model, _ := newViewer(t, listEmail(&core.MailingList{Id: "announce.example.com", Post: "announce@example.com"}), nil, Options{})
+
+	_, cmd := pressKey(model, 'L')
+	msg := findMessage[ui.ShowEmailComposerMessage](t, cmd)
+	if !msg.ReplyToList || msg.ReplyTo != model.email {
+		t.Errorf("Expected a reply to the list, got %+v", msg)
+	}
+}
+
+func TestEmailViewerModel_ReplyToList_NotAList(t *testing.T) {
+	model, _ := newViewer(t, listEmail(nil), nil, Options{})
+
+	model, cmd := pressKey(model, 'L')
+	if cmd != nil {
+		if _, ok := cmd().(ui.ShowEmailComposerMessage); ok {
+			t.Error("Expected the composer not to be opened")
+		}
+	}
+	if view := ansi.Strip(model.View()); !strings.Contains(view, "didn't come through a list that can be posted to") {
+		t.Errorf("Expected a warning, got:\n%s", view)
+	}
+}
+
+func TestEmailViewerModel_UnsubscribeOneClick(t *testing.T) {
+	var body string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	model, _ := newViewer(t, listEmail(&core.MailingList{
+		Name:        "Announcements",
+		Unsubscribe: []string{"mailto:leave@example.com", server.URL + "/leave"},
+		OneClick:    true,
+	}), nil, Options{})
+	model.httpClient = server.Client()
+
+	model, _ = pressKey(model, 'U')
+	if view := ansi.Strip(model.View()); !strings.Contains(view, "Unsubscribe from Announcements with one click? (y/n)") {
+		t.Fatalf("Expected to be asked first, got:\n%s", view)
+	}
+	if body != "" {
+		t.Fatal("Expected nothing to be sent before confirming")
+	}
+
+	model, cmd := pressKey(model, 'y')
+	model, _ = model.Update(findMessage[unsubscribedMessage](t, cmd))
+	if body != "List-Unsubscribe=One-Click" {
+		t.Errorf("Expected a one-click POST, got %q", body)
+	}
+	if view := ansi.Strip(model.View()); !strings.Contains(view, "Unsubscribed from Announcements") {
+		t.Errorf("Expected to be told it worked, got:\n%s", view)
+	}
+}
+
+func TestEmailViewerModel_UnsubscribeByEmail(t *testing.T) {
+	model, _ := newViewer(t, listEmail(&core.MailingList{
+		Id:          "announce.example.com",
+		Unsubscribe: []string{"https://example.com/leave", "mailto:leave@example.com?subject=remove%20me"},
+	}), nil, Options{Identities: []identity.Identity{{Name: "Bob Smith", Address: "bob@example.com"}}})
+
+	model, _ = pressKey(model, 'U')
+	if view := ansi.Strip(model.View()); !strings.Contains(view, "by emailing leave@example.com? (y/n)") {
+		t.Fatalf("Expected to be asked first, got:\n%s", view)
+	}
+
+	_, cmd := pressKey(model, 'y')
+	msg := findMessage[ui.ShowEmailComposerMessage](t, cmd)
+	email := msg.Compose
+	if email == nil || email.To != "leave@example.com" || email.Subject != "remove me" {
+		t.Fatalf("Expected the unsubscribe email to be composed, got %+v", email)
+	}
+	if email.From != `"Bob Smith" <bob@example.com>` {
+		t.Errorf("Expected it to be from the identity the list sends to, got %q", email.From)
+	}
+}
+
+func TestEmailViewerModel_UnsubscribeCancelled(t *testing.T) {
+	model, _ := newViewer(t, listEmail(&core.MailingList{Unsubscribe: []string{"mailto:leave@example.com"}}), nil, Options{})
+
+	model, _ = pressKey(model, 'U')
+	model, cmd := pressKey(model, 'n')
+	if cmd != nil {
+		if _, ok := cmd().(ui.ShowEmailComposerMessage); ok {
+			t.Error("Expected the composer not to be opened")
+		}
+	}
+	if view := ansi.Strip(model.View()); strings.Contains(view, "Unsubscribe from") {
+		t.Errorf("Expected the question to be gone, got:\n%s", view)
+	}
+}
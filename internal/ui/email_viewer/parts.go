@@ -0,0 +1,211 @@
+package email_viewer
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/reflow/wrap"
+
+	"github.com/bengesoff/mail-tui/internal/mailcap"
+	"github.com/bengesoff/mail-tui/internal/message"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+// partCommandMessage is sent once a part has been written out and its mailcap command is ready to run.
+type partCommandMessage struct {
+	part    message.Part
+	command *mailcap.Command
+	error   error
+}
+
+// partOutputMessage carries what a copiousoutput command printed, to be shown in the viewer.
+type partOutputMessage struct {
+	part   message.Part
+	output []byte
+	error  error
+}
+
+// partViewedMessage is sent once a command that shows a part itself has finished.
+type partViewedMessage struct {
+	part  message.Part
+	error error
+}
+
+// openParts lists the parts of the current email to choose one to view, fetching its source the first time.
+func (m *EmailViewerModel) openParts() tea.Cmd {
+	if m.email == nil {
+		return nil
+	}
+	m.pickingPart = true
+	m.partIndex = 0
+	m.partStatus = ""
+	if m.raw != nil {
+		m.findParts()
+		return nil
+	}
+	m.parts = nil
+	if m.loadingRaw {
+		return nil
+	}
+	m.loadingRaw = true
+	return m.loadRawEmail(m.email.Id)
+}
+
+func (m *EmailViewerModel) findParts() {
+	parts, err := message.ParseParts(bytes.NewReader(m.raw))
+	if err != nil {
+		m.partStatus = "Failed to read the parts of the email: " + err.Error()
+	}
+	m.parts = parts
+}
+
+// updateParts handles keys while a part is being chosen.
+func (m *EmailViewerModel) updateParts(msg tea.KeyMsg) tea.Cmd {
+	switch key := msg.String(); key {
+	case "esc", "q":
+		m.pickingPart = false
+	case "up", "k":
+		m.partIndex = max(m.partIndex-1, 0)
+	case "down", "j":
+		m.partIndex = max(min(m.partIndex+1, len(m.parts)-1), 0)
+	case "enter":
+		if m.partIndex < len(m.parts) {
+			return m.viewPart(m.parts[m.partIndex])
+		}
+	default:
+		if number, err := strconv.Atoi(key); err == nil && number >= 1 && number <= len(m.parts) {
+			m.partIndex = number - 1
+		}
+	}
+	return nil
+}
+
+// viewPart finds the mailcap entry for the part and writes it to a temporary file for the entry's command. Looking
+// up the entry can run its test command, so it isn't done while handling the key.
+func (m *EmailViewerModel) viewPart(part message.Part) tea.Cmd {
+	m.partStatus = "Opening " + partName(part) + "..."
+	entries := m.options.Mailcap
+	return func() tea.Msg {
+		entry, ok := entries.Lookup(part.ContentType, part.Params)
+		if !ok {
+			return partCommandMessage{part: part, error: fmt.Errorf("there's no mailcap entry for %s", part.ContentType)}
+		}
+		command, err := entry.Prepare(part.Data, part.ContentType, part.Params, part.Filename)
+		return partCommandMessage{part: part, command: command, error: err}
+	}
+}
+
+// handlePartCommand runs a part's command in the way its mailcap entry asks for. Commands that need the terminal get
+// it while they run, and the temporary file is removed once each one finishes.
+func (m *EmailViewerModel) handlePartCommand(msg partCommandMessage) tea.Cmd {
+	if msg.error != nil {
+		m.partStatus = "Failed to open " + partName(msg.part) + ": " + msg.error.Error()
+		return nil
+	}
+
+	command := msg.command
+	switch {
+	case command.Entry.CopiousOutput:
+		return func() tea.Msg {
+			output, err := command.Output()
+			_ = command.Cleanup()
+			return partOutputMessage{part: msg.part, output: output, error: err}
+		}
+	case command.Entry.NeedsTerminal:
+		return tea.ExecProcess(command.Cmd, func(err error) tea.Msg {
+			_ = command.Cleanup()
+			return partViewedMessage{part: msg.part, error: err}
+		})
+	default:
+		return func() tea.Msg {
+			err := command.Run()
+			_ = command.Cleanup()
+			return partViewedMessage{part: msg.part, error: err}
+		}
+	}
+}
+
+func (m *EmailViewerModel) handlePartOutput(msg partOutputMessage) {
+	if msg.error != nil {
+		m.partStatus = "Failed to open " + partName(msg.part) + ": " + msg.error.Error()
+		return
+	}
+	m.pickingPart = false
+	m.mode = partMode
+	m.partTitle = partName(msg.part)
+	m.partOutput = sanitise(string(msg.output))
+	if err := m.updateViewportContent(); err != nil {
+		m.error = err.Error()
+		return
+	}
+	m.viewport.GotoTop()
+}
+
+func (m *EmailViewerModel) handlePartViewed(msg partViewedMessage) {
+	if msg.error != nil {
+		m.partStatus = "Failed to open " + partName(msg.part) + ": " + msg.error.Error()
+		return
+	}
+	m.partStatus = "Closed " + partName(msg.part)
+}
+
+// sanitise removes escape sequences and other control characters from a command's output, since it comes from the
+// email and could otherwise control the terminal.
+func sanitise(output string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' && r != '\n' && r != '\t' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, ansi.Strip(strings.ReplaceAll(output, "\r\n", "\n")))
+}
+
+func (m *EmailViewerModel) renderPartOutput() string {
+	heading := conversationHeadingStyle.Render(m.partTitle) + "\n\n"
+	return heading + wrap.String(m.partOutput, m.viewport.Width)
+}
+
+func partName(part message.Part) string {
+	if part.Filename != "" {
+		return part.Filename
+	}
+	return part.ContentType
+}
+
+func (m *EmailViewerModel) partsView() string {
+	var b strings.Builder
+	b.WriteString(metadataHeadingStyle.Render("Parts"))
+	b.WriteString("\n\n")
+
+	switch {
+	case m.parts == nil && m.loadingRaw:
+		b.WriteString("Finding parts...\n")
+	case len(m.parts) == 0:
+		b.WriteString("There are no parts in this email\n")
+	}
+	for i, part := range m.parts {
+		cursor := "  "
+		if i == m.partIndex {
+			cursor = selectedLinkStyle.Render("> ")
+		}
+		line := fmt.Sprintf("%s%2d  %s", cursor, i+1, hostStyle.Render(partName(part)))
+		if part.Filename != "" {
+			line += "  " + urlStyle.Render(part.ContentType)
+		}
+		line += "  " + urlStyle.Render(ui.FormatSize(len(part.Data)))
+		b.WriteString(ansi.Truncate(line, m.viewport.Width, "…"))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	if m.partStatus != "" {
+		b.WriteString(m.partStatus)
+		b.WriteString("\n")
+	}
+	b.WriteString(urlStyle.Render("1-9: Choose • Enter: View with mailcap • Esc: Back"))
+	return b.String()
+}
@@ -0,0 +1,109 @@
+package email_viewer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/mailcap"
+)
+
+const rawEmailWithAttachment = "Content-Type: multipart/mixed; boundary=b\r\n" +
+	"\r\n" +
+	"--b\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"The menu is attached\r\n" +
+	"--b\r\n" +
+	"Content-Type: application/pdf\r\n" +
+	"Content-Disposition: attachment; filename=menu.pdf\r\n" +
+	"\r\n" +
+	"Soup of the day\x1b[31m\x1b]52;c;ZXZpbA==\x07\r\n" +
+	"--b--\r\n"
+
+func loadTestMailcap(t *testing.T, contents string) *mailcap.Mailcap {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mailcap")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	loaded, err := mailcap.Load(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return loaded
+}
+
+func openPartPicker(t *testing.T, options Options) *EmailViewerModel {
+	t.Helper()
+	email := &core.Email{EmailMetadata: core.EmailMetadata{Id: "1"}, Body: "The menu is attached"}
+	model, load := newViewer(t, email, []byte(rawEmailWithAttachment), options)
+
+	model, _ = pressKey(model, 'a')
+	model, _ = loadSource(t, model, load)
+	return model
+}
+
+func TestEmailViewerModel_Parts_CopiousOutput(t *testing.T) {
+	model := openPartPicker(t, Options{Mailcap: loadTestMailcap(t, "application/pdf; cat %s; copiousoutput\n")})
+
+	view := ansi.Strip(model.View())
+	if !strings.Contains(view, " 1  text/plain") || !strings.Contains(view, " 2  menu.pdf  application/pdf") {
+		t.Errorf("Expected the parts to be listed, got:\n%s", view)
+	}
+
+	model, _ = pressKey(model, '2')
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	ready := findMessage[partCommandMessage](t, cmd)
+	if ready.error != nil {
+		t.Fatalf("Unexpected error: %v", ready.error)
+	}
+	path := strings.Trim(strings.TrimPrefix(ready.command.Args[2], "cat "), "'")
+	model, cmd = model.Update(ready)
+	model, _ = model.Update(findMessage[partOutputMessage](t, cmd))
+
+	if model.pickingPart || model.mode != partMode {
+		t.Fatal("Expected the output to be shown in the viewer")
+	}
+	view = model.View()
+	if !strings.Contains(view, "Soup of the day") || strings.Contains(view, "\x1b]52") {
+		t.Errorf("Expected the output without its escape sequences, got %q", view)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file to be removed, got %v", err)
+	}
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if model.mode != bodyMode {
+		t.Error("Expected esc to go back to the body")
+	}
+}
+
+func TestEmailViewerModel_Parts_ExternalViewer(t *testing.T) {
+	model := openPartPicker(t, Options{Mailcap: loadTestMailcap(t, "application/*; test -s %s\n")})
+
+	model, _ = pressKey(model, '2')
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model, cmd = model.Update(findMessage[partCommandMessage](t, cmd))
+	model, _ = model.Update(findMessage[partViewedMessage](t, cmd))
+
+	if model.partStatus != "Closed menu.pdf" {
+		t.Errorf("Expected the viewer to have run, got %q", model.partStatus)
+	}
+}
+
+func TestEmailViewerModel_Parts_NoMailcapEntry(t *testing.T) {
+	model := openPartPicker(t, Options{})
+
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model, _ = model.Update(findMessage[partCommandMessage](t, cmd))
+
+	if !strings.Contains(model.partStatus, "no mailcap entry for text/plain") {
+		t.Errorf("Expected to be told there's no entry, got %q", model.partStatus)
+	}
+}
@@ -0,0 +1,145 @@
+package email_viewer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/message"
+	"github.com/bengesoff/mail-tui/internal/pgp"
+	"github.com/bengesoff/mail-tui/internal/smime"
+)
+
+type unprotectedMessage struct {
+	id core.EmailId
+	// raw is the source that was unwrapped
+	raw     []byte
+	openPGP *pgp.Result
+	smime   *smime.Result
+}
+
+// unprotect verifies an email's OpenPGP or S/MIME signature, or decrypts it, from its source. Emails that aren't
+// protected are left as they are.
+func (m *EmailViewerModel) unprotect(emailId core.EmailId, raw []byte) tea.Cmd {
+	keyring, smimeConfig := m.options.Keyring, m.options.SMIME
+	return func() tea.Msg {
+		if result := pgp.Unwrap(raw, keyring); result != nil {
+			return unprotectedMessage{id: emailId, raw: raw, openPGP: result}
+		}
+		return unprotectedMessage{id: emailId, raw: raw, smime: smime.Unwrap(raw, smimeConfig)}
+	}
+}
+
+// handleUnprotected shows the email that was protected, and then decodes its images, so the images in an encrypted
+// email are found too.
+func (m *EmailViewerModel) handleUnprotected(msg unprotectedMessage) tea.Cmd {
+	if m.email == nil || (msg.id != m.email.Id && !m.inConversation(msg.id)) {
+		// the viewer may have moved on to another email while this one was being unwrapped
+		return nil
+	}
+	source := msg.raw
+	var unwrapped []byte
+	encrypted := false
+	switch {
+	case msg.openPGP != nil && msg.openPGP.Encrypted:
+		unwrapped, encrypted = msg.openPGP.Raw, true
+	case msg.smime != nil:
+		// S/MIME emails can be signed with their content inside the signature, where it can only be read once it's
+		// been unwrapped
+		unwrapped, encrypted = msg.smime.Raw, msg.smime.Encrypted
+	}
+	if unwrapped != nil {
+		source = unwrapped
+		for i, email := range m.conversation {
+			if email.Id == msg.id {
+				m.conversation[i] = unwrappedEmail(email, unwrapped, encrypted)
+			}
+		}
+		if m.email.Id == msg.id {
+			m.email = unwrappedEmail(m.email, unwrapped, encrypted)
+			// the parts and links are the ones that were protected, rather than the protected message itself
+			m.raw = unwrapped
+		}
+	}
+	m.openPGP[msg.id] = msg.openPGP
+	m.smime[msg.id] = msg.smime
+	if err := m.updateViewportContent(); err != nil {
+		m.error = err.Error()
+	}
+	if len(m.conversation) > 0 {
+		return nil
+	}
+	return m.loadImages(msg.id, source)
+}
+
+// unwrappedEmail is a copy of an email with its body read from the message that was protected.
+func unwrappedEmail(email *core.Email, raw []byte, encrypted bool) *core.Email {
+	unwrapped := *email
+	body, flowed, err := message.ParseBody(bytes.NewReader(raw))
+	if err != nil {
+		body = "Failed to read the protected email: " + err.Error()
+	}
+	unwrapped.Body = body
+	unwrapped.Flowed = flowed
+	unwrapped.Encrypted = encrypted
+	return &unwrapped
+}
+
+// describeOpenPGP summarises how the email was protected, like "Encrypted • Signed by Alice <alice@example.com> ✓".
+func describeOpenPGP(result *pgp.Result) string {
+	var signature string
+	if result.Signature != nil {
+		signature = describeSignature(result.Signature.Signer, result.Signature.Err)
+	}
+	return describeProtection(result.Encrypted, result.Err, signature)
+}
+
+// describeSMIME summarises how the email was protected like describeOpenPGP, along with who issued the signer's
+// certificate and when it's valid.
+func describeSMIME(result *smime.Result) string {
+	var signature string
+	if s := result.Signature; s != nil {
+		signature = describeSignature(s.Signer, s.Err)
+		if s.Issuer != "" {
+			signature += urlStyle.Render(fmt.Sprintf(" (issued by %s, valid %s to %s)", s.Issuer,
+				s.NotBefore.Local().Format("2 Jan 2006"), s.NotAfter.Local().Format("2 Jan 2006")))
+		}
+	}
+	return describeProtection(result.Encrypted, result.Err, signature)
+}
+
+// describeSignature says who made a signature, and why it's bad if it is.
+func describeSignature(signer string, err error) string {
+	switch {
+	case err == nil:
+		return "Signed by " + signer + " ✓"
+	case signer != "":
+		return warningStyle.Render("Bad signature from " + signer + ": " + err.Error())
+	default:
+		return warningStyle.Render("Couldn't verify the signature: " + err.Error())
+	}
+}
+
+// describeProtection lists whether an email was encrypted and how it was signed, or that it wasn't.
+func describeProtection(encrypted bool, err error, signature string) string {
+	var descriptions []string
+	if encrypted {
+		if err != nil {
+			descriptions = append(descriptions, warningStyle.Render("Encrypted, but couldn't be decrypted: "+err.Error()))
+		} else {
+			descriptions = append(descriptions, "Encrypted")
+		}
+	} else if err != nil {
+		descriptions = append(descriptions, warningStyle.Render("Couldn't be read: "+err.Error()))
+	}
+
+	if signature != "" {
+		descriptions = append(descriptions, signature)
+	} else if err == nil {
+		descriptions = append(descriptions, urlStyle.Render("Not signed"))
+	}
+	return strings.Join(descriptions, " • ")
+}
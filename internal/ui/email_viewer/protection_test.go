@@ -0,0 +1,370 @@
+package email_viewer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/smallstep/pkcs7"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/message"
+	"github.com/bengesoff/mail-tui/internal/pgp"
+	"github.com/bengesoff/mail-tui/internal/smime"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+// newKeys generates throwaway keys for Alice, who sends the emails, and Bob, who reads them. Each keyring has its
+// owner's private key and the other's public key.
+func newKeys(t *testing.T) (alice, bob *pgp.Keyring) {
+	t.Helper()
+	aliceKey, err := openpgp.NewEntity("Alice", "", "alice@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobKey, err := openpgp.NewEntity("Bob", "", "bob@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicOnly := func(entity *openpgp.Entity) *openpgp.Entity {
+		var b bytes.Buffer
+		if err := entity.Serialize(&b); err != nil {
+			t.Fatal(err)
+		}
+		entities, err := openpgp.ReadKeyRing(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entities[0]
+	}
+	return pgp.NewKeyring(aliceKey, publicOnly(bobKey)), pgp.NewKeyring(bobKey, publicOnly(aliceKey))
+}
+
+func protectedEmail(t *testing.T, keyring *pgp.Keyring, protection core.Protection) []byte {
+	t.Helper()
+	raw, err := message.Build(core.OutgoingEmail{
+		From:      "Alice <alice@example.com>",
+		To:        "bob@example.com",
+		Subject:   "Launch codes",
+		Body:      "The code is 1234.",
+		MessageId: "launch@example.com",
+	}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	protected, err := pgp.Protect(raw, keyring, "alice@example.com", []string{"bob@example.com"}, protection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return protected
+}
+
+// launchCodesEmail has no text to show until it's decrypted, like the encrypted emails the server lists.
+func launchCodesEmail() *core.Email {
+	return &core.Email{EmailMetadata: core.EmailMetadata{Id: "1", From: "Alice <alice@example.com>", Subject: "Launch codes"}}
+}
+
+// unprotectSource delivers the source fetched by the commands from loading an email, and then what verifying or
+// decrypting it found.
+func unprotectSource(t *testing.T, model *EmailViewerModel, cmd tea.Cmd) *EmailViewerModel {
+	t.Helper()
+	model, cmd = loadSource(t, model, cmd)
+	model, _ = model.Update(findMessage[unprotectedMessage](t, cmd))
+	return model
+}
+
+func TestEmailViewerModel_Decrypted(t *testing.T) {
+	alice, bob := newKeys(t)
+	model, load := newViewer(t, launchCodesEmail(), protectedEmail(t, alice, core.Protection{Sign: true, Encrypt: true}), Options{Keyring: bob})
+	model = unprotectSource(t, model, load)
+
+	view := ansi.Strip(model.View())
+	if !strings.Contains(view, "The code is 1234.") {
+		t.Errorf("Expected the decrypted body, got:\n%s", view)
+	}
+	if !strings.Contains(view, "│OpenPGP│Encrypted • Signed by Alice <alice@example.com> ✓") {
+		t.Errorf("Expected the signature in the summary, got:\n%s", view)
+	}
+	if !bytes.Contains(model.raw, []byte("The code is 1234.")) {
+		t.Error("Expected the decrypted source to be kept for the parts and links")
+	}
+
+	_, cmd := pressKey(model, 'r')
+	if reply := findMessage[ui.ShowEmailComposerMessage](t, cmd); reply.ReplyTo == nil || !reply.ReplyTo.Encrypted {
+		t.Errorf("Expected the reply to know the email was encrypted, got %+v", reply.ReplyTo)
+	}
+}
+
+func TestEmailViewerModel_NotDecrypted(t *testing.T) {
+	alice, _ := newKeys(t)
+	model, load := newViewer(t, launchCodesEmail(), protectedEmail(t, alice, core.Protection{Encrypt: true}), Options{})
+	model = unprotectSource(t, model, load)
+
+	view := ansi.Strip(model.View())
+	if !strings.Contains(view, "Encrypted, but couldn't be decrypted: no OpenPGP keyring is configured") {
+		t.Errorf("Expected to be told the email couldn't be decrypted, got:\n%s", view)
+	}
+}
+
+func TestEmailViewerModel_BadSignature(t *testing.T) {
+	alice, bob := newKeys(t)
+	signed := protectedEmail(t, alice, core.Protection{Sign: true})
+	tampered := bytes.Replace(signed, []byte("1234"), []byte("4321"), 1)
+	model, load := newViewer(t, launchCodesEmail(), tampered, Options{Keyring: bob})
+	model = unprotectSource(t, model, load)
+
+	view := ansi.Strip(model.View())
+	if !strings.Contains(view, "Bad signature from Alice <alice@example.com>") {
+		t.Errorf("Expected a warning about the signature, got:\n%s", view)
+	}
+	if model.email.Encrypted {
+		t.Error("Expected a signed email not to count as encrypted")
+	}
+}
+
+// unprotectConversation loads a conversation where only the first email's source is protected, returning its view.
+func unprotectConversation(t *testing.T, raw []byte, options Options) string {
+	t.Helper()
+	model := NewEmailViewerModel(&mockBackend{}, options)
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 120, Height: 80})
+	first := &core.Email{EmailMetadata: core.EmailMetadata{Id: "1", From: "Alice <alice@example.com>", Flags: []core.Flag{core.FlagSeen}}}
+	second := &core.Email{EmailMetadata: core.EmailMetadata{Id: "2", From: "bob@example.com", Flags: []core.Flag{core.FlagSeen}}, Body: "Thanks"}
+	model, _ = model.Update(conversationLoadedMessage{emails: []*core.Email{first, second}})
+
+	model, cmd := model.Update(rawEmailLoadedMessage{id: "1", raw: raw})
+	model, _ = model.Update(findMessage[unprotectedMessage](t, cmd))
+	model, cmd = model.Update(rawEmailLoadedMessage{id: "2", raw: []byte("From: bob@example.com\r\n\r\nThanks\r\n")})
+	model, _ = model.Update(findMessage[unprotectedMessage](t, cmd))
+	return ansi.Strip(model.View())
+}
+
+func TestEmailViewerModel_ConversationSignatures(t *testing.T) {
+	alice, bob := newKeys(t)
+	signed := protectedEmail(t, alice, core.Protection{Sign: true})
+	tampered := bytes.Replace(signed, []byte("1234"), []byte("4321"), 1)
+
+	view := unprotectConversation(t, tampered, Options{Keyring: bob})
+
+	warning, reply := strings.Index(view, "Bad signature from Alice <alice@example.com>"), strings.Index(view, "Message 2 of 2")
+	if warning == -1 || warning > reply {
+		t.Errorf("Expected a warning about the first email's signature, got:\n%s", view)
+	}
+	if strings.Count(view, "OpenPGP") != 1 {
+		t.Errorf("Expected nothing to be said about OpenPGP for the reply, got:\n%s", view)
+	}
+}
+
+func TestEmailViewerModel_UnprotectedEmail(t *testing.T) {
+	model, load := newViewer(t, launchCodesEmail(), []byte("From: alice@example.com\r\n\r\nHello\r\n"), Options{})
+	model = unprotectSource(t, model, load)
+	if model.openPGP["1"] != nil || model.smime["1"] != nil || strings.Contains(model.View(), "OpenPGP") || strings.Contains(model.View(), "S/MIME") {
+		t.Error("Expected nothing to be said about OpenPGP or S/MIME for an email that isn't protected")
+	}
+}
+
+// newCertificates generates a throwaway certificate authority that issues certificates for Alice, who sends the
+// emails, and Bob, who reads them. Both configs trust the authority.
+func newCertificates(t *testing.T) (alice, bob *smime.Config) {
+	t.Helper()
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted := x509.NewCertPool()
+	trusted.AddCert(ca)
+
+	issue := func(serial int64, name, address string) *smime.Config {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber:   big.NewInt(serial),
+			Subject:        pkix.Name{CommonName: name},
+			EmailAddresses: []string{address},
+			NotBefore:      time.Now().Add(-time.Hour),
+			NotAfter:       time.Now().Add(12 * time.Hour),
+			KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certificate, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &smime.Config{Trusted: trusted, Certificate: certificate, Key: key}
+	}
+	return issue(2, "Alice", "alice@example.com"), issue(3, "Bob", "bob@example.com")
+}
+
+func smimeEmail(t *testing.T) []byte {
+	t.Helper()
+	raw, err := message.Build(core.OutgoingEmail{
+		From:      "Alice <alice@example.com>",
+		To:        "bob@example.com",
+		Subject:   "Launch codes",
+		Body:      "The code is 1234.",
+		MessageId: "launch@example.com",
+	}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// encryptedSMIMEEmail encrypts an email to Bob, as other clients send them, since emails are only signed with S/MIME
+// here.
+func encryptedSMIMEEmail(t *testing.T, bob *smime.Config) []byte {
+	t.Helper()
+	return wrapSMIMEEmail(t, "enveloped-data", func(content []byte) ([]byte, error) {
+		return pkcs7.Encrypt(content, []*x509.Certificate{bob.Certificate})
+	})
+}
+
+// opaqueSMIMEEmail signs an email as Alice with its content inside the signature, as other clients can send them.
+func opaqueSMIMEEmail(t *testing.T, alice *smime.Config) []byte {
+	t.Helper()
+	return wrapSMIMEEmail(t, "signed-data", func(content []byte) ([]byte, error) {
+		signedData, err := pkcs7.NewSignedData(content)
+		if err != nil {
+			return nil, err
+		}
+		if err := signedData.AddSigner(alice.Certificate, alice.Key, pkcs7.SignerInfoConfig{}); err != nil {
+			return nil, err
+		}
+		return signedData.Finish()
+	})
+}
+
+// wrapSMIMEEmail replaces the content of an email with the application/pkcs7-mime part that wrap makes from it.
+func wrapSMIMEEmail(t *testing.T, smimeType string, wrap func(content []byte) ([]byte, error)) []byte {
+	t.Helper()
+	header, content, err := message.SplitContent(smimeEmail(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := wrap(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped := "Content-Type: application/pkcs7-mime; smime-type=" + smimeType + "; name=\"smime.p7m\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" + base64.StdEncoding.EncodeToString(der) + "\r\n"
+	raw, err := message.JoinContent(header, []byte(wrapped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestEmailViewerModel_SMIMESigned(t *testing.T) {
+	alice, bob := newCertificates(t)
+	signed, err := smime.Sign(smimeEmail(t), alice, "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, load := newViewer(t, launchCodesEmail(), signed, Options{SMIME: bob})
+	model = unprotectSource(t, model, load)
+
+	view := ansi.Strip(model.View())
+	if !strings.Contains(view, "│S/MIME │Signed by Alice <alice@example.com> ✓ (issued by Test CA, valid") {
+		t.Errorf("Expected the signer and their certificate in the summary, got:\n%s", view)
+	}
+	if !strings.Contains(view, "The code is 1234.") || model.email.Encrypted {
+		t.Errorf("Expected the signed body, got:\n%s", view)
+	}
+}
+
+func TestEmailViewerModel_SMIMEUntrusted(t *testing.T) {
+	alice, _ := newCertificates(t)
+	signed, err := smime.Sign(smimeEmail(t), alice, "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, bob := newCertificates(t)
+	model, load := newViewer(t, launchCodesEmail(), signed, Options{SMIME: bob})
+	model = unprotectSource(t, model, load)
+
+	view := ansi.Strip(model.View())
+	if !strings.Contains(view, "Bad signature from Alice <alice@example.com>: the certificate isn't issued by a trusted authority") {
+		t.Errorf("Expected a warning that Alice's certificate isn't trusted, got:\n%s", view)
+	}
+}
+
+func TestEmailViewerModel_ConversationSMIMESignatures(t *testing.T) {
+	alice, _ := newCertificates(t)
+	_, bob := newCertificates(t)
+
+	view := unprotectConversation(t, opaqueSMIMEEmail(t, alice), Options{SMIME: bob})
+
+	warning, reply := strings.Index(view, "Bad signature from Alice <alice@example.com>"), strings.Index(view, "Message 2 of 2")
+	if warning == -1 || warning > reply {
+		t.Errorf("Expected a warning about the first email's signature, got:\n%s", view)
+	}
+	if !strings.Contains(view, "The code is 1234.") {
+		t.Errorf("Expected the signed body of the first email, got:\n%s", view)
+	}
+}
+
+func TestEmailViewerModel_SMIMEDecrypted(t *testing.T) {
+	_, bob := newCertificates(t)
+	model, load := newViewer(t, launchCodesEmail(), encryptedSMIMEEmail(t, bob), Options{SMIME: bob})
+	model = unprotectSource(t, model, load)
+
+	view := ansi.Strip(model.View())
+	if !strings.Contains(view, "The code is 1234.") {
+		t.Errorf("Expected the decrypted body, got:\n%s", view)
+	}
+	if !strings.Contains(view, "│S/MIME │Encrypted • Not signed") {
+		t.Errorf("Expected the encryption in the summary, got:\n%s", view)
+	}
+	if !bytes.Contains(model.raw, []byte("The code is 1234.")) {
+		t.Error("Expected the decrypted source to be kept for the parts and links")
+	}
+
+	_, cmd := pressKey(model, 'r')
+	if reply := findMessage[ui.ShowEmailComposerMessage](t, cmd); reply.ReplyTo == nil || !reply.ReplyTo.Encrypted {
+		t.Errorf("Expected the reply to know the email was encrypted, got %+v", reply.ReplyTo)
+	}
+}
+
+func TestEmailViewerModel_SMIMENotDecrypted(t *testing.T) {
+	_, bob := newCertificates(t)
+	model, load := newViewer(t, launchCodesEmail(), encryptedSMIMEEmail(t, bob), Options{})
+	model = unprotectSource(t, model, load)
+
+	view := ansi.Strip(model.View())
+	if !strings.Contains(view, "Encrypted, but couldn't be decrypted: no S/MIME certificate and key are configured") {
+		t.Errorf("Expected to be told the email couldn't be decrypted, got:\n%s", view)
+	}
+}
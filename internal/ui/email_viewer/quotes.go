@@ -0,0 +1,210 @@
+package email_viewer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/muesli/reflow/wrap"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/flowed"
+)
+
+type blockKind int
+
+const (
+	textBlock blockKind = iota
+	quoteBlock
+	signatureBlock
+)
+
+// block is a run of lines in a body that are shown, coloured or folded together.
+type block struct {
+	kind  blockKind
+	lines []string
+}
+
+var (
+	// attributionPattern matches the line clients put above a quoted reply, like "On Mon, 2 Jan 2025, Bob wrote:".
+	attributionPattern = regexp.MustCompile(`^On\s.+\swrote:\s*$`)
+
+	// quoteStyles colour quoted lines by how deeply they're quoted, cycling round for deeper quotes.
+	quoteStyles = []lipgloss.Style{
+		lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#1F6FEB", Dark: "#6CB6FF"}),
+		lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#1A7F37", Dark: "#57AB5A"}),
+		lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#9A6700", Dark: "#C69026"}),
+		lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#8250DF", Dark: "#B083F0"}),
+	}
+
+	foldedStyle = lipgloss.NewStyle().
+			Faint(true).
+			Italic(true)
+
+	signatureStyle = lipgloss.NewStyle().
+			Faint(true)
+)
+
+// splitBlocks breaks a body into its own text, quoted text along with the attribution line above it, and signatures.
+// Blank lines between quoted lines are part of the quote, so a quoted email with several paragraphs is one block.
+func splitBlocks(body string) []block {
+	lines := strings.Split(body, "\n")
+	kinds := make([]blockKind, len(lines))
+
+	inSignature := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case isQuoted(line):
+			inSignature = false
+			kinds[i] = quoteBlock
+		case attributionLength(lines[i:]) > 0:
+			inSignature = false
+			for n := attributionLength(lines[i:]); n > 0; n-- {
+				kinds[i] = quoteBlock
+				i++
+			}
+			i--
+		case line == "-- " || line == "--":
+			inSignature = true
+			kinds[i] = signatureBlock
+		case inSignature:
+			kinds[i] = signatureBlock
+		default:
+			kinds[i] = textBlock
+		}
+	}
+
+	// runs of blank lines only belong to a quote or signature if it carries on after them
+	for start := 0; start < len(lines); start++ {
+		if strings.TrimSpace(lines[start]) != "" {
+			continue
+		}
+		end := start
+		for end < len(lines) && strings.TrimSpace(lines[end]) == "" {
+			end++
+		}
+		kind := textBlock
+		if start > 0 && end < len(lines) && kinds[start-1] == kinds[end] {
+			kind = kinds[end]
+		}
+		for i := start; i < end; i++ {
+			kinds[i] = kind
+		}
+		start = end
+	}
+
+	var blocks []block
+	for i, line := range lines {
+		if n := len(blocks); n > 0 && blocks[n-1].kind == kinds[i] {
+			blocks[n-1].lines = append(blocks[n-1].lines, line)
+			continue
+		}
+		blocks = append(blocks, block{kind: kinds[i], lines: []string{line}})
+	}
+	return blocks
+}
+
+func isQuoted(line string) bool {
+	return strings.HasPrefix(line, ">")
+}
+
+// attributionLength returns how many lines the attribution at the start of lines takes up, which can be wrapped onto a
+// second line, or 0 if there isn't one. It's only an attribution if a quote follows it.
+func attributionLength(lines []string) int {
+	length := 0
+	switch {
+	case attributionPattern.MatchString(lines[0]):
+		length = 1
+	case len(lines) > 1 && strings.HasPrefix(lines[0], "On ") && attributionPattern.MatchString(lines[0]+" "+lines[1]):
+		length = 2
+	default:
+		return 0
+	}
+
+	for _, line := range lines[length:] {
+		if strings.TrimSpace(line) != "" {
+			if isQuoted(line) {
+				return length
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// quoteDepth counts the ">" markers at the start of a line, including ones spaced out like "> > text".
+func quoteDepth(line string) int {
+	depth := 0
+	for _, char := range line {
+		switch char {
+		case '>':
+			depth++
+		case ' ':
+		default:
+			return depth
+		}
+	}
+	return depth
+}
+
+// renderBody wraps the body to the width, colouring quotes by depth. Unless showQuotes is set, quotes and signatures
+// are folded into a placeholder saying how many lines they hide.
+func renderBody(email *core.Email, width int, showQuotes bool) string {
+	var rendered []string
+	for _, block := range splitBlocks(email.Body) {
+		text := strings.Join(block.lines, "\n")
+		switch {
+		case block.kind == textBlock:
+			rendered = append(rendered, wrapText(text, width, email.Flowed))
+		case !showQuotes:
+			rendered = append(rendered, foldedStyle.Render(placeholder(block)))
+		case block.kind == quoteBlock:
+			rendered = append(rendered, renderQuote(text, width))
+		case block.kind == signatureBlock:
+			rendered = append(rendered, renderSignature(text, width))
+		}
+	}
+	return strings.Join(rendered, "\n")
+}
+
+func wrapText(text string, width int, isFlowed bool) string {
+	if isFlowed {
+		return flowed.Wrap(text, width)
+	}
+	return wrap.String(wordwrap.String(text, width), width)
+}
+
+// renderQuote wraps quoted lines so each keeps its quote markers, which works whether or not the body was flowed.
+func renderQuote(text string, width int) string {
+	lines := strings.Split(flowed.Wrap(text, width), "\n")
+	for i, line := range lines {
+		if depth := quoteDepth(line); depth > 0 {
+			lines[i] = quoteStyles[(depth-1)%len(quoteStyles)].Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func renderSignature(text string, width int) string {
+	lines := strings.Split(wrapText(text, width, false), "\n")
+	for i, line := range lines {
+		// styled one line at a time, since lipgloss pads blocks of lines to the same width
+		lines[i] = signatureStyle.Render(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func placeholder(block block) string {
+	noun := "quoted"
+	if block.kind == signatureBlock {
+		noun = "signature"
+	}
+	plural := "s"
+	if len(block.lines) == 1 {
+		plural = ""
+	}
+	return fmt.Sprintf("[… %d %s line%s]", len(block.lines), noun, plural)
+}
@@ -0,0 +1,97 @@
+package email_viewer
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+)
+
+const replyBody = `Sounds good, see you then.
+
+On Mon, 2 Jan 2025 at 10:00, Bob <bob@example.com>
+wrote:
+> Shall we meet on Thursday?
+>
+>> Did we ever book the room?
+> Bob
+
+-- 
+Alice
+Example Ltd`
+
+func TestSplitBlocks(t *testing.T) {
+	blocks := splitBlocks(replyBody)
+
+	kinds := []blockKind{textBlock, quoteBlock, textBlock, signatureBlock}
+	lengths := []int{2, 6, 1, 3}
+	if len(blocks) != len(kinds) {
+		t.Fatalf("Expected %d blocks, got %+v", len(kinds), blocks)
+	}
+	for i, block := range blocks {
+		if block.kind != kinds[i] || len(block.lines) != lengths[i] {
+			t.Errorf("Expected block %d to be kind %d with %d lines, got %+v", i, kinds[i], lengths[i], block)
+		}
+	}
+}
+
+func TestSplitBlocks_AttributionNeedsQuote(t *testing.T) {
+	blocks := splitBlocks("On Monday, Bob wrote:\nthat the meeting had moved")
+
+	if len(blocks) != 1 || blocks[0].kind != textBlock {
+		t.Errorf("Expected the lines to stay as text without a quote after them, got %+v", blocks)
+	}
+}
+
+func TestSplitBlocks_TopPostedSignature(t *testing.T) {
+	blocks := splitBlocks("Yes\n-- \nAlice\n\n> Are you coming?")
+
+	if len(blocks) != 4 || blocks[1].kind != signatureBlock || len(blocks[1].lines) != 2 || blocks[3].kind != quoteBlock {
+		t.Errorf("Expected the signature to end at the quote, got %+v", blocks)
+	}
+}
+
+func TestRenderBody_Folded(t *testing.T) {
+	rendered := ansi.Strip(renderBody(&core.Email{Body: replyBody}, 60, false))
+
+	want := "Sounds good, see you then.\n\n[… 6 quoted lines]\n\n[… 3 signature lines]"
+	if rendered != want {
+		t.Errorf("Expected %q, got %q", want, rendered)
+	}
+}
+
+func TestRenderBody_Unfolded(t *testing.T) {
+	rendered := ansi.Strip(renderBody(&core.Email{Body: replyBody}, 60, true))
+
+	if rendered != replyBody {
+		t.Errorf("Expected the whole body, got %q", rendered)
+	}
+}
+
+func TestQuoteDepth(t *testing.T) {
+	tests := map[string]int{"text": 0, "> one": 1, ">> two": 2, "> > two": 2, ">": 1}
+	for line, want := range tests {
+		if got := quoteDepth(line); got != want {
+			t.Errorf("Expected depth %d for %q, got %d", want, line, got)
+		}
+	}
+}
+
+func TestEmailViewerModel_KeyMsg_ToggleQuotes(t *testing.T) {
+	model := NewEmailViewerModel(&mockBackend{}, Options{})
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	model, _ = model.Update(emailLoadedMessage{email: &core.Email{Body: replyBody}})
+
+	if view := model.View(); !strings.Contains(view, "6 quoted lines") || strings.Contains(view, "Shall we meet") {
+		t.Errorf("Expected quotes to be folded at first, got:\n%s", view)
+	}
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'z'}})
+
+	if view := model.View(); strings.Contains(view, "quoted lines") || !strings.Contains(view, "Shall we meet") {
+		t.Errorf("Expected quotes to be shown after toggling, got:\n%s", view)
+	}
+}
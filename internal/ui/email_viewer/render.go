@@ -1,18 +1,26 @@
 package email_viewer
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
-	"github.com/muesli/reflow/wordwrap"
-	"github.com/muesli/reflow/wrap"
 
 	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/pgp"
+	"github.com/bengesoff/mail-tui/internal/senderauth"
+	"github.com/bengesoff/mail-tui/internal/smime"
 )
 
 var (
 	metadataHeadingStyle = lipgloss.NewStyle().
 				Bold(true)
 
+	conversationHeadingStyle = lipgloss.NewStyle().
+					Bold(true).
+					Foreground(lipgloss.AdaptiveColor{Light: "#EE6FF8", Dark: "#EE6FF8"})
+
 	bodyStyle = func(width int) lipgloss.Style {
 		return lipgloss.NewStyle().
 			Width(width).
@@ -21,7 +29,27 @@ var (
 	}
 )
 
-func RenderEmail(email *core.Email, windowWidth int) (string, error) {
+// RenderOptions changes how emails are rendered.
+type RenderOptions struct {
+	// ShowQuotes shows quoted text and signatures, rather than folding each into a placeholder.
+	ShowQuotes bool
+	// Headers replaces the summary at the top with every one of the email's header fields.
+	Headers []HeaderField
+	// InvitationStatus is shown on the invitation card, like whether it's been replied to.
+	InvitationStatus string
+	// ListStatus is shown below the mailing list the email came through, like whether it's been unsubscribed from.
+	ListStatus string
+	// Sender is what was found out about who the email is really from. The checks are listed in the summary, with a
+	// warning above it if the email looks spoofed.
+	Sender *senderauth.Report
+	// OpenPGP is how the email was protected with OpenPGP, like who signed it, which is listed in the summary.
+	OpenPGP *pgp.Result
+	// SMIME is how the email was protected with S/MIME, like whose certificate signed it, which is listed in the
+	// summary.
+	SMIME *smime.Result
+}
+
+func RenderEmail(email *core.Email, windowWidth int, options RenderOptions) (string, error) {
 	sent, err := email.SentAt.MarshalText()
 	if err != nil {
 		return "", err
@@ -33,6 +61,25 @@ func RenderEmail(email *core.Email, windowWidth int) (string, error) {
 		{"Sent", string(sent)},
 		{"Subject", email.Subject},
 	}
+	if email.List != nil {
+		rows = append(rows, []string{"List", describeList(*email.List)})
+	}
+	if options.Sender != nil {
+		rows = append(rows, []string{"Checks", describeChecks(options.Sender)})
+	}
+	if options.OpenPGP != nil {
+		rows = append(rows, []string{"OpenPGP", describeOpenPGP(options.OpenPGP)})
+	}
+	if options.SMIME != nil {
+		rows = append(rows, []string{"S/MIME", describeSMIME(options.SMIME)})
+	}
+	if keywords := email.Keywords(); len(keywords) > 0 {
+		var names []string
+		for _, keyword := range keywords {
+			names = append(names, string(keyword))
+		}
+		rows = append(rows, []string{"Keywords", strings.Join(names, ", ")})
+	}
 	metadata := table.New().
 		StyleFunc(func(row, col int) lipgloss.Style {
 			if col == 0 {
@@ -41,15 +88,56 @@ func RenderEmail(email *core.Email, windowWidth int) (string, error) {
 			return lipgloss.NewStyle()
 		}).
 		Rows(rows...)
-	output := metadata.Render() + "\n"
+	output := renderSenderWarnings(options.Sender) + metadata.Render() + "\n"
+	if options.Headers != nil {
+		output = renderSenderWarnings(options.Sender) + renderHeaders(options.Headers, windowWidth) + "\n"
+	}
+	if options.ListStatus != "" {
+		output += options.ListStatus + "\n"
+	}
 
-	output += bodyStyle(windowWidth - 2).Render(
-		wrap.String(
-			wordwrap.String(
-				email.Body,
-				windowWidth-4),
-			windowWidth-4),
-	)
+	output += renderInvitation(email, windowWidth-2, options.InvitationStatus)
+	output += bodyStyle(windowWidth - 2).Render(renderBody(email, windowWidth-4, options.ShowQuotes))
 
 	return output, nil
 }
+
+// describeList names a mailing list along with what can be done with it.
+func describeList(list core.MailingList) string {
+	description := list.Label()
+	if list.Name != "" && list.Id != "" {
+		description += " <" + list.Id + ">"
+	}
+	var actions []string
+	if list.Post != "" {
+		actions = append(actions, "L: reply to list")
+	}
+	if len(list.Unsubscribe) > 0 {
+		actions = append(actions, "U: unsubscribe")
+	}
+	if len(actions) > 0 {
+		description += " " + urlStyle.Render("("+strings.Join(actions, " • ")+")")
+	}
+	return description
+}
+
+// RenderConversation stacks the emails in a thread, each rendered as it would be on its own with its options, so
+// every email's sender is checked.
+func RenderConversation(emails []*core.Email, windowWidth int, options func(email *core.Email) RenderOptions) (string, error) {
+	var rendered []string
+	for i, email := range emails {
+		emailOptions := options(email)
+		if i < len(emails)-1 {
+			// replies are to the latest email's invitation
+			emailOptions.InvitationStatus = ""
+			emailOptions.ListStatus = ""
+		}
+		output, err := RenderEmail(email, windowWidth, emailOptions)
+		if err != nil {
+			return "", err
+		}
+		heading := conversationHeadingStyle.Render(fmt.Sprintf("Message %d of %d", i+1, len(emails)))
+		rendered = append(rendered, heading+"\n"+output)
+	}
+	return strings.Join(rendered, "\n\n"), nil
+}
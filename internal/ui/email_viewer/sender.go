@@ -0,0 +1,94 @@
+package email_viewer
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/senderauth"
+)
+
+// senderCheckTimeout is how long looking up the keys for DKIM signatures can take.
+const senderCheckTimeout = 10 * time.Second
+
+var impersonationBadgeStyle = lipgloss.NewStyle().
+	Bold(true).
+	Padding(0, 1).
+	Foreground(lipgloss.Color("#FFFFFF")).
+	Background(lipgloss.AdaptiveColor{Light: "#D70000", Dark: "#AF0000"})
+
+type senderCheckedMessage struct {
+	id     core.EmailId
+	report *senderauth.Report
+	error  error
+}
+
+// checkSender looks at the source of the email for signs it isn't from who it says it is.
+func (m *EmailViewerModel) checkSender(emailId core.EmailId, raw []byte) tea.Cmd {
+	options := m.options.SenderAuth
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), senderCheckTimeout)
+		defer cancel()
+		report, err := senderauth.Check(ctx, raw, options)
+		if err != nil {
+			return senderCheckedMessage{id: emailId, error: err}
+		}
+		return senderCheckedMessage{id: emailId, report: &report}
+	}
+}
+
+func (m *EmailViewerModel) handleSenderChecked(msg senderCheckedMessage) {
+	if m.email == nil || (msg.id != m.email.Id && !m.inConversation(msg.id)) {
+		return
+	}
+	if msg.error != nil {
+		// the email is still worth reading, but it's said that the sender couldn't be checked rather than nothing
+		msg.report = &senderauth.Report{}
+	}
+	m.senders[msg.id] = msg.report
+	if err := m.updateViewportContent(); err != nil {
+		m.error = err.Error()
+	}
+}
+
+// renderSenderWarnings renders a badge with everything that looks spoofed about the email, or nothing if it looks fine.
+func renderSenderWarnings(report *senderauth.Report) string {
+	if report == nil || len(report.Warnings) == 0 {
+		return ""
+	}
+	lines := []string{impersonationBadgeStyle.Render("⚠ This email may not be from who it says it is")}
+	for _, warning := range report.Warnings {
+		lines = append(lines, warningStyle.Render("• "+warning))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// describeChecks summarises the server's checks and the signatures verified locally, like
+// "SPF pass • DKIM pass • DMARC pass".
+func describeChecks(report *senderauth.Report) string {
+	if !report.Checked {
+		return warningStyle.Render("Not checked")
+	}
+	var checks []string
+	for _, result := range report.Results {
+		check := strings.ToUpper(result.Method) + " " + result.Value
+		if !result.Passed() {
+			check = warningStyle.Render(check)
+		}
+		checks = append(checks, check)
+	}
+	for _, signature := range report.Signatures {
+		if signature.Valid() {
+			checks = append(checks, "Signed by "+signature.Domain)
+		} else if signature.Domain != "" {
+			checks = append(checks, warningStyle.Render("Bad signature from "+signature.Domain+": "+signature.Err.Error()))
+		} else {
+			checks = append(checks, warningStyle.Render("Bad signature: "+signature.Err.Error()))
+		}
+	}
+	return strings.Join(checks, " • ")
+}
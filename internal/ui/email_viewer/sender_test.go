@@ -0,0 +1,92 @@
+package email_viewer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/senderauth"
+)
+
+func wireTransferEmail() *core.Email {
+	return &core.Email{EmailMetadata: core.EmailMetadata{Id: "1", From: "ceo@example.com", Subject: "Wire transfer"}, Body: "Please pay"}
+}
+
+func TestEmailViewerModel_SenderChecks(t *testing.T) {
+	raw := "Authentication-Results: mx.example.net; spf=pass smtp.mailfrom=example.com; dkim=fail header.d=example.com\r\n" +
+		"From: ceo@example.com\r\n" +
+		"\r\n" +
+		"Please pay\r\n"
+	model, cmd := newViewer(t, wireTransferEmail(), []byte(raw), Options{})
+	model, cmd = loadSource(t, model, cmd)
+	model, _ = model.Update(findMessage[senderCheckedMessage](t, cmd))
+
+	view := ansi.Strip(model.View())
+	if !strings.Contains(view, "│Checks │SPF pass • DKIM fail│") {
+		t.Errorf("Expected the checks in the summary, got:\n%s", view)
+	}
+	if strings.Contains(view, "may not be from who it says it is") {
+		t.Errorf("Expected no warning, got:\n%s", view)
+	}
+	if model.raw == nil {
+		t.Error("Expected the source to be kept")
+	}
+}
+
+func TestEmailViewerModel_SpoofWarning(t *testing.T) {
+	raw := "Authentication-Results: mx.example.net; dmarc=fail header.from=example.com\r\n" +
+		`From: "ceo@example.com" <ceo@attacker.example>` + "\r\n" +
+		"\r\n" +
+		"Please pay\r\n"
+	model, cmd := newViewer(t, wireTransferEmail(), []byte(raw), Options{})
+	model, cmd = loadSource(t, model, cmd)
+	model, _ = model.Update(findMessage[senderCheckedMessage](t, cmd))
+
+	view := ansi.Strip(model.View())
+	for _, want := range []string{
+		"⚠ This email may not be from who it says it is",
+		"• The sender's name shows ceo@example.com, but it's really from ceo@attacker.example",
+		"• attacker.example failed DMARC",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected the view to contain %q, got:\n%s", want, view)
+		}
+	}
+	if badge := strings.Index(view, "⚠"); badge > strings.Index(view, "Subject") {
+		t.Errorf("Expected the warning above the summary, got:\n%s", view)
+	}
+}
+
+func TestEmailViewerModel_ConversationSendersChecked(t *testing.T) {
+	model := NewEmailViewerModel(&mockBackend{}, Options{})
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 120, Height: 80})
+	first := &core.Email{EmailMetadata: core.EmailMetadata{Id: "1", From: "ceo@example.com", Flags: []core.Flag{core.FlagSeen}}, Body: "Please pay"}
+	second := &core.Email{EmailMetadata: core.EmailMetadata{Id: "2", From: "bob@example.com", Flags: []core.Flag{core.FlagSeen}}, Body: "Paid"}
+	model, _ = model.Update(conversationLoadedMessage{emails: []*core.Email{first, second}})
+
+	spoofed := "Authentication-Results: mx.example.net; dmarc=fail header.from=example.com\r\n" +
+		`From: "ceo@example.com" <ceo@attacker.example>` + "\r\n\r\nPlease pay\r\n"
+	model, cmd := model.Update(rawEmailLoadedMessage{id: "1", raw: []byte(spoofed)})
+	model, _ = model.Update(findMessage[senderCheckedMessage](t, cmd))
+	model, _ = model.Update(rawEmailLoadedMessage{id: "2", error: errors.New("connection lost")})
+
+	view := ansi.Strip(model.View())
+	if badge, reply := strings.Index(view, "⚠ This email may not be from who it says it is"), strings.Index(view, "Message 2 of 2"); badge == -1 || badge > reply {
+		t.Errorf("Expected a warning on the first email, got:\n%s", view)
+	}
+	if checks := strings.Count(view, "Not checked"); checks != 1 {
+		t.Errorf("Expected the email whose source didn't load to be shown as not checked, got:\n%s", view)
+	}
+}
+
+func TestEmailViewerModel_SenderCheckForPreviousEmailIsIgnored(t *testing.T) {
+	model, _ := newViewer(t, cafeEmail(), nil, Options{})
+	model, _ = model.Update(senderCheckedMessage{id: "2", report: &senderauth.Report{Checked: true}})
+	if model.senders["2"] != nil {
+		t.Error("Expected the check for another email to be ignored")
+	}
+}
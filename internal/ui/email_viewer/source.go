@@ -0,0 +1,209 @@
+package email_viewer
+
+import (
+	"bufio"
+	"bytes"
+	"mime"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/textproto"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/muesli/reflow/wrap"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/links"
+	"github.com/bengesoff/mail-tui/internal/senderauth"
+)
+
+type viewMode int
+
+const (
+	bodyMode viewMode = iota
+	// headersMode shows every header of the email above its body
+	headersMode
+	// sourceMode shows the email exactly as it was received
+	sourceMode
+	// partMode shows what a mailcap command printed for one of the email's parts
+	partMode
+)
+
+type rawEmailLoadedMessage struct {
+	id    core.EmailId
+	raw   []byte
+	error error
+}
+
+// HeaderField is a header of an email, decoded to be read.
+type HeaderField struct {
+	Key   string
+	Value string
+}
+
+var (
+	foldPattern = regexp.MustCompile(`\r?\n[ \t]+`)
+
+	wordDecoder = &mime.WordDecoder{CharsetReader: charset.Reader}
+)
+
+// toggleMode switches to showing the headers or source of the current email, or back to the body if it's already
+// showing them. The source is only fetched the first time it's needed.
+func (m *EmailViewerModel) toggleMode(mode viewMode) tea.Cmd {
+	if m.email == nil {
+		return nil
+	}
+	if m.mode == mode {
+		mode = bodyMode
+	}
+	m.mode = mode
+
+	var command tea.Cmd
+	if mode != bodyMode && m.raw == nil && !m.loadingRaw {
+		m.loadingRaw = true
+		command = m.loadRawEmail(m.email.Id)
+	}
+	if err := m.updateViewportContent(); err != nil {
+		m.error = err.Error()
+		return nil
+	}
+	m.viewport.GotoTop()
+	return command
+}
+
+// handleRawEmailLoaded keeps the current email's source and starts everything that reads it: checking the sender,
+// and verifying or decrypting it, which then decodes its images. The other emails in a conversation only have their
+// senders checked and are decrypted.
+func (m *EmailViewerModel) handleRawEmailLoaded(msg rawEmailLoadedMessage) tea.Cmd {
+	if m.email == nil {
+		return nil
+	}
+	if msg.id != m.email.Id {
+		// the viewer may have moved on to another email while this one was loading
+		if !m.inConversation(msg.id) {
+			return nil
+		}
+		if msg.error != nil {
+			// an empty report shows the sender as not checked
+			m.senders[msg.id] = &senderauth.Report{}
+			if err := m.updateViewportContent(); err != nil {
+				m.error = err.Error()
+			}
+			return nil
+		}
+		return tea.Batch(m.checkSender(msg.id, msg.raw), m.unprotect(msg.id, msg.raw))
+	}
+	m.loadingRaw = false
+	if msg.error != nil {
+		m.senders[msg.id] = &senderauth.Report{}
+		switch {
+		case m.pickingPart:
+			m.partStatus = "Failed to load the email's parts: " + msg.error.Error()
+		case m.pickingLink:
+			m.links = links.FromText(m.email.Body)
+			m.linkStatus = "Only showing the links in the text of the email: " + msg.error.Error()
+		case m.mode != bodyMode:
+			m.mode = bodyMode
+			m.error = "error loading email source: " + msg.error.Error()
+		default:
+			if err := m.updateViewportContent(); err != nil {
+				m.error = err.Error()
+			}
+		}
+		return nil
+	}
+	m.raw = msg.raw
+	if m.pickingLink {
+		m.findLinks()
+	}
+	if m.pickingPart {
+		m.findParts()
+	}
+	if err := m.updateViewportContent(); err != nil {
+		m.error = err.Error()
+	}
+	return tea.Batch(m.checkSender(msg.id, msg.raw), m.unprotect(msg.id, msg.raw))
+}
+
+// inConversation reports whether an email is one of the conversation being viewed.
+func (m *EmailViewerModel) inConversation(emailId core.EmailId) bool {
+	for _, email := range m.conversation {
+		if email.Id == emailId {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *EmailViewerModel) loadRawEmail(emailId core.EmailId) tea.Cmd {
+	return func() tea.Msg {
+		raw, err := m.backend.GetRawEmail(emailId)
+		return rawEmailLoadedMessage{
+			id:    emailId,
+			raw:   raw,
+			error: err,
+		}
+	}
+}
+
+// renderRaw renders the current email for the headers, source and part modes.
+func (m *EmailViewerModel) renderRaw() (string, error) {
+	if m.mode == partMode {
+		return m.renderPartOutput(), nil
+	}
+	if m.raw == nil {
+		return "Loading email source...", nil
+	}
+	if m.mode == sourceMode {
+		return renderSource(m.raw, m.viewport.Width), nil
+	}
+
+	headers, err := parseHeaders(m.raw)
+	if err != nil {
+		return "", err
+	}
+	options := m.renderOptions(m.email)
+	options.Headers = headers
+	return RenderEmail(m.email, m.viewport.Width, options)
+}
+
+// parseHeaders reads every header field at the top of an email's source, in order, with folded lines joined and
+// encoded words decoded.
+func parseHeaders(raw []byte) ([]HeaderField, error) {
+	header, err := textproto.ReadHeader(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []HeaderField
+	for field := header.Fields(); field.Next(); {
+		value := foldPattern.ReplaceAllString(field.Value(), " ")
+		if decoded, err := wordDecoder.DecodeHeader(value); err == nil {
+			value = decoded
+		}
+		fields = append(fields, HeaderField{Key: field.Key(), Value: value})
+	}
+	return fields, nil
+}
+
+// renderHeaders lists header fields one after another, with long values wrapped onto indented lines.
+func renderHeaders(fields []HeaderField, width int) string {
+	var lines []string
+	for _, field := range fields {
+		wrapped := wrap.String(wordwrap.String(field.Key+": "+field.Value, width-2), width-2)
+		key, rest, _ := strings.Cut(wrapped, ":")
+		for i, line := range strings.Split(metadataHeadingStyle.Render(key+":")+rest, "\n") {
+			if i > 0 {
+				line = "  " + line
+			}
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderSource shows the source of an email as it is, only wrapping lines that are too long to fit.
+func renderSource(raw []byte, width int) string {
+	return wrap.String(strings.ReplaceAll(string(raw), "\r\n", "\n"), width)
+}
@@ -0,0 +1,163 @@
+package email_viewer
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/termimage"
+)
+
+const rawEmail = "Received: from mail.example.com\r\n" +
+	"\tby mx.example.com; Thu, 2 Jan 2025 10:00:00 +0000\r\n" +
+	"Authentication-Results: mx.example.com; dkim=pass\r\n" +
+	"From: Bob <bob@example.com>\r\n" +
+	"Subject: =?utf-8?q?Caf=C3=A9_plans?=\r\n" +
+	"Message-ID: <1@example.com>\r\n" +
+	"\r\n" +
+	"Shall we meet at the café?\r\n"
+
+func TestParseHeaders(t *testing.T) {
+	fields, err := parseHeaders([]byte(rawEmail))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []HeaderField{
+		{"Received", "from mail.example.com by mx.example.com; Thu, 2 Jan 2025 10:00:00 +0000"},
+		{"Authentication-Results", "mx.example.com; dkim=pass"},
+		{"From", "Bob <bob@example.com>"},
+		{"Subject", "Café plans"},
+		{"Message-Id", "<1@example.com>"},
+	}
+	if len(fields) != len(want) {
+		t.Fatalf("Expected %d fields, got %+v", len(want), fields)
+	}
+	for i := range want {
+		if fields[i] != want[i] {
+			t.Errorf("Expected field %d to be %+v, got %+v", i, want[i], fields[i])
+		}
+	}
+}
+
+func TestRenderHeaders_WrapsLongValues(t *testing.T) {
+	rendered := ansi.Strip(renderHeaders([]HeaderField{{"Received", "from mail.example.com by mx.example.com with ESMTPS"}}, 30))
+
+	lines := strings.Split(rendered, "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "Received: ") || !strings.HasPrefix(lines[1], "  ") {
+		t.Errorf("Expected the value to wrap onto indented lines, got %q", rendered)
+	}
+}
+
+func cafeEmail() *core.Email {
+	return &core.Email{EmailMetadata: core.EmailMetadata{Id: "1", Subject: "Café plans"}, Body: "Shall we meet at the café?"}
+}
+
+func pressKey(model *EmailViewerModel, key rune) (*EmailViewerModel, tea.Cmd) {
+	return model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{key}})
+}
+
+func TestEmailViewerModel_KeyMsg_FullHeaders(t *testing.T) {
+	model, load := newViewer(t, cafeEmail(), []byte(rawEmail), Options{})
+
+	model, _ = pressKey(model, 'H')
+	if view := model.View(); !strings.Contains(view, "Loading email source") {
+		t.Errorf("Expected the source to be loading, got:\n%s", view)
+	}
+	model, _ = loadSource(t, model, load)
+
+	view := ansi.Strip(model.View())
+	if !strings.Contains(view, "Authentication-Results: mx.example.com") || !strings.Contains(view, "Shall we meet") {
+		t.Errorf("Expected every header above the body, got:\n%s", view)
+	}
+
+	model, _ = pressKey(model, 'H')
+	if view := ansi.Strip(model.View()); strings.Contains(view, "Authentication-Results") {
+		t.Errorf("Expected the headers to be hidden again, got:\n%s", view)
+	}
+
+	model, _ = pressKey(model, 'V')
+	if model.loadingRaw || !strings.Contains(model.View(), "Message-ID: <1@example.com>") {
+		t.Error("Expected the source to be shown without fetching it again")
+	}
+}
+
+func TestEmailViewerModel_KeyMsg_Source(t *testing.T) {
+	model, load := newViewer(t, cafeEmail(), []byte(rawEmail), Options{})
+
+	model, _ = pressKey(model, 'V')
+	model, _ = loadSource(t, model, load)
+
+	view := model.View()
+	if !strings.Contains(view, "=?utf-8?q?Caf=C3=A9_plans?=") || !strings.Contains(view, "\n    by mx.example.com") {
+		t.Errorf("Expected the source as it was received, got:\n%s", view)
+	}
+}
+
+func TestEmailViewerModel_RawEmailLoadedMessage_Stale(t *testing.T) {
+	model, _ := newViewer(t, cafeEmail(), nil, Options{})
+	model, _ = pressKey(model, 'V')
+
+	model, _ = model.Update(rawEmailLoadedMessage{id: "2", raw: []byte(rawEmail)})
+
+	if model.raw != nil {
+		t.Error("Expected the source of another email to be ignored")
+	}
+}
+
+func TestEmailViewerModel_SourceFetchedOnce(t *testing.T) {
+	email := &core.Email{EmailMetadata: core.EmailMetadata{Id: "1"}, Body: "Here it is"}
+	model, cmd := newViewer(t, email, emailWithImage(t), Options{Images: termimage.HalfBlock})
+	backend := model.backend.(*mockBackend)
+
+	model, cmd = loadSource(t, model, cmd)
+	model, _ = model.Update(findMessage[senderCheckedMessage](t, cmd))
+	model, cmd = model.Update(findMessage[unprotectedMessage](t, cmd))
+	model, _ = model.Update(findMessage[imagesDecodedMessage](t, cmd))
+	for _, key := range []rune{'H', 'V', 'V'} {
+		if model, cmd = pressKey(model, key); cmd != nil {
+			cmd()
+		}
+	}
+
+	if backend.rawFetches != 1 {
+		t.Errorf("Expected the source to be fetched once, got %d times", backend.rawFetches)
+	}
+	if model.senders["1"] == nil || len(model.images) != 1 {
+		t.Errorf("Expected the sender to be checked and the image decoded from the same source")
+	}
+}
+
+func TestEmailViewerModel_ConversationSourcesFetchedOnce(t *testing.T) {
+	backend := &mockBackend{raw: []byte(rawEmail)}
+	model := NewEmailViewerModel(backend, Options{})
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	first := &core.Email{EmailMetadata: core.EmailMetadata{Id: "1", Flags: []core.Flag{core.FlagSeen}}, Body: "Lunch?"}
+	second := &core.Email{EmailMetadata: core.EmailMetadata{Id: "2", Flags: []core.Flag{core.FlagSeen}}, Body: "Sure"}
+
+	model, cmd := model.Update(conversationLoadedMessage{emails: []*core.Email{first, second}})
+	batch, ok := cmd().(tea.BatchMsg)
+	if !ok {
+		t.Fatal("Expected a batch of commands")
+	}
+	for _, cmd := range batch {
+		msg, ok := cmd().(rawEmailLoadedMessage)
+		if !ok {
+			continue
+		}
+		var next tea.Cmd
+		model, next = model.Update(msg)
+		findMessage[senderCheckedMessage](t, next)
+		findMessage[unprotectedMessage](t, next)
+	}
+
+	if backend.rawFetches != 2 {
+		t.Errorf("Expected each email's source to be fetched once, got %d fetches", backend.rawFetches)
+	}
+	if model.raw == nil || model.loadingRaw {
+		t.Error("Expected the latest email's source to be kept")
+	}
+}
@@ -0,0 +1,15 @@
+package ui
+
+import "fmt"
+
+// FormatSize formats a number of bytes like "12 KB" or "3.4 MB".
+func FormatSize(bytes int) string {
+	switch {
+	case bytes >= 1000*1000:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1000*1000))
+	case bytes >= 1000:
+		return fmt.Sprintf("%d KB", bytes/1000)
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
@@ -0,0 +1,16 @@
+package ui
+
+import "testing"
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int]string{
+		512:       "512 B",
+		12_345:    "12 KB",
+		3_400_000: "3.4 MB",
+	}
+	for bytes, want := range tests {
+		if got := FormatSize(bytes); got != want {
+			t.Errorf("FormatSize(%d) = %s, want %s", bytes, got, want)
+		}
+	}
+}
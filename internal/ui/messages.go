@@ -1,11 +1,56 @@
 package ui
 
-import "github.com/bengesoff/mail-tui/internal/core"
+import (
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/outbox"
+	"github.com/bengesoff/mail-tui/internal/undo"
+)
 
-type ShowEmailListMessage struct{}
+type ShowEmailListMessage struct {
+	// Status is shown in the status bar once the list is showing, if it's set.
+	Status string
+}
 
 type ShowEmailViewerMessage struct {
 	EmailId core.EmailId
+	// Conversation holds the ids of every email in the thread, oldest first, when the whole conversation should be shown.
+	Conversation []core.EmailId
+}
+
+type ShowEmailComposerMessage struct {
+	// ReplyTo is the email being replied to, if any.
+	ReplyTo *core.Email
+	// ReplyToList sends the reply to the mailing list ReplyTo came through, rather than its sender.
+	ReplyToList bool
+	// Draft is a saved draft to carry on writing, if any.
+	Draft *core.Draft
+	// Queued is an email from the outbox that failed to send, to edit before sending it again, if any.
+	Queued *outbox.Entry
+	// Compose is an email to start from, such as the one a mailto: link asks for, if any.
+	Compose *core.OutgoingEmail
+}
+
+type ShowDraftListMessage struct{}
+
+type ShowOutboxMessage struct{}
+
+type ShowContactListMessage struct{}
+
+// OutboxEventMessage reports that the outbox worker has sent an email, or failed to.
+type OutboxEventMessage struct {
+	Event outbox.Event
+}
+
+// RecordUndoMessage adds an operation to the undo stack once it has succeeded.
+type RecordUndoMessage struct {
+	Operation undo.Operation
 }
 
-type ShowEmailComposerMessage struct{}
+// UndoMessage asks for the most recent operation to be undone.
+type UndoMessage struct{}
+
+// UndoneMessage reports the result of undoing an operation.
+type UndoneMessage struct {
+	Operation undo.Operation
+	Error     error
+}
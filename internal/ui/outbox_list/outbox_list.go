@@ -0,0 +1,207 @@
+package outbox_list
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/outbox"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+type entriesLoadedMessage struct {
+	entries []outbox.Entry
+	error   error
+}
+
+type entryChangedMessage struct {
+	// status describes the change once it has been made
+	status string
+	error  error
+}
+
+// OutboxListModel lists the emails waiting to be sent, so ones that failed can be edited, resent or deleted.
+type OutboxListModel struct {
+	outbox *outbox.Outbox
+
+	loading bool
+	error   string
+
+	list list.Model
+}
+
+type entryItem struct {
+	outbox.Entry
+}
+
+func (i entryItem) Title() string {
+	subject := i.Email.Subject
+	if subject == "" {
+		subject = "(no subject)"
+	}
+	if i.Status == outbox.StatusFailed {
+		return "✗ " + subject
+	}
+	return subject
+}
+
+func (i entryItem) Description() string {
+	switch {
+	case i.Status == outbox.StatusFailed:
+		return fmt.Sprintf("To: %s • failed: %s", i.Email.To, i.LastError)
+	case i.Status == outbox.StatusSending:
+		return fmt.Sprintf("To: %s • sending", i.Email.To)
+	case i.Attempts > 0:
+		return fmt.Sprintf("To: %s • retrying at %s: %s", i.Email.To, i.NextAttempt.Format("15:04"), i.LastError)
+	default:
+		return fmt.Sprintf("To: %s • sending at %s", i.Email.To, i.SendAt.Format("Mon, 2 Jan 15:04"))
+	}
+}
+
+func (i entryItem) FilterValue() string {
+	return i.Email.Subject + " " + i.Email.To
+}
+
+func NewOutboxListModel(outbox *outbox.Outbox) *OutboxListModel {
+	outboxList := list.New([]list.Item{}, list.NewDefaultDelegate(), 0, 0)
+	outboxList.Title = "Outbox"
+	outboxList.SetStatusBarItemName("email", "emails")
+	outboxList.StatusMessageLifetime = 3 * time.Second
+	outboxList.DisableQuitKeybindings()
+	outboxList.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{
+			key.NewBinding(key.WithKeys("enter", "e"), key.WithHelp("enter", "edit failed email")),
+			key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "resend")),
+			key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "delete")),
+			key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back")),
+		}
+	}
+	// d is used for deleting emails rather than paging
+	outboxList.KeyMap.NextPage.SetKeys("right", "l", "pgdown", "f")
+
+	return &OutboxListModel{
+		outbox: outbox,
+		list:   outboxList,
+	}
+}
+
+func (m *OutboxListModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *OutboxListModel) Update(msg tea.Msg) (*OutboxListModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ui.ShowOutboxMessage:
+		m.loading = true
+		m.error = ""
+		return m, m.loadEntries()
+	case ui.OutboxEventMessage:
+		// the worker has sent or given up on an email, so the list is out of date
+		return m, m.loadEntries()
+	case entriesLoadedMessage:
+		m.loading = false
+		if msg.error != nil {
+			m.error = msg.error.Error()
+			return m, nil
+		}
+		items := make([]list.Item, 0, len(msg.entries))
+		for _, entry := range msg.entries {
+			items = append(items, entryItem{entry})
+		}
+		return m, m.list.SetItems(items)
+	case entryChangedMessage:
+		if msg.error != nil {
+			return m, m.list.NewStatusMessage("Failed: " + msg.error.Error())
+		}
+		return m, tea.Batch(m.list.NewStatusMessage(msg.status), m.loadEntries())
+	case tea.WindowSizeMsg:
+		m.list.SetSize(msg.Width, msg.Height)
+	case tea.KeyMsg:
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+		switch msg.String() {
+		case "esc":
+			if m.list.FilterState() == list.Unfiltered {
+				return m, showEmailList
+			}
+		case "q":
+			return m, showEmailList
+		case "enter", "e":
+			selectedItem, ok := m.list.SelectedItem().(entryItem)
+			if !ok {
+				return m, nil
+			}
+			// queued emails could be sent while they're being edited
+			if selectedItem.Status != outbox.StatusFailed {
+				return m, m.list.NewStatusMessage("Only emails that failed to send can be edited")
+			}
+			return m, func() tea.Msg {
+				return ui.ShowEmailComposerMessage{Queued: &selectedItem.Entry}
+			}
+		case "r":
+			selectedItem, ok := m.list.SelectedItem().(entryItem)
+			if !ok {
+				return m, nil
+			}
+			return m, m.resend(selectedItem.Id)
+		case "d":
+			selectedItem, ok := m.list.SelectedItem().(entryItem)
+			if !ok {
+				return m, nil
+			}
+			return m, m.remove(selectedItem.Id)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m *OutboxListModel) View() string {
+	if m.loading {
+		return "Loading outbox..."
+	}
+
+	if m.error != "" {
+		return "Error loading outbox: " + m.error
+	}
+
+	return m.list.View()
+}
+
+func (m *OutboxListModel) loadEntries() tea.Cmd {
+	return func() tea.Msg {
+		entries, err := m.outbox.List()
+		return entriesLoadedMessage{
+			entries: entries,
+			error:   err,
+		}
+	}
+}
+
+func (m *OutboxListModel) resend(id string) tea.Cmd {
+	return func() tea.Msg {
+		return entryChangedMessage{
+			status: "Sending again",
+			error:  m.outbox.Resend(id),
+		}
+	}
+}
+
+func (m *OutboxListModel) remove(id string) tea.Cmd {
+	return func() tea.Msg {
+		return entryChangedMessage{
+			status: "Deleted email",
+			error:  m.outbox.Remove(id),
+		}
+	}
+}
+
+func showEmailList() tea.Msg {
+	return ui.ShowEmailListMessage{}
+}
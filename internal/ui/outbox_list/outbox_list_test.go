@@ -0,0 +1,75 @@
+package outbox_list
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/outbox"
+	"github.com/bengesoff/mail-tui/internal/ui"
+)
+
+func loadedModel(t *testing.T) (*OutboxListModel, *outbox.Outbox, *outbox.Entry) {
+	queue, err := outbox.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	entry, _ := queue.Enqueue(core.OutgoingEmail{Subject: "Plans"}, "", time.Time{})
+
+	model := NewOutboxListModel(queue)
+	model.list.StatusMessageLifetime = time.Millisecond
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	model, cmd := model.Update(ui.ShowOutboxMessage{})
+	model, _ = model.Update(cmd())
+	return model, queue, entry
+}
+
+func TestOutboxListModel_OnlyFailedEmailsCanBeEdited(t *testing.T) {
+	model, queue, entry := loadedModel(t)
+
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if _, ok := cmd().(ui.ShowEmailComposerMessage); ok {
+		t.Fatal("Expected a queued email not to be opened, since it could be sent while it's edited")
+	}
+
+	entry.Status = outbox.StatusFailed
+	_ = queue.Update(*entry)
+	model, cmd = model.Update(ui.OutboxEventMessage{})
+	model, _ = model.Update(cmd())
+
+	_, cmd = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	msg, ok := cmd().(ui.ShowEmailComposerMessage)
+	if !ok || msg.Queued == nil || msg.Queued.Id != entry.Id {
+		t.Errorf("Expected the failed email to open in the composer, got %+v", msg)
+	}
+}
+
+func TestOutboxListModel_ResendQueuesFailedEmail(t *testing.T) {
+	model, queue, entry := loadedModel(t)
+	entry.Status = outbox.StatusFailed
+	_ = queue.Update(*entry)
+
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	if msg, ok := cmd().(entryChangedMessage); !ok || msg.error != nil {
+		t.Fatalf("Expected the email to be resent, got %+v", msg)
+	}
+
+	resent, _ := queue.Get(entry.Id)
+	if resent.Status != outbox.StatusQueued {
+		t.Errorf("Expected the email to be queued again, got %s", resent.Status)
+	}
+}
+
+func TestOutboxListModel_DeleteRemovesEmail(t *testing.T) {
+	model, queue, _ := loadedModel(t)
+
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	cmd()
+
+	entries, _ := queue.List()
+	if len(entries) != 0 {
+		t.Errorf("Expected the email to be deleted, got %d entries", len(entries))
+	}
+}
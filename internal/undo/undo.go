@@ -0,0 +1,132 @@
+// Package undo records changes made to emails, and knows how to reverse each of them.
+package undo
+
+import (
+	"errors"
+	"maps"
+	"slices"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/outbox"
+)
+
+// limit is how many operations are remembered, after which the oldest ones are forgotten.
+const limit = 100
+
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// Operation is a change to some emails that can be reversed.
+type Operation interface {
+	// Describe summarises the operation for the status bar, e.g. "Moved 12 emails to Work".
+	Describe() string
+	// Undo runs the inverse operation against the backend.
+	Undo(backend core.EmailBackend) error
+}
+
+// FlagChange records a flag being added to or removed from some emails.
+type FlagChange struct {
+	Description string
+	Flag        core.Flag
+	// Previous records whether each email had the flag before the change
+	Previous map[core.EmailId]bool
+}
+
+func (c FlagChange) Describe() string {
+	return c.Description
+}
+
+// Undo puts back the flag on the emails that had it, and removes it from the ones that didn't.
+func (c FlagChange) Undo(backend core.EmailBackend) error {
+	var add, remove []core.EmailId
+	for _, id := range slices.Sorted(maps.Keys(c.Previous)) {
+		if c.Previous[id] {
+			add = append(add, id)
+		} else {
+			remove = append(remove, id)
+		}
+	}
+
+	var errs []error
+	if len(add) > 0 {
+		errs = append(errs, backend.AddFlags(add, c.Flag))
+	}
+	if len(remove) > 0 {
+		errs = append(errs, backend.RemoveFlags(remove, c.Flag))
+	}
+	return errors.Join(errs...)
+}
+
+// Move records emails being moved out of the inbox, which includes archiving them and moving them to the trash.
+type Move struct {
+	Description string
+	Result      *core.MoveResult
+}
+
+// CanUndoMove reports whether emails can be moved back to the inbox, which needs to know where they went and what
+// their new ids are.
+// Emails that were deleted permanently can't be restored, and neither can emails that were moved by an IMAP server
+// that doesn't report their new UIDs.
+func CanUndoMove(result *core.MoveResult) bool {
+	return result != nil && result.Mailbox != "" && len(result.Ids) > 0
+}
+
+func (m Move) Describe() string {
+	return m.Description
+}
+
+// Undo moves the emails back to the inbox using their ids in the mailbox they were moved to.
+func (m Move) Undo(backend core.EmailBackend) error {
+	ids := slices.Sorted(maps.Values(m.Result.Ids))
+	_, err := backend.RestoreEmails(m.Result.Mailbox, ids)
+	return err
+}
+
+// Send records an email being added to the outbox, which can be cancelled until the worker starts sending it.
+type Send struct {
+	Description string
+	Outbox      *outbox.Outbox
+	Id          string
+}
+
+func (s Send) Describe() string {
+	return s.Description
+}
+
+// Undo takes the email out of the outbox and saves it as a draft, so it can be changed and sent again.
+func (s Send) Undo(backend core.EmailBackend) error {
+	entry, err := s.Outbox.Cancel(s.Id)
+	if errors.Is(err, outbox.ErrNotFound) {
+		return errors.New("the email has already been sent")
+	}
+	if err != nil {
+		return err
+	}
+	_, err = backend.SaveDraft(core.Draft{OutgoingEmail: entry.Email})
+	return err
+}
+
+// Stack holds the operations that can be undone, with the most recent last.
+type Stack struct {
+	operations []Operation
+}
+
+func (s *Stack) Push(operation Operation) {
+	s.operations = append(s.operations, operation)
+	if len(s.operations) > limit {
+		s.operations = slices.Delete(s.operations, 0, len(s.operations)-limit)
+	}
+}
+
+// Pop removes the most recent operation, returning false if there isn't one.
+func (s *Stack) Pop() (Operation, bool) {
+	if len(s.operations) == 0 {
+		return nil, false
+	}
+	operation := s.operations[len(s.operations)-1]
+	s.operations = s.operations[:len(s.operations)-1]
+	return operation, true
+}
+
+func (s *Stack) Len() int {
+	return len(s.operations)
+}
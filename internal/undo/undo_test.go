@@ -0,0 +1,145 @@
+package undo
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bengesoff/mail-tui/internal/core"
+	"github.com/bengesoff/mail-tui/internal/outbox"
+)
+
+// recordingBackend records the calls used to undo operations. Any other calls panic.
+type recordingBackend struct {
+	core.EmailBackend
+
+	added    []core.EmailId
+	removed  []core.EmailId
+	restored []core.EmailId
+	from     string
+	drafts   []core.Draft
+}
+
+func (b *recordingBackend) AddFlags(ids []core.EmailId, flags ...core.Flag) error {
+	b.added = append(b.added, ids...)
+	return nil
+}
+
+func (b *recordingBackend) RemoveFlags(ids []core.EmailId, flags ...core.Flag) error {
+	b.removed = append(b.removed, ids...)
+	return nil
+}
+
+func (b *recordingBackend) RestoreEmails(mailbox string, ids []core.EmailId) (*core.MoveResult, error) {
+	b.from = mailbox
+	b.restored = append(b.restored, ids...)
+	return &core.MoveResult{Mailbox: "INBOX"}, nil
+}
+
+func (b *recordingBackend) SaveDraft(draft core.Draft) (*core.Draft, error) {
+	b.drafts = append(b.drafts, draft)
+	return &draft, nil
+}
+
+func TestFlagChange_Undo(t *testing.T) {
+	backend := &recordingBackend{}
+	change := FlagChange{
+		Flag:     core.FlagFlagged,
+		Previous: map[core.EmailId]bool{"1": true, "2": false, "3": false},
+	}
+
+	if err := change.Undo(backend); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !slices.Equal(backend.added, []core.EmailId{"1"}) {
+		t.Errorf("Expected the flag to be put back on 1, got %v", backend.added)
+	}
+	if !slices.Equal(backend.removed, []core.EmailId{"2", "3"}) {
+		t.Errorf("Expected the flag to be removed from 2 and 3, got %v", backend.removed)
+	}
+}
+
+func TestMove_Undo(t *testing.T) {
+	backend := &recordingBackend{}
+	move := Move{Result: &core.MoveResult{
+		Mailbox: "Trash",
+		Ids:     map[core.EmailId]core.EmailId{"1": "11", "2": "12"},
+	}}
+
+	if err := move.Undo(backend); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if backend.from != "Trash" {
+		t.Errorf("Expected the emails to be restored from Trash, got '%s'", backend.from)
+	}
+	if !slices.Equal(backend.restored, []core.EmailId{"11", "12"}) {
+		t.Errorf("Expected the emails' new ids to be used, got %v", backend.restored)
+	}
+}
+
+func TestCanUndoMove(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *core.MoveResult
+		want   bool
+	}{
+		{"moved", &core.MoveResult{Mailbox: "Archive", Ids: map[core.EmailId]core.EmailId{"1": "5"}}, true},
+		{"deleted permanently", &core.MoveResult{}, false},
+		{"new ids unknown", &core.MoveResult{Mailbox: "Archive"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanUndoMove(tt.result); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStack(t *testing.T) {
+	stack := &Stack{}
+	for i := range limit + 1 {
+		stack.Push(Move{Description: string(rune('a' + i%26))})
+	}
+
+	if stack.Len() != limit {
+		t.Errorf("Expected the stack to be capped at %d, got %d", limit, stack.Len())
+	}
+
+	operation, ok := stack.Pop()
+	if !ok || operation.Describe() != string(rune('a'+limit%26)) {
+		t.Errorf("Expected the most recent operation, got %v", operation)
+	}
+
+	stack = &Stack{}
+	if _, ok := stack.Pop(); ok {
+		t.Error("Expected an empty stack to have nothing to pop")
+	}
+}
+
+func TestSend_UndoCancelsAndKeepsDraft(t *testing.T) {
+	queue, err := outbox.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	entry, _ := queue.Enqueue(core.OutgoingEmail{Subject: "Oops"}, "", time.Now().Add(time.Minute))
+	backend := &recordingBackend{}
+
+	if err := (Send{Outbox: queue, Id: entry.Id}).Undo(backend); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	entries, _ := queue.List()
+	if len(entries) != 0 {
+		t.Errorf("Expected the email to be taken out of the outbox, got %d entries", len(entries))
+	}
+	if len(backend.drafts) != 1 || backend.drafts[0].Subject != "Oops" {
+		t.Errorf("Expected the email to be saved as a draft, got %+v", backend.drafts)
+	}
+
+	if err := (Send{Outbox: queue, Id: entry.Id}).Undo(backend); err == nil {
+		t.Error("Expected an error once the email has gone")
+	}
+}